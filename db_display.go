@@ -1,12 +1,14 @@
 package main
 
 import (
-    "database/sql"
     "fmt"
     "log"
+    "sort"
     "strconv"
     "strings"
     "time"
+
+    "github.com/igorp74/ToDo/todo"
 )
 
 // Display formats
@@ -39,269 +41,376 @@ const (
     bg_white   = "\033[47m"
 )
 
-// ListTasks fetches and displays tasks based on filters and sorting.
-// Added endBefore and endAfter parameters for filtering by end date.
-// Added taskIDs for filtering by specific task IDs, and searchText for title/description/note search.
-func ListTasks(tm *TodoManager, projectFilter, contextFilter, tagFilter, statusFilter, startBefore, startAfter, dueBefore, dueAfter, endBefore, endAfter, sortBy, order string, format int, displayNotes string, taskIDs []int64, searchText string) {
-    // Base query to select task details.
-    // LEFT JOIN is used for projects, and now for task_notes to allow searching within notes
-    // without requiring every task to have notes.
-    query := `
-        SELECT
-            t.id, t.title, t.description, p.name, t.start_date, t.due_date, t.end_date, t.status,
-            t.recurrence, t.recurrence_interval, t.start_waiting_date, t.end_waiting_date, t.original_task_id
-        FROM tasks t
-        LEFT JOIN projects p ON t.project_id = p.id
-    `
-    args := []any{}
-    whereClauses := []string{"1=1"} // Start with a true condition to simplify AND logic
-
-    // Filter by specific task IDs
-    if len(taskIDs) > 0 {
-        placeholders := make([]string, len(taskIDs))
-        for i := range taskIDs {
-            placeholders[i] = "?"
-            args = append(args, taskIDs[i])
-        }
-        whereClauses = append(whereClauses, fmt.Sprintf("t.id IN (%s)", strings.Join(placeholders, ",")))
-    }
-
-    // Project filter
-    if projectFilter != "" {
-        whereClauses = append(whereClauses, "p.name = ?")
-        args = append(args, projectFilter)
-    }
-
-    // Status filter
-    if statusFilter != "" && statusFilter != "all" {
-        whereClauses = append(whereClauses, "t.status = ?")
-        args = append(args, statusFilter)
-    }
-
-    // Search text filter in title, description, and notes
-    if searchText != "" {
-        searchPattern := "%" + searchText + "%"
-        // Use a subquery with EXISTS to check for matching notes
-        whereClauses = append(whereClauses, `(
-            t.title LIKE ? OR t.description LIKE ?
-            OR EXISTS (SELECT 1 FROM task_notes tn WHERE tn.task_id = t.id AND tn.description LIKE ?)
-        )`)
-        args = append(args, searchPattern, searchPattern, searchPattern)
-    }
-
-    // Date filters - parse with local timezone and then convert to UTC for query
-    if startBefore != "" {
-        parsed, err := ParseDateTime(startBefore, time.Local)
-        if err != nil {
-            log.Printf("Warning: Invalid start-before date format: %v", err)
-        } else {
-            whereClauses = append(whereClauses, "t.start_date <= ?")
-            sqlParsed, _ := parsed.Value() // Get sql.NullTime (already UTC)
-            args = append(args, sqlParsed)
+// shortUUIDLength is the number of UUID characters shown in condensed listings,
+// enough to be used as a UUID prefix in --ids.
+const shortUUIDLength = 8
+
+// shortUUID returns the first characters of a UUID, or "-" when there is none.
+func shortUUID(uuid string) string {
+    if uuid == "" {
+        return "-"
+    }
+    if len(uuid) > shortUUIDLength {
+        return uuid[:shortUUIDLength]
+    }
+    return uuid
+}
+
+// taskDurations holds the durations computed for a task at display time.
+type taskDurations struct {
+    Calendar, Working time.Duration // start to end, or start to now for open tasks
+    HasElapsed        bool          // false when there is no start date, or a completed task has no end date
+    Waiting           time.Duration // calendar time of the waiting period
+    WaitingWorking    time.Duration // working time of the waiting period
+    HasWaitingWorking bool
+    ToDue             time.Duration // time remaining until the due date, or elapsed since it when overdue
+    HasDue, Overdue   bool
+    Tracked           time.Duration // effort tracked in time entries, clipped to working hours when Clipped
+    Clipped           bool
+    RemainingEstimate time.Duration // estimate minus the tracked time, for open tasks with an estimate
+    WorkingToDue      time.Duration // working time left until the due date, 0 when overdue
+    HasRemaining      bool
+    HasWorkingToDue   bool          // false without a due date or without working hours
+}
+
+// computeDurations calculates the calendar, working, waiting, time-to-due and tracked durations
+// of a task. With clipTracked, only the tracked time within working hours is counted.
+func computeDurations(tm *todo.TodoManager, task todo.Task, workingHours map[time.Weekday]todo.WorkingHours, holidaysMap map[string]todo.Holiday, clipTracked bool) taskDurations {
+    var d taskDurations
+
+    if task.StartDate.Valid {
+        if task.Status == "completed" && task.EndDate.Valid {
+            d.Calendar = todo.CalculateCalendarDuration(task)
+            d.Working = tm.CalculateWorkingDuration(task.StartDate, task.EndDate, workingHours, holidaysMap)
+            d.HasElapsed = true
+        } else if task.Status != "completed" {
+            now := todo.NullableTime{Time: time.Now().UTC(), Valid: true}
+            tempTask := task
+            tempTask.EndDate = now
+            d.Calendar = todo.CalculateCalendarDuration(tempTask)
+            d.Working = tm.CalculateWorkingDuration(task.StartDate, now, workingHours, holidaysMap)
+            d.HasElapsed = true
         }
     }
-    if startAfter != "" {
-        parsed, err := ParseDateTime(startAfter, time.Local)
+
+    // Calculate time to/after due date
+    if task.DueDate.Valid {
+        d.ToDue, d.Overdue = todo.CalculateTimeDifference(task.DueDate)
+        d.HasDue = true
+    }
+
+    // Calculate waiting duration (calendar time), and working hours within the waiting period
+    d.Waiting = todo.CalculateWaitingDuration(task)
+    if task.StartWaitingDate.Valid && task.EndWaitingDate.Valid {
+        d.WaitingWorking = tm.CalculateWorkingDuration(task.StartWaitingDate, task.EndWaitingDate, workingHours, holidaysMap)
+        d.HasWaitingWorking = true
+    }
+
+    d.Tracked = task.Tracked
+    if clipTracked && !task.Projected && task.Tracked > 0 {
+        tracked, err := tm.TrackedWorkingTime(task.ID, workingHours, holidaysMap)
         if err != nil {
-            log.Printf("Warning: Invalid start-after date format: %v", err)
-        } else {
-            whereClauses = append(whereClauses, "t.start_date >= ?")
-            sqlParsed, _ := parsed.Value() // Get sql.NullTime (already UTC)
-            args = append(args, sqlParsed)
+            log.Fatalf("Error computing tracked working time of task %d: %v", task.ID, err)
         }
+        d.Tracked, d.Clipped = tracked, true
     }
-    if dueBefore != "" {
-        parsed, err := ParseDateTime(dueBefore, time.Local)
-        if err != nil {
-            log.Printf("Warning: Invalid due-before date format: %v", err)
-        } else {
-            whereClauses = append(whereClauses, "t.due_date <= ?")
-            sqlParsed, _ := parsed.Value() // Get sql.NullTime (already UTC)
-            args = append(args, sqlParsed)
+
+    // Compare what is left of the estimate with the working time left until the due date
+    if task.Estimate.Valid && (task.Status == "pending" || task.Status == "waiting") {
+        d.RemainingEstimate = task.EstimateDuration() - task.Tracked
+        if d.RemainingEstimate < 0 {
+            d.RemainingEstimate = 0
+        }
+        if task.DueDate.Valid && !d.Overdue {
+            now := todo.NullableTime{Time: time.Now().UTC(), Valid: true}
+            d.WorkingToDue = tm.CalculateWorkingDuration(now, task.DueDate, workingHours, holidaysMap)
         }
+        d.HasRemaining = true
+        // Without working hours there is no working time to compare the work left with
+        d.HasWorkingToDue = task.DueDate.Valid && len(workingHours) > 0
     }
-    if dueAfter != "" {
-        parsed, err := ParseDateTime(dueAfter, time.Local)
-        if err != nil {
-            log.Printf("Warning: Invalid due-after date format: %v", err)
-        } else {
-            whereClauses = append(whereClauses, "t.due_date >= ?")
-            sqlParsed, _ := parsed.Value() // Get sql.NullTime (already UTC)
-            args = append(args, sqlParsed)
+    return d
+}
+
+// subtaskRollup sums up the subtasks of a task, at every level below it.
+type subtaskRollup struct {
+    Total, Completed  int           // cancelled subtasks are not counted
+    Calendar, Working time.Duration // summed durations of the subtasks
+    Tracked           time.Duration // summed tracked time of the subtasks, including cancelled ones
+}
+
+// computeRollup rolls up the progress and the calendar, working and tracked durations of the
+// subtasks of a task. It returns nil for tasks without subtasks.
+func computeRollup(tm *todo.TodoManager, task todo.Task, workingHours map[time.Weekday]todo.WorkingHours, holidaysMap map[string]todo.Holiday, clipTracked bool) *subtaskRollup {
+    if task.Projected {
+        return nil
+    }
+    subtasks, err := tm.GetSubtasks(task.ID)
+    if err != nil {
+        log.Fatalf("Error querying subtasks of task %d: %v", task.ID, err)
+    }
+    if len(subtasks) == 0 {
+        return nil
+    }
+    r := &subtaskRollup{}
+    for _, s := range subtasks {
+        d := computeDurations(tm, s, workingHours, holidaysMap, clipTracked)
+        r.Tracked += d.Tracked // Time spent on a cancelled subtask was still spent
+        if s.Status == "cancelled" {
+            continue
+        }
+        r.Total++
+        if s.Status == "completed" {
+            r.Completed++
+        }
+        if d.HasElapsed {
+            r.Calendar += d.Calendar
+            r.Working += d.Working
         }
     }
-    // New: End Date filters
-    if endBefore != "" {
-        parsed, err := ParseDateTime(endBefore, time.Local)
-        if err != nil {
-            log.Printf("Warning: Invalid end-before date format: %v", err)
-        } else {
-            whereClauses = append(whereClauses, "t.end_date <= ?")
-            sqlParsed, _ := parsed.Value() // Get sql.NullTime (already UTC)
-            args = append(args, sqlParsed)
+    return r
+}
+
+// treeRow is a task in tree order, with the guides drawn in front of it.
+type treeRow struct {
+    task   todo.Task
+    nested bool   // shown below its parent
+    blank  string // guides on the empty line above the task
+    branch string // guides in front of the first line of the task
+    stem   string // guides in front of the other lines, and of its subtasks
+}
+
+// treeOrder places subtasks right below their parent, keeping the order of the list among
+// siblings. Subtasks whose parent is not listed are shown at the top level.
+func treeOrder(tasks []todo.Task) []treeRow {
+    listed := make(map[int64]bool)
+    for _, task := range tasks {
+        if !task.Projected {
+            listed[task.ID] = true
         }
     }
-    if endAfter != "" {
-        parsed, err := ParseDateTime(endAfter, time.Local)
-        if err != nil {
-            log.Printf("Warning: Invalid end-after date format: %v", err)
+    children := make(map[int64][]todo.Task)
+    roots := []todo.Task{}
+    for _, task := range tasks {
+        if task.ParentID.Valid && listed[task.ParentID.Int64] {
+            children[task.ParentID.Int64] = append(children[task.ParentID.Int64], task)
         } else {
-            whereClauses = append(whereClauses, "t.end_date >= ?")
-            sqlParsed, _ := parsed.Value() // Get sql.NullTime (already UTC)
-            args = append(args, sqlParsed)
+            roots = append(roots, task)
         }
     }
 
-    // Context and Tag filters (require JOINs and GROUP BY or EXISTS subqueries)
-    if contextFilter != "" {
-        whereClauses = append(whereClauses, `EXISTS (SELECT 1 FROM task_contexts tc JOIN contexts c ON tc.context_id = c.id WHERE tc.task_id = t.id AND c.name = ?)`)
-        args = append(args, contextFilter)
+    rows := []treeRow{}
+    var walk func(task todo.Task, lead string, nested, last bool)
+    walk = func(task todo.Task, lead string, nested, last bool) {
+        row := treeRow{task: task, nested: nested}
+        if nested {
+            row.blank = lead + "│"
+            row.branch, row.stem = lead+"├─ ", lead+"│  "
+            if last {
+                row.branch, row.stem = lead+"└─ ", lead+"   "
+            }
+        }
+        rows = append(rows, row)
+        if task.Projected {
+            return // Projected occurrences share the ID of the open instance
+        }
+        kids := children[task.ID]
+        for i, kid := range kids {
+            walk(kid, row.stem, true, i == len(kids)-1)
+        }
     }
-    if tagFilter != "" {
-        whereClauses = append(whereClauses, `EXISTS (SELECT 1 FROM task_tags tt JOIN tags tg ON tt.tag_id = tg.id WHERE tt.task_id = t.id AND tg.name = ?)`)
-        args = append(args, tagFilter)
+    for _, task := range roots {
+        walk(task, "", false, false)
     }
+    return rows
+}
 
-    // Combine all WHERE clauses
-    query += " WHERE " + strings.Join(whereClauses, " AND ")
+// indentTree draws the tree guides of a row in front of the lines of a rendered task, which
+// starts with an empty line.
+func indentTree(block string, row treeRow) string {
+    if !row.nested {
+        return block
+    }
+    lines := strings.Split(block, "\n")
+    for i, line := range lines {
+        switch {
+        case i == 0:
+            lines[i] = row.blank + line
+        case i == 1:
+            lines[i] = row.branch + line
+        case line != "":
+            lines[i] = row.stem + line
+        }
+    }
+    return strings.Join(lines, "\n")
+}
 
-    // Order by
-    orderByMap := map[string]string{
-        "id":         "t.id",
-        "title":      "t.title",
-        "start_date": "t.start_date",
-        "due_date":   "t.due_date",
-        "status":     "t.status",
-        "project":    "p.name",
-        "end_date":   "t.end_date",
+// ListTasks fetches and displays tasks based on filters and sorting.
+// Subtasks are shown as a tree below their parent in the full and condensed formats.
+// displayNotes is 'none', 'all', or the number of most recent notes to show per task.
+// output selects text, json or ndjson; the format is ignored for json and ndjson.
+// clipTracked counts only the tracked time within working hours.
+func ListTasks(tm *todo.TodoManager, filter todo.TaskFilter, format int, displayNotes string, output string, clipTracked bool) {
+    filter.IncludeNotes = displayNotes != "none"
+    tasks, err := tm.GetTasks(filter)
+    if err != nil {
+        log.Fatalf("Error querying tasks: %v", err)
     }
-    actualSortBy := orderByMap[sortBy]
-    if actualSortBy == "" {
-        actualSortBy = orderByMap["due_date"] // Default
+    printTasks(tm, tasks, format, displayNotes, output, true, clipTracked)
+}
+
+// ShowNextTasks displays up to n tasks that can be worked on now, most pressing first.
+// The status of the filter is ignored.
+func ShowNextTasks(tm *todo.TodoManager, filter todo.TaskFilter, n int, format int, displayNotes string, output string, clipTracked bool) {
+    filter.IncludeNotes = displayNotes != "none"
+    tasks, err := tm.NextTasks(filter, time.Now(), n)
+    if err != nil {
+        log.Fatalf("Error querying next tasks: %v", err)
     }
-    if order != "asc" && order != "desc" {
-        order = "asc" // Default
+    if len(tasks) == 0 && output == OutputText {
+        fmt.Println("Nothing to do right now.")
+        return
     }
-    query += fmt.Sprintf(" ORDER BY %s %s", actualSortBy, order)
+    printTasks(tm, tasks, format, displayNotes, output, false, clipTracked)
+}
 
-    rows, err := tm.db.Query(query, args...)
+// ShowPlan lays the open tasks with an estimate end-to-end over the working time ahead and
+// flags those that would finish after their due date. The status of the filter is ignored.
+func ShowPlan(tm *todo.TodoManager, filter todo.TaskFilter, output string) {
+    plan, err := tm.PlanTasks(filter, time.Now())
     if err != nil {
-        log.Fatalf("Error querying tasks: %v", err)
+        log.Fatalf("Error planning tasks: %v", err)
+    }
+    if output != OutputText {
+        records := make([]planRecord, 0, len(plan.Tasks)+len(plan.Unestimated))
+        for _, p := range plan.Tasks {
+            records = append(records, newPlanRecord(p))
+        }
+        for _, task := range plan.Unestimated {
+            records = append(records, planRecord{ID: task.ID, Title: task.Title, DueDate: task.DueDate})
+        }
+        writeRecords(output, records)
+        return
+    }
+
+    if len(plan.Tasks) == 0 && len(plan.Unestimated) == 0 {
+        fmt.Println("No open tasks to plan.")
+        return
+    }
+    const timeFormat = "Mon 2006-01-02 15:04"
+    fmt.Println("------------------------------------------------------------------------------------------------------------------")
+    fmt.Printf("%-5s %-40s %8s  %-20s  %-20s  %-20s\n", "ID", "Title", "Work", "Start", "Finish", "Due")
+    fmt.Println("------------------------------------------------------------------------------------------------------------------")
+    for _, p := range plan.Tasks {
+        due := "-"
+        if p.Task.DueDate.Valid {
+            due = p.Task.DueDate.Time.Local().Format(timeFormat)
+        }
+        finish := fmt.Sprintf("%-20s", p.Finish.Format(timeFormat))
+        if p.Late {
+            finish = fg_red + finish + style_reset
+        }
+        fmt.Printf("%-5d %-40s %8s  %-20s  %s  %-20s\n", p.Task.ID, p.Task.Title, todo.FormatEstimate(p.Work), p.Start.Format(timeFormat), finish, due)
     }
-    defer rows.Close()
+    fmt.Println("------------------------------------------------------------------------------------------------------------------")
 
+    late := plan.Late()
+    summary := fmt.Sprintf("%d tasks, %s of work", len(plan.Tasks), todo.FormatEstimate(plan.Work()))
+    if len(plan.Tasks) > 0 {
+        summary += ", done by " + plan.Tasks[len(plan.Tasks)-1].Finish.Format(timeFormat)
+    }
+    fmt.Println(summary + ".")
+    if len(late) > 0 {
+        ids := make([]int64, len(late))
+        for i, p := range late {
+            ids[i] = p.Task.ID
+        }
+        fmt.Printf("%s⚠ %d tasks would finish after their due date: %s%s\n", fg_red, len(late), joinIDs(ids), style_reset)
+    }
+    if len(plan.Unestimated) > 0 {
+        ids := make([]int64, len(plan.Unestimated))
+        for i, task := range plan.Unestimated {
+            ids[i] = task.ID
+        }
+        fmt.Printf("Not planned, without an estimate: %s\n", joinIDs(ids))
+    }
+}
+
+// printTasks displays tasks in the given format and output mode. With tree, subtasks are
+// moved below their parent in the full and condensed formats; otherwise the order is kept.
+func printTasks(tm *todo.TodoManager, tasks []todo.Task, format int, displayNotes string, output string, tree bool, clipTracked bool) {
     // Load working hours and holidays once for all calculations
     workingHours, err := tm.GetWorkingHours()
     if err != nil {
         log.Fatalf("Error loading working hours: %v", err)
     }
-    holidaysList, err := tm.GetHolidays()
+    holidaysMap, err := tm.GetHolidaysMap()
     if err != nil {
         log.Fatalf("Error loading holidays: %v", err)
     }
-    holidaysMap := make(map[string]Holiday)
-    for _, h := range holidaysList {
-        holidaysMap[h.Date.Time.Format("2006-01-02")] = h
+
+    var records []taskRecord
+    if output != OutputText {
+        format = -1 // No header
     }
 
     // Print header based on format
     switch format {
     case DisplayMinimal:
         fmt.Println("----------------------------------------------------------------------------------------------------------------")
-        fmt.Printf("%-5s    %-20s %-80s\n", "ID", "Project", "Title")
+        fmt.Printf("%-5s    %-8s %-20s %-80s\n", "ID", "UUID", "Project", "Title")
         fmt.Println("----------------------------------------------------------------------------------------------------------------")
     }
 
-    for rows.Next() {
-        var task Task
-        var project_name sql.NullString
-        var desc, recurrence sql.NullString
-        var recurrenceInterval sql.NullInt64
-        var startDate, dueDate, endDate, startWaitingDate, endWaitingDate sql.NullTime
-        var originalTaskID sql.NullInt64
-
-        err := rows.Scan(&task.ID, &task.Title, &desc, &project_name, &startDate, &dueDate, &endDate, &task.Status,
-            &recurrence, &recurrenceInterval, &startWaitingDate, &endWaitingDate, &originalTaskID)
-        if err != nil {
-            log.Printf("Error scanning task: %v", err)
-            continue
+    // JSON and the minimal format keep the order of the list
+    var rows []treeRow
+    if tree && (format == DisplayFull || format == DisplayCondensed) {
+        rows = treeOrder(tasks)
+    } else {
+        for _, task := range tasks {
+            rows = append(rows, treeRow{task: task})
         }
+    }
 
-        task.Description = desc
-        task.ProjectName = project_name
-        task.StartDate = NullableTime{Time: startDate.Time, Valid: startDate.Valid}
-        task.DueDate = NullableTime{Time: dueDate.Time, Valid: dueDate.Valid}
-        task.EndDate = NullableTime{Time: endDate.Time, Valid: endDate.Valid}
-        task.Recurrence = recurrence
-        task.RecurrenceInterval = recurrenceInterval
-        task.StartWaitingDate = NullableTime{Time: startWaitingDate.Time, Valid: startWaitingDate.Valid}
-        task.EndWaitingDate = NullableTime{Time: endWaitingDate.Time, Valid: endWaitingDate.Valid}
-        task.OriginalTaskID = originalTaskID
-
-        // Fetch contexts and tags
-        task.Contexts = tm.GetTaskNames(int64(task.ID), "task_contexts", "contexts")
-        task.Tags = tm.GetTaskNames(int64(task.ID), "task_tags", "tags")
-
-        // Fetch notes based on displayNotes parameter
-        if displayNotes != "none" {
-            allNotes := tm.GetNotesForTask(task.ID)
-            if displayNotes == "all" {
-                task.Notes = allNotes
-            } else {
-                numNotes, err := strconv.Atoi(displayNotes)
-                if err == nil && numNotes > 0 {
-                    if numNotes > len(allNotes) {
-                        task.Notes = allNotes
-                    } else {
-                        task.Notes = allNotes[len(allNotes)-numNotes:]
-                    }
-                }
+    for _, row := range rows {
+        task := row.task
+        // Keep only the last N notes when a number was requested
+        if displayNotes != "none" && displayNotes != "all" {
+            numNotes, err := strconv.Atoi(displayNotes)
+            if err != nil || numNotes <= 0 {
+                task.Notes = nil
+            } else if numNotes < len(task.Notes) {
+                task.Notes = task.Notes[len(task.Notes)-numNotes:]
             }
         }
 
-        // Calculate Duration and Working Hours Duration
+        d := computeDurations(tm, task, workingHours, holidaysMap, clipTracked)
+        rollup := computeRollup(tm, task, workingHours, holidaysMap, clipTracked)
+        if output != OutputText {
+            records = append(records, newTaskRecord(task, d, rollup))
+            continue
+        }
+
+        // Format Duration and Working Hours Duration
         totalDurationStr := "N/A"
         workingDurationStr := "N/A"
-        waitingDurationStr := "N/A"
+        waitingDurationStr := todo.FormatDuration(d.Waiting)
         waitingWorkingDurationStr := "N/A"
         timeToDueStr := ""
 
-        if task.StartDate.Valid {
-            if task.Status == "completed" && task.EndDate.Valid {
-                totalDuration := CalculateCalendarDuration(task)
-                totalDurationStr = FormatDuration(totalDuration)
-
-                workingDuration := tm.CalculateWorkingDuration(task.StartDate, task.EndDate, workingHours, holidaysMap)
-                workingDurationStr = FormatWorkingHoursDisplay(workingDuration)
-            } else if task.Status != "completed" {
-                tempTask := task
-                tempTask.EndDate = NullableTime{Time: time.Now().UTC(), Valid: true}
-                totalDuration := CalculateCalendarDuration(tempTask)
-                totalDurationStr = FormatDuration(totalDuration)
-
-                workingDuration := tm.CalculateWorkingDuration(task.StartDate, NullableTime{Time: time.Now().UTC(), Valid: true}, workingHours, holidaysMap)
-                workingDurationStr = FormatWorkingHoursDisplay(workingDuration)
-            }
+        if d.HasElapsed {
+            totalDurationStr = todo.FormatDuration(d.Calendar)
+            workingDurationStr = todo.FormatWorkingHoursDisplay(d.Working)
         }
-
-        // Calculate time to/after due date
-        if task.DueDate.Valid {
-            diffDuration, isOverdue := CalculateTimeDifference(task.DueDate)
-            if isOverdue {
-                timeToDueStr = fmt.Sprintf(" (%s%s%s overdue)", fg_red, FormatDuration(diffDuration), style_reset)
+        if d.HasDue {
+            if d.Overdue {
+                timeToDueStr = fmt.Sprintf(" (%s%s%s overdue)", fg_red, todo.FormatDuration(d.ToDue), style_reset)
             } else {
-                timeToDueStr = fmt.Sprintf(" (%s%s%s remaining)", fg_cyan, FormatDuration(diffDuration), style_reset)
+                timeToDueStr = fmt.Sprintf(" (%s%s%s remaining)", fg_cyan, todo.FormatDuration(d.ToDue), style_reset)
             }
         }
-
-        // Calculate waiting duration (calendar time)
-        waitingDuration := CalculateWaitingDuration(task)
-        waitingDurationStr = FormatDuration(waitingDuration)
-
-        // Calculate working hours within the waiting period
-        if task.StartWaitingDate.Valid && task.EndWaitingDate.Valid {
-            waitingWorkingDuration := tm.CalculateWorkingDuration(task.StartWaitingDate, task.EndWaitingDate, workingHours, holidaysMap)
-            waitingWorkingDurationStr = FormatWorkingHoursDisplay(waitingWorkingDuration)
+        if d.HasWaitingWorking {
+            waitingWorkingDurationStr = todo.FormatWorkingHoursDisplay(d.WaitingWorking)
         }
 
         switch format {
@@ -322,9 +431,18 @@ func ListTasks(tm *TodoManager, projectFilter, contextFilter, tagFilter, statusF
             case "waiting":
                 status_str = style_bold + fg_blue + "waiting" + style_reset
             }
+            if task.Projected {
+                status_str = style_bold + fg_cyan + "projected" + style_reset
+            }
             titleParts = append(titleParts, status_str)
+            if isBlocked(task) {
+                titleParts = append(titleParts, style_bold+fg_red+"blocked"+style_reset)
+            }
 
             sb.WriteString(fmt.Sprintf(" %s\n", strings.Join(titleParts, " | ")))
+            if task.UUID.Valid {
+                sb.WriteString(fmt.Sprintf("      🆔 UUID: %s\n", task.UUID.String))
+            }
 
             if task.Description.Valid && task.Description.String != "" {
                 sb.WriteString(fmt.Sprintf("      📜 %s%s%s%s\n", style_italic, fg_yellow, task.Description.String, style_reset))
@@ -345,22 +463,39 @@ func ListTasks(tm *TodoManager, projectFilter, contextFilter, tagFilter, statusF
                 sb.WriteString(fmt.Sprintf("      %s\n", strings.Join(projectParts, " | ")))
             }
 
+            urgencyParts := []string{}
+            if task.Priority.Valid && task.Priority.String != "" {
+                urgencyParts = append(urgencyParts, "❗ Priority: "+priorityColor(task.Priority.String)+priorityNames[task.Priority.String]+style_reset)
+            }
+            if task.Status == "pending" || task.Status == "waiting" {
+                urgencyParts = append(urgencyParts, fmt.Sprintf("🔥 Urgency: %.1f", task.Urgency))
+            }
+            if len(urgencyParts) > 0 {
+                sb.WriteString(fmt.Sprintf("      %s\n", strings.Join(urgencyParts, " | ")))
+            }
+
             dateParts := []string{}
 
             if task.StartDate.Valid {
-                dateParts = append(dateParts, "🚀 Start: "+FormatDisplayDateTime(task.StartDate))
+                dateParts = append(dateParts, "🚀 Start: "+todo.FormatDisplayDateTime(task.StartDate))
             }
             if task.EndDate.Valid {
-                dateParts = append(dateParts, "🏁 End: "+FormatDisplayDateTime(task.EndDate))
+                dateParts = append(dateParts, "🏁 End: "+todo.FormatDisplayDateTime(task.EndDate))
             }
             if task.DueDate.Valid {
-                dateParts = append(dateParts, "⏱️ Due: "+FormatDisplayDateTime(task.DueDate)+timeToDueStr) // Added time to due date
+                dateParts = append(dateParts, "⏱️ Due: "+todo.FormatDisplayDateTime(task.DueDate)+timeToDueStr) // Added time to due date
             }
             if task.Recurrence.Valid {
                 interval := ""
-                if task.RecurrenceInterval.Valid {
+                if task.RecurrenceInterval.Valid && !todo.IsRRule(task.Recurrence.String) {
                     interval = fmt.Sprintf(" every %d", task.RecurrenceInterval.Int64)
                 }
+                if task.RecurrenceShift.Valid && task.RecurrenceShift.String != "" {
+                    interval += fmt.Sprintf(" (%s working day)", task.RecurrenceShift.String)
+                }
+                if task.RecurrenceAnchor.String == todo.AnchorCompletion {
+                    interval += " from completion"
+                }
                 dateParts = append(dateParts, "🔄 Recurrence: "+task.Recurrence.String+interval)
             }
 
@@ -371,16 +506,28 @@ func ListTasks(tm *TodoManager, projectFilter, contextFilter, tagFilter, statusF
             waitingParts := []string{}
 
             if task.StartWaitingDate.Valid {
-                waitingParts = append(waitingParts, "⏸️ Pause: "+FormatDisplayDateTime(task.StartWaitingDate))
+                waitingParts = append(waitingParts, "⏸️ Pause: "+todo.FormatDisplayDateTime(task.StartWaitingDate))
             }
             if task.EndWaitingDate.Valid {
-                waitingParts = append(waitingParts, "▶️ End: "+FormatDisplayDateTime(task.EndWaitingDate))
+                waitingParts = append(waitingParts, "▶️ End: "+todo.FormatDisplayDateTime(task.EndWaitingDate))
             }
 
             if len(waitingParts) > 0 {
                 sb.WriteString(fmt.Sprintf("      %s\n", strings.Join(waitingParts, " | ")))
             }
 
+            if task.ParentID.Valid && !row.nested {
+                sb.WriteString(fmt.Sprintf("      🌳 Subtask of: %d\n", task.ParentID.Int64))
+            }
+
+            if len(task.DependsOn) > 0 {
+                dependencyParts := []string{"🔗 Depends on: " + joinIDs(task.DependsOn)}
+                if isBlocked(task) {
+                    dependencyParts = append(dependencyParts, "⛔ Blocked by: "+fg_red+joinIDs(task.BlockedBy)+style_reset)
+                }
+                sb.WriteString(fmt.Sprintf("      %s\n", strings.Join(dependencyParts, " | ")))
+            }
+
             durationParts := []string{}
             if len(totalDurationStr) > 0 && totalDurationStr != "N/A" {
                 durationParts = append(durationParts, "⌛ Duration: "+totalDurationStr)
@@ -394,11 +541,45 @@ func ListTasks(tm *TodoManager, projectFilter, contextFilter, tagFilter, statusF
             if waitingWorkingDurationStr != "0s" && waitingWorkingDurationStr != "N/A" { // Only add if there's a non-zero waiting working duration
                 durationParts = append(durationParts, "🚧 Waiting (Working): "+waitingWorkingDurationStr)
             }
+            if task.Tracked > 0 || task.TimerRunning {
+                durationParts = append(durationParts, formatTracked(d, task.TimerRunning))
+            }
 
             if len(durationParts) > 0 {
                 sb.WriteString(fmt.Sprintf("      %s\n", strings.Join(durationParts, " | ")))
             }
 
+            if task.Estimate.Valid {
+                estimateParts := []string{"📐 Estimate: " + todo.FormatEstimate(task.EstimateDuration())}
+                if d.HasRemaining {
+                    remaining := todo.FormatEstimate(d.RemainingEstimate)
+                    if d.HasWorkingToDue {
+                        // Red when the work left does not fit in the working time left
+                        color := fg_green
+                        if d.RemainingEstimate > d.WorkingToDue {
+                            color = fg_red
+                        }
+                        remaining = fmt.Sprintf("%s%s%s of %s working time until due", color, remaining, style_reset, todo.FormatEstimate(d.WorkingToDue))
+                    }
+                    estimateParts = append(estimateParts, "Remaining: "+remaining)
+                }
+                sb.WriteString(fmt.Sprintf("      %s\n", strings.Join(estimateParts, " | ")))
+            }
+
+            if rollup != nil {
+                rollupParts := []string{fmt.Sprintf("📊 Subtasks: %s%d of %d completed%s", fg_cyan, rollup.Completed, rollup.Total, style_reset)}
+                if rollup.Calendar > 0 {
+                    rollupParts = append(rollupParts, "⌛ Σ Duration: "+todo.FormatDuration(rollup.Calendar))
+                }
+                if rollup.Working > 0 {
+                    rollupParts = append(rollupParts, "⌚ Σ Working: "+todo.FormatWorkingHoursDisplay(rollup.Working))
+                }
+                if rollup.Tracked > 0 {
+                    rollupParts = append(rollupParts, "⏱ Σ Tracked: "+todo.FormatDuration(rollup.Tracked))
+                }
+                sb.WriteString(fmt.Sprintf("      %s\n", strings.Join(rollupParts, " | ")))
+            }
+
             // Display Notes
             if len(task.Notes) > 0 {
                 sb.WriteString(fmt.Sprintf("      📝 %sNotes:%s\n", style_bold, style_reset))
@@ -407,12 +588,12 @@ func ListTasks(tm *TodoManager, projectFilter, contextFilter, tagFilter, statusF
                     note := task.Notes[j]
                     if note.Timestamp.Valid && note.Description.Valid {
                         // Changed to display actual note.ID instead of a calculated display ID
-                        sb.WriteString(fmt.Sprintf("         %-5d %s%s%s%s: %s%s%s\n", note.ID, style_italic, fg_green, FormatDisplayDateTime(note.Timestamp), style_reset, fg_yellow, note.Description.String, style_reset))
+                        sb.WriteString(fmt.Sprintf("         %-5d %-8s %s%s%s%s: %s%s%s\n", note.ID, shortUUID(note.UUID), style_italic, fg_green, todo.FormatDisplayDateTime(note.Timestamp), style_reset, fg_yellow, note.Description.String, style_reset))
                     }
                 }
             }
 
-            fmt.Printf("%s", sb.String())
+            fmt.Printf("%s", indentTree(sb.String(), row))
 
         case DisplayCondensed:
 
@@ -432,8 +613,17 @@ func ListTasks(tm *TodoManager, projectFilter, contextFilter, tagFilter, statusF
             case "waiting":
                 status_str = "⏸️"
             }
+            if task.Projected {
+                status_str = "🔮"
+            } else if isBlocked(task) {
+                status_str = "⛔"
+            }
             titleParts = append(titleParts, status_str)
             titleParts = append(titleParts, style_bold+task.Title+style_reset)
+            if rollup != nil {
+                titleParts = append(titleParts, fmt.Sprintf("%s[%d/%d]%s", fg_cyan, rollup.Completed, rollup.Total, style_reset))
+            }
+            titleParts = append(titleParts, style_italic+"("+shortUUID(task.UUID.String)+")"+style_reset)
 
             sb.WriteString(fmt.Sprintf(" %s\n", strings.Join(titleParts, " ")))
 
@@ -458,7 +648,7 @@ func ListTasks(tm *TodoManager, projectFilter, contextFilter, tagFilter, statusF
 
             // Add due date and time to due
             if task.DueDate.Valid {
-                sb.WriteString(fmt.Sprintf("         Due: %s%s%s\n", FormatDisplayDateTime(task.DueDate), timeToDueStr, style_reset))
+                sb.WriteString(fmt.Sprintf("         Due: %s%s%s\n", todo.FormatDisplayDateTime(task.DueDate), timeToDueStr, style_reset))
             }
 
             // Display Notes
@@ -469,12 +659,12 @@ func ListTasks(tm *TodoManager, projectFilter, contextFilter, tagFilter, statusF
                     note := task.Notes[j]
                     if note.Timestamp.Valid && note.Description.Valid {
                         // Changed to display actual note.ID instead of a calculated display ID
-                        sb.WriteString(fmt.Sprintf("         %-5d %s%s%s%s: %s%s%s\n", note.ID, style_italic, fg_green, FormatDisplayDateTime(note.Timestamp), style_reset, fg_yellow, note.Description.String, style_reset))
+                        sb.WriteString(fmt.Sprintf("         %-5d %-8s %s%s%s%s: %s%s%s\n", note.ID, shortUUID(note.UUID), style_italic, fg_green, todo.FormatDisplayDateTime(note.Timestamp), style_reset, fg_yellow, note.Description.String, style_reset))
                     }
                 }
             }
 
-            fmt.Printf("%s", sb.String())
+            fmt.Printf("%s", indentTree(sb.String(), row))
 
         case DisplayMinimal:
             status_str := ""
@@ -488,28 +678,151 @@ func ListTasks(tm *TodoManager, projectFilter, contextFilter, tagFilter, statusF
             case "waiting":
                 status_str = "⏸️"
             }
+            if task.Projected {
+                status_str = "🔮"
+            } else if isBlocked(task) {
+                status_str = "⛔"
+            }
 
-            fmt.Printf("%-5d%s  %s%-20s%s %s%-80s%s\n",
+            fmt.Printf("%-5d%s  %-8s %s%-20s%s %s%-80s%s\n",
                 task.ID,
                 status_str,
+                shortUUID(task.UUID.String),
                 fg_green, task.ProjectName.String, style_reset,
                 style_bold, task.Title, style_reset)
         }
     }
+    if output != OutputText {
+        writeRecords(output, records)
+        return
+    }
     fmt.Println("----------------------------------------------------------------------------------------------------------------")
 }
 
+// isBlocked reports whether an open task waits for tasks it depends on.
+func isBlocked(task todo.Task) bool {
+    return len(task.BlockedBy) > 0 && (task.Status == "pending" || task.Status == "waiting")
+}
+
+// formatTracked describes the tracked time of a task, e.g. "⏱ Tracked: 1h 30m (running)".
+func formatTracked(d taskDurations, running bool) string {
+    label := "⏱ Tracked: "
+    if d.Clipped {
+        label = "⏱ Tracked (Working): "
+    }
+    s := label + todo.FormatDuration(d.Tracked)
+    if running {
+        s += " " + fg_green + "(running)" + style_reset
+    }
+    return s
+}
+
+// priorityNames spells out task priorities for display.
+var priorityNames = map[string]string{todo.PriorityHigh: "High", todo.PriorityMedium: "Medium", todo.PriorityLow: "Low"}
+
+// priorityColor returns the color of a priority: red for high, yellow for medium and blue for low.
+func priorityColor(priority string) string {
+    switch priority {
+    case todo.PriorityHigh:
+        return fg_red
+    case todo.PriorityMedium:
+        return fg_yellow
+    }
+    return fg_blue
+}
+
+// joinIDs formats task IDs as a comma-separated list.
+func joinIDs(ids []int64) string {
+    parts := make([]string, len(ids))
+    for i, id := range ids {
+        parts[i] = strconv.FormatInt(id, 10)
+    }
+    return strings.Join(parts, ", ")
+}
+
+// ShowSeries lists every instance of the recurring series a task belongs to, followed by
+// the projected dates of up to upcoming next occurrences.
+func ShowSeries(tm *todo.TodoManager, id int64, upcoming int, output string) {
+    series, err := tm.GetSeries(id, upcoming)
+    if err != nil {
+        log.Fatalf("Error loading series: %v", err)
+    }
+    if output != OutputText {
+        workingHours, err := tm.GetWorkingHours()
+        if err != nil {
+            log.Fatalf("Error loading working hours: %v", err)
+        }
+        holidaysMap, err := tm.GetHolidaysMap()
+        if err != nil {
+            log.Fatalf("Error loading holidays: %v", err)
+        }
+        record := seriesRecord{ID: series.ID, Instances: []taskRecord{}, Upcoming: []todo.NullableTime{}}
+        for _, task := range series.Instances {
+            record.Instances = append(record.Instances, newTaskRecord(task, computeDurations(tm, task, workingHours, holidaysMap, false), nil))
+        }
+        for _, date := range series.Upcoming {
+            record.Upcoming = append(record.Upcoming, todo.NullableTime{Time: date.UTC(), Valid: true})
+        }
+        writeRecords(output, []seriesRecord{record})
+        return
+    }
+
+    latest := series.Instances[len(series.Instances)-1]
+    rule := "stopped"
+    if open := series.Open(); len(open) > 0 && open[len(open)-1].Recurrence.Valid && open[len(open)-1].Recurrence.String != "" {
+        last := open[len(open)-1]
+        rule = last.Recurrence.String
+        if last.RecurrenceInterval.Valid && last.RecurrenceInterval.Int64 > 1 {
+            rule = fmt.Sprintf("%s, every %d", rule, last.RecurrenceInterval.Int64)
+        }
+    }
+    fmt.Printf("--- Series %d: %s%s%s (%s) ---\n", series.ID, style_bold, latest.Title, style_reset, rule)
+    fmt.Printf("  %-5s %-10s %-25s %s\n", "ID", "Status", "Scheduled", "Ended")
+    fmt.Println("----------------------------------------------------------------------")
+    for _, task := range series.Instances {
+        scheduled := task.DueDate
+        if !scheduled.Valid {
+            scheduled = task.StartDate
+        }
+        status := task.Status
+        switch status {
+        case "pending", "waiting":
+            status = fg_yellow + fmt.Sprintf("%-10s", status) + style_reset
+        case "completed":
+            status = fg_green + fmt.Sprintf("%-10s", status) + style_reset
+        default:
+            status = fg_red + fmt.Sprintf("%-10s", status) + style_reset
+        }
+        ended := "-"
+        if task.EndDate.Valid {
+            ended = todo.FormatDisplayDateTime(task.EndDate)
+        }
+        fmt.Printf("  %-5d %s %-25s %s\n", task.ID, status, todo.FormatDisplayDateTime(scheduled), ended)
+    }
+    for _, date := range series.Upcoming {
+        fmt.Printf("  %s%-5s %-10s %-25s%s\n", style_italic, "-", "upcoming", todo.FormatDisplayDateTime(todo.NullableTime{Time: date, Valid: true}), style_reset)
+    }
+}
+
 // ListHolidays lists all configured holidays.
 // It now accepts *TodoManager.
-func ListHolidays(tm *TodoManager) {
+func ListHolidays(tm *todo.TodoManager, output string) {
     holidays, err := tm.GetHolidays() // Get as slice
     if err != nil {
         log.Fatalf("Error listing holidays: %v", err)
     }
+    if output != OutputText {
+        records := make([]holidayRecord, len(holidays))
+        for i, h := range holidays {
+            records[i] = newHolidayRecord(h)
+        }
+        writeRecords(output, records)
+        return
+    }
 
     fmt.Println("--- Holidays ---")
-    fmt.Println("  ID    Date        Name") // New header with ID
-    fmt.Println("------------------------------")
+    fmt.Println("  ID    UUID     Date        Name") // New header with ID
+    fmt.Println("---------------------------------------")
     if len(holidays) == 0 {
         fmt.Println("No holidays configured.")
         return
@@ -517,136 +830,164 @@ func ListHolidays(tm *TodoManager) {
     for _, h := range holidays { // Iterate over slice
         // Holidays are stored as YYYY-MM-DD strings, no time component.
         // Display them directly.
-        fmt.Printf("  %-5d %-10s %s\n", h.ID, h.Date.Time.Format("2006-01-02"), h.Name) // Print ID and formatted date
+        fmt.Printf("  %-5d %-8s %-10s %s\n", h.ID, shortUUID(h.UUID), h.Date.Time.Format("2006-01-02"), h.Name) // Print ID and formatted date
     }
 }
 
-// ListWorkingHours lists all configured working hours.
+// ListWorkingHours lists all configured working hours, followed by the hours of contexts.
 // It now accepts *TodoManager.
-func ListWorkingHours(tm *TodoManager) {
-    // Query all columns relevant to working hours, including minutes and break duration.
-    rows, err := tm.db.Query("SELECT day_of_week, start_hour, start_minute, end_hour, end_minute, break_minutes FROM working_hours ORDER BY day_of_week ASC")
+func ListWorkingHours(tm *todo.TodoManager, output string) {
+    workingHours, err := tm.GetWorkingHours()
     if err != nil {
         log.Fatalf("Error listing working hours: %v", err)
     }
-    defer rows.Close()
+    contextHours, err := tm.GetContextHours()
+    if err != nil {
+        log.Fatalf("Error listing context hours: %v", err)
+    }
+    contexts := make([]string, 0, len(contextHours))
+    for context := range contextHours {
+        contexts = append(contexts, context)
+    }
+    sort.Strings(contexts)
 
-    fmt.Println("--- Working Hours ---")
-    found := false
-    dayNames := []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
-    for rows.Next() {
-        found = true
-        var day, startHour, startMinute, endHour, endMinute, breakMinutes int
-        // Scan all fetched columns into respective variables.
-        if err := rows.Scan(&day, &startHour, &startMinute, &endHour, &endMinute, &breakMinutes); err != nil {
-            log.Printf("Error scanning working hours: %v", err)
-            continue
+    if output != OutputText {
+        records := []workingHoursRecord{}
+        for day := time.Sunday; day <= time.Saturday; day++ {
+            if wh, ok := workingHours[day]; ok {
+                records = append(records, newWorkingHoursRecord(wh))
+            }
         }
-        if day >= 0 && day < len(dayNames) {
-            // Print working hours including minutes and break duration.
-            fmt.Printf("  %-10s %02d:%02d - %02d:%02d (Break: %d minutes)\n", dayNames[day], startHour, startMinute, endHour, endMinute, breakMinutes)
-        } else {
-            fmt.Printf("  Day %d: %02d:%02d - %02d:%02d (Break: %d minutes) (Invalid Day Index)\n", day, startHour, startMinute, endHour, endMinute, breakMinutes)
+        for _, context := range contexts {
+            for day := time.Sunday; day <= time.Saturday; day++ {
+                if wh, ok := contextHours[context][day]; ok {
+                    record := newWorkingHoursRecord(wh)
+                    record.Context = context
+                    records = append(records, record)
+                }
+            }
         }
+        writeRecords(output, records)
+        return
     }
-    if !found {
+
+    fmt.Println("--- Working Hours ---")
+    if len(workingHours) == 0 {
         fmt.Println("No working hours configured.")
     }
-    if err = rows.Err(); err != nil {
-        log.Fatalf("Error after listing working hours: %v", err)
+    for day := time.Sunday; day <= time.Saturday; day++ {
+        wh, ok := workingHours[day]
+        if !ok {
+            continue
+        }
+        // Print working hours including minutes and break duration.
+        fmt.Printf("  %-10s %02d:%02d - %02d:%02d (Break: %d minutes)\n", day.String(), wh.StartHour, wh.StartMinute, wh.EndHour, wh.EndMinute, wh.BreakMinutes)
     }
+    listContextHours(contexts, contextHours)
 }
 
-// ListProjects lists all projects.
-// It now accepts *TodoManager.
-func ListProjects(tm *TodoManager) {
-    rows, err := tm.db.Query("SELECT id, name FROM projects ORDER BY name ASC")
-    if err != nil {
-        log.Fatalf("Error listing projects: %v", err)
-    }
-    defer rows.Close()
-
-    fmt.Println("----------------------------")
-    fmt.Println("  ID    Project")
-    fmt.Println("----------------------------")
-    found := false
-    for rows.Next() {
-        found = true
-        var id int
-        var name string
-        if err := rows.Scan(&id, &name); err != nil {
-            log.Printf("Error scanning project: %v", err)
-            continue
+// listContextHours prints the hours of contexts after the working hours.
+func listContextHours(contexts []string, contextHours map[string]map[time.Weekday]todo.WorkingHours) {
+    if len(contexts) == 0 {
+        return
+    }
+    fmt.Println("--- Context Hours ---")
+    for _, context := range contexts {
+        for day := time.Sunday; day <= time.Saturday; day++ {
+            if wh, ok := contextHours[context][day]; ok {
+                fmt.Printf("  %s%-12s%s %-10s %02d:%02d - %02d:%02d\n", fg_magenta, context, style_reset, day.String(), wh.StartHour, wh.StartMinute, wh.EndHour, wh.EndMinute)
+            }
         }
-        fmt.Printf("  %-5d %s%s%s\n", id, fg_green, name, style_reset)
     }
-    if !found {
-        fmt.Println("No projects found.")
+}
+
+// ListUrgencyCoefficients lists the coefficients of the urgency score, marking the changed ones.
+func ListUrgencyCoefficients(tm *todo.TodoManager, output string) {
+    coefficients, err := tm.GetUrgencyCoefficients()
+    if err != nil {
+        log.Fatalf("Error listing urgency coefficients: %v", err)
     }
-    if err = rows.Err(); err != nil {
-        log.Fatalf("Error after listing projects: %v", err)
+    if output != OutputText {
+        records := make([]urgencyCoefficientRecord, len(coefficients))
+        for i, c := range coefficients {
+            records[i] = urgencyCoefficientRecord{Name: c.Name, Value: c.Value, Default: c.Default}
+        }
+        writeRecords(output, records)
+        return
+    }
+
+    fmt.Println("--- Urgency Coefficients ---")
+    for _, c := range coefficients {
+        changed := ""
+        if !c.Default {
+            changed = fg_yellow + " (changed)" + style_reset
+        }
+        fmt.Printf("  %-20s %6.2f%s\n", c.Name, c.Value, changed)
     }
 }
 
-// ListContexts lists all contexts.
-// It now accepts *TodoManager.
-func ListContexts(tm *TodoManager) {
-    rows, err := tm.db.Query("SELECT id, name FROM contexts ORDER BY name ASC")
+// listLabels prints the entries of a lookup table (projects, contexts or tags).
+func listLabels(labels []todo.Label, err error, title, color, output string) {
     if err != nil {
-        log.Fatalf("Error listing contexts: %v", err)
-    }
-    defer rows.Close()
-
-    fmt.Println("----------------------------")
-    fmt.Println("  ID    Context")
-    fmt.Println("----------------------------")
-    found := false
-    for rows.Next() {
-        found = true
-        var id int
-        var name string
-        if err := rows.Scan(&id, &name); err != nil {
-            log.Printf("Error scanning context: %v", err)
-            continue
+        log.Fatalf("Error listing %ss: %v", strings.ToLower(title), err)
+    }
+    if output != OutputText {
+        records := make([]labelRecord, len(labels))
+        for i, l := range labels {
+            records[i] = labelRecord{ID: l.ID, UUID: l.UUID, Name: l.Name}
         }
-        fmt.Printf("  %-5d %s%s%s\n", id, fg_magenta, name, style_reset)
+        writeRecords(output, records)
+        return
     }
-    if !found {
-        fmt.Println("No contexts found.")
+
+    fmt.Println("-------------------------------------")
+    fmt.Printf("  ID    UUID     %s\n", title)
+    fmt.Println("-------------------------------------")
+    if len(labels) == 0 {
+        fmt.Printf("No %ss found.\n", strings.ToLower(title))
+        return
     }
-    if err = rows.Err(); err != nil {
-        log.Fatalf("Error after listing contexts: %v", err)
+    for _, l := range labels {
+        fmt.Printf("  %-5d %-8s %s%s%s\n", l.ID, shortUUID(l.UUID), color, l.Name, style_reset)
     }
 }
 
+// ListProjects lists all projects.
+// It now accepts *TodoManager.
+func ListProjects(tm *todo.TodoManager, output string) {
+    projects, err := tm.GetProjects()
+    listLabels(projects, err, "Project", fg_green, output)
+}
+
+// ListContexts lists all contexts.
+// It now accepts *TodoManager.
+func ListContexts(tm *todo.TodoManager, output string) {
+    contexts, err := tm.GetContexts()
+    listLabels(contexts, err, "Context", fg_magenta, output)
+}
+
 // ListTags lists all tags.
 // It now accepts *TodoManager.
-func ListTags(tm *TodoManager) {
-    rows, err := tm.db.Query("SELECT id, name FROM tags ORDER BY name ASC")
+func ListTags(tm *todo.TodoManager, output string) {
+    tags, err := tm.GetTags()
+    listLabels(tags, err, "Tag", fg_blue, output)
+}
+
+// ShowSchemaStatus lists applied and pending schema migrations.
+func ShowSchemaStatus(tm *todo.TodoManager) {
+    current, status, err := tm.SchemaStatus()
     if err != nil {
-        log.Fatalf("Error listing tags: %v", err)
-    }
-    defer rows.Close()
-
-    fmt.Println("----------------------------")
-    fmt.Println("  ID    Tag")
-    fmt.Println("----------------------------")
-    found := false
-    for rows.Next() {
-        found = true
-        var id int
-        var name string
-        if err := rows.Scan(&id, &name); err != nil {
-            log.Printf("Error scanning tag: %v", err)
-            continue
-        }
-        fmt.Printf("  %-5d %s%s%s\n", id, fg_blue, name, style_reset)
+        log.Fatalf("Error reading schema status: %v", err)
     }
-    if !found {
-        fmt.Println("No tags found.")
-    }
-    if err = rows.Err(); err != nil {
-        log.Fatalf("Error after listing tags: %v", err)
+
+    fmt.Println("--- Schema Migrations ---")
+    fmt.Printf("  Database version: %d, binary version: %d\n", current, todo.LatestSchemaVersion())
+    fmt.Println("------------------------------")
+    for _, m := range status {
+        if m.AppliedAt.Valid {
+            fmt.Printf("  %s%-5d%s %-45s applied %s\n", fg_green, m.Version, style_reset, m.Name, todo.FormatDisplayDateTime(m.AppliedAt))
+        } else {
+            fmt.Printf("  %s%-5d%s %-45s pending\n", fg_yellow, m.Version, style_reset, m.Name)
+        }
     }
 }
-
@@ -0,0 +1,388 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "log"
+    "math"
+    "os"
+    "time"
+
+    "github.com/igorp74/ToDo/todo"
+)
+
+// Output modes selected with the global --output flag
+const (
+    OutputText   = "text"
+    OutputJSON   = "json"
+    OutputNDJSON = "ndjson"
+)
+
+// validOutput reports whether mode is a supported output mode.
+func validOutput(mode string) bool {
+    return mode == OutputText || mode == OutputJSON || mode == OutputNDJSON
+}
+
+// durationRecord is a duration in machine-readable seconds along with its display form.
+type durationRecord struct {
+    Seconds int64  `json:"seconds"`
+    Display string `json:"display"`
+}
+
+// dueRecord describes the time remaining until the due date, or elapsed since it when overdue.
+type dueRecord struct {
+    Seconds int64  `json:"seconds"` // negative when overdue
+    Display string `json:"display"`
+    Overdue bool   `json:"overdue"`
+}
+
+// durationsRecord holds the computed durations of a task; a duration that does not apply is null.
+type durationsRecord struct {
+    Calendar          *durationRecord `json:"calendar"`
+    Working           *durationRecord `json:"working"`
+    Waiting           *durationRecord `json:"waiting"`
+    WaitingWorking    *durationRecord `json:"waiting_working"`
+    Tracked           *durationRecord `json:"tracked"`            // sum of the time entries
+    TrackedWorking    *durationRecord `json:"tracked_working"`    // the part within working hours; only with --clip-tracked
+    RemainingEstimate *durationRecord `json:"remaining_estimate"` // estimate minus tracked time, for open tasks
+    WorkingToDue      *durationRecord `json:"working_to_due"`     // working time left until the due date; null without working hours
+}
+
+type noteRecord struct {
+    ID          int64             `json:"id"`
+    UUID        string            `json:"uuid"`
+    Timestamp   todo.NullableTime `json:"timestamp"`
+    Description string            `json:"description"`
+}
+
+// subtasksRecord rolls up the subtasks of a task, at every level below it.
+type subtasksRecord struct {
+    Total     int             `json:"total"` // cancelled subtasks are not counted
+    Completed int             `json:"completed"`
+    Calendar  *durationRecord `json:"calendar"` // summed durations of the subtasks
+    Working   *durationRecord `json:"working"`
+    Tracked   *durationRecord `json:"tracked"`
+}
+
+// taskRecord is the JSON representation of a task.
+type taskRecord struct {
+    ID                 int64             `json:"id"`
+    UUID               *string           `json:"uuid"`
+    Title              string            `json:"title"`
+    Description        *string           `json:"description"`
+    ProjectID          *int64            `json:"project_id"`
+    Project            *string           `json:"project"`
+    Status             string            `json:"status"`
+    Priority           *string           `json:"priority"`
+    Urgency            float64           `json:"urgency"` // 0 for completed and cancelled tasks
+    Estimate           *durationRecord   `json:"estimate"`
+    StartDate          todo.NullableTime `json:"start_date"`
+    DueDate            todo.NullableTime `json:"due_date"`
+    EndDate            todo.NullableTime `json:"end_date"`
+    Recurrence         *string           `json:"recurrence"`
+    RecurrenceInterval *int64            `json:"recurrence_interval"`
+    RecurrenceShift    *string           `json:"recurrence_shift"`
+    RecurrenceAnchor   *string           `json:"recurrence_anchor"`
+    StartWaitingDate   todo.NullableTime `json:"start_waiting_date"`
+    EndWaitingDate     todo.NullableTime `json:"end_waiting_date"`
+    OriginalTaskID     *int64            `json:"original_task_id"`
+    Projected          bool              `json:"projected"` // an upcoming occurrence that is not stored yet
+    DependsOn          []int64           `json:"depends_on"`
+    BlockedBy          []int64           `json:"blocked_by"` // open tasks among depends_on
+    ParentID           *int64            `json:"parent_id"`
+    Subtasks           *subtasksRecord   `json:"subtasks"` // null for tasks without subtasks
+    Contexts           []string          `json:"contexts"`
+    Tags               []string          `json:"tags"`
+    Notes              []noteRecord      `json:"notes"`
+    Durations          durationsRecord   `json:"durations"`
+    TimeToDue          *dueRecord        `json:"time_to_due"`
+    TimerRunning       bool              `json:"timer_running"`
+}
+
+type holidayRecord struct {
+    ID   int64  `json:"id"`
+    UUID string `json:"uuid"`
+    Date string `json:"date"` // YYYY-MM-DD
+    Name string `json:"name"`
+}
+
+type workingHoursRecord struct {
+    Context      string `json:"context,omitempty"` // set for the hours of a context
+    DayOfWeek    int    `json:"day_of_week"`       // 0=Sunday, 1=Monday, ... 6=Saturday
+    Day          string `json:"day"`
+    Start        string `json:"start"` // HH:MM
+    End          string `json:"end"`   // HH:MM
+    BreakMinutes int    `json:"break_minutes"`
+}
+
+type urgencyCoefficientRecord struct {
+    Name    string  `json:"name"`
+    Value   float64 `json:"value"`
+    Default bool    `json:"default"` // the value is the default one
+}
+
+// accuracyRecord compares estimates and actual working time for a project, a tag or all tasks.
+type accuracyRecord struct {
+    Group     string          `json:"group"` // project, tag or total
+    Name      *string         `json:"name"`  // null for tasks without a project or tags, and for the total
+    Tasks     int             `json:"tasks"`
+    Overruns  int             `json:"overruns"` // tasks that took longer than estimated
+    Estimated *durationRecord `json:"estimated"`
+    Actual    *durationRecord `json:"actual"`
+    Ratio     float64         `json:"ratio"` // actual over estimated
+}
+
+func newAccuracyRecord(group string, g todo.AccuracyGroup) accuracyRecord {
+    r := accuracyRecord{
+        Group:     group,
+        Tasks:     g.Tasks,
+        Overruns:  g.Overruns,
+        Estimated: newDurationRecord(g.Estimated, todo.FormatEstimate(g.Estimated)),
+        Actual:    newDurationRecord(g.Actual, todo.FormatEstimate(g.Actual)),
+        Ratio:     math.Round(g.Ratio()*100) / 100,
+    }
+    if g.Name != "" && group != "total" {
+        r.Name = &g.Name
+    }
+    return r
+}
+
+// leadTimesRecord summarizes lead times in calendar or in working time.
+type leadTimesRecord struct {
+    Median       *durationRecord `json:"median"`
+    P85          *durationRecord `json:"p85"`
+    P95          *durationRecord `json:"p95"`
+    Waiting      *durationRecord `json:"waiting"`       // time spent waiting within the lead times
+    WaitingShare float64         `json:"waiting_share"` // waiting over the total lead time, from 0 to 1
+}
+
+func newLeadTimesRecord(l todo.LeadTimes, format func(time.Duration) string) leadTimesRecord {
+    return leadTimesRecord{
+        Median:       newDurationRecord(l.Median, format(l.Median)),
+        P85:          newDurationRecord(l.P85, format(l.P85)),
+        P95:          newDurationRecord(l.P95, format(l.P95)),
+        Waiting:      newDurationRecord(l.Waiting, format(l.Waiting)),
+        WaitingShare: math.Round(l.WaitingShare()*1000) / 1000,
+    }
+}
+
+// cycleTimeRecord summarizes the lead times of a project, a context, a tag or all tasks.
+type cycleTimeRecord struct {
+    Group    string          `json:"group"` // project, context, tag or total
+    Name     *string         `json:"name"`  // null for tasks without a project, contexts or tags, and for the total
+    Tasks    int             `json:"tasks"`
+    Calendar leadTimesRecord `json:"calendar"`
+    Working  leadTimesRecord `json:"working"`
+}
+
+func newCycleTimeRecord(group string, g todo.CycleTimeGroup) cycleTimeRecord {
+    r := cycleTimeRecord{
+        Group:    group,
+        Tasks:    g.Tasks,
+        Calendar: newLeadTimesRecord(g.Calendar, todo.FormatDuration),
+        Working:  newLeadTimesRecord(g.Working, todo.FormatEstimate),
+    }
+    if g.Name != "" && group != "total" {
+        r.Name = &g.Name
+    }
+    return r
+}
+
+// planRecord is a task in the plan. Tasks without an estimate are not planned: their work,
+// start and finish are null.
+type planRecord struct {
+    ID      int64             `json:"id"`
+    Title   string            `json:"title"`
+    Work    *durationRecord   `json:"work"` // the estimate minus the tracked time
+    Start   todo.NullableTime `json:"start"`
+    Finish  todo.NullableTime `json:"finish"`
+    DueDate todo.NullableTime `json:"due_date"`
+    Late    bool              `json:"late"` // finishes after the due date
+}
+
+func newPlanRecord(p todo.PlannedTask) planRecord {
+    return planRecord{
+        ID:      p.Task.ID,
+        Title:   p.Task.Title,
+        Work:    newDurationRecord(p.Work, todo.FormatEstimate(p.Work)),
+        Start:   todo.NullableTime{Time: p.Start.UTC(), Valid: true},
+        Finish:  todo.NullableTime{Time: p.Finish.UTC(), Valid: true},
+        DueDate: p.Task.DueDate,
+        Late:    p.Late,
+    }
+}
+
+// burndownRecord is the state of the scope at the end of a day.
+type burndownRecord struct {
+    Date      string `json:"date"` // YYYY-MM-DD
+    Open      int    `json:"open"`
+    Completed int    `json:"completed"`
+}
+
+// throughputRecord counts the tasks added and completed in a day, week or month.
+type throughputRecord struct {
+    Start     string `json:"start"` // YYYY-MM-DD, first day of the period
+    End       string `json:"end"`   // YYYY-MM-DD, last day of the period
+    Added     int    `json:"added"`
+    Completed int    `json:"completed"`
+    Open      int    `json:"open"` // open at the end of the period
+}
+
+// labelRecord is the JSON representation of a project, context or tag.
+type labelRecord struct {
+    ID   int64  `json:"id"`
+    UUID string `json:"uuid"`
+    Name string `json:"name"`
+}
+
+// seriesRecord is the JSON representation of a recurring series.
+type seriesRecord struct {
+    ID        int64               `json:"id"` // ID of the first instance
+    Instances []taskRecord        `json:"instances"`
+    Upcoming  []todo.NullableTime `json:"upcoming"` // projected dates of the next occurrences
+}
+
+// optionalNullString converts a NULL-able string into a pointer that encodes as null when invalid.
+func optionalNullString(s sql.NullString) *string {
+    if !s.Valid {
+        return nil
+    }
+    return &s.String
+}
+
+// optionalNullInt converts a NULL-able integer into a pointer that encodes as null when invalid.
+func optionalNullInt(n sql.NullInt64) *int64 {
+    if !n.Valid {
+        return nil
+    }
+    return &n.Int64
+}
+
+func newDurationRecord(d time.Duration, display string) *durationRecord {
+    return &durationRecord{Seconds: int64(d / time.Second), Display: display}
+}
+
+func newTaskRecord(task todo.Task, d taskDurations, rollup *subtaskRollup) taskRecord {
+    r := taskRecord{
+        ID:                 task.ID,
+        UUID:               optionalNullString(task.UUID),
+        Title:              task.Title,
+        Description:        optionalNullString(task.Description),
+        ProjectID:          optionalNullInt(task.ProjectID),
+        Project:            optionalNullString(task.ProjectName),
+        Status:             task.Status,
+        Priority:           optionalNullString(task.Priority),
+        Urgency:            task.Urgency,
+        StartDate:          task.StartDate,
+        DueDate:            task.DueDate,
+        EndDate:            task.EndDate,
+        Recurrence:         optionalNullString(task.Recurrence),
+        RecurrenceInterval: optionalNullInt(task.RecurrenceInterval),
+        RecurrenceShift:    optionalNullString(task.RecurrenceShift),
+        RecurrenceAnchor:   optionalNullString(task.RecurrenceAnchor),
+        StartWaitingDate:   task.StartWaitingDate,
+        EndWaitingDate:     task.EndWaitingDate,
+        OriginalTaskID:     optionalNullInt(task.OriginalTaskID),
+        Projected:          task.Projected,
+        DependsOn:          task.DependsOn,
+        BlockedBy:          task.BlockedBy,
+        ParentID:           optionalNullInt(task.ParentID),
+        Contexts:           task.Contexts,
+        Tags:               task.Tags,
+        Notes:              []noteRecord{},
+    }
+    // Encode missing lists as [] rather than null
+    if r.Contexts == nil {
+        r.Contexts = []string{}
+    }
+    if r.Tags == nil {
+        r.Tags = []string{}
+    }
+    if r.DependsOn == nil {
+        r.DependsOn = []int64{}
+    }
+    if r.BlockedBy == nil {
+        r.BlockedBy = []int64{}
+    }
+    for _, n := range task.Notes {
+        r.Notes = append(r.Notes, noteRecord{ID: n.ID, UUID: n.UUID, Timestamp: n.Timestamp, Description: n.Description.String})
+    }
+
+    if d.HasElapsed {
+        r.Durations.Calendar = newDurationRecord(d.Calendar, todo.FormatDuration(d.Calendar))
+        r.Durations.Working = newDurationRecord(d.Working, todo.FormatWorkingHoursDisplay(d.Working))
+    }
+    if task.StartWaitingDate.Valid && task.EndWaitingDate.Valid {
+        r.Durations.Waiting = newDurationRecord(d.Waiting, todo.FormatDuration(d.Waiting))
+    }
+    if d.HasWaitingWorking {
+        r.Durations.WaitingWorking = newDurationRecord(d.WaitingWorking, todo.FormatWorkingHoursDisplay(d.WaitingWorking))
+    }
+    r.Durations.Tracked = newDurationRecord(task.Tracked, todo.FormatDuration(task.Tracked))
+    if d.Clipped {
+        r.Durations.TrackedWorking = newDurationRecord(d.Tracked, todo.FormatDuration(d.Tracked))
+    }
+    r.TimerRunning = task.TimerRunning
+    if task.Estimate.Valid {
+        r.Estimate = newDurationRecord(task.EstimateDuration(), todo.FormatEstimate(task.EstimateDuration()))
+    }
+    if d.HasRemaining {
+        r.Durations.RemainingEstimate = newDurationRecord(d.RemainingEstimate, todo.FormatEstimate(d.RemainingEstimate))
+        if d.HasWorkingToDue {
+            r.Durations.WorkingToDue = newDurationRecord(d.WorkingToDue, todo.FormatEstimate(d.WorkingToDue))
+        }
+    }
+    if rollup != nil {
+        r.Subtasks = &subtasksRecord{
+            Total:     rollup.Total,
+            Completed: rollup.Completed,
+            Calendar:  newDurationRecord(rollup.Calendar, todo.FormatDuration(rollup.Calendar)),
+            Working:   newDurationRecord(rollup.Working, todo.FormatWorkingHoursDisplay(rollup.Working)),
+            Tracked:   newDurationRecord(rollup.Tracked, todo.FormatDuration(rollup.Tracked)),
+        }
+    }
+    if d.HasDue {
+        seconds := int64(d.ToDue / time.Second)
+        if d.Overdue {
+            seconds = -seconds
+        }
+        r.TimeToDue = &dueRecord{Seconds: seconds, Display: todo.FormatDuration(d.ToDue), Overdue: d.Overdue}
+    }
+    return r
+}
+
+func newHolidayRecord(h todo.Holiday) holidayRecord {
+    return holidayRecord{ID: h.ID, UUID: h.UUID, Date: h.Date.Time.Format("2006-01-02"), Name: h.Name}
+}
+
+func newWorkingHoursRecord(wh todo.WorkingHours) workingHoursRecord {
+    return workingHoursRecord{
+        DayOfWeek:    wh.DayOfWeek,
+        Day:          time.Weekday(wh.DayOfWeek).String(),
+        Start:        fmt.Sprintf("%02d:%02d", wh.StartHour, wh.StartMinute),
+        End:          fmt.Sprintf("%02d:%02d", wh.EndHour, wh.EndMinute),
+        BreakMinutes: wh.BreakMinutes,
+    }
+}
+
+// writeRecords prints records to stdout as an indented JSON array, or as one compact
+// JSON object per line in ndjson mode.
+func writeRecords[T any](output string, records []T) {
+    if records == nil {
+        records = []T{}
+    }
+    enc := json.NewEncoder(os.Stdout)
+    if output == OutputNDJSON {
+        for _, r := range records {
+            if err := enc.Encode(r); err != nil {
+                log.Fatalf("Error encoding output: %v", err)
+            }
+        }
+        return
+    }
+    enc.SetIndent("", "  ")
+    if err := enc.Encode(records); err != nil {
+        log.Fatalf("Error encoding output: %v", err)
+    }
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "database/sql"
+    "encoding/json"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/igorp74/ToDo/todo"
+)
+
+func TestNewTaskRecord(t *testing.T) {
+    start := todo.NullableTime{Time: time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC), Valid: true}
+    tests := []struct {
+        name string
+        task todo.Task
+        d    taskDurations
+        want []string // parts of the JSON encoding
+    }{
+        {
+            name: "bare task",
+            task: todo.Task{ID: 1, Title: "call plumber", Status: "pending"},
+            want: []string{`"id":1`, `"uuid":null`, `"project":null`, `"start_date":null`, `"contexts":[]`, `"tags":[]`, `"notes":[]`,
+                `"depends_on":[]`, `"subtasks":null`, `"calendar":null`, `"time_to_due":null`, `"estimate":null`},
+        },
+        {
+            name: "full task",
+            task: todo.Task{ID: 2, Title: "pay rent", Status: "pending", ProjectName: sql.NullString{String: "home", Valid: true},
+                StartDate: start, Contexts: []string{"phone"}, Tags: []string{"money"}, Estimate: sql.NullInt64{Int64: 90, Valid: true},
+                Notes: []todo.Note{{ID: 4, UUID: "0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10", Description: sql.NullString{String: "ask", Valid: true}}}},
+            d: taskDurations{Calendar: 2 * time.Hour, Working: time.Hour, HasElapsed: true, ToDue: 30 * time.Minute, HasDue: true},
+            want: []string{`"project":"home"`, `"start_date":"2026-03-01T08:00:00Z"`, `"contexts":["phone"]`, `"tags":["money"]`,
+                `"description":"ask"`, `"calendar":{"seconds":7200,`, `"working":{"seconds":3600,`, `"estimate":{"seconds":5400,`,
+                `"time_to_due":{"seconds":1800,`, `"overdue":false`},
+        },
+        {
+            name: "overdue",
+            task: todo.Task{ID: 3, Title: "file taxes", Status: "pending"},
+            d:    taskDurations{ToDue: time.Hour, HasDue: true, Overdue: true},
+            want: []string{`"time_to_due":{"seconds":-3600,`, `"overdue":true`},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data, err := json.Marshal(newTaskRecord(tt.task, tt.d, nil))
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, want := range tt.want {
+                if !strings.Contains(string(data), want) {
+                    t.Errorf("%s not in %s", want, data)
+                }
+            }
+        })
+    }
+}
+
+func TestNewWorkingHoursRecord(t *testing.T) {
+    got := newWorkingHoursRecord(todo.WorkingHours{DayOfWeek: 1, StartHour: 9, StartMinute: 30, EndHour: 17, BreakMinutes: 45})
+    want := workingHoursRecord{DayOfWeek: 1, Day: "Monday", Start: "09:30", End: "17:00", BreakMinutes: 45}
+    if got != want {
+        t.Errorf("got %+v, want %+v", got, want)
+    }
+}
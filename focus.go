@@ -0,0 +1,92 @@
+package main
+
+import (
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "os/signal"
+    "time"
+
+    "github.com/igorp74/ToDo/todo"
+)
+
+// runFocus works on a task in pomodoros: each one runs the timer of the task for length, shows
+// a countdown, and when it completes adds a note to the task and starts a break. Pomodoros are
+// numbered on from the ones completed on the task before. count is the number of pomodoros of
+// this session, or 0 to go on until interrupted. A pomodoro is not started outside working
+// hours or on a holiday, unless force is given; then only a warning is shown. Interrupting a
+// pomodoro with Ctrl+C stops the timer, so the time spent is still tracked, but adds no note.
+func runFocus(tm *todo.TodoManager, taskID int64, length, breakLength time.Duration, count int, force bool) {
+    interrupt := make(chan os.Signal, 1)
+    signal.Notify(interrupt, os.Interrupt)
+    defer signal.Stop(interrupt)
+
+    done, err := tm.CompletedPomodoros(taskID)
+    if err != nil {
+        log.Fatalf("Error reading pomodoros of task %d: %v", taskID, err)
+    }
+    for i := 1; count == 0 || i <= count; i++ {
+        pomodoro := done + i
+        if err := tm.CheckWorkingTime(time.Now()); errors.Is(err, todo.ErrOutsideWorkingHours) {
+            if !force {
+                if i == 1 {
+                    log.Fatalf("Not starting a focus session: %v (use --force to focus anyway)", err)
+                }
+                fmt.Printf("Focus session ended: %v.\n", err)
+                return
+            }
+            fmt.Printf("%sWarning:%s %v.\n", fg_yellow, style_reset, err)
+        } else if err != nil {
+            log.Fatalf("Error checking working hours: %v", err)
+        }
+
+        if _, err := tm.StartTimer(taskID); err != nil {
+            log.Fatalf("Error starting timer: %v", err)
+        }
+        completed := countdown(fmt.Sprintf("🍅 Pomodoro %d", pomodoro), length, interrupt)
+        entry, err := tm.StopTimer()
+        if err != nil {
+            log.Fatalf("Error stopping timer: %v", err)
+        }
+        if !completed {
+            fmt.Printf("Pomodoro %d interrupted; %s tracked on task %d.\n", pomodoro, todo.FormatDuration(entry.Duration(entry.End.Time)), taskID)
+            return
+        }
+        if _, err := tm.AddNoteToTask(taskID, todo.PomodoroNote(pomodoro), "", false); err != nil {
+            log.Fatalf("Error adding note to task %d: %v", taskID, err)
+        }
+        fmt.Printf("Pomodoro %d completed; %s tracked on task %d.\n", pomodoro, todo.FormatDuration(entry.Duration(entry.End.Time)), taskID)
+
+        if i == count || breakLength <= 0 {
+            continue
+        }
+        if !countdown("☕ Break", breakLength, interrupt) {
+            fmt.Println("Focus session ended during the break.")
+            return
+        }
+    }
+    fmt.Println("Focus session completed.")
+}
+
+// countdown shows the time remaining of a period on a single line, updated every second.
+// It returns false when it was interrupted before the end.
+func countdown(label string, d time.Duration, interrupt <-chan os.Signal) bool {
+    end := time.Now().Add(d)
+    ticker := time.NewTicker(time.Second)
+    defer ticker.Stop()
+    defer fmt.Print("\r\033[K") // Clear the countdown line
+
+    for {
+        remaining := time.Until(end).Round(time.Second)
+        if remaining <= 0 {
+            return true
+        }
+        fmt.Printf("\r\033[K%s  %02d:%02d remaining", label, int(remaining.Minutes()), int(remaining.Seconds())%60)
+        select {
+        case <-interrupt:
+            return false
+        case <-ticker.C:
+        }
+    }
+}
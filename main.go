@@ -2,12 +2,17 @@
 package main
 
 import (
-    "database/sql" // Import sql for sql.NullInt64
+    "errors"
     "fmt"
     "log"
     "os"
+    "regexp"
+    "sort"
     "strconv"
     "strings"
+    "time"
+
+    "github.com/igorp74/ToDo/todo"
 )
 
 func main() {
@@ -16,6 +21,7 @@ func main() {
 
     // Global flag for database path
     dbPath := parser.String("db-path", "", &Options{Help: "Custom path and name for the database file (e.g., /path/to/my/todo.db)"})
+    output := parser.String("output", "o", &Options{Default: OutputText, Help: "Output mode for list, next, plan, projects, contexts, tags, holiday list, workhours list, urgency list and report (text, json, ndjson)"})
 
     // Add command
     addCmd := parser.NewCommand("add", "Add a new todo task.")
@@ -24,34 +30,45 @@ func main() {
     addProject := addCmd.String("project", "p", &Options{Help: "Project name (will be created if not exists)"})
     addStart := addCmd.String("start-date", "s", &Options{Help: "Start date (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time."})
     addDue := addCmd.String("due-date", "D", &Options{Help: "Due date (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time."})
-    addEnd := addCmd.String("end-date", "E", &Options{Help: "End date (completion date) (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time."}) // Added
-    addRecurrence := addCmd.String("recurrence", "r", &Options{Help: "Recurrence pattern (daily, weekly, monthly, yearly)"})
+    addEnd := addCmd.String("end-date", "E", &Options{Help: "End date (completion date) (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time. Completes the task unless the status is cancelled."}) // Added
+    addRecurrence := addCmd.String("recurrence", "r", &Options{Help: "Recurrence pattern (daily, weekly, monthly, yearly, workdaily) or RRULE (e.g., 'FREQ=MONTHLY;BYDAY=TU;BYSETPOS=2')"})
     addRecurrenceInterval := addCmd.Int("recurrence-interval", "ri", &Options{Default: 1, Help: "Interval for recurrence (e.g., 2 for every 2 days)"})
+    addRecurrenceAnchor := addCmd.String("recurrence-anchor", "ra", &Options{Help: "Count the next instance from the scheduled date or from the completion date (scheduled, completion) (default: scheduled)"})
+    addRecurrenceShift := addCmd.String("recurrence-shift", "rs", &Options{Help: "Move recurring instances that fall on a holiday or non-working day to the next or previous working day (next, previous, none)"})
     addContexts := addCmd.StringList("contexts", "c", &Options{Help: "Comma-separated list of contexts (e.g., 'work,home')"})
     addTags := addCmd.StringList("tags", "T", &Options{Help: "Comma-separated list of tags (e.g., 'urgent,bug')"})
     addStartWaiting := addCmd.String("start-waiting", "sw", &Options{Help: "Start date of waiting period (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time."})
     addEndWaiting := addCmd.String("end-waiting", "ew", &Options{Help: "End date of waiting period (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time."})
     addStatus := addCmd.String("status", "st", &Options{Default: "pending", Help: "Initial status of the task (pending, completed, cancelled, waiting)"})
+    addPriority := addCmd.String("priority", "pr", &Options{Help: "Priority of the task (H, M, L or high, medium, low)"})
+    addEstimate := addCmd.String("estimate", "est", &Options{Help: "Expected working time (e.g., '3h', '1h30m', or '2d' in working days)"})
+    addDependsOn := addCmd.String("depends-on", "dp", &Options{Help: "Comma-separated IDs, ID ranges or UUID prefixes of tasks that must be done first (e.g., '1,3-5')"})
+    addParent := addCmd.String("parent", "P", &Options{Help: "ID or UUID prefix of the task this one is a subtask of"})
 
     // Delete command
     delCmd := parser.NewCommand("del", "Delete a task by ID.")
-    delIDs := delCmd.String("ids", "", &Options{Help: "Comma-separated IDs or ID ranges of tasks to delete (e.g., '1,2,3-5,10')"})
+    delIDs := delCmd.String("ids", "", &Options{Help: "Comma-separated IDs, ID ranges or UUID prefixes of tasks to delete (e.g., '1,2,3-5,3f2a9c')"})
     delID := delCmd.Int("id", "i", &Options{Help: "ID of a single task to delete (use -ids for multiple or ranges)"})
     delComplete := delCmd.Flag("complete", "C", &Options{Help: "Mark task as completed instead of deleting (for recurring tasks)"})
+    delChildren := delCmd.String("children", "ch", &Options{Default: "orphan", Help: "What happens to subtasks: move them up to the parent (orphan), delete them (delete) or complete them (complete)"})
 
     // Update command
     updateCmd := parser.NewCommand("update", "Update an existing task.")
-    updateIDs := updateCmd.String("ids", "", &Options{Help: "Comma-separated IDs or ID ranges of tasks to update (e.g., '1,2,3-5,10')"})
+    updateIDs := updateCmd.String("ids", "", &Options{Help: "Comma-separated IDs, ID ranges or UUID prefixes of tasks to update (e.g., '1,2,3-5,3f2a9c')"})
     updateID := updateCmd.Int("id", "i", &Options{Help: "ID of a single task to update (use -ids for multiple or ranges)"})
     updateTitle := updateCmd.String("title", "t", &Options{Help: "New title of the task"})
     updateDesc := updateCmd.String("description", "d", &Options{Help: "New description of the task"})
     updateProject := updateCmd.String("project", "p", &Options{Help: "New project name"})
     updateStart := updateCmd.String("start-date", "s", &Options{Help: "New start date (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time."})
     updateDue := updateCmd.String("due-date", "D", &Options{Help: "New due date (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time."})
-    updateEnd := updateCmd.String("end-date", "E", &Options{Help: "New end date (completion date) (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time."})
+    updateEnd := updateCmd.String("end-date", "E", &Options{Help: "New end date (completion date) (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time. Completes the task unless the status is cancelled."})
     updateStatus := updateCmd.String("status", "st", &Options{Help: "New status (pending, completed, cancelled, waiting)"}) // Unified flag
-    updateRecurrence := updateCmd.String("recurrence", "r", &Options{Help: "New recurrence pattern"})
+    updatePriority := updateCmd.String("priority", "pr", &Options{Help: "New priority (H, M, L or high, medium, low; none to clear)"})
+    updateEstimate := updateCmd.String("estimate", "est", &Options{Help: "New expected working time (e.g., '3h', '2d'; none to clear)"})
+    updateRecurrence := updateCmd.String("recurrence", "r", &Options{Help: "New recurrence pattern or RRULE"})
     updateRecurrenceInterval := updateCmd.Int("recurrence-interval", "ri", &Options{Help: "New interval for recurrence"})
+    updateRecurrenceAnchor := updateCmd.String("recurrence-anchor", "ra", &Options{Help: "New recurrence anchor (scheduled, completion)"})
+    updateRecurrenceShift := updateCmd.String("recurrence-shift", "rs", &Options{Help: "New shift of recurring instances off non-working days (next, previous, none)"})
     updateContexts := updateCmd.StringList("contexts", "c", &Options{Help: "Comma-separated list of contexts (replaces existing)"})
     updateTags := updateCmd.StringList("tags", "T", &Options{Help: "Comma-separated list of tags (replaces existing)"})
     updateStartWaiting := updateCmd.String("start-waiting", "sw", &Options{Help: "New start date of waiting period (YYYY-MM-DD HH:MM:SS orYYYY-MM-DD). Use empty string with flag to set current time."})
@@ -63,6 +80,13 @@ func main() {
     updateAddTags := updateCmd.StringList("add-tags", "at", &Options{Help: "Comma-separated list of tags to add (e.g., 'new_feature,high_priority'). Will append to existing."})
     updateRemoveTags := updateCmd.StringList("remove-tags", "rt", &Options{Help: "Comma-separated list of tags to remove (e.g., 'bug_fix'). Will remove from existing."})
 
+    // Dependencies
+    updateDependsOn := updateCmd.String("depends-on", "dp", &Options{Help: "Comma-separated IDs, ID ranges or UUID prefixes of tasks that must be done first (replaces existing)"})
+    updateAddDependsOn := updateCmd.String("add-depends-on", "adp", &Options{Help: "Comma-separated IDs of tasks to add to the dependencies"})
+    updateRemoveDependsOn := updateCmd.String("remove-depends-on", "rdp", &Options{Help: "Comma-separated IDs of tasks to remove from the dependencies"})
+    updateIgnoreBlockers := updateCmd.Flag("ignore-blockers", "", &Options{Help: "Complete tasks even while tasks they depend on are still open"})
+    updateParent := updateCmd.String("parent", "P", &Options{Help: "ID or UUID prefix of the task to make these tasks subtasks of"})
+
     // Clear flags for update command
     updateClearProject := updateCmd.Flag("clear-p", "", &Options{Help: "Clear project association"})
     updateClearContexts := updateCmd.Flag("clear-c", "", &Options{Help: "Clear all context associations"})
@@ -72,6 +96,10 @@ func main() {
     updateClearEnd := updateCmd.Flag("clear-E", "", &Options{Help: "Clear end date"})
     updateClearRecurrence := updateCmd.Flag("clear-r", "", &Options{Help: "Clear recurrence"})
     updateClearWaiting := updateCmd.Flag("clear-wait", "", &Options{Help: "Clear waiting period"})
+    updateClearDependsOn := updateCmd.Flag("clear-dp", "", &Options{Help: "Clear all dependencies"})
+    updateClearParent := updateCmd.Flag("clear-P", "", &Options{Help: "Make subtasks top-level tasks"})
+    updateClearPriority := updateCmd.Flag("clear-pr", "", &Options{Help: "Clear priority"})
+    updateClearEstimate := updateCmd.Flag("clear-est", "", &Options{Help: "Clear estimate"})
 
     // Add Note command
     addNoteCmd := parser.NewCommand("add-note", "Add a new note to a task.")
@@ -87,7 +115,7 @@ func main() {
 
     // Delete Note command
     deleteNoteCmd := parser.NewCommand("delete-note", "Delete one or more notes by ID.")
-    deleteNoteIDs := deleteNoteCmd.String("ids", "", &Options{Help: "Comma-separated IDs or ID ranges of notes to delete (e.g., '1,2,3-5,10')"})
+    deleteNoteIDs := deleteNoteCmd.String("ids", "", &Options{Help: "Comma-separated IDs, ID ranges or UUID prefixes of notes to delete (e.g., '1,2,3-5,3f2a9c')"})
     deleteNoteAll := deleteNoteCmd.Flag("all", "", &Options{Help: "Delete all notes"})
     deleteNoteTaskID := deleteNoteCmd.Int("task-id", "ti", &Options{Help: "ID of the task whose notes should be deleted"})
     deleteNoteAllForTask := deleteNoteCmd.Flag("all-for-task", "", &Options{Help: "Delete all notes associated with the specified task ID"})
@@ -105,12 +133,24 @@ func main() {
     listDueAfter := listCmd.String("due-after", "", &Options{Help: "Filter by due date after (YYYY-MM-DD HH:MM:SS)"})
     listEndBefore := listCmd.String("end-before", "", &Options{Help: "Filter by end date before (YYYY-MM-DD HH:MM:SS)"}) // Added new flag
     listEndAfter := listCmd.String("end-after", "", &Options{Help: "Filter by end date after (YYYY-MM-DD HH:MM:SS)"})   // Added new flag
-    listSortBy := listCmd.String("sort-by", "", &Options{Default: "due_date", Help: "Sort by field (id, title, start_date, due_date, status, project, end_date)"}) // Updated help
-    listOrder := listCmd.String("order", "", &Options{Default: "asc", Help: "Sort order (asc, desc)"})
+    listSortBy := listCmd.String("sort-by", "", &Options{Default: "due_date", Help: "Sort by field (id, title, start_date, due_date, status, project, end_date, urgency)"}) // Updated help
+    listOrder := listCmd.String("order", "", &Options{Default: "asc", Help: "Sort order (asc, desc); urgency sorts desc unless given"})
     listFormat := listCmd.Int("format", "f", &Options{Default: DisplayFull, Help: "Output format: 0=Full, 1=Condensed, 2=Minimal"})
     listNotes := listCmd.String("notes", "n", &Options{Default: "none", Help: "Display notes: 'none', 'all', or a number (e.g., '1', '2' for last N notes)"})
-    listTaskIDs := listCmd.String("ids", "i", &Options{Help: "Comma-separated IDs or ID ranges of tasks to list (e.g., '1,2,3-5,10')"})
+    listTaskIDs := listCmd.String("ids", "i", &Options{Help: "Comma-separated IDs, ID ranges or UUID prefixes of tasks to list (e.g., '1,2,3-5,3f2a9c')"})
     listSearch := listCmd.String("search", "S", &Options{Help: "Search for text in task titles, descriptions and notes (case-insensitive)"})
+    listExpandRecurring := listCmd.String("expand-recurring", "", &Options{Help: "Also show upcoming occurrences of recurring tasks within a window (e.g., '30d', '2w', '3m' or an end date)"})
+    listClipTracked := listCmd.Flag("clip-tracked", "", &Options{Help: "Count only the tracked time within working hours"})
+
+    // Next command
+    nextCmd := parser.NewCommand("next", "Show the tasks that can be worked on now, most pressing first.")
+    nextCount := nextCmd.Int("count", "n", &Options{Default: 1, Help: "Number of tasks to show (0 for all)"})
+    nextProject := nextCmd.String("project", "p", &Options{Help: "Only tasks of this project"})
+    nextContext := nextCmd.String("context", "c", &Options{Help: "Only tasks with this context"})
+    nextTag := nextCmd.String("tag", "T", &Options{Help: "Only tasks with this tag"})
+    nextFormat := nextCmd.Int("format", "f", &Options{Default: DisplayFull, Help: "Output format: 0=Full, 1=Condensed, 2=Minimal"})
+    nextNotes := nextCmd.String("notes", "N", &Options{Default: "none", Help: "Display notes: 'none', 'all', or a number (e.g., '1', '2' for last N notes)"})
+    nextClipTracked := nextCmd.Flag("clip-tracked", "", &Options{Help: "Count only the tracked time within working hours"})
 
 
     // Holiday commands
@@ -120,7 +160,7 @@ func main() {
     holidayAddName := holidayAddCmd.String("name", "n", &Options{Required: true, Help: "Name of the holiday"})
     holidayListCmd := holidayCmd.NewCommand("list", "List all holidays.")
     holidayDelCmd := holidayCmd.NewCommand("del", "Delete one or more holidays by ID or delete all.") // Modified help text
-    holidayDelIDs := holidayDelCmd.String("ids", "", &Options{Help: "Comma-separated IDs or ID ranges of holidays to delete (e.g., '1,2,3-5,10')"})
+    holidayDelIDs := holidayDelCmd.String("ids", "", &Options{Help: "Comma-separated IDs, ID ranges or UUID prefixes of holidays to delete (e.g., '1,2,3-5,3f2a9c')"})
     holidayDelAll := holidayDelCmd.Flag("all", "", &Options{Help: "Delete all holidays"})
 
 
@@ -133,12 +173,23 @@ func main() {
     workhoursSetEndHour := workhoursSetCmd.Int("end-hour", "eh", &Options{Required: true, Help: "End hour (0-24)"})
     workhoursSetEndMinute := workhoursSetCmd.Int("end-minute", "eM", &Options{Default: 0, Help: "End minute (0-59)"})
     workhoursSetBreakMinutes := workhoursSetCmd.Int("break-minutes", "b", &Options{Default: 0, Help: "Break duration in minutes for this day"})
+    workhoursSetContext := workhoursSetCmd.String("context", "c", &Options{Help: "Set the hours in which tasks of this context can be done instead (break is ignored)"})
     workhoursListCmd := workhoursCmd.NewCommand("list", "List all defined working hours.")
     workhoursDelCmd := workhoursCmd.NewCommand("del", "Delete working hours for one or more days or delete all.") // Modified help text
     workhoursDelDays := workhoursDelCmd.String("days", "", &Options{Help: "Comma-separated day of week numbers or ranges to delete working hours for (e.g., '1,2,3-5')"})
     workhoursDelAll := workhoursDelCmd.Flag("all", "", &Options{Help: "Delete all working hours"})
+    workhoursDelContext := workhoursDelCmd.String("context", "c", &Options{Help: "Delete the hours of this context instead"})
 
 
+    // Urgency commands
+    urgencyCmd := parser.NewCommand("urgency", "Manage the coefficients of the urgency score.")
+    urgencyListCmd := urgencyCmd.NewCommand("list", "List the urgency coefficients.")
+    urgencySetCmd := urgencyCmd.NewCommand("set", "Set an urgency coefficient.")
+    urgencySetName := urgencySetCmd.Arg("name", &Options{Required: true, Help: "Coefficient (priority.H, priority.M, priority.L, due, age, tags, project, blocked, tag.<name> or project.<name>)"})
+    urgencySetValue := urgencySetCmd.Arg("value", &Options{Required: true, Help: "New value; negative values lower the urgency (e.g., 4.5, -2)"})
+    urgencyResetCmd := urgencyCmd.NewCommand("reset", "Restore the default of one or all urgency coefficients.")
+    urgencyResetName := urgencyResetCmd.Arg("name", &Options{Help: "Coefficient to reset (default: all); tag and project coefficients are removed"})
+
     // List projects command
     listProjectsCmd := parser.NewCommand("projects", "List all projects.")
 
@@ -148,45 +199,152 @@ func main() {
     // List tags command
     listTagsCmd := parser.NewCommand("tags", "List all tags.")
 
+    // Recurring series commands
+    seriesCmd := parser.NewCommand("series", "Manage all instances of a recurring task.")
+    seriesShowCmd := seriesCmd.NewCommand("show", "List past instances and upcoming occurrences of a series.")
+    seriesShowID := seriesShowCmd.Arg("id", &Options{Required: true, Help: "ID or UUID prefix of any instance of the series"})
+    seriesShowUpcoming := seriesShowCmd.Int("upcoming", "n", &Options{Default: 5, Help: "Number of upcoming occurrences to show"})
+    seriesEditCmd := seriesCmd.NewCommand("edit", "Change the open and all future instances of a series.")
+    seriesEditID := seriesEditCmd.Arg("id", &Options{Required: true, Help: "ID or UUID prefix of any instance of the series"})
+    seriesEditTitle := seriesEditCmd.String("title", "t", &Options{Help: "New title"})
+    seriesEditDesc := seriesEditCmd.String("description", "d", &Options{Help: "New description"})
+    seriesEditProject := seriesEditCmd.String("project", "p", &Options{Help: "New project name"})
+    seriesEditRecurrence := seriesEditCmd.String("recurrence", "r", &Options{Help: "New recurrence pattern or RRULE"})
+    seriesEditRecurrenceInterval := seriesEditCmd.Int("recurrence-interval", "ri", &Options{Help: "New interval for recurrence"})
+    seriesEditRecurrenceAnchor := seriesEditCmd.String("recurrence-anchor", "ra", &Options{Help: "New recurrence anchor (scheduled, completion)"})
+    seriesEditRecurrenceShift := seriesEditCmd.String("recurrence-shift", "rs", &Options{Help: "New shift of recurring instances off non-working days (next, previous, none)"})
+    seriesEditContexts := seriesEditCmd.StringList("contexts", "c", &Options{Help: "Comma-separated list of contexts (replaces existing)"})
+    seriesEditTags := seriesEditCmd.StringList("tags", "T", &Options{Help: "Comma-separated list of tags (replaces existing)"})
+    seriesEditAddContexts := seriesEditCmd.StringList("add-contexts", "ac", &Options{Help: "Comma-separated list of contexts to add"})
+    seriesEditRemoveContexts := seriesEditCmd.StringList("remove-contexts", "rc", &Options{Help: "Comma-separated list of contexts to remove"})
+    seriesEditAddTags := seriesEditCmd.StringList("add-tags", "at", &Options{Help: "Comma-separated list of tags to add"})
+    seriesEditRemoveTags := seriesEditCmd.StringList("remove-tags", "rt", &Options{Help: "Comma-separated list of tags to remove"})
+    seriesEditClearProject := seriesEditCmd.Flag("clear-p", "", &Options{Help: "Clear project association"})
+    seriesEditClearContexts := seriesEditCmd.Flag("clear-c", "", &Options{Help: "Clear all context associations"})
+    seriesEditClearTags := seriesEditCmd.Flag("clear-T", "", &Options{Help: "Clear all tag associations"})
+    seriesStopCmd := seriesCmd.NewCommand("stop", "End a series, so that no further instances are created.")
+    seriesStopID := seriesStopCmd.Arg("id", &Options{Required: true, Help: "ID or UUID prefix of any instance of the series"})
+    seriesStopCancel := seriesStopCmd.Flag("cancel", "", &Options{Help: "Also cancel the open instances"})
+    seriesSkipCmd := seriesCmd.NewCommand("skip", "Skip the next occurrence of a series without completing it.")
+    seriesSkipID := seriesSkipCmd.Arg("id", &Options{Required: true, Help: "ID or UUID prefix of any instance of the series"})
+
+    // Time tracking commands
+    startCmd := parser.NewCommand("start", "Start a timer on a task.")
+    startID := startCmd.Arg("id", &Options{Required: true, Help: "ID or UUID prefix of the task"})
+    stopCmd := parser.NewCommand("stop", "Stop the running timer.")
+    logCmd := parser.NewCommand("log", "Log time spent on a task.")
+    logID := logCmd.Arg("id", &Options{Required: true, Help: "ID or UUID prefix of the task"})
+    logDuration := logCmd.Arg("duration", &Options{Required: true, Help: "Time spent (e.g., '1h30m', '45m')"})
+    logStart := logCmd.String("start", "s", &Options{Help: "When the work started (YYYY-MM-DD HH:MM:SS); by default it ends now"})
+    focusCmd := parser.NewCommand("focus", "Work on a task in pomodoros, with a countdown and the timer running.")
+    focusID := focusCmd.Arg("id", &Options{Required: true, Help: "ID or UUID prefix of the task"})
+    focusLength := focusCmd.String("length", "l", &Options{Default: "25m", Help: "Length of a pomodoro (e.g., '25m', '50m')"})
+    focusBreak := focusCmd.String("break", "b", &Options{Default: "5m", Help: "Length of the break after a pomodoro; '0' for none"})
+    focusCount := focusCmd.Int("count", "n", &Options{Default: 4, Help: "Number of pomodoros (0 to go on until interrupted)"})
+    focusForce := focusCmd.Flag("force", "", &Options{Help: "Focus outside working hours or on a holiday, with a warning"})
+
+    // Plan command
+    planCmd := parser.NewCommand("plan", "Schedule open tasks with an estimate over the working time ahead and flag those that would be late.")
+    planProject := planCmd.String("project", "p", &Options{Help: "Only tasks of this project"})
+    planContext := planCmd.String("context", "c", &Options{Help: "Only tasks with this context"})
+    planTag := planCmd.String("tag", "T", &Options{Help: "Only tasks with this tag"})
+
+    // Report commands
+    reportCmd := parser.NewCommand("report", "Report on the progress of work.")
+    reportAccuracyCmd := reportCmd.NewCommand("accuracy", "Compare estimates of completed tasks with the working time they took, by project and tag.")
+    reportCycleTimeCmd := reportCmd.NewCommand("cycletime", "Summarize the lead times of completed tasks and the share spent waiting, by project, context and tag.")
+    reportCycleTimeProject := reportCycleTimeCmd.String("project", "p", &Options{Help: "Only tasks of this project"})
+    reportCycleTimeContext := reportCycleTimeCmd.String("context", "c", &Options{Help: "Only tasks with this context"})
+    reportCycleTimeTag := reportCycleTimeCmd.String("tag", "T", &Options{Help: "Only tasks with this tag"})
+    reportCycleTimeFrom := reportCycleTimeCmd.String("from", "", &Options{Help: "Only tasks completed on or after this date (YYYY-MM-DD)"})
+    reportCycleTimeTo := reportCycleTimeCmd.String("to", "", &Options{Help: "Only tasks completed before this date (YYYY-MM-DD)"})
+    reportBurndownCmd := reportCmd.NewCommand("burndown", "Chart the open and completed tasks at the end of every day.")
+    reportBurndownProject := reportBurndownCmd.String("project", "p", &Options{Help: "Only tasks of this project"})
+    reportBurndownContext := reportBurndownCmd.String("context", "c", &Options{Help: "Only tasks with this context"})
+    reportBurndownTag := reportBurndownCmd.String("tag", "T", &Options{Help: "Only tasks with this tag"})
+    reportBurndownFrom := reportBurndownCmd.String("from", "", &Options{Help: "First day (YYYY-MM-DD); default: 14 days before the last"})
+    reportBurndownTo := reportBurndownCmd.String("to", "", &Options{Help: "Last day (YYYY-MM-DD); default: today"})
+    reportThroughputCmd := reportCmd.NewCommand("throughput", "Chart the tasks added and completed in every day, week or month.")
+    reportThroughputBy := reportThroughputCmd.String("by", "", &Options{Default: "week", Help: "Period (day, week, month)"})
+    reportThroughputProject := reportThroughputCmd.String("project", "p", &Options{Help: "Only tasks of this project"})
+    reportThroughputContext := reportThroughputCmd.String("context", "c", &Options{Help: "Only tasks with this context"})
+    reportThroughputTag := reportThroughputCmd.String("tag", "T", &Options{Help: "Only tasks with this tag"})
+    reportThroughputFrom := reportThroughputCmd.String("from", "", &Options{Help: "A day in the first period (YYYY-MM-DD); default: 8 periods up to the last"})
+    reportThroughputTo := reportThroughputCmd.String("to", "", &Options{Help: "A day in the last period (YYYY-MM-DD); default: today"})
+
+    // Export and import commands
+    exportCmd := parser.NewCommand("export", "Export all tasks with their contexts, tags and notes.")
+    exportFormat := exportCmd.String("format", "f", &Options{Help: "Export format (csv, tsv, todotxt, ics, taskwarrior). Defaults to the file extension, or csv"})
+    exportFile := exportCmd.String("file", "F", &Options{Help: "File to write to (default: standard output)"})
+    importCmd := parser.NewCommand("import", "Import tasks as new tasks from an export file.")
+    importFormat := importCmd.String("format", "f", &Options{Help: "Import format (csv, tsv, todotxt, ics, taskwarrior). Defaults to the file extension, or csv"})
+    importFile := importCmd.String("file", "F", &Options{Required: true, Help: "File to read from"})
+    importDryRun := importCmd.Flag("dry-run", "n", &Options{Help: "Report what would be created without changing the database"})
+
+    // Sync command
+    syncCmd := parser.NewCommand("sync", "Exchange changes with other devices through a shared directory.")
+    syncDir := syncCmd.Arg("dir", &Options{Required: true, Help: "Shared directory holding the change logs of all devices (e.g., a synced folder)"})
+
+    // Database maintenance commands
+    dbCmd := parser.NewCommand("db", "Manage the database schema.")
+    dbMigrateCmd := dbCmd.NewCommand("migrate", "Apply pending schema migrations.")
+    dbStatusCmd := dbCmd.NewCommand("status", "Show applied and pending schema migrations.")
+
     err := parser.Parse(os.Args)
     if err != nil {
         fmt.Println(parser.Usage(err))
         return
     }
+    if !validOutput(*output) {
+        fmt.Println(parser.Usage(fmt.Errorf("invalid output mode '%s' (expected text, json or ndjson)", *output)))
+        os.Exit(1)
+    }
 
-    // Initialize TodoManager with the determined database path
-    tm := NewTodoManager(*dbPath) // Correctly instantiate tm
+    // Initialize TodoManager with the determined database path.
+    // The db commands open the database as it is, so that they can show pending migrations.
+    var tm *todo.TodoManager
+    if dbCmd.Parsed || dbMigrateCmd.Parsed || dbStatusCmd.Parsed {
+        tm, err = todo.OpenTodoManager(*dbPath)
+    } else {
+        tm, err = todo.NewTodoManager(*dbPath)
+    }
+    if err != nil {
+        log.Fatalf("Error opening database: %v", err)
+    }
     defer tm.Close()
 
     switch {
     case addCmd.Parsed:
-        tm.AddTask(
-            nil, // Pass nil for the transaction when adding a new task directly
-            *addTitle,
-            *addDesc,
-            *addProject,
-            *addStart,
-            addCmd.GetFlag("start-date").IsSet, // Pass IsSet status for start-date
-            *addDue,
-            addCmd.GetFlag("due-date").IsSet, // Pass IsSet status for due-date
-            *addEnd, // Pass the new end date string
-            addCmd.GetFlag("end-date").IsSet, // Pass IsSet status for end-date
-            *addRecurrence,
-            *addRecurrenceInterval,
-            *addContexts,
-            *addTags,
-            *addStartWaiting,
-            addCmd.GetFlag("start-waiting").IsSet,
-            *addEndWaiting,
-            addCmd.GetFlag("end-waiting").IsSet,
-            *addStatus,
-            sql.NullInt64{}, // Pass empty sql.NullInt64 for originalTaskID for new tasks
-        )
+        task, err := tm.AddTask(todo.TaskInput{
+            Title:              *addTitle,
+            Description:        *addDesc,
+            Project:            *addProject,
+            StartDate:          optionalString(addCmd, "start-date", addStart),
+            DueDate:            optionalString(addCmd, "due-date", addDue),
+            EndDate:            optionalString(addCmd, "end-date", addEnd),
+            Recurrence:         *addRecurrence,
+            RecurrenceInterval: *addRecurrenceInterval,
+            RecurrenceShift:    *addRecurrenceShift,
+            RecurrenceAnchor:   *addRecurrenceAnchor,
+            Contexts:           *addContexts,
+            Tags:               *addTags,
+            StartWaiting:       optionalString(addCmd, "start-waiting", addStartWaiting),
+            EndWaiting:         optionalString(addCmd, "end-waiting", addEndWaiting),
+            Status:             *addStatus,
+            Priority:           *addPriority,
+            Estimate:           *addEstimate,
+            DependsOn:          dependencyIDs(tm, *addDependsOn),
+            ParentID:           parentID(tm, *addParent),
+        })
+        if err != nil {
+            log.Fatalf("Error adding task: %v", err)
+        }
+        fmt.Printf("Task '%s' added successfully with ID: %d\n", task.Title, task.ID)
     case delCmd.Parsed:
         var targetIDs []int64
         if *delIDs != "" {
             var parseErr error
-            targetIDs, parseErr = parseIDs(*delIDs) // Use generic parseIDs
+            targetIDs, parseErr = parseIDs(*delIDs, uuidLookup(tm, "tasks")) // Use generic parseIDs
             if parseErr != nil {
                 fmt.Printf("Error parsing task IDs: %v\n", parseErr)
                 fmt.Println(parser.Usage(nil))
@@ -199,13 +357,26 @@ func main() {
             fmt.Println(parser.Usage(nil))
             os.Exit(1)
         }
-        // Call the new DeleteTasks method
-        tm.DeleteTasks(targetIDs, *delComplete)
+        for _, id := range targetIDs {
+            err := tm.DeleteTask(id, *delComplete, *delChildren)
+            switch {
+            case errors.Is(err, todo.ErrTaskNotFound):
+                fmt.Printf("Task %d not found.\n", id)
+            case errors.Is(err, todo.ErrBlocked):
+                fmt.Printf("Not completed: %v. Use 'update -st completed --ignore-blockers' to complete it anyway.\n", err)
+            case err != nil:
+                log.Fatalf("Error deleting task %d: %v", id, err)
+            case *delComplete:
+                fmt.Printf("Task %d marked as completed.\n", id)
+            default:
+                fmt.Printf("Task %d deleted successfully.\n", id)
+            }
+        }
     case updateCmd.Parsed:
         var targetIDs []int64
         if *updateIDs != "" {
             var parseErr error
-            targetIDs, parseErr = parseIDs(*updateIDs) // Use generic parseIDs
+            targetIDs, parseErr = parseIDs(*updateIDs, uuidLookup(tm, "tasks")) // Use generic parseIDs
             if parseErr != nil {
                 fmt.Printf("Error parsing task IDs: %v\n", parseErr)
                 fmt.Println(parser.Usage(nil))
@@ -219,30 +390,70 @@ func main() {
             os.Exit(1)
         }
 
-        err := tm.UpdateTasks(targetIDs,
-            *updateTitle, *updateDesc, *updateProject,
-            *updateStart, updateCmd.GetFlag("start-date").IsSet,
-            *updateDue, updateCmd.GetFlag("due-date").IsSet,
-            *updateEnd, updateCmd.GetFlag("end-date").IsSet,
-            *updateStatus,
-            *updateRecurrence, *updateRecurrenceInterval,
-            *updateContexts, updateCmd.GetFlag("contexts").IsSet, // Pass IsSet for replace
-            *updateTags, updateCmd.GetFlag("tags").IsSet, // Pass IsSet for replace
-            *updateStartWaiting, updateCmd.GetFlag("start-waiting").IsSet,
-            *updateEndWaiting, updateCmd.GetFlag("end-waiting").IsSet,
-            *updateClearProject, *updateClearContexts, *updateClearTags,
-            *updateClearStart, *updateClearDue, *updateClearEnd, *updateClearRecurrence, *updateClearWaiting,
-            // Pass new incremental update flags
-            *updateAddContexts, updateCmd.GetFlag("add-contexts").IsSet,
-            *updateRemoveContexts, updateCmd.GetFlag("remove-contexts").IsSet,
-            *updateAddTags, updateCmd.GetFlag("add-tags").IsSet,
-            *updateRemoveTags, updateCmd.GetFlag("remove-tags").IsSet,
-        )
+        patch := todo.TaskPatch{
+            Title:              optionalString(updateCmd, "title", updateTitle),
+            Description:        optionalString(updateCmd, "description", updateDesc),
+            Project:            optionalString(updateCmd, "project", updateProject),
+            StartDate:          optionalString(updateCmd, "start-date", updateStart),
+            DueDate:            optionalString(updateCmd, "due-date", updateDue),
+            EndDate:            optionalString(updateCmd, "end-date", updateEnd),
+            Status:             optionalString(updateCmd, "status", updateStatus),
+            Priority:           optionalString(updateCmd, "priority", updatePriority),
+            Estimate:           optionalString(updateCmd, "estimate", updateEstimate),
+            Recurrence:         optionalString(updateCmd, "recurrence", updateRecurrence),
+            RecurrenceShift:    optionalString(updateCmd, "recurrence-shift", updateRecurrenceShift),
+            RecurrenceAnchor:   optionalString(updateCmd, "recurrence-anchor", updateRecurrenceAnchor),
+            StartWaiting:       optionalString(updateCmd, "start-waiting", updateStartWaiting),
+            EndWaiting:         optionalString(updateCmd, "end-waiting", updateEndWaiting),
+            AddContexts:        *updateAddContexts,
+            RemoveContexts:     *updateRemoveContexts,
+            AddTags:            *updateAddTags,
+            RemoveTags:         *updateRemoveTags,
+            ClearProject:       *updateClearProject,
+            ClearContexts:      *updateClearContexts,
+            ClearTags:          *updateClearTags,
+            ClearStartDate:     *updateClearStart,
+            ClearDueDate:       *updateClearDue,
+            ClearEndDate:       *updateClearEnd,
+            ClearRecurrence:    *updateClearRecurrence,
+            ClearWaiting:       *updateClearWaiting,
+            ClearDependsOn:     *updateClearDependsOn,
+            AddDependsOn:       dependencyIDs(tm, *updateAddDependsOn),
+            RemoveDependsOn:    dependencyIDs(tm, *updateRemoveDependsOn),
+            IgnoreBlockers:     *updateIgnoreBlockers,
+            ClearParent:        *updateClearParent,
+            ClearPriority:      *updateClearPriority,
+            ClearEstimate:      *updateClearEstimate,
+        }
+        if updateCmd.GetFlag("recurrence-interval").IsSet {
+            patch.RecurrenceInterval = updateRecurrenceInterval
+        }
+        if updateCmd.GetFlag("contexts").IsSet { // Replace existing contexts
+            patch.Contexts = updateContexts
+        }
+        if updateCmd.GetFlag("tags").IsSet { // Replace existing tags
+            patch.Tags = updateTags
+        }
+        if updateCmd.GetFlag("depends-on").IsSet { // Replace existing dependencies
+            deps := dependencyIDs(tm, *updateDependsOn)
+            patch.DependsOn = &deps
+        }
+        if *updateParent != "" {
+            parent := parseTaskID(tm, *updateParent)
+            patch.ParentID = &parent
+        }
+
+        results, err := tm.UpdateTasks(targetIDs, patch)
         if err != nil {
             log.Fatalf("Error updating tasks: %v", err)
         }
+        printUpdateResults(tm, results)
     case addNoteCmd.Parsed:
-        tm.AddNoteToTask(int64(*addNoteTaskID), *addNoteDescription, *addNoteTimestamp, addNoteCmd.GetFlag("timestamp").IsSet) // Pass timestamp and IsSet
+        taskID := int64(*addNoteTaskID)
+        if _, err := tm.AddNoteToTask(taskID, *addNoteDescription, *addNoteTimestamp, addNoteCmd.GetFlag("timestamp").IsSet); err != nil { // Pass timestamp and IsSet
+            log.Fatalf("Error adding note to task %d: %v", taskID, err)
+        }
+        fmt.Printf("Note added to task %d successfully.\n", taskID)
     case updateNoteCmd.Parsed:
         // Check if at least one of description or timestamp is provided
         if *updateNoteDescription == "" && !updateNoteCmd.GetFlag("timestamp").IsSet {
@@ -251,21 +462,47 @@ func main() {
             os.Exit(1)
         }
         // Pass the directly provided note ID for update
-        tm.UpdateNote(int64(*updateNoteID), *updateNoteDescription, *updateNoteTimestamp, updateNoteCmd.GetFlag("timestamp").IsSet)
+        noteID := int64(*updateNoteID)
+        err := tm.UpdateNote(noteID, *updateNoteDescription, *updateNoteTimestamp, updateNoteCmd.GetFlag("timestamp").IsSet)
+        if errors.Is(err, todo.ErrNoteNotFound) {
+            fmt.Printf("Note %d not found or values were not changed.\n", noteID)
+        } else if err != nil {
+            log.Fatalf("Error updating note %d: %v", noteID, err)
+        } else {
+            fmt.Printf("Note %d updated successfully.\n", noteID)
+        }
     case deleteNoteCmd.Parsed:
         // Prioritize specific task notes deletion, then global all, then specific note IDs
         if *deleteNoteTaskID != 0 && *deleteNoteAllForTask {
-            tm.DeleteAllNotesForTask(int64(*deleteNoteTaskID))
+            taskID := int64(*deleteNoteTaskID)
+            count, err := tm.DeleteAllNotesForTask(taskID)
+            if err != nil {
+                log.Fatalf("Error deleting all notes for task %d: %v", taskID, err)
+            }
+            fmt.Printf("Deleted %d notes for task %d.\n", count, taskID)
         } else if *deleteNoteAll {
-            tm.DeleteAllNotes()
+            count, err := tm.DeleteAllNotes()
+            if err != nil {
+                log.Printf("Warning: %v", err)
+            }
+            fmt.Printf("Deleted %d notes.\n", count)
         } else if *deleteNoteIDs != "" {
-            noteIDsToDelete, parseErr := parseIDs(*deleteNoteIDs) // Use generic parseIDs for notes
+            noteIDsToDelete, parseErr := parseIDs(*deleteNoteIDs, uuidLookup(tm, "task_notes")) // Use generic parseIDs for notes
             if parseErr != nil {
                 fmt.Printf("Error parsing note IDs: %v\n", parseErr)
                 fmt.Println(parser.Usage(nil))
                 os.Exit(1)
             }
-            tm.DeleteNotes(noteIDsToDelete)
+            for _, id := range noteIDsToDelete {
+                err := tm.DeleteNote(id)
+                if errors.Is(err, todo.ErrNoteNotFound) {
+                    fmt.Printf("Note %d not found.\n", id)
+                } else if err != nil {
+                    log.Printf("Error deleting note %d: %v", id, err)
+                } else {
+                    fmt.Printf("Note %d deleted successfully.\n", id)
+                }
+            }
         } else {
             fmt.Println("At least one of --ids, --all, or (--task-id and --all-for-task) is required for 'delete-note' command.")
             fmt.Println(parser.Usage(nil))
@@ -275,7 +512,7 @@ func main() {
         var parsedTaskIDs []int64
         if *listTaskIDs != "" {
             var parseErr error
-            parsedTaskIDs, parseErr = parseIDs(*listTaskIDs)
+            parsedTaskIDs, parseErr = parseIDs(*listTaskIDs, uuidLookup(tm, "tasks"))
             if parseErr != nil {
                 fmt.Printf("Error parsing task IDs for list: %v\n", parseErr)
                 fmt.Println(parser.Usage(nil))
@@ -283,71 +520,512 @@ func main() {
             }
         }
 
-        ListTasks(tm, *listProject, *listContext, *listTag, *listStatus,
-            *listStartBefore, *listStartAfter, *listDueBefore, *listDueAfter,
-            *listEndBefore, *listEndAfter, // Pass the new end date filters
-            *listSortBy, *listOrder, *listFormat, *listNotes,
-            parsedTaskIDs, *listSearch) // Pass the new task ID list and search text
+        filter := todo.TaskFilter{
+            IDs:         parsedTaskIDs,
+            Project:     *listProject,
+            Context:     *listContext,
+            Tag:         *listTag,
+            Status:      *listStatus,
+            Search:      *listSearch,
+            StartBefore: parseFilterDate("start-before", *listStartBefore),
+            StartAfter:  parseFilterDate("start-after", *listStartAfter),
+            DueBefore:   parseFilterDate("due-before", *listDueBefore),
+            DueAfter:    parseFilterDate("due-after", *listDueAfter),
+            EndBefore:   parseFilterDate("end-before", *listEndBefore),
+            EndAfter:    parseFilterDate("end-after", *listEndAfter),
+            SortBy:      *listSortBy,
+            Order:       *listOrder,
+        }
+        if *listSortBy == "urgency" && !listCmd.GetFlag("order").IsSet {
+            filter.Order = "desc" // Most urgent first
+        }
+        if *listExpandRecurring != "" {
+            until, err := todo.ParseWindowEnd(*listExpandRecurring, time.Now())
+            if err != nil {
+                fmt.Printf("Error parsing --expand-recurring: %v\n", err)
+                fmt.Println(parser.Usage(nil))
+                os.Exit(1)
+            }
+            filter.ExpandUntil = todo.NullableTime{Time: until.UTC(), Valid: true}
+        }
+        notes := *listNotes
+        if *output != OutputText && !listCmd.GetFlag("notes").IsSet {
+            notes = "all" // Machine-readable output includes every note unless asked otherwise
+        }
+        ListTasks(tm, filter, *listFormat, notes, *output, *listClipTracked)
+    case nextCmd.Parsed:
+        filter := todo.TaskFilter{
+            Project: *nextProject,
+            Context: *nextContext,
+            Tag:     *nextTag,
+        }
+        notes := *nextNotes
+        if *output != OutputText && !nextCmd.GetFlag("notes").IsSet {
+            notes = "all" // Machine-readable output includes every note unless asked otherwise
+        }
+        ShowNextTasks(tm, filter, *nextCount, *nextFormat, notes, *output, *nextClipTracked)
 
     case holidayAddCmd.Parsed:
-        tm.AddHoliday(*holidayAddDate, *holidayAddName)
+        if _, err := tm.AddHoliday(*holidayAddDate, *holidayAddName); err != nil {
+            log.Fatalf("Error adding holiday: %v", err)
+        }
+        fmt.Printf("Holiday '%s' on %s added successfully.\n", *holidayAddName, *holidayAddDate)
     case holidayListCmd.Parsed:
-        ListHolidays(tm)
+        ListHolidays(tm, *output)
     case holidayDelCmd.Parsed: // New case for deleting holidays
         if *holidayDelAll {
-            tm.DeleteAllHolidays()
+            count, err := tm.DeleteAllHolidays()
+            if err != nil {
+                log.Printf("Warning: %v", err)
+            }
+            fmt.Printf("Deleted %d holidays.\n", count)
         } else if *holidayDelIDs != "" {
-            idsToDelete, parseErr := parseIDs(*holidayDelIDs)
+            idsToDelete, parseErr := parseIDs(*holidayDelIDs, uuidLookup(tm, "holidays"))
             if parseErr != nil {
                 fmt.Printf("Error parsing holiday IDs: %v\n", parseErr)
                 fmt.Println(parser.Usage(nil))
                 os.Exit(1)
             }
-            tm.DeleteHolidays(idsToDelete)
+            for _, id := range idsToDelete {
+                err := tm.DeleteHoliday(id)
+                if errors.Is(err, todo.ErrHolidayNotFound) {
+                    fmt.Printf("Holiday %d not found.\n", id)
+                } else if err != nil {
+                    log.Printf("Error deleting holiday %d: %v", id, err)
+                } else {
+                    fmt.Printf("Holiday %d deleted successfully.\n", id)
+                }
+            }
         } else {
             fmt.Println("At least one of --ids or --all is required for 'holiday del' command.")
             fmt.Println(parser.Usage(nil))
             os.Exit(1)
         }
+    case urgencyListCmd.Parsed:
+        ListUrgencyCoefficients(tm, *output)
+    case urgencySetCmd.Parsed:
+        value, parseErr := strconv.ParseFloat(*urgencySetValue, 64)
+        if parseErr != nil {
+            fmt.Printf("Error parsing coefficient value '%s': expected a number\n", *urgencySetValue)
+            fmt.Println(parser.Usage(nil))
+            os.Exit(1)
+        }
+        if err := tm.SetUrgencyCoefficient(*urgencySetName, value); err != nil {
+            log.Fatalf("Error setting urgency coefficient: %v", err)
+        }
+        fmt.Printf("Urgency coefficient %s set to %g.\n", *urgencySetName, value)
+    case urgencyResetCmd.Parsed:
+        if err := tm.ResetUrgencyCoefficient(*urgencyResetName); err != nil {
+            log.Fatalf("Error resetting urgency coefficients: %v", err)
+        }
+        if *urgencyResetName == "" {
+            fmt.Println("All urgency coefficients reset to their defaults.")
+        } else {
+            fmt.Printf("Urgency coefficient %s reset.\n", *urgencyResetName)
+        }
+    case workhoursSetCmd.Parsed && *workhoursSetContext != "":
+        created, err := tm.SetContextHours(*workhoursSetContext, *workhoursSetDay, *workhoursSetStartHour, *workhoursSetStartMinute, *workhoursSetEndHour, *workhoursSetEndMinute)
+        if err != nil {
+            log.Fatalf("Error setting context hours: %v", err)
+        }
+        verb := "updated"
+        if created {
+            verb = "set"
+        }
+        fmt.Printf("Hours of context '%s' %s for day %d (%s) from %02d:%02d to %02d:%02d.\n", *workhoursSetContext, verb, *workhoursSetDay, time.Weekday(*workhoursSetDay).String(), *workhoursSetStartHour, *workhoursSetStartMinute, *workhoursSetEndHour, *workhoursSetEndMinute)
     case workhoursSetCmd.Parsed:
-        tm.SetWorkingHours(*workhoursSetDay, *workhoursSetStartHour, *workhoursSetStartMinute, *workhoursSetEndHour, *workhoursSetEndMinute, *workhoursSetBreakMinutes)
+        created, err := tm.SetWorkingHours(*workhoursSetDay, *workhoursSetStartHour, *workhoursSetStartMinute, *workhoursSetEndHour, *workhoursSetEndMinute, *workhoursSetBreakMinutes)
+        if err != nil {
+            log.Fatalf("Error setting working hours: %v", err)
+        }
+        verb := "updated"
+        if created {
+            verb = "set"
+        }
+        fmt.Printf("Working hours %s for day %d (%s) from %02d:%02d to %02d:%02d with a %d minute break.\n", verb, *workhoursSetDay, time.Weekday(*workhoursSetDay).String(), *workhoursSetStartHour, *workhoursSetStartMinute, *workhoursSetEndHour, *workhoursSetEndMinute, *workhoursSetBreakMinutes)
     case workhoursListCmd.Parsed:
-        ListWorkingHours(tm)
+        ListWorkingHours(tm, *output)
+    case workhoursDelCmd.Parsed && *workhoursDelContext != "":
+        if *workhoursDelAll {
+            count, err := tm.DeleteAllContextHours(*workhoursDelContext)
+            if err != nil {
+                log.Fatalf("Error deleting context hours: %v", err)
+            }
+            fmt.Printf("Deleted %d hour entries of context '%s'.\n", count, *workhoursDelContext)
+        } else if *workhoursDelDays != "" {
+            daysToDelete, parseErr := parseIDs(*workhoursDelDays, nil)
+            if parseErr != nil {
+                fmt.Printf("Error parsing day IDs for context hours: %v\n", parseErr)
+                fmt.Println(parser.Usage(nil))
+                os.Exit(1)
+            }
+            for _, id := range daysToDelete {
+                day := int(id)
+                err := tm.DeleteContextHours(*workhoursDelContext, day)
+                switch {
+                case errors.Is(err, todo.ErrInvalidInput):
+                    fmt.Printf("Skipping invalid day of week %d.\n", day)
+                case errors.Is(err, todo.ErrWorkingHoursNotFound):
+                    fmt.Printf("No hours of context '%s' found for day %d (%s).\n", *workhoursDelContext, day, time.Weekday(day).String())
+                case err != nil:
+                    log.Fatalf("Error deleting context hours: %v", err)
+                default:
+                    fmt.Printf("Hours of context '%s' for day %d (%s) deleted successfully.\n", *workhoursDelContext, day, time.Weekday(day).String())
+                }
+            }
+        } else {
+            fmt.Println("At least one of --days or --all is required for 'workhours del' command.")
+            fmt.Println(parser.Usage(nil))
+            os.Exit(1)
+        }
     case workhoursDelCmd.Parsed: // New case for deleting working hours
         if *workhoursDelAll {
-            tm.DeleteAllWorkingHours()
+            count, err := tm.DeleteAllWorkingHours()
+            if err != nil {
+                log.Printf("Warning: %v", err)
+            }
+            fmt.Printf("Deleted %d working hour entries.\n", count)
         } else if *workhoursDelDays != "" {
-            daysToDelete, parseErr := parseIDs(*workhoursDelDays) // parseIDs works for int64, need to convert to int
+            daysToDelete, parseErr := parseIDs(*workhoursDelDays, nil) // parseIDs works for int64, need to convert to int
             if parseErr != nil {
                 fmt.Printf("Error parsing day IDs for working hours: %v\n", parseErr)
                 fmt.Println(parser.Usage(nil))
                 os.Exit(1)
             }
-            var intDaysToDelete []int
             for _, id := range daysToDelete {
-                intDaysToDelete = append(intDaysToDelete, int(id))
+                day := int(id)
+                err := tm.DeleteWorkingHours(day)
+                switch {
+                case errors.Is(err, todo.ErrInvalidInput):
+                    fmt.Printf("Skipping invalid day of week %d.\n", day)
+                case errors.Is(err, todo.ErrWorkingHoursNotFound):
+                    fmt.Printf("No working hours found for day %d (%s).\n", day, time.Weekday(day).String())
+                case err != nil:
+                    log.Printf("Error deleting working hours for day %d: %v", day, err)
+                default:
+                    fmt.Printf("Working hours for day %d (%s) deleted successfully.\n", day, time.Weekday(day).String())
+                }
             }
-            tm.DeleteWorkingHoursByDays(intDaysToDelete)
         } else {
             fmt.Println("At least one of --days or --all is required for 'workhours del' command.")
             fmt.Println(parser.Usage(nil))
             os.Exit(1)
         }
+    case seriesShowCmd.Parsed:
+        ShowSeries(tm, parseTaskID(tm, *seriesShowID), *seriesShowUpcoming, *output)
+    case seriesEditCmd.Parsed:
+        patch := todo.TaskPatch{
+            Title:            optionalString(seriesEditCmd, "title", seriesEditTitle),
+            Description:      optionalString(seriesEditCmd, "description", seriesEditDesc),
+            Project:          optionalString(seriesEditCmd, "project", seriesEditProject),
+            Recurrence:       optionalString(seriesEditCmd, "recurrence", seriesEditRecurrence),
+            RecurrenceShift:  optionalString(seriesEditCmd, "recurrence-shift", seriesEditRecurrenceShift),
+            RecurrenceAnchor: optionalString(seriesEditCmd, "recurrence-anchor", seriesEditRecurrenceAnchor),
+            AddContexts:      *seriesEditAddContexts,
+            RemoveContexts:   *seriesEditRemoveContexts,
+            AddTags:          *seriesEditAddTags,
+            RemoveTags:       *seriesEditRemoveTags,
+            ClearProject:     *seriesEditClearProject,
+            ClearContexts:    *seriesEditClearContexts,
+            ClearTags:        *seriesEditClearTags,
+        }
+        if seriesEditCmd.GetFlag("recurrence-interval").IsSet {
+            patch.RecurrenceInterval = seriesEditRecurrenceInterval
+        }
+        if seriesEditCmd.GetFlag("contexts").IsSet {
+            patch.Contexts = seriesEditContexts
+        }
+        if seriesEditCmd.GetFlag("tags").IsSet {
+            patch.Tags = seriesEditTags
+        }
+        results, err := tm.EditSeries(parseTaskID(tm, *seriesEditID), patch)
+        if err != nil {
+            log.Fatalf("Error editing series: %v", err)
+        }
+        printUpdateResults(tm, results)
+    case seriesStopCmd.Parsed:
+        stopped, err := tm.StopSeries(parseTaskID(tm, *seriesStopID), *seriesStopCancel)
+        if err != nil {
+            log.Fatalf("Error stopping series: %v", err)
+        }
+        if len(stopped) == 0 {
+            fmt.Println("Series has no open instances; nothing to stop.")
+        }
+        for _, id := range stopped {
+            if *seriesStopCancel {
+                fmt.Printf("Task %d cancelled and no longer recurs.\n", id)
+            } else {
+                fmt.Printf("Task %d no longer recurs.\n", id)
+            }
+        }
+    case seriesSkipCmd.Parsed:
+        skipped, next, err := tm.SkipSeries(parseTaskID(tm, *seriesSkipID))
+        if err != nil {
+            log.Fatalf("Error skipping occurrence: %v", err)
+        }
+        fmt.Printf("Task %d skipped.\n", skipped)
+        if next != 0 {
+            task, err := tm.GetTask(next)
+            if err != nil {
+                log.Fatalf("Error fetching next recurring task: %v", err)
+            }
+            fmt.Printf("Task '%s' added successfully with ID: %d\n", task.Title, task.ID)
+        } else {
+            fmt.Println("The series has ended; no further instance was created.")
+        }
+    case seriesCmd.Parsed:
+        fmt.Println(parser.Usage(nil))
+    case planCmd.Parsed:
+        ShowPlan(tm, todo.TaskFilter{Project: *planProject, Context: *planContext, Tag: *planTag}, *output)
+    case reportAccuracyCmd.Parsed:
+        ShowAccuracyReport(tm, *output)
+    case reportCycleTimeCmd.Parsed:
+        ShowCycleTime(tm, todo.TaskFilter{
+            Project:   *reportCycleTimeProject,
+            Context:   *reportCycleTimeContext,
+            Tag:       *reportCycleTimeTag,
+            EndAfter:  parseFilterDate("from", *reportCycleTimeFrom),
+            EndBefore: parseFilterDate("to", *reportCycleTimeTo),
+        }, *output)
+    case reportBurndownCmd.Parsed:
+        ShowBurndown(tm, todo.TaskFilter{Project: *reportBurndownProject, Context: *reportBurndownContext, Tag: *reportBurndownTag},
+            parseFilterDate("from", *reportBurndownFrom).Time, parseFilterDate("to", *reportBurndownTo).Time, *output)
+    case reportThroughputCmd.Parsed:
+        ShowThroughput(tm, todo.TaskFilter{Project: *reportThroughputProject, Context: *reportThroughputContext, Tag: *reportThroughputTag},
+            *reportThroughputBy, parseFilterDate("from", *reportThroughputFrom).Time, parseFilterDate("to", *reportThroughputTo).Time, *output)
+    case reportCmd.Parsed:
+        fmt.Println(parser.Usage(nil))
+    case startCmd.Parsed:
+        entry, err := tm.StartTimer(parseTaskID(tm, *startID))
+        if err != nil {
+            log.Fatalf("Error starting timer: %v", err)
+        }
+        fmt.Printf("Timer started on task %d at %s.\n", entry.TaskID, entry.Start.Time.Local().Format("15:04"))
+    case stopCmd.Parsed:
+        entry, err := tm.StopTimer()
+        if errors.Is(err, todo.ErrNoTimerRunning) {
+            fmt.Println("No timer is running.")
+            return
+        } else if err != nil {
+            log.Fatalf("Error stopping timer: %v", err)
+        }
+        fmt.Printf("Timer stopped on task %d after %s.\n", entry.TaskID, todo.FormatDuration(entry.Duration(entry.End.Time)))
+    case focusCmd.Parsed:
+        length, err := time.ParseDuration(*focusLength)
+        if err != nil || length <= 0 {
+            log.Fatalf("Error: invalid pomodoro length '%s' (e.g., '25m')", *focusLength)
+        }
+        breakLength, err := time.ParseDuration(*focusBreak)
+        if err != nil || breakLength < 0 {
+            log.Fatalf("Error: invalid break length '%s' (e.g., '5m')", *focusBreak)
+        }
+        if *focusCount < 0 {
+            log.Fatalf("Error: the number of pomodoros cannot be negative")
+        }
+        runFocus(tm, parseTaskID(tm, *focusID), length, breakLength, *focusCount, *focusForce)
+    case logCmd.Parsed:
+        duration, err := time.ParseDuration(*logDuration)
+        if err != nil {
+            log.Fatalf("Error logging time: invalid duration '%s' (e.g., '1h30m', '45m')", *logDuration)
+        }
+        entry, err := tm.LogTime(parseTaskID(tm, *logID), duration, *logStart)
+        if err != nil {
+            log.Fatalf("Error logging time: %v", err)
+        }
+        fmt.Printf("Logged %s on task %d, from %s to %s.\n", todo.FormatDuration(duration), entry.TaskID,
+            entry.Start.Time.Local().Format("2006-01-02 15:04"), entry.End.Time.Local().Format("2006-01-02 15:04"))
     case listProjectsCmd.Parsed:
-        ListProjects(tm)
+        ListProjects(tm, *output)
     case listContextsCmd.Parsed:
-        ListContexts(tm)
+        ListContexts(tm, *output)
     case listTagsCmd.Parsed:
-        ListTags(tm)
+        ListTags(tm, *output)
+    case exportCmd.Parsed:
+        if err := exportTasks(tm, transferFormat(*exportFormat, *exportFile), *exportFile); err != nil {
+            log.Fatalf("Error exporting tasks: %v", err)
+        }
+    case importCmd.Parsed:
+        result, err := importTasks(tm, transferFormat(*importFormat, *importFile), *importFile, *importDryRun)
+        if err != nil {
+            log.Fatalf("Error importing tasks: %v", err)
+        }
+        printImportResult(result)
+    case syncCmd.Parsed:
+        result, err := tm.Sync(*syncDir)
+        if err != nil {
+            log.Fatalf("Error syncing: %v", err)
+        }
+        printSyncResult(result)
+    case dbMigrateCmd.Parsed:
+        applied, err := tm.Migrate()
+        for _, m := range applied {
+            fmt.Printf("Applied migration %d: %s\n", m.Version, m.Name)
+        }
+        if err != nil {
+            log.Fatalf("Error migrating database: %v", err)
+        }
+        if len(applied) == 0 {
+            fmt.Printf("Database schema is up to date (version %d).\n", todo.LatestSchemaVersion())
+        }
+    case dbStatusCmd.Parsed, dbCmd.Parsed:
+        ShowSchemaStatus(tm)
     default:
         fmt.Println(parser.Usage(nil))
     }
 }
 
+// printUpdateResults reports the outcome of an update for every requested task,
+// including any next recurring instance that was created.
+func printUpdateResults(tm *todo.TodoManager, results []todo.UpdateResult) {
+    for _, r := range results {
+        switch {
+        case errors.Is(r.Err, todo.ErrTaskNotFound):
+            fmt.Printf("Task ID %d not found, skipping update.\n", r.TaskID)
+            continue
+        case errors.Is(r.Err, todo.ErrNoChanges):
+            fmt.Printf("No update parameters provided for task ID %d.\n", r.TaskID)
+            continue
+        case errors.Is(r.Err, todo.ErrBlocked):
+            fmt.Printf("Not completed: %v. Use --ignore-blockers to complete it anyway.\n", r.Err)
+            continue
+        }
+        fmt.Printf("Task %d updated successfully.\n", r.TaskID)
+        if r.NextTaskID != 0 {
+            next, err := tm.GetTask(r.NextTaskID)
+            if err != nil {
+                log.Fatalf("Error fetching next recurring task: %v", err)
+            }
+            fmt.Printf("Task '%s' added successfully with ID: %d\n", next.Title, next.ID)
+        }
+    }
+}
+
+// printImportResult prints a summary of an import, or of what a dry run would have created.
+func printImportResult(result *todo.ImportResult) {
+    for _, w := range result.Warnings {
+        fmt.Printf("Warning: %s\n", w)
+    }
+    verb := "Imported"
+    if result.DryRun {
+        verb = "Dry run: would import"
+    }
+    if result.Updated > 0 {
+        fmt.Printf("%s %d new tasks, updated %d existing tasks, and %d notes.\n", verb, result.Tasks, result.Updated, result.Notes)
+    } else {
+        fmt.Printf("%s %d tasks and %d notes.\n", verb, result.Tasks, result.Notes)
+    }
+    if result.Holidays > 0 || result.Skipped > 0 {
+        fmt.Printf("%s %d holidays (%d skipped, already defined).\n", verb, result.Holidays, result.Skipped)
+    }
+    newLabels := []struct {
+        kind  string
+        names []string
+    }{
+        {"projects", result.NewProjects},
+        {"contexts", result.NewContexts},
+        {"tags", result.NewTags},
+    }
+    for _, l := range newLabels {
+        if len(l.names) > 0 {
+            fmt.Printf("  New %s: %s\n", l.kind, strings.Join(l.names, ", "))
+        }
+    }
+}
+
+// printSyncResult prints a summary of a sync and every conflict that was resolved.
+func printSyncResult(result *todo.SyncResult) {
+    fmt.Printf("Synced as device %s: exported %d changes, merged %d changes from %d other devices (%d skipped).\n",
+        shortUUID(result.DeviceID), result.Exported, result.Applied, len(result.Devices), result.Skipped)
+    for _, c := range result.Conflicts {
+        what := fmt.Sprintf("%s '%s' (%s)", c.Entity, c.Title, shortUUID(c.EntityUUID))
+        if c.Field == "" {
+            fmt.Printf("%sConflict:%s %s was changed here but deleted on device %s; deleted.\n", fg_yellow, style_reset, what, shortUUID(c.RemoteDevice))
+            continue
+        }
+        kept, lost := c.LocalValue, c.RemoteValue
+        source := "this device"
+        if c.RemoteWon {
+            kept, lost = c.RemoteValue, c.LocalValue
+            source = "device " + shortUUID(c.RemoteDevice)
+        }
+        fmt.Printf("%sConflict:%s %s %s changed on both devices; kept %s from %s over %s.\n", fg_yellow, style_reset, what, c.Field, kept, source, lost)
+    }
+}
+
+// optionalString returns the value of a string flag if it was given on the command line, or nil.
+// A flag given without a value yields a pointer to an empty string.
+func optionalString(cmd *Command, name string, value *string) *string {
+    if cmd.GetFlag(name).IsSet {
+        return value
+    }
+    return nil
+}
+
+// parseFilterDate parses a date given to a list filter flag.
+// Invalid dates are reported as a warning and the filter is ignored.
+func parseFilterDate(flagName, value string) todo.NullableTime {
+    if value == "" {
+        return todo.NullableTime{}
+    }
+    parsed, err := todo.ParseDateTime(value, time.Local)
+    if err != nil {
+        log.Printf("Warning: Invalid %s date format: %v", flagName, err)
+        return todo.NullableTime{}
+    }
+    return parsed
+}
+
+// numericIDPattern matches an ID or an ID range, which parseIDs never treats as a UUID prefix.
+var numericIDPattern = regexp.MustCompile(`^\d+(\s*-\s*\d+)?$`)
+
+// uuidLookup resolves UUID prefixes to row IDs of tableName for parseIDs.
+func uuidLookup(tm *todo.TodoManager, tableName string) func(string) (int64, error) {
+    return func(prefix string) (int64, error) {
+        return tm.IDByUUIDPrefix(tableName, prefix)
+    }
+}
+
+// dependencyIDs parses the task IDs given to a dependency flag, exiting when they are invalid.
+// An empty value yields no IDs.
+func dependencyIDs(tm *todo.TodoManager, value string) []int64 {
+    if value == "" {
+        return nil
+    }
+    ids, err := parseIDs(value, uuidLookup(tm, "tasks"))
+    if err != nil {
+        log.Fatalf("Error parsing dependencies: %v", err)
+    }
+    sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+    return ids
+}
+
+// parseTaskID resolves a single task ID or UUID prefix given as a positional argument,
+// exiting when it does not name exactly one task.
+func parseTaskID(tm *todo.TodoManager, idStr string) int64 {
+    ids, err := parseIDs(idStr, uuidLookup(tm, "tasks"))
+    if err != nil {
+        log.Fatalf("Error parsing task ID: %v", err)
+    }
+    if len(ids) != 1 {
+        log.Fatalf("Error parsing task ID: '%s' must name exactly one task", idStr)
+    }
+    return ids[0]
+}
+
+// parentID resolves the task given to a --parent flag; an empty value yields 0, no parent.
+func parentID(tm *todo.TodoManager, value string) int64 {
+    if value == "" {
+        return 0
+    }
+    return parseTaskID(tm, value)
+}
+
 // parseIDs parses a comma-separated string of IDs and ID ranges
 // (e.g., "1,3-5,8") into a unique slice of int64 IDs.
 // This function is now generic and can be used for tasks, notes, etc.
-func parseIDs(idStr string) ([]int64, error) {
+// When lookup is not nil, parts that are not numbers or number ranges are
+// treated as UUID prefixes (e.g., "3f2a9c,1-3") and resolved with lookup.
+func parseIDs(idStr string, lookup func(prefix string) (int64, error)) ([]int64, error) {
     uniqueIDs := make(map[int64]bool)
     parts := strings.Split(idStr, ",")
 
@@ -357,7 +1035,14 @@ func parseIDs(idStr string) ([]int64, error) {
             continue
         }
 
-        if strings.Contains(part, "-") {
+        if lookup != nil && !numericIDPattern.MatchString(part) && todo.IsUUIDPrefix(part) {
+            // It's a UUID prefix
+            id, err := lookup(part)
+            if err != nil {
+                return nil, err
+            }
+            uniqueIDs[id] = true
+        } else if strings.Contains(part, "-") {
             // It's a range
             rangeParts := strings.Split(part, "-")
             if len(rangeParts) != 2 {
@@ -417,6 +1102,7 @@ type Command struct {
     Name     string
     Help     string
     Flags    []*Flag
+    Args     []*Flag    // Positional arguments, in order
     Commands []*Command // Added to support subcommands
     Parsed   bool
     parent   *Parser    // Parent is a Parser for top-level commands
@@ -473,6 +1159,17 @@ func (c *Command) Flag(name, short string, opts *Options) *bool {
     return &val
 }
 
+// Arg method for Command struct to define positional string arguments, filled in the order they are defined
+func (c *Command) Arg(name string, opts *Options) *string {
+    var val string
+    if opts != nil && opts.Default != nil {
+        val = opts.Default.(string)
+    }
+    arg := &Flag{Name: name, Value: &val, Options: opts}
+    c.Args = append(c.Args, arg)
+    return &val
+}
+
 // GetFlag retrieves a flag by its name. Used to check IsSet status.
 func (c *Command) GetFlag(name string) *Flag {
     for _, flag := range c.Flags {
@@ -604,6 +1301,7 @@ func (p *Parser) Parse(args []string) error {
     p.parsed = true
 
     flagArgs := remainingArgs[argStartIndex:]
+    argIndex := 0 // Next positional argument to fill
 
     for i := 0; i < len(flagArgs); i++ {
         arg := flagArgs[i]
@@ -613,7 +1311,7 @@ func (p *Parser) Parse(args []string) error {
         if strings.HasPrefix(arg, "--") {
             flagName = strings.TrimPrefix(arg, "--")
             isFlag = true
-        } else if strings.HasPrefix(arg, "-") {
+        } else if _, err := strconv.ParseFloat(arg, 64); strings.HasPrefix(arg, "-") && err != nil { // Negative numbers are values
             flagName = strings.TrimPrefix(arg, "-")
             isFlag = true
         }
@@ -673,17 +1371,27 @@ func (p *Parser) Parse(args []string) error {
             if !foundFlag {
                 return fmt.Errorf("unknown flag: %s", arg)
             }
+        } else if argIndex < len(currentCmd.Args) {
+            positional := currentCmd.Args[argIndex]
+            *positional.Value.(*string) = arg
+            positional.IsSet = true
+            argIndex++
         } else {
             return fmt.Errorf("unexpected argument: %s", arg)
         }
     }
 
-    // Check for required flags
+    // Check for required flags and arguments
     for _, flag := range currentCmd.Flags {
         if flag.Options != nil && flag.Options.Required && !flag.IsSet {
             return fmt.Errorf("required flag --%s is missing", flag.Name)
         }
     }
+    for _, positional := range currentCmd.Args {
+        if positional.Options != nil && positional.Options.Required && !positional.IsSet {
+            return fmt.Errorf("required argument <%s> is missing", positional.Name)
+        }
+    }
 
     return nil
 }
@@ -716,6 +1424,9 @@ func (p *Parser) Usage(err error) string {
     sb.WriteString("Commands:\n")
     for _, cmd := range p.Commands {
         sb.WriteString(fmt.Sprintf("\n  %s%s%s\t%s%s\n", style_bold, fg_green, cmd.Name, style_reset, cmd.Help))
+        for _, positional := range cmd.Args {
+            sb.WriteString(fmt.Sprintf("    <%s>\t%s\n", positional.Name, positional.Options.Help))
+        }
         if len(cmd.Flags) > 0 {
             for _, flag := range cmd.Flags {
                 short := ""
@@ -738,6 +1449,9 @@ func (p *Parser) Usage(err error) string {
             sb.WriteString(fmt.Sprintf("    Subcommands for %s:\n", cmd.Name))
             for _, subCmd := range cmd.Commands {
                 sb.WriteString(fmt.Sprintf("      %s %s\t%s\n", cmd.Name, subCmd.Name, subCmd.Help))
+                for _, positional := range subCmd.Args {
+                    sb.WriteString(fmt.Sprintf("          <%s>\t%s\n", positional.Name, positional.Options.Help))
+                }
                 for _, flag := range subCmd.Flags {
                     short := ""
                     if flag.Short != "" {
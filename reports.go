@@ -0,0 +1,234 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "math"
+    "strings"
+    "time"
+
+    "github.com/igorp74/ToDo/todo"
+)
+
+// ShowAccuracyReport compares the estimates of completed tasks with the working time they
+// took, by project and by tag.
+func ShowAccuracyReport(tm *todo.TodoManager, output string) {
+    report, err := tm.EstimateAccuracy()
+    if err != nil {
+        log.Fatalf("Error computing estimate accuracy: %v", err)
+    }
+    if output != OutputText {
+        records := []accuracyRecord{newAccuracyRecord("total", report.Total)}
+        for _, g := range report.ByProject {
+            records = append(records, newAccuracyRecord("project", g))
+        }
+        for _, g := range report.ByTag {
+            records = append(records, newAccuracyRecord("tag", g))
+        }
+        writeRecords(output, records)
+        return
+    }
+
+    if report.Total.Tasks == 0 {
+        fmt.Println("No completed tasks with an estimate.")
+        return
+    }
+    printAccuracyGroups("Project", "(no project)", report.ByProject)
+    fmt.Println()
+    printAccuracyGroups("Tag", "(no tag)", report.ByTag)
+    fmt.Println()
+    printAccuracyGroups("Total", "All tasks", []todo.AccuracyGroup{report.Total})
+}
+
+// printAccuracyGroups prints a table of accuracy groups. The ratio is red when tasks took
+// longer than estimated and green otherwise.
+func printAccuracyGroups(title, unnamed string, groups []todo.AccuracyGroup) {
+    fmt.Println("------------------------------------------------------------------------------")
+    fmt.Printf("%-24s %6s %9s %12s %12s %8s\n", title, "Tasks", "Overruns", "Estimated", "Actual", "Ratio")
+    fmt.Println("------------------------------------------------------------------------------")
+    for _, g := range groups {
+        name := g.Name
+        if name == "" {
+            name = unnamed
+        }
+        color := fg_green
+        if g.Ratio() > 1 {
+            color = fg_red
+        }
+        fmt.Printf("%-24s %6d %9d %12s %12s %s%8.2f%s\n", name, g.Tasks, g.Overruns,
+            todo.FormatEstimate(g.Estimated), todo.FormatEstimate(g.Actual), color, g.Ratio(), style_reset)
+    }
+}
+
+// chartWidth is the width of the longest bar of a chart, in characters.
+const chartWidth = 50
+
+// ShowBurndown charts the open and completed tasks at the end of every day, open tasks as
+// '█' and completed ones as '░'.
+func ShowBurndown(tm *todo.TodoManager, filter todo.TaskFilter, from, to time.Time, output string) {
+    points, err := tm.Burndown(filter, from, to)
+    if err != nil {
+        log.Fatalf("Error computing burndown: %v", err)
+    }
+    if output != OutputText {
+        records := make([]burndownRecord, 0, len(points))
+        for _, p := range points {
+            records = append(records, burndownRecord{Date: p.Date.Format("2006-01-02"), Open: p.Open, Completed: p.Completed})
+        }
+        writeRecords(output, records)
+        return
+    }
+
+    longest := 0
+    for _, p := range points {
+        if p.Open+p.Completed > longest {
+            longest = p.Open + p.Completed
+        }
+    }
+    fmt.Printf("Burndown%s: %s█%s open  %s░%s completed\n", reportScope(filter), fg_red, style_reset, fg_green, style_reset)
+    fmt.Println("------------------------------------------------------------------------------")
+    fmt.Printf("%-10s %6s %6s\n", "Date", "Open", "Done")
+    fmt.Println("------------------------------------------------------------------------------")
+    for _, p := range points {
+        open := barLength(p.Open, longest)
+        completed := barLength(p.Open+p.Completed, longest) - open
+        fmt.Printf("%-10s %6d %6d  %s%s%s%s%s%s\n", p.Date.Format("2006-01-02"), p.Open, p.Completed,
+            fg_red, strings.Repeat("█", open), style_reset, fg_green, strings.Repeat("░", completed), style_reset)
+    }
+}
+
+// ShowThroughput charts the tasks completed in every day, week or month, along with the
+// tasks added in it and those left open at its end.
+func ShowThroughput(tm *todo.TodoManager, filter todo.TaskFilter, by string, from, to time.Time, output string) {
+    periods, err := tm.Throughput(filter, by, from, to)
+    if err != nil {
+        log.Fatalf("Error computing throughput: %v", err)
+    }
+    if output != OutputText {
+        records := make([]throughputRecord, 0, len(periods))
+        for _, p := range periods {
+            records = append(records, throughputRecord{
+                Start:     p.Start.Format("2006-01-02"),
+                End:       p.End.AddDate(0, 0, -1).Format("2006-01-02"),
+                Added:     p.Added,
+                Completed: p.Completed,
+                Open:      p.Open,
+            })
+        }
+        writeRecords(output, records)
+        return
+    }
+
+    header, layout := map[string]string{"day": "Day", "week": "Week of", "month": "Month"}[by], "2006-01-02"
+    if by == "month" {
+        layout = "2006-01"
+    }
+    longest, total := 0, 0
+    for _, p := range periods {
+        if p.Completed > longest {
+            longest = p.Completed
+        }
+        total += p.Completed
+    }
+    fmt.Printf("Throughput%s: %s█%s completed per %s\n", reportScope(filter), fg_green, style_reset, by)
+    fmt.Println("------------------------------------------------------------------------------")
+    fmt.Printf("%-10s %6s %6s %6s\n", header, "Added", "Done", "Open")
+    fmt.Println("------------------------------------------------------------------------------")
+    for _, p := range periods {
+        fmt.Printf("%-10s %6d %6d %6d  %s%s%s\n", p.Start.Format(layout), p.Added, p.Completed, p.Open,
+            fg_green, strings.Repeat("█", barLength(p.Completed, longest)), style_reset)
+    }
+    fmt.Println("------------------------------------------------------------------------------")
+    fmt.Printf("Average: %.1f completed per %s\n", float64(total)/float64(len(periods)), by)
+}
+
+// reportScope describes the filter of a report, e.g. " of project Work".
+func reportScope(filter todo.TaskFilter) string {
+    scope := ""
+    if filter.Project != "" {
+        scope += fmt.Sprintf(" of project %s", filter.Project)
+    }
+    if filter.Context != "" {
+        scope += fmt.Sprintf(" in context %s", filter.Context)
+    }
+    if filter.Tag != "" {
+        scope += fmt.Sprintf(" tagged %s", filter.Tag)
+    }
+    return scope
+}
+
+// barLength scales a count to the chart width, where longest fills it. Non-zero counts get at
+// least one character.
+func barLength(n, longest int) int {
+    if n <= 0 || longest <= 0 {
+        return 0
+    }
+    length := int(math.Round(float64(n) * chartWidth / float64(longest)))
+    if length == 0 {
+        length = 1
+    }
+    return length
+}
+
+// ShowCycleTime summarizes the lead times of completed tasks and the share of them spent
+// waiting, by project, context and tag, in calendar and in working time.
+func ShowCycleTime(tm *todo.TodoManager, filter todo.TaskFilter, output string) {
+    report, err := tm.CycleTime(filter)
+    if err != nil {
+        log.Fatalf("Error computing cycle time: %v", err)
+    }
+    if output != OutputText {
+        records := []cycleTimeRecord{newCycleTimeRecord("total", report.Total)}
+        for _, g := range report.ByProject {
+            records = append(records, newCycleTimeRecord("project", g))
+        }
+        for _, g := range report.ByContext {
+            records = append(records, newCycleTimeRecord("context", g))
+        }
+        for _, g := range report.ByTag {
+            records = append(records, newCycleTimeRecord("tag", g))
+        }
+        writeRecords(output, records)
+        return
+    }
+
+    if report.Total.Tasks == 0 {
+        fmt.Println("No completed tasks with a start and an end date.")
+        return
+    }
+    printCycleTimeGroups("Project", "(no project)", report.ByProject)
+    fmt.Println()
+    printCycleTimeGroups("Context", "(no context)", report.ByContext)
+    fmt.Println()
+    printCycleTimeGroups("Tag", "(no tag)", report.ByTag)
+    fmt.Println()
+    printCycleTimeGroups("Total", "All tasks", []todo.CycleTimeGroup{report.Total})
+}
+
+// printCycleTimeGroups prints a table of lead time percentiles and waiting shares, calendar
+// time on the left and working time on the right.
+func printCycleTimeGroups(title, unnamed string, groups []todo.CycleTimeGroup) {
+    fmt.Println("------------------------------------------------------------------------------------------------------------")
+    fmt.Printf("%-24s %6s | %-35s | %s\n", "", "", "Calendar time", "Working time")
+    fmt.Printf("%-24s %6s | %8s %8s %8s %8s | %8s %8s %8s %8s\n", title, "Tasks",
+        "Median", "P85", "P95", "Waiting", "Median", "P85", "P95", "Waiting")
+    fmt.Println("------------------------------------------------------------------------------------------------------------")
+    for _, g := range groups {
+        name := g.Name
+        if name == "" {
+            name = unnamed
+        }
+        fmt.Printf("%-24s %6d | %8s %8s %8s %7.0f%% | %8s %8s %8s %7.0f%%\n", name, g.Tasks,
+            shortDuration(g.Calendar.Median), shortDuration(g.Calendar.P85), shortDuration(g.Calendar.P95), g.Calendar.WaitingShare()*100,
+            todo.FormatEstimate(g.Working.Median), todo.FormatEstimate(g.Working.P85), todo.FormatEstimate(g.Working.P95), g.Working.WaitingShare()*100)
+    }
+}
+
+// shortDuration formats a calendar duration with its two largest units, e.g. "3d 4h".
+func shortDuration(d time.Duration) string {
+    parts := strings.Fields(todo.FormatDuration(d.Round(time.Minute)))
+    if len(parts) > 2 {
+        parts = parts[:2]
+    }
+    return strings.Join(parts, " ")
+}
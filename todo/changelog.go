@@ -0,0 +1,590 @@
+package todo
+
+import (
+    "bytes"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "time"
+)
+
+// Every mutation of a task or note made through TodoManager is recorded in the change_log
+// table, one change per modified field. The log is what Sync exchanges between devices:
+// replaying the changes of another device, field by field, merges its edits into this database.
+// Contexts and tags are sets rather than values: each change adds or removes one name, so that
+// names added and removed on different devices are all kept.
+
+// Entities and operations recorded in the change log
+const (
+    EntityTask = "task"
+    EntityNote = "note"
+
+    OpSet    = "set"
+    OpDelete = "delete"
+    OpAdd    = "add"    // adds the name in Value to a contexts or tags field
+    OpRemove = "remove" // removes the name in Value from a contexts or tags field
+)
+
+// Change is a single entry of the change log: the new value of one field of a task or note,
+// a name added to or removed from the contexts or tags of a task, or the deletion of the
+// whole record.
+type Change struct {
+    UUID       string          `json:"uuid"`
+    DeviceID   string          `json:"device"`
+    Seq        int64           `json:"seq"` // Position in the log of DeviceID, starting at 1
+    ChangedAt  time.Time       `json:"changed_at"`
+    Entity     string          `json:"entity"`      // EntityTask or EntityNote
+    EntityUUID string          `json:"entity_uuid"` // UUID of the task or note
+    Op         string          `json:"op"`          // OpSet, OpAdd, OpRemove or OpDelete
+    Field      string          `json:"field,omitempty"`
+    Value      json.RawMessage `json:"value,omitempty"` // JSON encoded; null clears the field
+    Prev       string          `json:"prev,omitempty"`  // UUID of the change to the same field, or name, this one replaces
+}
+
+// taskFields and noteFields list the fields recorded for each entity, in the order they
+// are logged. A record is created by the first change to any of its fields.
+var (
+    taskFields = []string{
+        "title", "description", "project", "status", "start_date", "due_date", "end_date",
+        "recurrence", "recurrence_interval", "start_waiting_date", "end_waiting_date",
+        "original_task", "contexts", "tags", "recurrence_shift", "recurrence_anchor", "depends_on",
+        "parent", "priority", "estimate",
+    }
+    noteFields = []string{"task", "timestamp", "description"}
+
+    // linkFields are the task fields that refer to other tasks by UUID
+    linkFields = []string{"original_task", "parent", "depends_on"}
+
+    // nameFields are the task fields holding a set of names, logged with OpAdd and OpRemove
+    nameFields = []string{"contexts", "tags"}
+)
+
+// DeviceID returns the identifier of this database in the change log, creating it on first use.
+func (tm *TodoManager) DeviceID() (string, error) {
+    return deviceID(tm.db)
+}
+
+func deviceID(q queryer) (string, error) {
+    var id string
+    err := q.QueryRow("SELECT value FROM sync_state WHERE key = 'device_id'").Scan(&id)
+    if err == nil {
+        return id, nil
+    } else if err != sql.ErrNoRows {
+        return "", fmt.Errorf("error reading device ID: %w", err)
+    }
+    if id, err = NewUUID(); err != nil {
+        return "", err
+    }
+    if _, err := q.Exec("INSERT INTO sync_state (key, value) VALUES ('device_id', ?)", id); err != nil {
+        return "", fmt.Errorf("error saving device ID: %w", err)
+    }
+    return id, nil
+}
+
+// jsonValue encodes a field value for the change log.
+func jsonValue(v any) json.RawMessage {
+    b, _ := json.Marshal(v) // Only strings, integers, slices and nil are encoded
+    return b
+}
+
+func jsonNullString(s sql.NullString) json.RawMessage {
+    if !s.Valid {
+        return jsonValue(nil)
+    }
+    return jsonValue(s.String)
+}
+
+func jsonNullInt(n sql.NullInt64) json.RawMessage {
+    if !n.Valid {
+        return jsonValue(nil)
+    }
+    return jsonValue(n.Int64)
+}
+
+func jsonTime(nt NullableTime) json.RawMessage {
+    if !nt.Valid {
+        return jsonValue(nil)
+    }
+    return jsonValue(nt.Time.UTC().Format(time.RFC3339Nano))
+}
+
+func jsonNames(names []string) json.RawMessage {
+    sorted := append([]string{}, names...)
+    sort.Strings(sorted)
+    return jsonValue(sorted)
+}
+
+// taskValues returns the current value of every logged field of a task.
+func taskValues(q queryer, task *Task) (map[string]json.RawMessage, error) {
+    originalTask := sql.NullString{}
+    if task.OriginalTaskID.Valid {
+        err := q.QueryRow("SELECT uuid FROM tasks WHERE id = ?", task.OriginalTaskID.Int64).Scan(&originalTask)
+        if err != nil && err != sql.ErrNoRows {
+            return nil, fmt.Errorf("error looking up original task %d: %w", task.OriginalTaskID.Int64, err)
+        }
+    }
+    parent := sql.NullString{}
+    if task.ParentID.Valid {
+        err := q.QueryRow("SELECT uuid FROM tasks WHERE id = ?", task.ParentID.Int64).Scan(&parent)
+        if err != nil && err != sql.ErrNoRows {
+            return nil, fmt.Errorf("error looking up parent task %d: %w", task.ParentID.Int64, err)
+        }
+    }
+    dependsOn, err := dependencyUUIDs(q, task.ID)
+    if err != nil {
+        return nil, err
+    }
+    return map[string]json.RawMessage{
+        "title":               jsonValue(task.Title),
+        "description":         jsonNullString(task.Description),
+        "project":             jsonNullString(task.ProjectName),
+        "status":              jsonValue(task.Status),
+        "start_date":          jsonTime(task.StartDate),
+        "due_date":            jsonTime(task.DueDate),
+        "end_date":            jsonTime(task.EndDate),
+        "recurrence":          jsonNullString(task.Recurrence),
+        "recurrence_interval": jsonNullInt(task.RecurrenceInterval),
+        "recurrence_shift":    jsonNullString(task.RecurrenceShift),
+        "recurrence_anchor":   jsonNullString(task.RecurrenceAnchor),
+        "start_waiting_date":  jsonTime(task.StartWaitingDate),
+        "end_waiting_date":    jsonTime(task.EndWaitingDate),
+        "original_task":       jsonNullString(originalTask),
+        "contexts":            jsonNames(task.Contexts),
+        "tags":                jsonNames(task.Tags),
+        "depends_on":          jsonValue(dependsOn),
+        "parent":              jsonNullString(parent),
+        "priority":            jsonNullString(task.Priority),
+        "estimate":            jsonNullInt(task.Estimate),
+    }, nil
+}
+
+// logTask records the fields of a task that changed since they were last logged.
+func (tm *TodoManager) logTask(tx *sql.Tx, id int64) error {
+    task, err := getTask(tx, id)
+    if err != nil {
+        return err
+    }
+    if !task.UUID.Valid || task.UUID.String == "" {
+        return nil // Only tasks written by other tools lack a UUID; they are logged once they get one
+    }
+    values, err := taskValues(tx, task)
+    if err != nil {
+        return err
+    }
+    // A link to a task that has not arrived from another device yet is missing here, which is
+    // not a change made on this device
+    latest, _, err := latestChanges(tx, task.UUID.String)
+    if err != nil {
+        return err
+    }
+    for _, field := range linkFields {
+        logged, ok := latest[field]
+        if !ok {
+            continue
+        }
+        resolved, unresolved, err := resolveLinks(tx, field, logged.Value)
+        if err != nil {
+            return err
+        }
+        if unresolved && bytes.Equal(resolved, values[field]) {
+            values[field] = logged.Value
+        }
+    }
+    return tm.logChanges(tx, EntityTask, task.UUID.String, taskFields, values)
+}
+
+// resolveLinks returns the value a link field has in this database when it is set to a logged
+// value: links to tasks unknown here are left out. It also reports whether any link was.
+func resolveLinks(q queryer, field string, value json.RawMessage) (json.RawMessage, bool, error) {
+    known := func(uuid string) (bool, error) {
+        var n int
+        if err := q.QueryRow("SELECT COUNT(*) FROM tasks WHERE uuid = ?", uuid).Scan(&n); err != nil {
+            return false, fmt.Errorf("error looking up task %s: %w", uuid, err)
+        }
+        return n > 0, nil
+    }
+    if field == "depends_on" {
+        var uuids []string
+        if err := json.Unmarshal(value, &uuids); err != nil {
+            return nil, false, fmt.Errorf("invalid dependencies in change log: %w", err)
+        }
+        resolved := []string{}
+        for _, uuid := range uuids {
+            ok, err := known(uuid)
+            if err != nil {
+                return nil, false, err
+            }
+            if ok {
+                resolved = append(resolved, uuid)
+            }
+        }
+        sort.Strings(resolved)
+        return jsonValue(resolved), len(resolved) < len(uuids), nil
+    }
+    var linked *string
+    if err := json.Unmarshal(value, &linked); err != nil {
+        return nil, false, fmt.Errorf("invalid %s in change log: %w", field, err)
+    }
+    if linked == nil {
+        return value, false, nil
+    }
+    ok, err := known(*linked)
+    if err != nil || ok {
+        return value, false, err
+    }
+    return jsonValue(nil), true, nil
+}
+
+// logNote records the fields of a note that changed since they were last logged.
+func (tm *TodoManager) logNote(tx *sql.Tx, id int64) error {
+    var uuid, taskUUID sql.NullString
+    var timestamp sql.NullTime
+    var description string
+    err := tx.QueryRow(`
+        SELECT n.uuid, t.uuid, n.timestamp, n.description
+        FROM task_notes n LEFT JOIN tasks t ON n.task_id = t.id
+        WHERE n.id = ?
+    `, id).Scan(&uuid, &taskUUID, &timestamp, &description)
+    if err == sql.ErrNoRows {
+        return fmt.Errorf("note %d: %w", id, ErrNoteNotFound)
+    } else if err != nil {
+        return fmt.Errorf("error fetching note %d: %w", id, err)
+    }
+    if !uuid.Valid || uuid.String == "" || !taskUUID.Valid {
+        return nil // Notes left behind by a deleted task are not logged
+    }
+    values := map[string]json.RawMessage{
+        "task":        jsonNullString(taskUUID),
+        "timestamp":   jsonTime(NullableTime{Time: timestamp.Time, Valid: timestamp.Valid}),
+        "description": jsonValue(description),
+    }
+    return tm.logChanges(tx, EntityNote, uuid.String, noteFields, values)
+}
+
+// logTaskNotes records the changed fields of every note of a task.
+func (tm *TodoManager) logTaskNotes(tx *sql.Tx, taskID int64) error {
+    noteIDs, err := queryIDs(tx, "SELECT id FROM task_notes WHERE task_id = ?", taskID)
+    if err != nil {
+        return err
+    }
+    for _, id := range noteIDs {
+        if err := tm.logNote(tx, id); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// logDelete records the deletion of a task or note. Its row must still exist.
+// The deletion refers to the latest change of the record, so another device can tell
+// whether the record was changed there since.
+func (tm *TodoManager) logDelete(tx *sql.Tx, entity string, id int64) error {
+    table := "tasks"
+    if entity == EntityNote {
+        table = "task_notes"
+    }
+    var uuid sql.NullString
+    if err := tx.QueryRow(fmt.Sprintf("SELECT uuid FROM %s WHERE id = ?", table), id).Scan(&uuid); err != nil {
+        if err == sql.ErrNoRows {
+            return nil // Reported as not found by the caller
+        }
+        return fmt.Errorf("error fetching %s %d: %w", entity, id, err)
+    }
+    if !uuid.Valid || uuid.String == "" {
+        return nil
+    }
+    latest, _, err := latestChanges(tx, uuid.String)
+    if err != nil {
+        return err
+    }
+    return tm.appendLocalChanges(tx, []Change{{Entity: entity, EntityUUID: uuid.String, Op: OpDelete, Prev: newestChange(latest).UUID}})
+}
+
+// logChanges appends a change for every field whose value differs from its last logged value.
+func (tm *TodoManager) logChanges(tx *sql.Tx, entity, entityUUID string, fields []string, values map[string]json.RawMessage) error {
+    latest, deleted, err := latestChanges(tx, entityUUID)
+    if err != nil {
+        return err
+    }
+    if deleted {
+        return nil // Records deleted on another device are not brought back
+    }
+    var changes []Change
+    for _, field := range fields {
+        if entity == EntityTask && contains(nameFields, field) {
+            added, err := nameChanges(tx, entityUUID, field, values[field])
+            if err != nil {
+                return err
+            }
+            changes = append(changes, added...)
+            continue
+        }
+        prev, ok := latest[field]
+        if ok && bytes.Equal(prev.Value, values[field]) {
+            continue
+        }
+        changes = append(changes, Change{Entity: entity, EntityUUID: entityUUID, Op: OpSet, Field: field, Value: values[field], Prev: prev.UUID})
+    }
+    return tm.appendLocalChanges(tx, changes)
+}
+
+// nameState is whether a name is in a contexts or tags field, and the change that decided it.
+type nameState struct {
+    member bool
+    change Change
+}
+
+// loggedNames replays the changes of a contexts or tags field of a task, oldest first, and
+// returns the state of every name they mention. A change that sets the whole list, as
+// earlier versions logged them, adds the names in the list and removes all others.
+func loggedNames(q queryer, entityUUID, field string) (map[string]nameState, error) {
+    rows, err := q.Query(changeSelect+" WHERE entity_uuid = ? AND field = ? ORDER BY changed_at, device_id, seq", entityUUID, field)
+    if err != nil {
+        return nil, fmt.Errorf("error reading change log: %w", err)
+    }
+    changes, err := scanChanges(rows)
+    if err != nil {
+        return nil, err
+    }
+    names := map[string]nameState{}
+    for _, c := range changes {
+        if c.Op == OpSet {
+            var list []string
+            if err := json.Unmarshal(c.Value, &list); err != nil {
+                return nil, fmt.Errorf("invalid %s in change log: %w", field, err)
+            }
+            for name := range names {
+                names[name] = nameState{false, c}
+            }
+            for _, name := range list {
+                names[name] = nameState{true, c}
+            }
+            continue
+        }
+        var name string
+        if err := json.Unmarshal(c.Value, &name); err != nil {
+            return nil, fmt.Errorf("invalid %s in change log: %w", field, err)
+        }
+        names[name] = nameState{c.Op == OpAdd, c}
+    }
+    return names, nil
+}
+
+// nameChanges returns the changes that turn the logged names of a contexts or tags field into
+// the names of value: an OpAdd for every new name and an OpRemove for every name that is gone.
+func nameChanges(q queryer, entityUUID, field string, value json.RawMessage) ([]Change, error) {
+    var current []string
+    if err := json.Unmarshal(value, &current); err != nil {
+        return nil, fmt.Errorf("invalid %s: %w", field, err)
+    }
+    logged, err := loggedNames(q, entityUUID, field)
+    if err != nil {
+        return nil, err
+    }
+    var changes []Change
+    for _, name := range current {
+        if state := logged[name]; !state.member {
+            changes = append(changes, Change{Entity: EntityTask, EntityUUID: entityUUID, Op: OpAdd, Field: field, Value: jsonValue(name), Prev: state.change.UUID})
+        }
+    }
+    var removed []string
+    for name, state := range logged {
+        if state.member && !contains(current, name) {
+            removed = append(removed, name)
+        }
+    }
+    sort.Strings(removed)
+    for _, name := range removed {
+        changes = append(changes, Change{Entity: EntityTask, EntityUUID: entityUUID, Op: OpRemove, Field: field, Value: jsonValue(name), Prev: logged[name].change.UUID})
+    }
+    return changes, nil
+}
+
+// appendLocalChanges stamps changes made on this device with a UUID, the next sequence
+// numbers and a common timestamp, and appends them to the log.
+func (tm *TodoManager) appendLocalChanges(tx *sql.Tx, changes []Change) error {
+    if len(changes) == 0 {
+        return nil
+    }
+    device, err := deviceID(tx)
+    if err != nil {
+        return err
+    }
+    var seq, last int64
+    if err := tx.QueryRow("SELECT COALESCE(MAX(seq), 0) FROM change_log WHERE device_id = ?", device).Scan(&seq); err != nil {
+        return fmt.Errorf("error reading change log: %w", err)
+    }
+    if err := tx.QueryRow("SELECT COALESCE(MAX(changed_at), 0) FROM change_log").Scan(&last); err != nil {
+        return fmt.Errorf("error reading change log: %w", err)
+    }
+    // Never go back in time, even when this clock is behind the devices whose changes were merged
+    now := time.Now().UTC()
+    if now.UnixNano() <= last {
+        now = time.Unix(0, last+1).UTC()
+    }
+
+    for _, c := range changes {
+        seq++
+        if c.UUID, err = NewUUID(); err != nil {
+            return err
+        }
+        c.DeviceID, c.Seq, c.ChangedAt = device, seq, now
+        if err := insertChange(tx, c); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// insertChange appends a change, made on this device or merged from another one, to the log.
+func insertChange(q queryer, c Change) error {
+    _, err := q.Exec(`
+        INSERT INTO change_log (uuid, device_id, seq, changed_at, entity, entity_uuid, op, field, value, prev_uuid)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `, c.UUID, c.DeviceID, c.Seq, c.ChangedAt.UnixNano(), c.Entity, c.EntityUUID, c.Op, c.Field,
+        sql.NullString{String: string(c.Value), Valid: c.Value != nil},
+        sql.NullString{String: c.Prev, Valid: c.Prev != ""})
+    if err != nil {
+        return fmt.Errorf("error writing change log: %w", err)
+    }
+    return nil
+}
+
+const changeSelect = `
+    SELECT uuid, device_id, seq, changed_at, entity, entity_uuid, op, field, value, prev_uuid
+    FROM change_log
+`
+
+// scanChanges reads the rows produced by changeSelect.
+func scanChanges(rows *sql.Rows) ([]Change, error) {
+    defer rows.Close()
+    changes := []Change{}
+    for rows.Next() {
+        var c Change
+        var changedAt int64
+        var value, prev sql.NullString
+        if err := rows.Scan(&c.UUID, &c.DeviceID, &c.Seq, &changedAt, &c.Entity, &c.EntityUUID, &c.Op, &c.Field, &value, &prev); err != nil {
+            return nil, fmt.Errorf("error scanning change log: %w", err)
+        }
+        c.ChangedAt = time.Unix(0, changedAt).UTC()
+        if value.Valid {
+            c.Value = json.RawMessage(value.String)
+        }
+        c.Prev = prev.String
+        changes = append(changes, c)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("error reading change log: %w", err)
+    }
+    return changes, nil
+}
+
+// latestChanges returns the winning change of every field of a task or note, and whether
+// the record has been deleted.
+func latestChanges(q queryer, entityUUID string) (map[string]Change, bool, error) {
+    rows, err := q.Query(changeSelect+" WHERE entity_uuid = ? ORDER BY changed_at, device_id, seq", entityUUID)
+    if err != nil {
+        return nil, false, fmt.Errorf("error reading change log: %w", err)
+    }
+    changes, err := scanChanges(rows)
+    if err != nil {
+        return nil, false, err
+    }
+    latest := map[string]Change{}
+    deleted := false
+    for _, c := range changes {
+        if c.Op == OpDelete {
+            deleted = true
+            continue
+        }
+        latest[c.Field] = c
+    }
+    return latest, deleted, nil
+}
+
+// newestChange returns the most recent of a set of changes, or a zero Change if there are none.
+func newestChange(changes map[string]Change) Change {
+    var newest Change
+    for _, c := range changes {
+        if newest.UUID == "" || changeBefore(newest, c) {
+            newest = c
+        }
+    }
+    return newest
+}
+
+// ChangesSince returns the changes made on a device with a sequence number above seq, in order.
+func (tm *TodoManager) ChangesSince(device string, seq int64) ([]Change, error) {
+    rows, err := tm.db.Query(changeSelect+" WHERE device_id = ? AND seq > ? ORDER BY seq", device, seq)
+    if err != nil {
+        return nil, fmt.Errorf("error reading change log: %w", err)
+    }
+    return scanChanges(rows)
+}
+
+// logAll records every task and note that changed without going through TodoManager (or
+// before the change log existed), and the deletion of logged records that no longer exist.
+func (tm *TodoManager) logAll(tx *sql.Tx) error {
+    for _, entity := range []struct{ name, table string }{{EntityTask, "tasks"}, {EntityNote, "task_notes"}} {
+        ids, err := queryIDs(tx, fmt.Sprintf("SELECT id FROM %s ORDER BY id", entity.table))
+        if err != nil {
+            return err
+        }
+        for _, id := range ids {
+            if entity.name == EntityTask {
+                err = tm.logTask(tx, id)
+            } else {
+                err = tm.logNote(tx, id)
+            }
+            if err != nil {
+                return err
+            }
+        }
+
+        rows, err := tx.Query(fmt.Sprintf(`
+            SELECT DISTINCT c.entity_uuid FROM change_log c
+            WHERE c.entity = ?
+              AND NOT EXISTS (SELECT 1 FROM %s r WHERE r.uuid = c.entity_uuid)
+              AND NOT EXISTS (SELECT 1 FROM change_log d WHERE d.entity_uuid = c.entity_uuid AND d.op = 'delete')
+        `, entity.table), entity.name)
+        if err != nil {
+            return fmt.Errorf("error reading change log: %w", err)
+        }
+        var missing []Change
+        for rows.Next() {
+            c := Change{Entity: entity.name, Op: OpDelete}
+            if err := rows.Scan(&c.EntityUUID); err != nil {
+                rows.Close()
+                return fmt.Errorf("error scanning change log: %w", err)
+            }
+            missing = append(missing, c)
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+            return fmt.Errorf("error reading change log: %w", err)
+        }
+        if err := tm.appendLocalChanges(tx, missing); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// queryIDs returns the integer IDs selected by a query.
+func queryIDs(q queryer, query string, args ...any) ([]int64, error) {
+    rows, err := q.Query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("error reading IDs: %w", err)
+    }
+    defer rows.Close()
+    var ids []int64
+    for rows.Next() {
+        var id int64
+        if err := rows.Scan(&id); err != nil {
+            return nil, fmt.Errorf("error scanning ID: %w", err)
+        }
+        ids = append(ids, id)
+    }
+    return ids, rows.Err()
+}
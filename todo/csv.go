@@ -0,0 +1,243 @@
+package todo
+
+import (
+    "database/sql"
+    "encoding/csv"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// csvColumns lists the columns written by WriteTasksCSV, in order.
+// ReadTasksCSV matches columns by header name, so they may be reordered or omitted (except title).
+var csvColumns = []string{
+    "id", "uuid", "title", "description", "project", "status", "priority", "estimate_minutes",
+    "start_date", "due_date", "end_date", "recurrence", "recurrence_interval", "recurrence_shift",
+    "recurrence_anchor",
+    "start_waiting_date", "end_waiting_date", "original_task_id", "depends_on",
+    "parent_id",
+    "contexts", "tags", "notes",
+}
+
+// csvFields maps columns to the task fields they hold; an import updates the fields of the
+// columns a file has.
+var csvFields = map[string]TaskFields{
+    "description": FieldDescription, "project": FieldProject, "status": FieldStatus, "priority": FieldPriority,
+    "estimate_minutes": FieldEstimate, "start_date": FieldStartDate, "due_date": FieldDueDate, "end_date": FieldEndDate,
+    "recurrence": FieldRecurrence, "recurrence_shift": FieldRecurrenceShift, "recurrence_anchor": FieldRecurrenceAnchor,
+    "start_waiting_date": FieldStartWaiting, "end_waiting_date": FieldEndWaiting, "contexts": FieldContexts, "tags": FieldTags,
+}
+
+// WriteTasksCSV writes tasks as CSV with a header row. Use ',' as the separator for CSV and '\t' for TSV.
+//
+// Dates are written in RFC 3339 format, contexts and tags as comma-separated names,
+// dependencies as comma-separated task IDs, and notes
+// one per line as "<RFC 3339 timestamp> <description>", with newlines and backslashes in the
+// description escaped as \n and \\.
+func WriteTasksCSV(w io.Writer, tasks []Task, comma rune) error {
+    cw := csv.NewWriter(w)
+    cw.Comma = comma
+    if err := cw.Write(csvColumns); err != nil {
+        return fmt.Errorf("error writing header: %w", err)
+    }
+    for _, task := range tasks {
+        notes := make([]string, 0, len(task.Notes))
+        for _, n := range task.Notes {
+            notes = append(notes, formatCSVTime(n.Timestamp)+" "+escapeNote(n.Description.String))
+        }
+        record := []string{
+            strconv.FormatInt(task.ID, 10),
+            task.UUID.String,
+            task.Title,
+            task.Description.String,
+            task.ProjectName.String,
+            task.Status,
+            task.Priority.String,
+            formatCSVInt(task.Estimate),
+            formatCSVTime(task.StartDate),
+            formatCSVTime(task.DueDate),
+            formatCSVTime(task.EndDate),
+            task.Recurrence.String,
+            formatCSVInt(task.RecurrenceInterval),
+            task.RecurrenceShift.String,
+            task.RecurrenceAnchor.String,
+            formatCSVTime(task.StartWaitingDate),
+            formatCSVTime(task.EndWaitingDate),
+            formatCSVInt(task.OriginalTaskID),
+            formatIDs(task.DependsOn, ","),
+            formatCSVInt(task.ParentID),
+            strings.Join(task.Contexts, ","),
+            strings.Join(task.Tags, ","),
+            strings.Join(notes, "\n"),
+        }
+        if err := cw.Write(record); err != nil {
+            return fmt.Errorf("error writing task %d: %w", task.ID, err)
+        }
+    }
+    cw.Flush()
+    return cw.Error()
+}
+
+// ReadTasksCSV reads tasks written by WriteTasksCSV, ready for ImportTasks, and returns the
+// fields of the columns in the header. Rows with the UUID of an existing task update those
+// fields of that task when imported.
+// Dates may also be given in any format accepted by ParseDateTime (in local time), which
+// helps with files edited in a spreadsheet. Every problem in the file is reported at once
+// in a ValidationError.
+func ReadTasksCSV(r io.Reader, comma rune) ([]Task, TaskFields, error) {
+    cr := csv.NewReader(r)
+    cr.Comma = comma
+    cr.FieldsPerRecord = -1 // Checked below with a clearer message
+
+    header, err := cr.Read()
+    if err == io.EOF {
+        return []Task{}, 0, nil
+    } else if err != nil {
+        return nil, 0, fmt.Errorf("error reading header: %w", err)
+    }
+    columns := map[string]int{}
+    var fields TaskFields
+    for i, name := range header {
+        name = strings.ToLower(strings.TrimSpace(name))
+        columns[name] = i
+        fields |= csvFields[name]
+    }
+    if _, ok := columns["title"]; !ok {
+        return nil, 0, fmt.Errorf("%w: missing 'title' column", ErrInvalidInput)
+    }
+
+    var p problems
+    tasks := []Task{}
+    for {
+        record, err := cr.Read()
+        if err == io.EOF {
+            break
+        } else if err != nil {
+            return nil, 0, fmt.Errorf("error reading tasks: %w", err)
+        }
+        line, _ := cr.FieldPos(0)
+        if len(record) != len(header) {
+            p.add("line %d: expected %d fields, got %d", line, len(header), len(record))
+            continue
+        }
+        field := func(name string) string {
+            if i, ok := columns[name]; ok {
+                return strings.TrimSpace(record[i])
+            }
+            return ""
+        }
+        at := fmt.Sprintf("line %d", line)
+
+        task := Task{
+            Title:              field("title"),
+            UUID:               nullString(field("uuid")),
+            Description:        nullString(field("description")),
+            ProjectName:        nullString(field("project")),
+            Status:             field("status"),
+            Priority:           nullString(normalizePriority(field("priority"))),
+            Estimate:           p.csvInt(at, "estimate_minutes", field("estimate_minutes")),
+            StartDate:          p.csvTime(at, "start_date", field("start_date")),
+            DueDate:            p.csvTime(at, "due_date", field("due_date")),
+            EndDate:            p.csvTime(at, "end_date", field("end_date")),
+            Recurrence:         nullString(field("recurrence")),
+            RecurrenceInterval: p.csvInt(at, "recurrence_interval", field("recurrence_interval")),
+            RecurrenceShift:    nullString(normalizeShift(field("recurrence_shift"))),
+            RecurrenceAnchor:   nullString(normalizeAnchor(field("recurrence_anchor"))),
+            StartWaitingDate:   p.csvTime(at, "start_waiting_date", field("start_waiting_date")),
+            EndWaitingDate:     p.csvTime(at, "end_waiting_date", field("end_waiting_date")),
+            OriginalTaskID:     p.csvInt(at, "original_task_id", field("original_task_id")),
+            ParentID:           p.csvInt(at, "parent_id", field("parent_id")),
+            Contexts:           splitNames(field("contexts")),
+            Tags:               splitNames(field("tags")),
+        }
+        if id := p.csvInt(at, "id", field("id")); id.Valid {
+            task.ID = id.Int64
+        }
+        if _, ok := columns["depends_on"]; ok {
+            task.DependsOn = []int64{}
+            for _, dep := range splitNames(field("depends_on")) {
+                if id := p.csvInt(at, "depends_on", dep); id.Valid {
+                    task.DependsOn = append(task.DependsOn, id.Int64)
+                }
+            }
+        }
+        for _, n := range strings.Split(field("notes"), "\n") {
+            if strings.TrimSpace(n) == "" {
+                continue
+            }
+            timestamp, description, _ := strings.Cut(n, " ")
+            task.Notes = append(task.Notes, Note{
+                Timestamp:   p.csvTime(at, "notes", timestamp),
+                Description: sql.NullString{String: unescapeNote(description), Valid: true},
+            })
+        }
+        tasks = append(tasks, task)
+    }
+    return tasks, fields, p.err()
+}
+
+func formatCSVTime(nt NullableTime) string {
+    if !nt.Valid {
+        return ""
+    }
+    return nt.Time.UTC().Format(time.RFC3339)
+}
+
+func formatCSVInt(n sql.NullInt64) string {
+    if !n.Valid {
+        return ""
+    }
+    return strconv.FormatInt(n.Int64, 10)
+}
+
+// csvTime parses an RFC 3339 date, falling back to the formats accepted by ParseDateTime.
+func (p *problems) csvTime(at, column, value string) NullableTime {
+    if value == "" {
+        return NullableTime{}
+    }
+    if t, err := time.Parse(time.RFC3339, value); err == nil {
+        return NullableTime{Time: t.UTC(), Valid: true}
+    }
+    parsed, err := ParseDateTime(value, time.Local)
+    if err != nil {
+        *p = append(*p, &DateError{Field: at + " " + column, Value: value, Err: err})
+    }
+    return parsed
+}
+
+func (p *problems) csvInt(at, column, value string) sql.NullInt64 {
+    if value == "" {
+        return sql.NullInt64{}
+    }
+    n, err := strconv.ParseInt(value, 10, 64)
+    if err != nil {
+        p.add("%s: invalid %s '%s'", at, column, value)
+        return sql.NullInt64{}
+    }
+    return sql.NullInt64{Int64: n, Valid: true}
+}
+
+func nullString(s string) sql.NullString {
+    return sql.NullString{String: s, Valid: s != ""}
+}
+
+// splitNames splits a comma-separated list of contexts or tags, dropping empty entries.
+func splitNames(s string) []string {
+    names := []string{}
+    for _, name := range strings.Split(s, ",") {
+        if name = strings.TrimSpace(name); name != "" {
+            names = append(names, name)
+        }
+    }
+    return names
+}
+
+var (
+    noteEscaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+    noteUnescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+func escapeNote(s string) string   { return noteEscaper.Replace(s) }
+func unescapeNote(s string) string { return noteUnescaper.Replace(s) }
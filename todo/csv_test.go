@@ -0,0 +1,133 @@
+package todo
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+func TestReadTasksCSV(t *testing.T) {
+    // Columns in another order than WriteTasksCSV writes them, and a date as typed in a spreadsheet
+    const file = `Tags;Title;due_date;notes;contexts;id;original_task_id;depends_on
+ home , ,garden;water plants;2026-03-04;"2026-03-01T09:00:00Z ask \\ the neighbour\nor not
+2026-03-02T09:00:00Z done";phone;7;3;1, 2
+;pay rent;;;;;;
+`
+    tasks, fields, err := ReadTasksCSV(strings.NewReader(file), ';')
+    if err != nil {
+        t.Fatal(err)
+    }
+    if fields != FieldDueDate|FieldContexts|FieldTags {
+        t.Errorf("got fields %b", fields)
+    }
+    if len(tasks) != 2 {
+        t.Fatalf("got %d tasks", len(tasks))
+    }
+    first := tasks[0]
+    tests := []struct {
+        name      string
+        got, want string
+    }{
+        {"title", first.Title, "water plants"},
+        {"tags", fmt.Sprint(first.Tags), "[home garden]"},
+        {"contexts", fmt.Sprint(first.Contexts), "[phone]"},
+        {"due date", fmt.Sprint(first.DueDate.Time.Equal(localTime(t, "2026-03-04 00:00"))), "true"},
+        {"id", fmt.Sprint(first.ID), "7"},
+        {"original task", fmt.Sprint(first.OriginalTaskID.Int64), "3"},
+        {"dependencies", fmt.Sprint(first.DependsOn), "[1 2]"},
+        {"notes", fmt.Sprint(len(first.Notes)), "2"},
+        {"note", first.Notes[0].Description.String, "ask \\ the neighbour\nor not"},
+        {"note time", first.Notes[1].Timestamp.Time.Format("2006-01-02 15:04"), "2026-03-02 09:00"},
+        {"empty row", fmt.Sprintf("%s %v %v %v %d", tasks[1].Title, tasks[1].Tags, tasks[1].DependsOn, tasks[1].DueDate.Valid, len(tasks[1].Notes)), "pay rent [] [] false 0"},
+    }
+    for _, tt := range tests {
+        if tt.got != tt.want {
+            t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+        }
+    }
+}
+
+func TestReadTasksCSVErrors(t *testing.T) {
+    tests := []struct {
+        name     string
+        file     string
+        problems int // 0 for an error that is not a ValidationError
+        date     bool
+    }{
+        {"no title column", "description\nabout the leak\n", 0, false},
+        {"wrong number of fields", "title,project\ncall plumber\n", 1, false},
+        {"bad date", "title,due_date\ncall plumber,tomorrow\n", 1, true},
+        {"bad number", "title,estimate_minutes\ncall plumber,an hour\n", 1, false},
+        {"every problem reported", "title,due_date,id\ncall plumber,tomorrow,one\npay rent\n", 3, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, _, err := ReadTasksCSV(strings.NewReader(tt.file), ',')
+            if !errors.Is(err, ErrInvalidInput) {
+                t.Fatalf("got %v, want ErrInvalidInput", err)
+            }
+            var v *ValidationError
+            if tt.problems > 0 && (!errors.As(err, &v) || len(v.Problems) != tt.problems) {
+                t.Errorf("got %v, want %d problems", err, tt.problems)
+            }
+            if errors.Is(err, ErrInvalidDate) != tt.date {
+                t.Errorf("%v matches ErrInvalidDate: %v, want %v", err, !tt.date, tt.date)
+            }
+        })
+    }
+}
+
+func TestImportTasksCSV(t *testing.T) {
+    // The IDs of the file are taken by other tasks in the database
+    const file = `id,title,project,original_task_id,parent_id,depends_on,contexts,tags,notes
+11,move house,home,,,,,,
+12,pack,home,,11,13,,boxes,2026-03-01T09:00:00Z start with books
+13,hire a van,,,11,,phone,,
+14,hire a van,,13,11,99,phone,,
+`
+    for _, dryRun := range []bool{true, false} {
+        t.Run(fmt.Sprintf("dry run %v", dryRun), func(t *testing.T) {
+            tm := newTestManager(t)
+            for _, title := range []string{"water plants", "pay rent"} {
+                if _, err := tm.AddTask(TaskInput{Title: title}); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            tasks, fields, err := ReadTasksCSV(strings.NewReader(file), ',')
+            if err != nil {
+                t.Fatal(err)
+            }
+            result, err := tm.ImportTasks(tasks, fields, dryRun)
+            if err != nil {
+                t.Fatalf("ImportTasks: %v", err)
+            }
+            if result.Tasks != 4 || result.Notes != 1 || len(result.Warnings) != 1 ||
+                fmt.Sprint(result.NewProjects, result.NewContexts, result.NewTags) != "[home] [phone] [boxes]" {
+                t.Errorf("got %+v", result)
+            }
+
+            all, err := tm.ExportTasks()
+            if err != nil {
+                t.Fatal(err)
+            }
+            if dryRun {
+                if len(all) != 2 {
+                    t.Errorf("dry run left %d tasks", len(all))
+                }
+                return
+            }
+            if len(all) != 6 {
+                t.Fatalf("got %d tasks", len(all))
+            }
+            id := result.IDMap
+            pack, van, again := all[3], all[4], all[5]
+            if pack.ParentID.Int64 != id[11] || fmt.Sprint(pack.DependsOn) != fmt.Sprint([]int64{id[13]}) || len(pack.Notes) != 1 {
+                t.Errorf("pack: got parent %d, dependencies %v and notes %v", pack.ParentID.Int64, pack.DependsOn, pack.Notes)
+            }
+            if van.ParentID.Int64 != id[11] || again.OriginalTaskID.Int64 != id[13] || len(again.DependsOn) != 0 {
+                t.Errorf("got parent %d, original task %d and dependencies %v", van.ParentID.Int64, again.OriginalTaskID.Int64, again.DependsOn)
+            }
+        })
+    }
+}
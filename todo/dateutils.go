@@ -1,8 +1,9 @@
-package main
+package todo
 
 import (
     "database/sql"
     "fmt"
+    "strconv"
     "strings"
     "time"
 )
@@ -39,6 +40,33 @@ func ParseDateTime(dateTimeStr string, loc *time.Location) (NullableTime, error)
     return NullableTime{Valid: false}, fmt.Errorf("could not parse date/time '%s'. Please use YYYY-MM-DD HH:MM:SS, YYYY-MM-DD, MM-DD-YYYY, or DD-MM-YYYY format", dateTimeStr)
 }
 
+// ParseWindowEnd parses the length of a window that starts at from, given as a number of
+// days, weeks or months ("30d", "2w", "3m") or as the date it ends on, and returns the end
+// of its last day.
+func ParseWindowEnd(window string, from time.Time) (time.Time, error) {
+    window = strings.ToLower(strings.TrimSpace(window))
+    from = from.Local()
+    var end time.Time
+    if n, err := strconv.Atoi(strings.TrimRight(window, "dwm")); err == nil && n > 0 && len(window) == len(strconv.Itoa(n))+1 {
+        switch window[len(window)-1] {
+        case 'd':
+            end = from.AddDate(0, 0, n)
+        case 'w':
+            end = from.AddDate(0, 0, 7*n)
+        case 'm':
+            end = from.AddDate(0, n, 0)
+        }
+    }
+    if end.IsZero() {
+        date, err := ParseDateTime(window, time.Local)
+        if err != nil || !date.Valid {
+            return time.Time{}, &DateError{Field: "window", Value: window, Err: fmt.Errorf("expected a number of days, weeks or months (e.g., 30d, 2w, 3m) or an end date")}
+        }
+        end = date.Time.Local()
+    }
+    return time.Date(end.Year(), end.Month(), end.Day(), 23, 59, 59, 0, time.Local), nil
+}
+
 // FormatDuration formats a time.Duration into a human-readable string (days, hours, minutes, seconds),
 // skipping any components that are zero. This is for general calendar duration.
 func FormatDuration(d time.Duration) string { // Renamed to FormatDuration
@@ -275,47 +303,53 @@ func CalculateWorkingHoursDuration(db *sql.DB, start, end NullableTime, workingH
     // Iterate through each day from startDate to endDate (inclusive for the end day if it falls within working hours)
     // Adding 24 * time.Hour to endDate ensures the end day is also considered if it has working hours.
     for currentDay.Before(endDate.Add(24 * time.Hour).Truncate(24 * time.Hour)) {
-        dateKey := currentDay.Format("2006-01-02")
-        if _, isHol := holidays[dateKey]; isHol {
-            currentDay = currentDay.Add(24 * time.Hour)
-            continue // Skip holidays
-        }
+        totalWorkingDuration += workingTimeOnDay(currentDay, startDate, endDate, workingHours, holidays)
+        currentDay = currentDay.Add(24 * time.Hour)
+    }
 
-        dayOfWeek := currentDay.Weekday()
-        wh, hasWorkingHours := workingHours[dayOfWeek]
+    return totalWorkingDuration
+}
 
-        // Check if working hours are defined and if there's a valid working period for the day
-        if hasWorkingHours && (wh.StartHour*60+wh.StartMinute < wh.EndHour*60+wh.EndMinute) {
-            // Create daily working hour times in the current day's location (Local)
-            dailyWorkStart := time.Date(currentDay.Year(), currentDay.Month(), currentDay.Day(), wh.StartHour, wh.StartMinute, 0, 0, currentDay.Location())
-            dailyWorkEnd := time.Date(currentDay.Year(), currentDay.Month(), currentDay.Day(), wh.EndHour, wh.EndMinute, 0, 0, currentDay.Location())
+// workingTimeOnDay returns the working time of a day that falls between startDate and endDate,
+// minus the break of the day. Holidays and days without working hours have none.
+func workingTimeOnDay(currentDay, startDate, endDate time.Time, workingHours map[time.Weekday]WorkingHours, holidays map[string]Holiday) time.Duration {
+    dateKey := currentDay.Format("2006-01-02")
+    if _, isHol := holidays[dateKey]; isHol {
+        return 0 // Skip holidays
+    }
 
-            // Handle overnight shifts (e.g., 22:00 to 06:00 next day)
-            if dailyWorkEnd.Before(dailyWorkStart) {
-                dailyWorkEnd = dailyWorkEnd.Add(24 * time.Hour)
-            }
+    dailyWorkStart, dailyWorkEnd, ok := workingWindow(currentDay, workingHours)
+    if !ok {
+        return 0
+    }
 
-            // Calculate the intersection of the task's overall time range and the current day's working hours
-            effectiveIntersectionStart := MaxTime(startDate, dailyWorkStart)
-            effectiveIntersectionEnd := MinTime(endDate, dailyWorkEnd)
-
-            // Add the duration of the intersection to the total working duration
-            if effectiveIntersectionStart.Before(effectiveIntersectionEnd) {
-                dailyWorkingTime := effectiveIntersectionEnd.Sub(effectiveIntersectionStart)
-                // Subtract break duration if the working period for the day is substantial enough to include a break
-                breakDuration := time.Duration(wh.BreakMinutes) * time.Minute
-                if dailyWorkingTime > breakDuration { // Corrected logic: if there's enough time to work AFTER the break
-                    dailyWorkingTime -= breakDuration
-                } else { // If the working time is less than or equal to the break duration
-                    dailyWorkingTime = 0 // No effective working time after break
-                }
-                totalWorkingDuration += dailyWorkingTime
-            }
-        }
-        currentDay = currentDay.Add(24 * time.Hour)
+    // Calculate the intersection of the task's overall time range and the current day's working hours
+    effectiveIntersectionStart := MaxTime(startDate, dailyWorkStart)
+    effectiveIntersectionEnd := MinTime(endDate, dailyWorkEnd)
+    if !effectiveIntersectionStart.Before(effectiveIntersectionEnd) {
+        return 0
     }
 
-    return totalWorkingDuration
+    dailyWorkingTime := effectiveIntersectionEnd.Sub(effectiveIntersectionStart)
+    // Subtract break duration if the working period for the day is substantial enough to include a break
+    breakDuration := time.Duration(workingHours[currentDay.Weekday()].BreakMinutes) * time.Minute
+    if dailyWorkingTime > breakDuration { // Corrected logic: if there's enough time to work AFTER the break
+        return dailyWorkingTime - breakDuration
+    }
+    return 0 // No effective working time after break
+}
+
+// workingWindow returns the start and end of the working hours of a day, if it has any.
+func workingWindow(currentDay time.Time, workingHours map[time.Weekday]WorkingHours) (time.Time, time.Time, bool) {
+    wh, hasWorkingHours := workingHours[currentDay.Weekday()]
+    // Check if working hours are defined and if there's a valid working period for the day
+    if !hasWorkingHours || wh.StartHour*60+wh.StartMinute >= wh.EndHour*60+wh.EndMinute {
+        return time.Time{}, time.Time{}, false
+    }
+    // Create daily working hour times in the current day's location (Local)
+    dailyWorkStart := time.Date(currentDay.Year(), currentDay.Month(), currentDay.Day(), wh.StartHour, wh.StartMinute, 0, 0, currentDay.Location())
+    dailyWorkEnd := time.Date(currentDay.Year(), currentDay.Month(), currentDay.Day(), wh.EndHour, wh.EndMinute, 0, 0, currentDay.Location())
+    return dailyWorkStart, dailyWorkEnd, true
 }
 
 // MaxTime returns the later of two times.
@@ -0,0 +1,40 @@
+package todo
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestParseWindowEnd(t *testing.T) {
+    tests := []struct {
+        window string
+        want   string // empty for an invalid window
+    }{
+        {"30d", "2026-03-31 23:59"},
+        {"2w", "2026-03-15 23:59"},
+        {"1m", "2026-04-01 23:59"},
+        {" 3D ", "2026-03-04 23:59"},
+        {"2026-03-20", "2026-03-20 23:59"},
+        {"0d", ""},
+        {"d", ""},
+        {"2y", ""},
+        {"soon", ""},
+    }
+    for _, tt := range tests {
+        got, err := ParseWindowEnd(tt.window, localTime(t, "2026-03-01 10:00"))
+        if tt.want == "" {
+            if !errors.Is(err, ErrInvalidDate) {
+                t.Errorf("ParseWindowEnd(%q) = %v, %v, want ErrInvalidDate", tt.window, got, err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("ParseWindowEnd(%q): %v", tt.window, err)
+            continue
+        }
+        if want := localTime(t, tt.want).Add(59 * time.Second); !got.Equal(want) {
+            t.Errorf("ParseWindowEnd(%q) = %s, want %s", tt.window, got, want)
+        }
+    }
+}
@@ -1,7 +1,8 @@
-package main
+package todo
 
 import (
     "database/sql"
+    "encoding/json"
     "time"
 )
 
@@ -35,9 +36,36 @@ func (nt NullableTime) Value() (sql.NullTime, error) {
     return sql.NullTime{Time: nt.Time, Valid: true}, nil
 }
 
+// MarshalJSON encodes a valid time as an RFC 3339 string and an invalid one as null.
+func (nt NullableTime) MarshalJSON() ([]byte, error) {
+    if !nt.Valid {
+        return []byte("null"), nil
+    }
+    return json.Marshal(nt.Time.Format(time.RFC3339))
+}
+
+// UnmarshalJSON decodes an RFC 3339 string or null.
+func (nt *NullableTime) UnmarshalJSON(data []byte) error {
+    var s *string
+    if err := json.Unmarshal(data, &s); err != nil {
+        return err
+    }
+    if s == nil || *s == "" {
+        nt.Time, nt.Valid = time.Time{}, false
+        return nil
+    }
+    t, err := time.Parse(time.RFC3339, *s)
+    if err != nil {
+        return err
+    }
+    nt.Time, nt.Valid = t.UTC(), true
+    return nil
+}
+
 // Note represents a single note associated with a task.
 type Note struct {
     ID          int64
+    UUID        string
     Timestamp   NullableTime
     Description sql.NullString
 }
@@ -53,19 +81,32 @@ type Task struct {
     DueDate            NullableTime
     EndDate            NullableTime   // Completion date
     Status             string         // e.g., pending, completed, cancelled, waiting
+    Priority           sql.NullString // H, M or L
+    Estimate           sql.NullInt64  // Expected working time in minutes
     Recurrence         sql.NullString // e.g., "daily", "weekly"
     RecurrenceInterval sql.NullInt64  // e.g., 1, 2
+    RecurrenceShift    sql.NullString // "next" or "previous": moves instances off non-working days
+    RecurrenceAnchor   sql.NullString // "completion": the next instance counts from the completion day
     StartWaitingDate   NullableTime   // Task cannot be started before this date
     EndWaitingDate     NullableTime   // Task cannot be started after this date
     OriginalTaskID     sql.NullInt64  // Added: ID of the original recurring task
+    ParentID           sql.NullInt64  // ID of the task this one is a subtask of
+    UUID               sql.NullString // Globally unique ID, preserved across exports and imports
     Contexts           []string       // For display purposes, fetched from join table
     Tags               []string       // For display purposes, fetched from join table
     Notes              []Note         // Added: For display purposes, fetched from notes table
+    DependsOn          []int64        // IDs of the tasks that must be done first
+    BlockedBy          []int64        // IDs of the tasks in DependsOn that are still open
+    Urgency            float64        // Computed from the urgency coefficients, not stored
+    Tracked            time.Duration  // Sum of the time entries, up to now for a running timer
+    TimerRunning       bool           // A timer is running on this task
+    Projected          bool           // A future occurrence of a recurring series that is not stored yet; ID is the open instance's
 }
 
 // Holiday represents a public or personal holiday.
 type Holiday struct {
     ID   int64
+    UUID string
     Date NullableTime // Use NullableTime for consistency with other dates
     Name string
 }
@@ -0,0 +1,61 @@
+package todo
+
+import (
+    "encoding/json"
+    "testing"
+    "time"
+)
+
+func TestNullableTimeJSON(t *testing.T) {
+    tests := []struct {
+        name string
+        time NullableTime
+        json string
+    }{
+        {"not set", NullableTime{}, "null"},
+        {"UTC", NullableTime{Time: time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC), Valid: true}, `"2026-03-01T08:30:00Z"`},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            data, err := json.Marshal(tt.time)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if string(data) != tt.json {
+                t.Errorf("got %s, want %s", data, tt.json)
+            }
+            var decoded NullableTime
+            if err := json.Unmarshal(data, &decoded); err != nil {
+                t.Fatal(err)
+            }
+            if decoded.Valid != tt.time.Valid || !decoded.Time.Equal(tt.time.Time) {
+                t.Errorf("got %v back, want %v", decoded, tt.time)
+            }
+        })
+    }
+}
+
+func TestNullableTimeUnmarshalJSON(t *testing.T) {
+    tests := []struct {
+        json string
+        want time.Time // zero when not set
+        ok   bool
+    }{
+        {`""`, time.Time{}, true},
+        {`"2026-03-01T10:30:00+02:00"`, time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC), true},
+        {`"2026-03-01 10:30"`, time.Time{}, false},
+        {`42`, time.Time{}, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.json, func(t *testing.T) {
+            var nt NullableTime
+            err := json.Unmarshal([]byte(tt.json), &nt)
+            if (err == nil) != tt.ok {
+                t.Fatalf("got %v, want ok %v", err, tt.ok)
+            }
+            if tt.ok && (nt.Valid != !tt.want.IsZero() || !nt.Time.Equal(tt.want) || nt.Time.Location() != time.UTC) {
+                t.Errorf("got %v", nt)
+            }
+        })
+    }
+}
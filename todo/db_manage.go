@@ -0,0 +1,1708 @@
+// Package todo implements the task store behind the todo CLI: tasks with projects, contexts,
+// tags, notes and recurrence, plus the holidays and working hours used for duration math.
+// All methods return errors instead of printing, so the package can be embedded in other tools.
+package todo
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+    "time"
+
+    _ "modernc.org/sqlite" // SQLite driver without CGO
+)
+
+const (
+    dbFileName = "todo.db"
+)
+
+// TodoManager handles all todo operations.
+type TodoManager struct {
+    db *sql.DB
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx, so helpers can run inside or outside a transaction.
+type queryer interface {
+    Exec(query string, args ...any) (sql.Result, error)
+    Query(query string, args ...any) (*sql.Rows, error)
+    QueryRow(query string, args ...any) *sql.Row
+}
+
+// UpdateResult reports the outcome of UpdateTasks for a single task ID.
+type UpdateResult struct {
+    TaskID     int64
+    Err        error // ErrTaskNotFound or ErrNoChanges if the task was skipped, nil if it was updated
+    NextTaskID int64 // ID of the next recurring instance created on completion, 0 if none
+}
+
+// DefaultDBPath returns the default database location, todo.db in the user's home directory.
+func DefaultDBPath() (string, error) {
+    homeDir, err := os.UserHomeDir()
+    if err != nil {
+        return "", fmt.Errorf("error getting user home directory: %w", err)
+    }
+    return fmt.Sprintf("%s%c%s", homeDir, os.PathSeparator, dbFileName), nil
+}
+
+// NewTodoManager creates a new TodoManager instance and initializes the database.
+// A brand-new database is initialized and an existing database that is behind the schema of
+// this binary is upgraded automatically; a database written by a newer binary returns
+// ErrSchemaTooNew.
+// An empty dbPath selects DefaultDBPath.
+func NewTodoManager(dbPath string) (*TodoManager, error) {
+    tm, err := OpenTodoManager(dbPath)
+    if err != nil {
+        return nil, err
+    }
+    if err := tm.initDB(); err != nil {
+        tm.Close()
+        return nil, err
+    }
+    return tm, nil
+}
+
+// OpenTodoManager opens the database without checking or migrating its schema.
+// It is used by the 'db' maintenance commands.
+func OpenTodoManager(dbPath string) (*TodoManager, error) {
+    if dbPath == "" {
+        var err error
+        dbPath, err = DefaultDBPath()
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    // Added _busy_timeout to the connection string
+    db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_busy_timeout=5000", dbPath))
+    if err != nil {
+        return nil, fmt.Errorf("error opening database: %w", err)
+    }
+
+    return &TodoManager{db: db}, nil
+}
+
+// Close closes the database connection.
+func (tm *TodoManager) Close() error {
+    return tm.db.Close()
+}
+
+// initDB brings the database schema up to date, initializing empty databases. Every pending
+// migration runs in its own transaction, so an interrupted upgrade resumes on the next start.
+func (tm *TodoManager) initDB() error {
+    current, err := tm.checkSchemaNotNewer()
+    if err != nil {
+        return err
+    }
+    if current == LatestSchemaVersion() {
+        return nil
+    }
+    if _, err := tm.Migrate(); err != nil {
+        return fmt.Errorf("error upgrading database schema from version %d: %w", current, err)
+    }
+    return nil
+}
+
+// resolveDate parses a user supplied date for the named field.
+// An empty value means "now"; other values are parsed in local time and stored as UTC.
+func resolveDate(field, value string) (NullableTime, error) {
+    if value == "" {
+        return NullableTime{Time: time.Now().UTC(), Valid: true}, nil
+    }
+    parsed, err := ParseDateTime(value, time.Local)
+    if err != nil {
+        return NullableTime{}, &DateError{Field: field, Value: value, Err: err}
+    }
+    return parsed, nil
+}
+
+// getID inserts a name into a lookup table (contexts, tags, projects) and returns its ID.
+// Now accepts a transaction *sql.Tx
+func (tm *TodoManager) getID(tx *sql.Tx, tableName, name string) (int64, error) {
+    var id int64
+    query := fmt.Sprintf("SELECT id FROM %s WHERE name = ?", tableName)
+    err := tx.QueryRow(query, name).Scan(&id) // Use tx for query
+
+    if err == sql.ErrNoRows {
+        uuid, err := NewUUID()
+        if err != nil {
+            return 0, err
+        }
+        insertQuery := fmt.Sprintf("INSERT INTO %s (name, uuid) VALUES (?, ?)", tableName)
+        res, err := tx.Exec(insertQuery, name, uuid) // Use tx for exec
+        if err != nil {
+            return 0, fmt.Errorf("failed to insert %s %s: %w", tableName, name, wrapDBError(err))
+        }
+        id, err = res.LastInsertId()
+        if err != nil {
+            return 0, fmt.Errorf("failed to get last insert id for %s %s: %w", tableName, name, err)
+        }
+        return id, nil
+    } else if err != nil {
+        return 0, fmt.Errorf("failed to query %s for %s: %w", tableName, name, err)
+    }
+    return id, nil
+}
+
+// getIDs resolves a list of names in a lookup table to their IDs, creating missing entries.
+func (tm *TodoManager) getIDs(tx *sql.Tx, tableName string, names []string) ([]int64, error) {
+    ids := []int64{}
+    for _, name := range names {
+        id, err := tm.getID(tx, tableName, name)
+        if err != nil {
+            return nil, err
+        }
+        ids = append(ids, id)
+    }
+    return ids, nil
+}
+
+// GetNameByID gets the name for a given ID in a table.
+func (tm *TodoManager) GetNameByID(tableName string, id int64) (string, error) {
+    return getNameByID(tm.db, tableName, id)
+}
+
+func getNameByID(q queryer, tableName string, id int64) (string, error) {
+    var name string
+    err := q.QueryRow(fmt.Sprintf("SELECT name FROM %s WHERE id = ?", tableName), id).Scan(&name)
+    if err == sql.ErrNoRows {
+        return "", nil // Not found
+    } else if err != nil {
+        return "", fmt.Errorf("failed to query %s by ID %d: %w", tableName, id, err)
+    }
+    return name, nil
+}
+
+// GetTaskNames fetches associated names (contexts or tags) for a given task.
+func (tm *TodoManager) GetTaskNames(taskID int64, joinTable, nameTable string) ([]string, error) {
+    return getTaskNames(tm.db, taskID, joinTable, nameTable)
+}
+
+func getTaskNames(q queryer, taskID int64, joinTable, nameTable string) ([]string, error) {
+    names := []string{}
+    query := fmt.Sprintf(`
+        SELECT t.name FROM %s jt
+        JOIN %s t ON jt.%s_id = t.id
+        WHERE jt.task_id = ?
+    `, joinTable, nameTable, strings.TrimSuffix(nameTable, "s")) // context_id or tag_id
+    rows, err := q.Query(query, taskID)
+    if err != nil {
+        return nil, fmt.Errorf("error getting %s for task %d: %w", nameTable, taskID, err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var name string
+        if err := rows.Scan(&name); err != nil {
+            return nil, fmt.Errorf("error scanning %s name for task %d: %w", nameTable, taskID, err)
+        }
+        names = append(names, name)
+    }
+    return names, rows.Err()
+}
+
+// associateTaskWithNames handles linking tasks to contexts or tags.
+// Now accepts a transaction *sql.Tx and does not manage its own transaction.
+func (tm *TodoManager) associateTaskWithNames(tx *sql.Tx, taskID int64, nameIDs []int64, joinTable, foreignKey string) error {
+    // Clear existing associations for the task if a new set is provided or explicitly cleared
+    clearQuery := fmt.Sprintf("DELETE FROM %s WHERE task_id = ?", joinTable)
+    _, err := tx.Exec(clearQuery, taskID) // Use tx for exec
+    if err != nil {
+        return fmt.Errorf("failed to clear old associations for task %d in %s: %w", taskID, joinTable, err)
+    }
+
+    for _, nameID := range nameIDs {
+        insertQuery := fmt.Sprintf("INSERT INTO %s (task_id, %s) VALUES (?, ?)", joinTable, foreignKey)
+        _, err := tx.Exec(insertQuery, taskID, nameID) // Use tx for exec
+        if err != nil {
+            // A name listed twice (e.g. 'work,work') hits the primary key; keep the first one.
+            if isConstraintError(err) {
+                continue
+            }
+            return fmt.Errorf("failed to associate task %d with ID %d in %s: %w", taskID, nameID, joinTable, err)
+        }
+    }
+
+    return nil // No commit here, caller's transaction handles it
+}
+
+// taskSelect selects all task columns plus the project name, in the order expected by scanTask.
+const taskSelect = `
+    SELECT
+        t.id, t.title, t.description, t.project_id, p.name, t.start_date, t.due_date, t.end_date, t.status,
+        t.recurrence, t.recurrence_interval, t.start_waiting_date, t.end_waiting_date, t.original_task_id,
+        t.uuid, t.recurrence_shift, t.recurrence_anchor, t.parent_id, t.priority, t.estimate_minutes
+    FROM tasks t
+    LEFT JOIN projects p ON t.project_id = p.id
+`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+    Scan(dest ...any) error
+}
+
+// scanTask scans one row produced by taskSelect into a Task.
+func scanTask(row rowScanner) (Task, error) {
+    var task Task
+    var startDate, dueDate, endDate, startWaitingDate, endWaitingDate sql.NullTime
+
+    err := row.Scan(&task.ID, &task.Title, &task.Description, &task.ProjectID, &task.ProjectName,
+        &startDate, &dueDate, &endDate, &task.Status,
+        &task.Recurrence, &task.RecurrenceInterval, &startWaitingDate, &endWaitingDate, &task.OriginalTaskID,
+        &task.UUID, &task.RecurrenceShift, &task.RecurrenceAnchor, &task.ParentID, &task.Priority, &task.Estimate)
+    if err != nil {
+        return task, err
+    }
+    // NullableTime will automatically convert scanned UTC time to local when accessing .Time
+    task.StartDate = NullableTime{Time: startDate.Time, Valid: startDate.Valid}
+    task.DueDate = NullableTime{Time: dueDate.Time, Valid: dueDate.Valid}
+    task.EndDate = NullableTime{Time: endDate.Time, Valid: endDate.Valid}
+    task.StartWaitingDate = NullableTime{Time: startWaitingDate.Time, Valid: startWaitingDate.Valid}
+    task.EndWaitingDate = NullableTime{Time: endWaitingDate.Time, Valid: endWaitingDate.Valid}
+    return task, nil
+}
+
+// getTask fetches a single task with its contexts and tags.
+func getTask(q queryer, id int64) (*Task, error) {
+    task, err := scanTask(q.QueryRow(taskSelect+" WHERE t.id = ?", id))
+    if err == sql.ErrNoRows {
+        return nil, fmt.Errorf("task %d: %w", id, ErrTaskNotFound)
+    } else if err != nil {
+        return nil, fmt.Errorf("error fetching task %d: %w", id, err)
+    }
+    if task.Contexts, err = getTaskNames(q, id, "task_contexts", "contexts"); err != nil {
+        return nil, err
+    }
+    if task.Tags, err = getTaskNames(q, id, "task_tags", "tags"); err != nil {
+        return nil, err
+    }
+    if task.DependsOn, task.BlockedBy, err = getDependencies(q, id); err != nil {
+        return nil, err
+    }
+    return &task, nil
+}
+
+// GetTask fetches a single task by ID, including its contexts, tags and notes.
+func (tm *TodoManager) GetTask(id int64) (*Task, error) {
+    task, err := getTask(tm.db, id)
+    if err != nil {
+        return nil, err
+    }
+    if task.Notes, err = tm.GetNotesForTask(id); err != nil {
+        return nil, err
+    }
+    coefficients, err := loadUrgencyCoefficients(tm.db)
+    if err != nil {
+        return nil, err
+    }
+    task.Urgency = urgency(task, coefficients)
+    return task, nil
+}
+
+// TaskFilter selects and orders the tasks returned by GetTasks.
+// Zero values mean "no filter".
+type TaskFilter struct {
+    IDs          []int64
+    Project      string
+    Context      string
+    Tag          string
+    Status       string // pending, completed, cancelled, waiting; "" or "all" for any status
+    Search       string // case-insensitive text in title, description and notes
+    StartBefore  NullableTime
+    StartAfter   NullableTime
+    DueBefore    NullableTime
+    DueAfter     NullableTime
+    EndBefore    NullableTime
+    EndAfter     NullableTime
+    SortBy       string // id, title, start_date, due_date, status, project, end_date, urgency (default: due_date)
+    Order        string // asc, desc (default: asc)
+    IncludeNotes bool
+    ExpandUntil  NullableTime // also return the projected occurrences of recurring series up to this time
+}
+
+// GetTasks fetches the tasks matching a filter, with contexts and tags (and optionally notes) populated.
+// When the filter has ExpandUntil, the projected occurrences of recurring series that match it are
+// returned along with the stored tasks; they are marked Projected and are not stored.
+func (tm *TodoManager) GetTasks(filter TaskFilter) ([]Task, error) {
+    tasks, err := tm.queryTasks(filter, "")
+    if err != nil {
+        return nil, err
+    }
+    resort := filter.SortBy == "urgency"
+    if filter.ExpandUntil.Valid {
+        projected, err := tm.projectTasks(filter)
+        if err != nil {
+            return nil, err
+        }
+        tasks = append(tasks, projected...)
+        resort = resort || len(projected) > 0
+    }
+    if err := tm.scoreTasks(tasks); err != nil {
+        return nil, err
+    }
+    if resort {
+        sortTasks(tasks, filter.SortBy, filter.Order)
+    }
+    return tasks, nil
+}
+
+// queryTasks fetches the stored tasks matching a filter and an optional extra WHERE clause.
+func (tm *TodoManager) queryTasks(filter TaskFilter, extraClause string) ([]Task, error) {
+    query := taskSelect
+    args := []any{}
+    whereClauses := []string{"1=1"} // Start with a true condition to simplify AND logic
+
+    // Filter by specific task IDs
+    if len(filter.IDs) > 0 {
+        placeholders := make([]string, len(filter.IDs))
+        for i := range filter.IDs {
+            placeholders[i] = "?"
+            args = append(args, filter.IDs[i])
+        }
+        whereClauses = append(whereClauses, fmt.Sprintf("t.id IN (%s)", strings.Join(placeholders, ",")))
+    }
+
+    // Project filter
+    if filter.Project != "" {
+        whereClauses = append(whereClauses, "p.name = ?")
+        args = append(args, filter.Project)
+    }
+
+    // Status filter
+    if filter.Status != "" && filter.Status != "all" {
+        whereClauses = append(whereClauses, "t.status = ?")
+        args = append(args, filter.Status)
+    }
+
+    // Search text filter in title, description, and notes
+    if filter.Search != "" {
+        searchPattern := "%" + filter.Search + "%"
+        // Use a subquery with EXISTS to check for matching notes
+        whereClauses = append(whereClauses, `(
+            t.title LIKE ? OR t.description LIKE ?
+            OR EXISTS (SELECT 1 FROM task_notes tn WHERE tn.task_id = t.id AND tn.description LIKE ?)
+        )`)
+        args = append(args, searchPattern, searchPattern, searchPattern)
+    }
+
+    // Date filters (already UTC)
+    dateFilters := []struct {
+        clause string
+        value  NullableTime
+    }{
+        {"t.start_date <= ?", filter.StartBefore},
+        {"t.start_date >= ?", filter.StartAfter},
+        {"t.due_date <= ?", filter.DueBefore},
+        {"t.due_date >= ?", filter.DueAfter},
+        {"t.end_date <= ?", filter.EndBefore},
+        {"t.end_date >= ?", filter.EndAfter},
+    }
+    for _, df := range dateFilters {
+        if df.value.Valid {
+            whereClauses = append(whereClauses, df.clause)
+            sqlValue, _ := df.value.Value()
+            args = append(args, sqlValue)
+        }
+    }
+
+    // Context and Tag filters (require JOINs and GROUP BY or EXISTS subqueries)
+    if filter.Context != "" {
+        whereClauses = append(whereClauses, `EXISTS (SELECT 1 FROM task_contexts tc JOIN contexts c ON tc.context_id = c.id WHERE tc.task_id = t.id AND c.name = ?)`)
+        args = append(args, filter.Context)
+    }
+    if filter.Tag != "" {
+        whereClauses = append(whereClauses, `EXISTS (SELECT 1 FROM task_tags tt JOIN tags tg ON tt.tag_id = tg.id WHERE tt.task_id = t.id AND tg.name = ?)`)
+        args = append(args, filter.Tag)
+    }
+
+    if extraClause != "" {
+        whereClauses = append(whereClauses, extraClause)
+    }
+
+    // Combine all WHERE clauses
+    query += " WHERE " + strings.Join(whereClauses, " AND ")
+
+    // Order by
+    orderByMap := map[string]string{
+        "id":         "t.id",
+        "title":      "t.title",
+        "start_date": "t.start_date",
+        "due_date":   "t.due_date",
+        "status":     "t.status",
+        "project":    "p.name",
+        "end_date":   "t.end_date",
+    }
+    actualSortBy := orderByMap[filter.SortBy]
+    if actualSortBy == "" {
+        actualSortBy = orderByMap["due_date"] // Default
+    }
+    order := filter.Order
+    if order != "asc" && order != "desc" {
+        order = "asc" // Default
+    }
+    query += fmt.Sprintf(" ORDER BY %s %s", actualSortBy, order)
+
+    rows, err := tm.db.Query(query, args...)
+    if err != nil {
+        return nil, fmt.Errorf("error querying tasks: %w", err)
+    }
+    defer rows.Close()
+
+    tasks := []Task{}
+    for rows.Next() {
+        task, err := scanTask(rows)
+        if err != nil {
+            return nil, fmt.Errorf("error scanning task: %w", err)
+        }
+        tasks = append(tasks, task)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, fmt.Errorf("error querying tasks: %w", err)
+    }
+    rows.Close()
+
+    // Fetch contexts, tags and notes once the task rows are fully read
+    for i := range tasks {
+        if tasks[i].Contexts, err = tm.GetTaskNames(tasks[i].ID, "task_contexts", "contexts"); err != nil {
+            return nil, err
+        }
+        if tasks[i].Tags, err = tm.GetTaskNames(tasks[i].ID, "task_tags", "tags"); err != nil {
+            return nil, err
+        }
+        if tasks[i].DependsOn, tasks[i].BlockedBy, err = getDependencies(tm.db, tasks[i].ID); err != nil {
+            return nil, err
+        }
+        if tasks[i].Tracked, tasks[i].TimerRunning, err = trackedTime(tm.db, tasks[i].ID, time.Now().UTC()); err != nil {
+            return nil, err
+        }
+        if filter.IncludeNotes {
+            if tasks[i].Notes, err = tm.GetNotesForTask(tasks[i].ID); err != nil {
+                return nil, err
+            }
+        }
+    }
+    return tasks, nil
+}
+
+// matchesDates applies the date filters to a task that is not stored, the way queryTasks
+// applies them in SQL: a missing date never matches a filter on it.
+func (filter TaskFilter) matchesDates(task *Task) bool {
+    checks := []struct{ value, before, after NullableTime }{
+        {task.StartDate, filter.StartBefore, filter.StartAfter},
+        {task.DueDate, filter.DueBefore, filter.DueAfter},
+        {task.EndDate, filter.EndBefore, filter.EndAfter},
+    }
+    for _, c := range checks {
+        if c.before.Valid && (!c.value.Valid || c.value.Time.After(c.before.Time)) {
+            return false
+        }
+        if c.after.Valid && (!c.value.Valid || c.value.Time.Before(c.after.Time)) {
+            return false
+        }
+    }
+    return true
+}
+
+// sortTasks orders a list that mixes stored tasks and projected occurrences the way
+// queryTasks orders stored tasks, with missing values first in ascending order. It is also
+// the only place tasks are ordered by urgency, which is not stored.
+func sortTasks(tasks []Task, sortBy, order string) {
+    timeLess := func(a, b NullableTime) bool {
+        if !a.Valid || !b.Valid {
+            return !a.Valid && b.Valid
+        }
+        return a.Time.Before(b.Time)
+    }
+    less := func(a, b *Task) bool {
+        switch sortBy {
+        case "id":
+            return a.ID < b.ID
+        case "title":
+            return a.Title < b.Title
+        case "start_date":
+            return timeLess(a.StartDate, b.StartDate)
+        case "status":
+            return a.Status < b.Status
+        case "project":
+            if !a.ProjectName.Valid || !b.ProjectName.Valid {
+                return !a.ProjectName.Valid && b.ProjectName.Valid
+            }
+            return a.ProjectName.String < b.ProjectName.String
+        case "end_date":
+            return timeLess(a.EndDate, b.EndDate)
+        case "urgency":
+            return a.Urgency < b.Urgency
+        default:
+            return timeLess(a.DueDate, b.DueDate)
+        }
+    }
+    sort.SliceStable(tasks, func(i, j int) bool {
+        if order == "desc" {
+            return less(&tasks[j], &tasks[i])
+        }
+        return less(&tasks[i], &tasks[j])
+    })
+}
+
+// AddTask validates and adds a new task to the database and returns it.
+// Invalid input is reported as a ValidationError listing every problem.
+func (tm *TodoManager) AddTask(input TaskInput) (*Task, error) {
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    taskID, err := tm.addTask(tx, input, sql.NullInt64{})
+    if err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return tm.GetTask(taskID)
+}
+
+// addTask inserts a task inside an existing transaction and returns its ID.
+// It is shared by AddTask and the recurrence logic in UpdateTasks.
+func (tm *TodoManager) addTask(tx *sql.Tx, input TaskInput, originalTaskID sql.NullInt64) (int64, error) {
+    resolved, err := input.resolve()
+    if err != nil {
+        return 0, err
+    }
+
+    parentID := sql.NullInt64{}
+    if input.ParentID != 0 {
+        if err := checkParent(tx, 0, input.ParentID); err != nil {
+            return 0, err
+        }
+        parentID = sql.NullInt64{Int64: input.ParentID, Valid: true}
+    }
+
+    var projectID sql.NullInt64
+    if input.Project != "" {
+        id, err := tm.getID(tx, "projects", input.Project) // Pass tx
+        if err != nil {
+            return 0, fmt.Errorf("error getting project ID: %w", err)
+        }
+        projectID = sql.NullInt64{Int64: id, Valid: true}
+    }
+
+    // Get sql.NullTime values from NullableTime for database insertion
+    // NullableTime.Value() already returns time in its stored location (UTC in this case)
+    sqlStartDate, _ := resolved.startDate.Value()
+    sqlDueDate, _ := resolved.dueDate.Value()
+    sqlEndDate, _ := resolved.endDate.Value() // Get sql.NullTime for end date
+    sqlStartWaitingDate, _ := resolved.startWaiting.Value()
+    sqlEndWaitingDate, _ := resolved.endWaiting.Value()
+
+    estimate, err := resolveEstimate(tx, input.Estimate)
+    if err != nil {
+        return 0, err
+    }
+
+    uuid, err := NewUUID()
+    if err != nil {
+        return 0, err
+    }
+
+    insertQuery := `
+        INSERT INTO tasks (title, description, project_id, start_date, due_date, end_date, recurrence, recurrence_interval, recurrence_shift, recurrence_anchor, status, start_waiting_date, end_waiting_date, original_task_id, uuid, parent_id, priority, estimate_minutes)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    `
+    res, err := tx.Exec(insertQuery,
+        input.Title,
+        sql.NullString{String: input.Description, Valid: input.Description != ""},
+        projectID,
+        sqlStartDate,
+        sqlDueDate,
+        sqlEndDate, // Include end date in the insert
+        nullString(normalizeRecurrence(input.Recurrence)),
+        sql.NullInt64{Int64: int64(input.RecurrenceInterval), Valid: input.RecurrenceInterval != 0},
+        nullString(normalizeShift(input.RecurrenceShift)),
+        nullString(normalizeAnchor(input.RecurrenceAnchor)),
+        resolved.status,
+        sqlStartWaitingDate,
+        sqlEndWaitingDate,
+        originalTaskID, // Pass originalTaskID
+        uuid,
+        parentID,
+        nullString(normalizePriority(input.Priority)),
+        estimate,
+    )
+    if err != nil {
+        return 0, fmt.Errorf("error adding task: %w", wrapDBError(err))
+    }
+
+    taskID, err := res.LastInsertId()
+    if err != nil {
+        return 0, fmt.Errorf("error getting last insert ID: %w", err)
+    }
+
+    contextIDs, err := tm.getIDs(tx, "contexts", input.Contexts)
+    if err != nil {
+        return 0, fmt.Errorf("error getting context ID: %w", err)
+    }
+    if err := tm.associateTaskWithNames(tx, taskID, contextIDs, "task_contexts", "context_id"); err != nil { // Pass tx
+        return 0, fmt.Errorf("error associating contexts: %w", err)
+    }
+
+    tagIDs, err := tm.getIDs(tx, "tags", input.Tags)
+    if err != nil {
+        return 0, fmt.Errorf("error getting tag ID: %w", err)
+    }
+    if err := tm.associateTaskWithNames(tx, taskID, tagIDs, "task_tags", "tag_id"); err != nil { // Pass tx
+        return 0, fmt.Errorf("error associating tags: %w", err)
+    }
+    if len(input.DependsOn) > 0 {
+        if err := setDependencies(tx, taskID, input.DependsOn); err != nil {
+            return 0, err
+        }
+    }
+
+    if err := tm.logTask(tx, taskID); err != nil {
+        return 0, err
+    }
+    return taskID, nil
+}
+
+// DeleteTask deletes a single task by ID, or marks it completed if completeInstead is true.
+// children says what happens to its subtasks: ChildrenOrphan (the default, also given as
+// "orphan") moves them up to the task's parent when it is deleted, ChildrenDelete deletes and
+// ChildrenComplete completes them, at every level below the task.
+// Completed tasks are completed as by UpdateTasks, so recurring ones get their next instance.
+// It returns ErrTaskNotFound if no such task exists, and ErrBlocked if it, or a subtask it
+// completes, should be completed while tasks it depends on are open. Tasks that depended on a
+// deleted task no longer do.
+func (tm *TodoManager) DeleteTask(id int64, completeInstead bool, children string) error {
+    var p problems
+    p.checkChildPolicy(children)
+    if err := p.err(); err != nil {
+        return err
+    }
+    children = normalizeChildPolicy(children)
+
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    var changed []int64
+    if completeInstead {
+        if _, err := getTask(tx, id); err != nil {
+            return err
+        }
+        // Subtasks first, so a task that depends on its subtasks can be completed with them
+        if changed, err = tm.handleChildren(tx, id, children, false); err != nil {
+            return err
+        }
+        if _, err := tm.completeTask(tx, id); err != nil {
+            return err
+        }
+    } else {
+        if changed, err = tm.handleChildren(tx, id, children, true); err != nil {
+            return err
+        }
+        if err := tm.deleteTask(tx, id); err != nil {
+            return err
+        }
+    }
+    for _, c := range changed {
+        if err := tm.logTask(tx, c); err != nil {
+            return err
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("error committing transaction: %w", err)
+    }
+    return nil
+}
+
+// completeTask completes a task inside an existing transaction the way UpdateTasks does: it
+// returns ErrBlocked while tasks it depends on are open, keeps an end date the task already
+// has, logs the change and creates the next instance of a recurring task, whose ID it returns.
+// A completed task is left as it is.
+func (tm *TodoManager) completeTask(tx *sql.Tx, id int64) (int64, error) {
+    task, err := getTask(tx, id)
+    if err != nil {
+        return 0, err
+    }
+    if task.Status == "completed" {
+        return 0, nil
+    }
+    blockers, err := openBlockers(tx, task.DependsOn)
+    if err != nil {
+        return 0, err
+    }
+    if len(blockers) > 0 {
+        return 0, blockedError(id, blockers)
+    }
+    endDate := task.EndDate
+    if !endDate.Valid {
+        endDate = NullableTime{Time: time.Now().UTC(), Valid: true}
+    }
+    sqlEndDate, _ := endDate.Value()
+    if _, err := tx.Exec("UPDATE tasks SET status = 'completed', end_date = ? WHERE id = ?", sqlEndDate, id); err != nil {
+        return 0, fmt.Errorf("error completing task %d: %w", id, err)
+    }
+    if err := tm.logTask(tx, id); err != nil {
+        return 0, err
+    }
+    if !task.Recurrence.Valid {
+        return 0, nil
+    }
+    return tm.createNextRecurrence(tx, task, false)
+}
+
+// taskRowTables lists the tables whose rows belong to a task. SQLite does not enforce the
+// foreign keys of the schema, so these rows are deleted with the task by hand.
+var taskRowTables = []string{"task_contexts", "task_tags", "task_notes", "time_entries"}
+
+// deleteTask deletes a task with its dependencies, contexts, tags, notes and time entries
+// inside an existing transaction, and logs the tasks that depended on it.
+func (tm *TodoManager) deleteTask(tx *sql.Tx, id int64) error {
+    if err := tm.logDelete(tx, EntityTask, id); err != nil {
+        return err
+    }
+    dependents, err := removeDependencies(tx, id)
+    if err != nil {
+        return err
+    }
+    for _, table := range taskRowTables {
+        if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE task_id = ?", table), id); err != nil {
+            return fmt.Errorf("error deleting rows of task %d from %s: %w", id, table, err)
+        }
+    }
+    res, err := tx.Exec("DELETE FROM tasks WHERE id = ?", id)
+    if err != nil {
+        return fmt.Errorf("error deleting task %d: %w", id, err)
+    }
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking rows affected for task %d: %w", id, err)
+    }
+    if rowsAffected == 0 {
+        return fmt.Errorf("task %d: %w", id, ErrTaskNotFound)
+    }
+    for _, dependent := range dependents {
+        if err := tm.logTask(tx, dependent); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// UpdateTasks applies a patch to one or more tasks in a single transaction.
+// The patch is validated first and every problem is reported in one ValidationError.
+// Tasks that do not exist, have nothing to change, or would be completed while tasks they
+// depend on are open (ErrBlocked, unless the patch has IgnoreBlockers) are skipped and
+// reported in the results; any other error rolls back the whole update.
+func (tm *TodoManager) UpdateTasks(ids []int64, patch TaskPatch) ([]UpdateResult, error) {
+    if len(ids) == 0 {
+        return nil, fmt.Errorf("%w: no task IDs provided for update", ErrInvalidInput)
+    }
+    resolved, err := patch.resolve()
+    if err != nil {
+        return nil, err
+    }
+
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback() // Ensure rollback if commit fails
+
+    // Tasks other tasks of the update depend on come first, so both can be completed at once
+    if ids, err = dependencyOrder(tx, ids); err != nil {
+        return nil, err
+    }
+
+    results := []UpdateResult{}
+
+    for _, id := range ids {
+        status := "" // Status may be derived per task from the end date and waiting dates
+        if patch.Status != nil {
+            status = *patch.Status
+        }
+
+        // Fetch current task state to apply conditional updates and recurrence logic
+        currentTask, err := getTask(tx, id)
+        if errors.Is(err, ErrTaskNotFound) {
+            results = append(results, UpdateResult{TaskID: id, Err: err})
+            continue
+        } else if err != nil {
+            return nil, fmt.Errorf("error fetching current task state for ID %d: %w", id, err)
+        }
+
+        updates := []string{}
+        args := []any{}
+
+        // Title
+        if patch.Title != nil {
+            updates = append(updates, "title = ?")
+            args = append(args, *patch.Title)
+        }
+        // Description
+        if patch.Description != nil {
+            updates = append(updates, "description = ?")
+            args = append(args, sql.NullString{String: *patch.Description, Valid: *patch.Description != ""})
+        }
+
+        // Project
+        if patch.Project != nil && *patch.Project != "" {
+            projectID, err := tm.getID(tx, "projects", *patch.Project) // Pass tx
+            if err != nil {
+                return nil, fmt.Errorf("error getting project ID for task %d: %w", id, err)
+            }
+            updates = append(updates, "project_id = ?")
+            args = append(args, projectID)
+        } else if patch.Project != nil || patch.ClearProject {
+            updates = append(updates, "project_id = NULL")
+        }
+
+        // Parent
+        if patch.ParentID != nil {
+            if err := checkParent(tx, id, *patch.ParentID); err != nil {
+                return nil, err
+            }
+            updates = append(updates, "parent_id = ?")
+            args = append(args, *patch.ParentID)
+        } else if patch.ClearParent {
+            updates = append(updates, "parent_id = NULL")
+        }
+
+        // Priority
+        if patch.Priority != nil && normalizePriority(*patch.Priority) != "" {
+            updates = append(updates, "priority = ?")
+            args = append(args, normalizePriority(*patch.Priority))
+        } else if patch.Priority != nil || patch.ClearPriority {
+            updates = append(updates, "priority = NULL")
+        }
+
+        // Estimate
+        if patch.Estimate != nil {
+            estimate, err := resolveEstimate(tx, *patch.Estimate)
+            if err != nil {
+                return nil, err
+            }
+            updates = append(updates, "estimate_minutes = ?")
+            args = append(args, estimate)
+        } else if patch.ClearEstimate {
+            updates = append(updates, "estimate_minutes = NULL")
+        }
+
+        // Start Date
+        if resolved.startDate.Valid { // Only update if the date was explicitly provided
+            sqlParsedDate, _ := resolved.startDate.Value()
+            updates = append(updates, "start_date = ?")
+            args = append(args, sqlParsedDate)
+        } else if patch.ClearStartDate {
+            updates = append(updates, "start_date = NULL")
+        }
+
+        // Due Date
+        if resolved.dueDate.Valid {
+            sqlParsedDate, _ := resolved.dueDate.Value()
+            updates = append(updates, "due_date = ?")
+            args = append(args, sqlParsedDate)
+        } else if patch.ClearDueDate {
+            updates = append(updates, "due_date = NULL")
+        }
+
+        // End Date (Completion Date) and Status interaction
+        endUpdateApplied := false
+        oldStatus := currentTask.Status // Store old status before potential update
+
+        if patch.ClearEndDate { // Explicitly clear end date (e.g., -clear-E)
+            updates = append(updates, "end_date = NULL")
+            endUpdateApplied = true
+        } else if resolved.endDate.Valid { // Explicitly set end date (e.g., -E "2025-01-01" or -E)
+            sqlParsedDate, _ := resolved.endDate.Value()
+            updates = append(updates, "end_date = ?")
+            args = append(args, sqlParsedDate)
+            endUpdateApplied = true
+
+            // If end_date is set, and status is not explicitly provided, set status to 'completed'
+            if status == "" && oldStatus != "completed" { // Only change if not already completed
+                status = "completed" // Set the status variable, which will be used below
+            }
+        }
+
+        // Handle waiting period status updates
+        if resolved.startWaiting.Valid && !resolved.endWaiting.Valid {
+            // If a waiting start is given without an end, set status to 'waiting' if not explicitly overridden
+            if status == "" && currentTask.Status != "waiting" {
+                status = "waiting"
+            }
+        } else if resolved.endWaiting.Valid {
+            // If a waiting end is given, set status to 'pending' if it was 'waiting' and not explicitly overridden
+            if status == "" && currentTask.Status == "waiting" {
+                status = "pending"
+            }
+        }
+
+        // A task cannot be completed while tasks it depends on are open, unless the patch says so
+        if status == "completed" && oldStatus != "completed" && !patch.IgnoreBlockers {
+            blockers, err := openBlockers(tx, patchedDependencies(currentTask.DependsOn, patch))
+            if err != nil {
+                return nil, err
+            }
+            if len(blockers) > 0 {
+                results = append(results, UpdateResult{TaskID: id, Err: blockedError(id, blockers)})
+                continue
+            }
+        }
+
+        // Handle status update (after potential auto-update from the end date and waiting dates)
+        if status != "" {
+            updates = append(updates, "status = ?")
+            args = append(args, status)
+            // If status is explicitly set to 'completed' and end_date was NOT already handled by -E or -clear-E
+            if status == "completed" && !endUpdateApplied && !currentTask.EndDate.Valid {
+                updates = append(updates, "end_date = ?")
+                args = append(args, time.Now().UTC()) // Use UTC
+            }
+        }
+
+        // Recurrence
+        if patch.Recurrence != nil && *patch.Recurrence != "" {
+            updates = append(updates, "recurrence = ?")
+            args = append(args, normalizeRecurrence(*patch.Recurrence))
+        } else if patch.Recurrence != nil || patch.ClearRecurrence {
+            updates = append(updates, "recurrence = NULL")
+        }
+        if patch.RecurrenceInterval != nil {
+            updates = append(updates, "recurrence_interval = ?")
+            args = append(args, *patch.RecurrenceInterval)
+        }
+        if patch.RecurrenceShift != nil {
+            updates = append(updates, "recurrence_shift = ?")
+            args = append(args, nullString(normalizeShift(*patch.RecurrenceShift)))
+        } else if patch.ClearRecurrence {
+            updates = append(updates, "recurrence_shift = NULL")
+        }
+        if patch.RecurrenceAnchor != nil {
+            updates = append(updates, "recurrence_anchor = ?")
+            args = append(args, nullString(normalizeAnchor(*patch.RecurrenceAnchor)))
+        } else if patch.ClearRecurrence {
+            updates = append(updates, "recurrence_anchor = NULL")
+        }
+
+        // Start Waiting Date & End Waiting Date
+        if patch.ClearWaiting {
+            updates = append(updates, "start_waiting_date = NULL, end_waiting_date = NULL")
+        } else {
+            if resolved.startWaiting.Valid {
+                sqlParsed, _ := resolved.startWaiting.Value()
+                updates = append(updates, "start_waiting_date = ?")
+                args = append(args, sqlParsed)
+            }
+            if resolved.endWaiting.Valid {
+                sqlParsed, _ := resolved.endWaiting.Value()
+                updates = append(updates, "end_waiting_date = ?")
+                args = append(args, sqlParsed)
+            }
+        }
+
+        if len(updates) == 0 && patch.Contexts == nil && patch.Tags == nil && !patch.ClearContexts && !patch.ClearTags &&
+            len(patch.AddContexts) == 0 && len(patch.RemoveContexts) == 0 && len(patch.AddTags) == 0 && len(patch.RemoveTags) == 0 &&
+            patch.DependsOn == nil && !patch.ClearDependsOn && len(patch.AddDependsOn) == 0 && len(patch.RemoveDependsOn) == 0 {
+            results = append(results, UpdateResult{TaskID: id, Err: fmt.Errorf("task %d: %w", id, ErrNoChanges)})
+            continue
+        }
+
+        // Build and execute the UPDATE query for task details
+        if len(updates) > 0 {
+            updateQuery := fmt.Sprintf("UPDATE tasks SET %s WHERE id = ?", strings.Join(updates, ", "))
+            args = append(args, id)
+            _, err := tx.Exec(updateQuery, args...)
+            if err != nil {
+                return nil, fmt.Errorf("error updating task %d: %w", id, wrapDBError(err))
+            }
+        }
+
+        // Handle contexts and tags updates
+        if err := tm.updateTaskNames(tx, id, "contexts", "task_contexts", "context_id", currentTask.Contexts,
+            patch.Contexts, patch.ClearContexts, patch.AddContexts, patch.RemoveContexts); err != nil {
+            return nil, err
+        }
+        if err := tm.updateTaskNames(tx, id, "tags", "task_tags", "tag_id", currentTask.Tags,
+            patch.Tags, patch.ClearTags, patch.AddTags, patch.RemoveTags); err != nil {
+            return nil, err
+        }
+        if err := updateDependencies(tx, id, currentTask.DependsOn, patch); err != nil {
+            return nil, err
+        }
+        if err := tm.logTask(tx, id); err != nil {
+            return nil, err
+        }
+
+        result := UpdateResult{TaskID: id}
+
+        // --- Recurrence Logic: Create next task if completed and recurring ---
+        // Check if the status was just changed to "completed" and it's a recurring task
+        if status == "completed" && oldStatus != "completed" && currentTask.Recurrence.Valid {
+            nextID, err := tm.createNextRecurrence(tx, currentTask, false)
+            if err != nil {
+                return nil, err
+            }
+            result.NextTaskID = nextID
+        }
+        results = append(results, result)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return results, nil
+}
+
+// updateTaskNames applies replace, clear, add and remove operations to a task's contexts or tags.
+func (tm *TodoManager) updateTaskNames(tx *sql.Tx, id int64, nameTable, joinTable, foreignKey string, currentNames []string,
+    names *[]string, clear bool, addNames, removeNames []string) error {
+
+    if clear {
+        // Clear all existing associations
+        if err := tm.associateTaskWithNames(tx, id, []int64{}, joinTable, foreignKey); err != nil {
+            return fmt.Errorf("error clearing %s for task %d: %w", nameTable, id, err)
+        }
+        return nil
+    }
+
+    if names != nil {
+        // Replace all associations with the provided list
+        nameIDs, err := tm.getIDs(tx, nameTable, *names)
+        if err != nil {
+            return fmt.Errorf("error getting %s IDs for task %d: %w", nameTable, id, err)
+        }
+        if err := tm.associateTaskWithNames(tx, id, nameIDs, joinTable, foreignKey); err != nil {
+            return fmt.Errorf("error associating %s for task %d: %w", nameTable, id, err)
+        }
+        return nil
+    }
+
+    if len(addNames) == 0 && len(removeNames) == 0 {
+        return nil
+    }
+
+    // Incremental updates
+    updatedNames := make(map[string]bool)
+    for _, n := range currentNames {
+        updatedNames[n] = true
+    }
+    for _, n := range addNames {
+        updatedNames[n] = true
+    }
+    for _, n := range removeNames {
+        delete(updatedNames, n)
+    }
+
+    // Convert map keys back to slice of names
+    finalNames := []string{}
+    for name := range updatedNames {
+        finalNames = append(finalNames, name)
+    }
+    // Convert names to IDs for association
+    finalIDs, err := tm.getIDs(tx, nameTable, finalNames)
+    if err != nil {
+        return fmt.Errorf("error getting %s IDs for task %d: %w", nameTable, id, err)
+    }
+    if err := tm.associateTaskWithNames(tx, id, finalIDs, joinTable, foreignKey); err != nil {
+        return fmt.Errorf("error associating %s for task %d: %w", nameTable, id, err)
+    }
+    return nil
+}
+
+// createNextRecurrence creates the next instance of a recurring task that was just completed
+// and returns its ID. The next occurrence of the recurrence rule after the due date (or the
+// start date when there is none), or after the completion day for tasks anchored on completion,
+// moved off non-working days by the task's shift policy, becomes the new due date, and the
+// other dates move by the same number of days, keeping their offsets to it. Nothing is
+// created, and 0 is returned, for unknown recurrence patterns and for series that ended
+// because of the rule's UNTIL or COUNT. fromSchedule ignores the completion anchor, for
+// instances that were skipped rather than done.
+func (tm *TodoManager) createNextRecurrence(tx *sql.Tx, currentTask *Task, fromSchedule bool) (int64, error) {
+    id := currentTask.ID
+
+    rule, err := ParseRecurrence(currentTask.Recurrence.String, int(currentTask.RecurrenceInterval.Int64))
+    if err != nil {
+        // Unknown recurrence pattern: do not create a next task
+        return 0, nil
+    }
+    if rule.Count > 0 {
+        instances, err := countSeries(tx, currentTask)
+        if err != nil {
+            return 0, err
+        }
+        if instances >= rule.Count {
+            return 0, nil
+        }
+    }
+
+    // Convert stored UTC times to local for recurrence calculation logic
+    anchor := recurrenceAnchor(currentTask)
+    base := anchor
+    if !fromSchedule {
+        // The end date was just set by the update; currentTask holds the state before it
+        completedAt := time.Now()
+        if completed, err := getTask(tx, id); err == nil && completed.EndDate.Valid {
+            completedAt = completed.EndDate.Time
+        }
+        base = recurrenceBase(currentTask, completedAt)
+    }
+    occurrence, ok, err := nextOccurrence(tx, rule, currentTask, base)
+    if err != nil || !ok {
+        return 0, err
+    }
+    days := calendarDays(anchor, occurrence)
+
+    nextStartDate := currentTask.StartDate.Time.Local().AddDate(0, 0, days)
+    nextDueDate := currentTask.DueDate.Time.Local().AddDate(0, 0, days)
+
+    // Initialize next waiting dates to nil, and set only if original had them
+    var nextStartWaitingDate time.Time
+    var nextEndWaitingDate time.Time
+    isNextStartWaitingSet := false
+    isNextEndWaitingSet := false
+
+    if currentTask.StartWaitingDate.Valid {
+        nextStartWaitingDate = currentTask.StartWaitingDate.Time.Local().AddDate(0, 0, days)
+        isNextStartWaitingSet = true
+    }
+    if currentTask.EndWaitingDate.Valid {
+        nextEndWaitingDate = currentTask.EndWaitingDate.Time.Local().AddDate(0, 0, days)
+        isNextEndWaitingSet = true
+    }
+
+    // Determine the original_task_id for the new recurring task
+    newOriginalTaskID := currentTask.OriginalTaskID
+    if !newOriginalTaskID.Valid {
+        newOriginalTaskID = sql.NullInt64{Int64: id, Valid: true} // If this is the first instance, set itself as original
+    }
+
+    // Get current contexts and tags to pass to the new task
+    currentContexts, err := getTaskNames(tx, id, "task_contexts", "contexts")
+    if err != nil {
+        return 0, err
+    }
+    currentTags, err := getTaskNames(tx, id, "task_tags", "tags")
+    if err != nil {
+        return 0, err
+    }
+    projectName := ""
+    if currentTask.ProjectID.Valid {
+        if projectName, err = getNameByID(tx, "projects", currentTask.ProjectID.Int64); err != nil {
+            return 0, err
+        }
+    }
+
+    formatOptional := func(t time.Time, isSet bool) *string {
+        if !isSet {
+            return nil
+        }
+        formatted := t.Format("2006-01-02 15:04:05")
+        return &formatted
+    }
+
+    // Create the next task, passing the existing transaction.
+    // Format dates back to string, which will be parsed by addTask and converted to UTC.
+    next := TaskInput{
+        Title:              currentTask.Title,
+        Description:        currentTask.Description.String,
+        Project:            projectName,
+        StartDate:          formatOptional(nextStartDate, true), // Force setting the new start date
+        DueDate:            formatOptional(nextDueDate, currentTask.DueDate.Valid), // Only set if original had a due date
+        Recurrence:         currentTask.Recurrence.String,
+        RecurrenceInterval: int(currentTask.RecurrenceInterval.Int64),
+        RecurrenceShift:    currentTask.RecurrenceShift.String,
+        RecurrenceAnchor:   currentTask.RecurrenceAnchor.String,
+        Contexts:           currentContexts,
+        Tags:               currentTags,
+        StartWaiting:       formatOptional(nextStartWaitingDate, isNextStartWaitingSet),
+        EndWaiting:         formatOptional(nextEndWaitingDate, isNextEndWaitingSet),
+        Status:             "pending", // New task is always pending
+        Priority:           currentTask.Priority.String,
+        ParentID:           currentTask.ParentID.Int64,
+    }
+    if currentTask.Estimate.Valid {
+        next.Estimate = fmt.Sprintf("%dm", currentTask.Estimate.Int64)
+    }
+    return tm.addTask(tx, next, newOriginalTaskID)
+}
+
+// Label is an entry of one of the lookup tables: projects, contexts or tags.
+type Label struct {
+    ID   int64
+    UUID string
+    Name string
+}
+
+// getLabels lists all entries of a lookup table ordered by name.
+func (tm *TodoManager) getLabels(tableName string) ([]Label, error) {
+    labels := []Label{}
+    rows, err := tm.db.Query(fmt.Sprintf("SELECT id, COALESCE(uuid, ''), name FROM %s ORDER BY name ASC", tableName))
+    if err != nil {
+        return nil, fmt.Errorf("error listing %s: %w", tableName, err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var l Label
+        if err := rows.Scan(&l.ID, &l.UUID, &l.Name); err != nil {
+            return nil, fmt.Errorf("error scanning %s: %w", tableName, err)
+        }
+        labels = append(labels, l)
+    }
+    return labels, rows.Err()
+}
+
+// GetProjects lists all projects ordered by name.
+func (tm *TodoManager) GetProjects() ([]Label, error) {
+    return tm.getLabels("projects")
+}
+
+// GetContexts lists all contexts ordered by name.
+func (tm *TodoManager) GetContexts() ([]Label, error) {
+    return tm.getLabels("contexts")
+}
+
+// GetTags lists all tags ordered by name.
+func (tm *TodoManager) GetTags() ([]Label, error) {
+    return tm.getLabels("tags")
+}
+
+// AddHoliday adds a new holiday and returns its ID.
+// Adding a second holiday on the same date returns ErrConstraint.
+func (tm *TodoManager) AddHoliday(date, name string) (int64, error) {
+    // Holidays are typically date-only, so parsing in UTC or Local for the date string doesn't matter for the date itself.
+    // However, for consistency, ParseDateTime still converts to UTC.
+    // The date is stored as TEXT "YYYY-MM-DD" in the DB.
+    parsedDate, err := ParseDateTime(date, nil) // Parse as date-only, location doesn't matter for string format
+    if err != nil || !parsedDate.Valid {
+        return 0, &DateError{Field: "holiday date", Value: date, Err: err}
+    }
+
+    uuid, err := NewUUID()
+    if err != nil {
+        return 0, err
+    }
+    res, err := tm.db.Exec("INSERT INTO holidays (date, name, uuid) VALUES (?, ?, ?)", parsedDate.Time.Format("2006-01-02"), name, uuid)
+    if err != nil {
+        return 0, fmt.Errorf("error adding holiday: %w", wrapDBError(err))
+    }
+    return res.LastInsertId()
+}
+
+// DeleteHoliday deletes a holiday by its ID.
+func (tm *TodoManager) DeleteHoliday(id int64) error {
+    res, err := tm.db.Exec("DELETE FROM holidays WHERE id = ?", id)
+    if err != nil {
+        return fmt.Errorf("error deleting holiday with ID %d: %w", id, err)
+    }
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking rows affected for holiday deletion (ID %d): %w", id, err)
+    }
+    if rowsAffected == 0 {
+        return fmt.Errorf("holiday %d: %w", id, ErrHolidayNotFound)
+    }
+    return nil
+}
+
+// DeleteAllHolidays deletes all holidays and returns how many were removed.
+func (tm *TodoManager) DeleteAllHolidays() (int64, error) {
+    return deleteAll(tm.db, "holidays")
+}
+
+// deleteAll empties a table, resets its auto-increment sequence and returns the number of deleted rows.
+func deleteAll(q queryer, tableName string) (int64, error) {
+    res, err := q.Exec(fmt.Sprintf("DELETE FROM %s", tableName))
+    if err != nil {
+        return 0, fmt.Errorf("error deleting all %s: %w", tableName, err)
+    }
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        return 0, fmt.Errorf("error checking rows affected for deleting all %s: %w", tableName, err)
+    }
+    // Reset the auto-increment sequence for the table
+    _, err = q.Exec("UPDATE sqlite_sequence SET seq = 0 WHERE name = ?", tableName)
+    if err != nil {
+        return rowsAffected, fmt.Errorf("could not reset sqlite_sequence for '%s': %w", tableName, err)
+    }
+    return rowsAffected, nil
+}
+
+// SetWorkingHours sets working hours for a specific day of the week, including minutes and break.
+// It returns true if a new entry was created and false if an existing one was updated.
+func (tm *TodoManager) SetWorkingHours(dayOfWeek, startHour, startMinute, endHour, endMinute, breakMinutes int) (bool, error) {
+    if err := checkWorkingHours(dayOfWeek, startHour, startMinute, endHour, endMinute, breakMinutes); err != nil {
+        return false, err
+    }
+
+    // UPSERT: try to update, if no row exists, insert
+    res, err := tm.db.Exec("UPDATE working_hours SET start_hour = ?, start_minute = ?, end_hour = ?, end_minute = ?, break_minutes = ? WHERE day_of_week = ?", startHour, startMinute, endHour, endMinute, breakMinutes, dayOfWeek)
+    if err != nil {
+        return false, fmt.Errorf("error updating working hours: %w", err)
+    }
+
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        return false, fmt.Errorf("error checking rows affected for working hours update: %w", err)
+    }
+    if rowsAffected > 0 {
+        return false, nil
+    }
+
+    _, err = tm.db.Exec("INSERT INTO working_hours (day_of_week, start_hour, start_minute, end_hour, end_minute, break_minutes) VALUES (?, ?, ?, ?, ?, ?)", dayOfWeek, startHour, startMinute, endHour, endMinute, breakMinutes)
+    if err != nil {
+        return false, fmt.Errorf("error inserting working hours: %w", wrapDBError(err))
+    }
+    return true, nil
+}
+
+// checkWorkingHours validates the hours of a day of the week.
+func checkWorkingHours(dayOfWeek, startHour, startMinute, endHour, endMinute, breakMinutes int) error {
+    if dayOfWeek < 0 || dayOfWeek > 6 {
+        return fmt.Errorf("%w: invalid day of week. Must be 0-6 (Sunday-Saturday)", ErrInvalidInput)
+    }
+    if startHour < 0 || startHour > 23 || endHour < 0 || endHour > 24 {
+        return fmt.Errorf("%w: invalid hour. Must be 0-23 for start, 0-24 for end", ErrInvalidInput)
+    }
+    if startMinute < 0 || startMinute > 59 || endMinute < 0 || endMinute > 59 {
+        return fmt.Errorf("%w: invalid minute. Must be 0-59", ErrInvalidInput)
+    }
+    if breakMinutes < 0 {
+        return fmt.Errorf("%w: break minutes cannot be negative", ErrInvalidInput)
+    }
+    // Check if start time is before end time
+    if startHour*60+startMinute >= endHour*60+endMinute {
+        return fmt.Errorf("%w: invalid working hours. Start time must be before end time", ErrInvalidInput)
+    }
+    return nil
+}
+
+// DeleteWorkingHours deletes working hours for a specific day of the week.
+func (tm *TodoManager) DeleteWorkingHours(dayOfWeek int) error {
+    if dayOfWeek < 0 || dayOfWeek > 6 {
+        return fmt.Errorf("%w: invalid day of week %d. Must be 0-6 (Sunday-Saturday)", ErrInvalidInput, dayOfWeek)
+    }
+
+    res, err := tm.db.Exec("DELETE FROM working_hours WHERE day_of_week = ?", dayOfWeek)
+    if err != nil {
+        return fmt.Errorf("error deleting working hours for day %d: %w", dayOfWeek, err)
+    }
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking rows affected for working hours deletion (day %d): %w", dayOfWeek, err)
+    }
+    if rowsAffected == 0 {
+        return fmt.Errorf("day %d (%s): %w", dayOfWeek, time.Weekday(dayOfWeek).String(), ErrWorkingHoursNotFound)
+    }
+    return nil
+}
+
+// DeleteAllWorkingHours deletes all configured working hours and returns how many were removed.
+func (tm *TodoManager) DeleteAllWorkingHours() (int64, error) {
+    return deleteAll(tm.db, "working_hours")
+}
+
+// GetWorkingHours fetches all defined working hours from the database.
+func (tm *TodoManager) GetWorkingHours() (map[time.Weekday]WorkingHours, error) {
+    return getWorkingHours(tm.db)
+}
+
+func getWorkingHours(q queryer) (map[time.Weekday]WorkingHours, error) {
+    hours := make(map[time.Weekday]WorkingHours)
+    rows, err := q.Query("SELECT id, day_of_week, start_hour, start_minute, end_hour, end_minute, break_minutes FROM working_hours")
+    if err != nil {
+        return nil, fmt.Errorf("failed to query working hours: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var wh WorkingHours
+        if err := rows.Scan(&wh.ID, &wh.DayOfWeek, &wh.StartHour, &wh.StartMinute, &wh.EndHour, &wh.EndMinute, &wh.BreakMinutes); err != nil {
+            return nil, fmt.Errorf("failed to scan working hours: %w", err)
+        }
+        hours[time.Weekday(wh.DayOfWeek)] = wh
+    }
+    return hours, rows.Err()
+}
+
+// GetHolidays fetches all defined holidays from the database.
+func (tm *TodoManager) GetHolidays() ([]Holiday, error) { // Changed return type to slice
+    return getHolidays(tm.db)
+}
+
+func getHolidays(q queryer) ([]Holiday, error) {
+    holidays := []Holiday{} // Initialize as slice
+    rows, err := q.Query("SELECT id, COALESCE(uuid, ''), date, name FROM holidays ORDER BY date ASC") // Added id to select, ordered for consistent listing
+    if err != nil {
+        return nil, fmt.Errorf("failed to query holidays: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var h Holiday // Use Holiday struct
+        var dateStr string
+        if err := rows.Scan(&h.ID, &h.UUID, &dateStr, &h.Name); err != nil { // Scan ID and Name into struct
+            return nil, fmt.Errorf("failed to scan holiday: %w", err)
+        }
+        parsedDate, err := time.Parse("2006-01-02", dateStr)
+        if err != nil {
+            return nil, &DateError{Field: fmt.Sprintf("date of holiday %d", h.ID), Value: dateStr, Err: err}
+        }
+        h.Date = NullableTime{Time: parsedDate, Valid: true}
+        holidays = append(holidays, h) // Append to slice
+    }
+    return holidays, rows.Err()
+}
+
+// GetHolidaysMap fetches all holidays keyed by their YYYY-MM-DD date,
+// the form expected by CalculateWorkingDuration.
+func (tm *TodoManager) GetHolidaysMap() (map[string]Holiday, error) {
+    holidaysList, err := tm.GetHolidays()
+    if err != nil {
+        return nil, err
+    }
+    holidaysMap := make(map[string]Holiday)
+    for _, h := range holidaysList {
+        holidaysMap[h.Date.Time.Format("2006-01-02")] = h
+    }
+    return holidaysMap, nil
+}
+
+// CalculateWorkingDuration calculates the actual working time between start and end dates,
+// considering defined working hours and holidays.
+// It returns the duration in minutes.
+func (tm *TodoManager) CalculateWorkingDuration(start, end NullableTime, workingHours map[time.Weekday]WorkingHours, holidays map[string]Holiday) time.Duration {
+    // Delegate to the utility function in dateutils, passing the *sql.DB for holiday/working hour lookups if needed there.
+    // However, since workingHours and holidays maps are already fetched, pass them directly.
+    return CalculateWorkingHoursDuration(tm.db, start, end, workingHours, holidays)
+}
+
+// AddNoteToTask adds a new note to a specific task and returns the note ID.
+// An unset timestamp defaults to now; a set but empty timestamp also means now.
+func (tm *TodoManager) AddNoteToTask(taskID int64, description string, timestampStr string, isTimestampSet bool) (int64, error) { // Added timestamp parameters
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return 0, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    var exists int
+    err = tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE id = ?", taskID).Scan(&exists)
+    if err != nil {
+        return 0, fmt.Errorf("error checking task %d: %w", taskID, err)
+    }
+    if exists == 0 {
+        return 0, fmt.Errorf("task %d: %w", taskID, ErrTaskNotFound)
+    }
+
+    insertQuery := `
+        INSERT INTO task_notes (task_id, timestamp, description, uuid)
+        VALUES (?, ?, ?, ?)
+    `
+    noteTimestamp := NullableTime{Time: time.Now().UTC(), Valid: true} // Default to current UTC time
+    if isTimestampSet {
+        if noteTimestamp, err = resolveDate("note timestamp", timestampStr); err != nil {
+            return 0, err
+        }
+    }
+
+    sqlNoteTimestamp, _ := noteTimestamp.Value()
+    uuid, err := NewUUID()
+    if err != nil {
+        return 0, err
+    }
+
+    res, err := tx.Exec(insertQuery, taskID, sqlNoteTimestamp, description, uuid)
+    if err != nil {
+        return 0, fmt.Errorf("error adding note to task %d: %w", taskID, wrapDBError(err))
+    }
+    noteID, err := res.LastInsertId()
+    if err != nil {
+        return 0, fmt.Errorf("error getting last insert ID: %w", err)
+    }
+    if err := tm.logNote(tx, noteID); err != nil {
+        return 0, err
+    }
+    if err := tx.Commit(); err != nil {
+        return 0, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return noteID, nil
+}
+
+// GetNotesForTask fetches notes for a given task, ordered by timestamp.
+// This now orders notes by timestamp in ascending order to facilitate 1-based indexing
+// where 1 is the oldest note, and N is the newest.
+func (tm *TodoManager) GetNotesForTask(taskID int64) ([]Note, error) {
+    notes := []Note{}
+    query := `
+        SELECT id, COALESCE(uuid, ''), timestamp, description FROM task_notes
+        WHERE task_id = ?
+        ORDER BY timestamp ASC
+    `
+    rows, err := tm.db.Query(query, taskID)
+    if err != nil {
+        return nil, fmt.Errorf("error getting notes for task %d: %w", taskID, err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var note Note
+        var timestamp sql.NullTime
+        var desc sql.NullString
+        if err := rows.Scan(&note.ID, &note.UUID, &timestamp, &desc); err != nil {
+            return nil, fmt.Errorf("error scanning note for task %d: %w", taskID, err)
+        }
+        // NullableTime will handle conversion from DB's UTC to local when accessing .Time
+        note.Timestamp = NullableTime{Time: timestamp.Time, Valid: timestamp.Valid}
+        note.Description = desc
+        notes = append(notes, note)
+    }
+    return notes, rows.Err()
+}
+
+// UpdateNote updates the description and/or timestamp of an existing note.
+func (tm *TodoManager) UpdateNote(noteID int64, description string, timestampStr string, isTimestampSet bool) error {
+    updates := []string{}
+    args := []any{}
+
+    if description != "" {
+        updates = append(updates, "description = ?")
+        args = append(args, description)
+    }
+
+    if isTimestampSet {
+        parsedTime, err := resolveDate("note timestamp", timestampStr)
+        if err != nil {
+            return err
+        }
+        sqlParsedTime, _ := parsedTime.Value()
+        updates = append(updates, "timestamp = ?")
+        args = append(args, sqlParsedTime)
+    }
+
+    if len(updates) == 0 {
+        return fmt.Errorf("note %d: %w", noteID, ErrNoChanges)
+    }
+
+    updateQuery := fmt.Sprintf("UPDATE task_notes SET %s WHERE id = ?", strings.Join(updates, ", "))
+    args = append(args, noteID)
+
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    res, err := tx.Exec(updateQuery, args...)
+    if err != nil {
+        return fmt.Errorf("error updating note %d: %w", noteID, wrapDBError(err))
+    }
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking rows affected for note update: %w", err)
+    }
+    if rowsAffected == 0 {
+        return fmt.Errorf("note %d: %w", noteID, ErrNoteNotFound)
+    }
+    if err := tm.logNote(tx, noteID); err != nil {
+        return err
+    }
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("error committing transaction: %w", err)
+    }
+    return nil
+}
+
+// DeleteNote deletes a single note by its ID.
+func (tm *TodoManager) DeleteNote(noteID int64) error {
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    if err := tm.logDelete(tx, EntityNote, noteID); err != nil {
+        return err
+    }
+    res, err := tx.Exec("DELETE FROM task_notes WHERE id = ?", noteID)
+    if err != nil {
+        return fmt.Errorf("error deleting note %d: %w", noteID, err)
+    }
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        return fmt.Errorf("error checking rows affected for note %d deletion: %w", noteID, err)
+    }
+    if rowsAffected == 0 {
+        return fmt.Errorf("note %d: %w", noteID, ErrNoteNotFound)
+    }
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("error committing transaction: %w", err)
+    }
+    return nil
+}
+
+// DeleteAllNotes deletes all notes from the database and returns how many were removed.
+func (tm *TodoManager) DeleteAllNotes() (int64, error) {
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return 0, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+    if err := tm.logNoteDeletes(tx, "SELECT id FROM task_notes"); err != nil {
+        return 0, err
+    }
+    rowsAffected, err := deleteAll(tx, "task_notes")
+    if err != nil {
+        return 0, err
+    }
+    if err := tx.Commit(); err != nil {
+        return 0, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return rowsAffected, nil
+}
+
+// DeleteAllNotesForTask deletes all notes associated with a specific task ID and returns how many were removed.
+func (tm *TodoManager) DeleteAllNotesForTask(taskID int64) (int64, error) {
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return 0, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    if err := tm.logNoteDeletes(tx, "SELECT id FROM task_notes WHERE task_id = ?", taskID); err != nil {
+        return 0, err
+    }
+    res, err := tx.Exec("DELETE FROM task_notes WHERE task_id = ?", taskID)
+    if err != nil {
+        return 0, fmt.Errorf("error deleting all notes for task %d: %w", taskID, err)
+    }
+    rowsAffected, err := res.RowsAffected()
+    if err != nil {
+        return 0, fmt.Errorf("error checking rows affected for deleting notes for task %d: %w", taskID, err)
+    }
+    if err := tx.Commit(); err != nil {
+        return 0, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return rowsAffected, nil
+}
+
+// logNoteDeletes records the deletion of the notes selected by a query, before they are deleted.
+func (tm *TodoManager) logNoteDeletes(tx *sql.Tx, query string, args ...any) error {
+    noteIDs, err := queryIDs(tx, query, args...)
+    if err != nil {
+        return err
+    }
+    for _, id := range noteIDs {
+        if err := tm.logDelete(tx, EntityNote, id); err != nil {
+            return err
+        }
+    }
+    return nil
+}
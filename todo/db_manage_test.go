@@ -0,0 +1,68 @@
+package todo
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+// taskRows counts the rows that belong to a task outside the tasks table.
+func taskRows(t *testing.T, q queryer, id int64) map[string]int {
+    t.Helper()
+    counts := map[string]int{}
+    for _, table := range append(taskRowTables, "task_dependencies") {
+        var n int
+        if err := q.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE task_id = ?", table), id).Scan(&n); err != nil {
+            t.Fatal(err)
+        }
+        if n > 0 {
+            counts[table] = n
+        }
+    }
+    return counts
+}
+
+func TestDeleteTaskRemovesItsRows(t *testing.T) {
+    tests := []struct {
+        name   string
+        delete func(tm *TodoManager) error
+    }{
+        {"deleted", func(tm *TodoManager) error { return tm.DeleteTask(3, false, "") }},
+        {"deleted with its parent", func(tm *TodoManager) error { return tm.DeleteTask(1, false, ChildrenDelete) }},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            inputs := []TaskInput{
+                {Title: "fix the house"},
+                {Title: "water the plants"},
+                {Title: "call plumber", ParentID: 1, DependsOn: []int64{2}, Contexts: []string{"phone"}, Tags: []string{"urgent"}},
+            }
+            for _, in := range inputs {
+                if _, err := tm.AddTask(in); err != nil {
+                    t.Fatalf("AddTask(%s): %v", in.Title, err)
+                }
+            }
+            if _, err := tm.AddNoteToTask(3, "asked for a quote", "", false); err != nil {
+                t.Fatal(err)
+            }
+            if _, err := tm.LogTime(3, 30*time.Minute, ""); err != nil {
+                t.Fatal(err)
+            }
+            if got := len(taskRows(t, tm.db, 3)); got != len(taskRowTables)+1 {
+                t.Fatalf("task 3 has rows in %d tables, want %d", got, len(taskRowTables)+1)
+            }
+
+            if err := tt.delete(tm); err != nil {
+                t.Fatal(err)
+            }
+            if rows := taskRows(t, tm.db, 3); len(rows) != 0 {
+                t.Errorf("rows left behind: %v", rows)
+            }
+            // The rows of other tasks are kept
+            if _, err := tm.GetTask(2); err != nil {
+                t.Errorf("task 2: %v", err)
+            }
+        })
+    }
+}
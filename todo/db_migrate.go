@@ -0,0 +1,525 @@
+package todo
+
+import (
+    "database/sql"
+    "fmt"
+    "time"
+)
+
+// migration describes a single, numbered step of the database schema.
+// Migrations are applied in ascending version order, each inside its own transaction,
+// and recorded in the schema_version table once they succeed.
+type migration struct {
+    Version int
+    Name    string
+    Apply   func(tx *sql.Tx) error
+}
+
+// MigrationInfo describes a schema migration and when it was applied.
+// AppliedAt is not valid for migrations that are still pending.
+type MigrationInfo struct {
+    Version   int
+    Name      string
+    AppliedAt NullableTime
+}
+
+// migrations is the ordered list of all schema migrations known to this binary.
+// Never edit or reorder a migration that has been released; append a new one instead.
+var migrations = []migration{
+    {Version: 1, Name: "initial schema", Apply: migrateInitialSchema},
+    {Version: 2, Name: "add original_task_id to tasks", Apply: migrateOriginalTaskID},
+    {Version: 3, Name: "add minutes and breaks to working_hours", Apply: migrateWorkingHoursMinutes},
+    {Version: 4, Name: "add uuid to tasks", Apply: migrateTaskUUID},
+    {Version: 5, Name: "add uuid to notes, projects, contexts, tags and holidays", Apply: migrateUUIDs},
+    {Version: 6, Name: "add change log and sync state", Apply: migrateChangeLog},
+    {Version: 7, Name: "add recurrence_shift to tasks", Apply: migrateRecurrenceShift},
+    {Version: 8, Name: "add recurrence_anchor to tasks", Apply: migrateRecurrenceAnchor},
+    {Version: 9, Name: "add task dependencies", Apply: migrateTaskDependencies},
+    {Version: 10, Name: "add parent_id to tasks", Apply: migrateTaskParent},
+    {Version: 11, Name: "add priority to tasks and urgency coefficients", Apply: migrateUrgency},
+    {Version: 12, Name: "add context hours", Apply: migrateContextHours},
+    {Version: 13, Name: "add time entries", Apply: migrateTimeEntries},
+    {Version: 14, Name: "add estimate_minutes to tasks", Apply: migrateTaskEstimate},
+    {Version: 15, Name: "remove rows left behind by deleted tasks", Apply: migrateOrphanRows},
+}
+
+// LatestSchemaVersion returns the highest schema version this binary knows about.
+func LatestSchemaVersion() int {
+    if len(migrations) == 0 {
+        return 0
+    }
+    return migrations[len(migrations)-1].Version
+}
+
+// migrateInitialSchema creates the base tables. It uses IF NOT EXISTS so that databases
+// created before schema versioning existed can be adopted without losing data.
+func migrateInitialSchema(tx *sql.Tx) error {
+    _, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS projects (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL UNIQUE
+    );
+
+    CREATE TABLE IF NOT EXISTS contexts (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL UNIQUE
+    );
+
+    CREATE TABLE IF NOT EXISTS tags (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        name TEXT NOT NULL UNIQUE
+    );
+
+    CREATE TABLE IF NOT EXISTS tasks (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        title TEXT NOT NULL,
+        description TEXT,
+        project_id INTEGER,
+        start_date DATETIME,
+        due_date DATETIME,
+        end_date DATETIME,
+        status TEXT NOT NULL DEFAULT 'pending', -- pending, completed, cancelled, waiting
+        recurrence TEXT, -- daily, weekly, monthly, yearly
+        recurrence_interval INTEGER DEFAULT 1,
+        start_waiting_date DATETIME,
+        end_waiting_date DATETIME,
+        FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE SET NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS task_contexts (
+        task_id INTEGER,
+        context_id INTEGER,
+        PRIMARY KEY (task_id, context_id),
+        FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+        FOREIGN KEY (context_id) REFERENCES contexts(id) ON DELETE CASCADE
+    );
+
+    CREATE TABLE IF NOT EXISTS task_tags (
+        task_id INTEGER,
+        tag_id INTEGER,
+        PRIMARY KEY (task_id, tag_id),
+        FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+        FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+    );
+
+    CREATE TABLE IF NOT EXISTS holidays (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        date TEXT NOT NULL UNIQUE, --YYYY-MM-DD
+        name TEXT NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS working_hours (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        day_of_week INTEGER NOT NULL UNIQUE, -- 0=Sunday, 1=Monday, ..., 6=Saturday
+        start_hour INTEGER NOT NULL,
+        end_hour INTEGER NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS task_notes (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        task_id INTEGER NOT NULL,
+        timestamp DATETIME NOT NULL,
+        description TEXT NOT NULL,
+        FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+    );
+    `)
+    return err
+}
+
+// migrateOriginalTaskID links recurring task instances back to the first task of the series.
+func migrateOriginalTaskID(tx *sql.Tx) error {
+    return addColumnIfMissing(tx, "tasks", "original_task_id", "INTEGER")
+}
+
+// migrateWorkingHoursMinutes adds minute precision and break duration to working hours.
+func migrateWorkingHoursMinutes(tx *sql.Tx) error {
+    if err := addColumnIfMissing(tx, "working_hours", "start_minute", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+        return err
+    }
+    if err := addColumnIfMissing(tx, "working_hours", "end_minute", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+        return err
+    }
+    return addColumnIfMissing(tx, "working_hours", "break_minutes", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// migrateTaskUUID adds a globally unique task identifier, used to recognise tasks that are
+// imported again (e.g. from Taskwarrior) so they are updated instead of duplicated.
+func migrateTaskUUID(tx *sql.Tx) error {
+    if err := addColumnIfMissing(tx, "tasks", "uuid", "TEXT"); err != nil {
+        return err
+    }
+    _, err := tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_uuid ON tasks(uuid)")
+    return err
+}
+
+// uuidTables lists every table whose rows carry a UUID.
+var uuidTables = []string{"tasks", "task_notes", "projects", "contexts", "tags", "holidays"}
+
+// migrateUUIDs gives notes, projects, contexts, tags and holidays a UUID column like tasks,
+// and assigns a UUID to every existing row that has none, so records can be matched
+// across databases.
+func migrateUUIDs(tx *sql.Tx) error {
+    for _, table := range uuidTables {
+        if err := addColumnIfMissing(tx, table, "uuid", "TEXT"); err != nil {
+            return err
+        }
+        if _, err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS idx_%s_uuid ON %s(uuid)", table, table)); err != nil {
+            return fmt.Errorf("failed to index uuid of %s: %w", table, err)
+        }
+        if err := backfillUUIDs(tx, table); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// migrateChangeLog adds the append-only log of task and note changes exchanged by Sync,
+// the device identity and how far the logs of other devices have been merged.
+func migrateChangeLog(tx *sql.Tx) error {
+    _, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS change_log (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        uuid TEXT NOT NULL UNIQUE,
+        device_id TEXT NOT NULL,
+        seq INTEGER NOT NULL, -- position in the log of the device that made the change
+        changed_at INTEGER NOT NULL, -- Unix time in nanoseconds
+        entity TEXT NOT NULL, -- task, note
+        entity_uuid TEXT NOT NULL,
+        op TEXT NOT NULL, -- set, delete
+        field TEXT NOT NULL DEFAULT '',
+        value TEXT, -- JSON encoded
+        prev_uuid TEXT, -- change to the same field this one replaces
+        UNIQUE (device_id, seq)
+    );
+    CREATE INDEX IF NOT EXISTS idx_change_log_entity ON change_log(entity_uuid, field);
+    CREATE INDEX IF NOT EXISTS idx_change_log_changed_at ON change_log(changed_at);
+
+    CREATE TABLE IF NOT EXISTS sync_state (
+        key TEXT PRIMARY KEY,
+        value TEXT NOT NULL
+    );
+
+    CREATE TABLE IF NOT EXISTS sync_peers (
+        device_id TEXT PRIMARY KEY,
+        last_seq INTEGER NOT NULL,
+        synced_at DATETIME NOT NULL
+    );
+    `)
+    return err
+}
+
+// migrateRecurrenceShift adds the policy that moves recurring instances off non-working days.
+func migrateRecurrenceShift(tx *sql.Tx) error {
+    return addColumnIfMissing(tx, "tasks", "recurrence_shift", "TEXT")
+}
+
+// migrateRecurrenceAnchor adds the choice between recurring from the scheduled date and from completion.
+func migrateRecurrenceAnchor(tx *sql.Tx) error {
+    return addColumnIfMissing(tx, "tasks", "recurrence_anchor", "TEXT")
+}
+
+// migrateTaskDependencies adds the table of tasks that must be done before other tasks.
+func migrateTaskDependencies(tx *sql.Tx) error {
+    _, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS task_dependencies (
+        task_id INTEGER NOT NULL,
+        depends_on_id INTEGER NOT NULL, -- the task that must be done first
+        PRIMARY KEY (task_id, depends_on_id),
+        FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+        FOREIGN KEY (depends_on_id) REFERENCES tasks(id) ON DELETE CASCADE
+    );
+    CREATE INDEX IF NOT EXISTS idx_task_dependencies_depends_on ON task_dependencies(depends_on_id);
+    `)
+    return err
+}
+
+// migrateTaskParent adds the parent of subtasks.
+func migrateTaskParent(tx *sql.Tx) error {
+    if err := addColumnIfMissing(tx, "tasks", "parent_id", "INTEGER REFERENCES tasks(id)"); err != nil {
+        return err
+    }
+    _, err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_tasks_parent_id ON tasks(parent_id)")
+    return err
+}
+
+// migrateUrgency adds the priority of tasks and the coefficients of the urgency score, seeded
+// with their defaults.
+func migrateUrgency(tx *sql.Tx) error {
+    if err := addColumnIfMissing(tx, "tasks", "priority", "TEXT"); err != nil {
+        return err
+    }
+    if _, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS urgency_coefficients (
+        name TEXT PRIMARY KEY, -- a factor such as due or priority.H, or tag.<name> and project.<name>
+        value REAL NOT NULL
+    );
+    `); err != nil {
+        return err
+    }
+    return seedUrgencyCoefficients(tx)
+}
+
+// migrateContextHours adds the hours in which the tasks of a context can be done.
+func migrateContextHours(tx *sql.Tx) error {
+    _, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS context_hours (
+        context_id INTEGER NOT NULL,
+        day_of_week INTEGER NOT NULL, -- 0=Sunday, 1=Monday, ..., 6=Saturday
+        start_hour INTEGER NOT NULL,
+        start_minute INTEGER NOT NULL DEFAULT 0,
+        end_hour INTEGER NOT NULL,
+        end_minute INTEGER NOT NULL DEFAULT 0,
+        PRIMARY KEY (context_id, day_of_week),
+        FOREIGN KEY (context_id) REFERENCES contexts(id) ON DELETE CASCADE
+    );
+    `)
+    return err
+}
+
+// migrateTimeEntries adds the time tracked on tasks. At most one entry, the running timer,
+// has no end time.
+func migrateTimeEntries(tx *sql.Tx) error {
+    _, err := tx.Exec(`
+    CREATE TABLE IF NOT EXISTS time_entries (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        task_id INTEGER NOT NULL,
+        start_time DATETIME NOT NULL,
+        end_time DATETIME,
+        FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE
+    );
+    CREATE INDEX IF NOT EXISTS idx_time_entries_task ON time_entries(task_id);
+    CREATE UNIQUE INDEX IF NOT EXISTS idx_time_entries_running ON time_entries((end_time IS NULL)) WHERE end_time IS NULL;
+    `)
+    return err
+}
+
+// migrateTaskEstimate adds the expected working time of tasks.
+func migrateTaskEstimate(tx *sql.Tx) error {
+    return addColumnIfMissing(tx, "tasks", "estimate_minutes", "INTEGER")
+}
+
+// migrateOrphanRows deletes the contexts, tags, notes, time entries and dependencies of tasks
+// that no longer exist, and the hours of contexts that no longer exist. Older versions left
+// them behind because SQLite does not enforce foreign keys unless asked to.
+func migrateOrphanRows(tx *sql.Tx) error {
+    for _, table := range taskRowTables {
+        if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE task_id NOT IN (SELECT id FROM tasks)", table)); err != nil {
+            return fmt.Errorf("failed to clean up %s: %w", table, err)
+        }
+    }
+    _, err := tx.Exec(`
+    DELETE FROM task_dependencies WHERE task_id NOT IN (SELECT id FROM tasks) OR depends_on_id NOT IN (SELECT id FROM tasks);
+    DELETE FROM context_hours WHERE context_id NOT IN (SELECT id FROM contexts);
+    `)
+    return err
+}
+
+// backfillUUIDs assigns a new UUID to every row of a table that has none.
+func backfillUUIDs(tx *sql.Tx, table string) error {
+    rows, err := tx.Query(fmt.Sprintf("SELECT id FROM %s WHERE uuid IS NULL OR uuid = ''", table))
+    if err != nil {
+        return fmt.Errorf("failed to read %s without uuid: %w", table, err)
+    }
+    var ids []int64
+    for rows.Next() {
+        var id int64
+        if err := rows.Scan(&id); err != nil {
+            rows.Close()
+            return fmt.Errorf("failed to scan %s id: %w", table, err)
+        }
+        ids = append(ids, id)
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return fmt.Errorf("failed to read %s without uuid: %w", table, err)
+    }
+
+    for _, id := range ids {
+        uuid, err := NewUUID()
+        if err != nil {
+            return err
+        }
+        if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET uuid = ? WHERE id = ?", table), uuid, id); err != nil {
+            return fmt.Errorf("failed to assign uuid to %s %d: %w", table, id, err)
+        }
+    }
+    return nil
+}
+
+// columnExists reports whether a table already has the given column.
+func columnExists(tx *sql.Tx, table, column string) (bool, error) {
+    rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+    if err != nil {
+        return false, fmt.Errorf("failed to read columns of %s: %w", table, err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var cid, notNull, pk int
+        var name, colType string
+        var defaultValue sql.NullString
+        if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+            return false, fmt.Errorf("failed to scan columns of %s: %w", table, err)
+        }
+        if name == column {
+            return true, nil
+        }
+    }
+    return false, rows.Err()
+}
+
+// addColumnIfMissing adds a column to a table unless it is already present.
+// Databases created before schema versioning may already contain columns added by
+// the old ad-hoc ALTER TABLE statements, so column additions must be idempotent.
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+    exists, err := columnExists(tx, table, column)
+    if err != nil {
+        return err
+    }
+    if exists {
+        return nil
+    }
+    if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+        return fmt.Errorf("failed to add column %s to %s: %w", column, table, err)
+    }
+    return nil
+}
+
+// ensureSchemaVersionTable creates the schema_version bookkeeping table.
+func (tm *TodoManager) ensureSchemaVersionTable() error {
+    _, err := tm.db.Exec(`
+    CREATE TABLE IF NOT EXISTS schema_version (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        applied_at DATETIME NOT NULL
+    );
+    `)
+    if err != nil {
+        return fmt.Errorf("failed to create schema_version table: %w", err)
+    }
+    return nil
+}
+
+// SchemaVersion returns the highest migration version applied to the database (0 if none).
+func (tm *TodoManager) SchemaVersion() (int, error) {
+    var version int
+    err := tm.db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+    if err != nil {
+        return 0, fmt.Errorf("failed to read schema version: %w", err)
+    }
+    return version, nil
+}
+
+// appliedMigrations returns all migrations recorded in the schema_version table, oldest first.
+func (tm *TodoManager) appliedMigrations() ([]MigrationInfo, error) {
+    applied := []MigrationInfo{}
+    rows, err := tm.db.Query("SELECT version, name, applied_at FROM schema_version ORDER BY version ASC")
+    if err != nil {
+        return nil, fmt.Errorf("failed to query schema_version: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var m MigrationInfo
+        var appliedAt sql.NullTime
+        if err := rows.Scan(&m.Version, &m.Name, &appliedAt); err != nil {
+            return nil, fmt.Errorf("failed to scan schema_version: %w", err)
+        }
+        m.AppliedAt = NullableTime{Time: appliedAt.Time, Valid: appliedAt.Valid}
+        applied = append(applied, m)
+    }
+    return applied, rows.Err()
+}
+
+// applyMigration runs one migration and records it, all within a single transaction.
+// It returns false if the migration had already been applied (e.g. by another process).
+func (tm *TodoManager) applyMigration(m migration) (bool, error) {
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return false, fmt.Errorf("error starting transaction for migration %d: %w", m.Version, err)
+    }
+    defer tx.Rollback()
+
+    var current int
+    if err := tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&current); err != nil {
+        return false, fmt.Errorf("failed to read schema version: %w", err)
+    }
+    if current >= m.Version {
+        return false, nil
+    }
+
+    if err := m.Apply(tx); err != nil {
+        return false, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+    }
+    _, err = tx.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)", m.Version, m.Name, time.Now().UTC())
+    if err != nil {
+        return false, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+    }
+    if err := tx.Commit(); err != nil {
+        return false, fmt.Errorf("error committing migration %d: %w", m.Version, err)
+    }
+    return true, nil
+}
+
+// checkSchemaNotNewer refuses to work with a database written by a newer binary,
+// because this binary would not know about (and could corrupt) the newer columns.
+func (tm *TodoManager) checkSchemaNotNewer() (int, error) {
+    if err := tm.ensureSchemaVersionTable(); err != nil {
+        return 0, err
+    }
+    current, err := tm.SchemaVersion()
+    if err != nil {
+        return 0, err
+    }
+    if current > LatestSchemaVersion() {
+        return current, fmt.Errorf("%w: database is at version %d, this binary supports up to %d; please upgrade todo", ErrSchemaTooNew, current, LatestSchemaVersion())
+    }
+    return current, nil
+}
+
+// Migrate applies all pending migrations in order and returns the ones it applied.
+func (tm *TodoManager) Migrate() ([]MigrationInfo, error) {
+    current, err := tm.checkSchemaNotNewer()
+    if err != nil {
+        return nil, err
+    }
+
+    applied := []MigrationInfo{}
+    for _, m := range migrations {
+        if m.Version <= current {
+            continue
+        }
+        ok, err := tm.applyMigration(m)
+        if err != nil {
+            return applied, err
+        }
+        if ok {
+            applied = append(applied, MigrationInfo{Version: m.Version, Name: m.Name, AppliedAt: NullableTime{Time: time.Now().UTC(), Valid: true}})
+        }
+    }
+    return applied, nil
+}
+
+// SchemaStatus returns the current schema version of the database together with every
+// migration known to this binary, applied ones first and pending ones after them.
+func (tm *TodoManager) SchemaStatus() (int, []MigrationInfo, error) {
+    current, err := tm.checkSchemaNotNewer()
+    if err != nil {
+        return current, nil, err
+    }
+    status, err := tm.appliedMigrations()
+    if err != nil {
+        return current, nil, err
+    }
+
+    appliedVersions := make(map[int]bool)
+    for _, m := range status {
+        appliedVersions[m.Version] = true
+    }
+    for _, m := range migrations {
+        if !appliedVersions[m.Version] {
+            status = append(status, MigrationInfo{Version: m.Version, Name: m.Name})
+        }
+    }
+    return current, status, nil
+}
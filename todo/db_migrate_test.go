@@ -0,0 +1,208 @@
+package todo
+
+import (
+    "errors"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// baselineData fills a database with the schema of version 1 the way todo wrote it before
+// the schema was versioned: no schema_version table, whole working hours without breaks.
+const baselineData = `
+INSERT INTO projects (id, name) VALUES (1, 'home');
+INSERT INTO contexts (id, name) VALUES (1, 'phone');
+INSERT INTO tasks (id, title, project_id, start_date, due_date, status, recurrence, recurrence_interval)
+    VALUES (1, 'call plumber', 1, '2024-03-01 08:00:00', '2024-03-04 17:00:00', 'pending', 'weekly', 1);
+INSERT INTO tasks (id, title, start_date, end_date, status) VALUES (2, 'pay rent', '2024-03-01 08:00:00', '2024-03-02 10:00:00', 'completed');
+INSERT INTO task_contexts (task_id, context_id) VALUES (1, 1);
+INSERT INTO task_notes (task_id, timestamp, description) VALUES (1, '2024-03-01 09:00:00', 'ask for a quote');
+INSERT INTO holidays (date, name) VALUES ('2024-12-25', 'Christmas');
+INSERT INTO working_hours (day_of_week, start_hour, end_hour) VALUES (1, 9, 17);
+`
+
+func TestMigrate(t *testing.T) {
+    tests := []struct {
+        name    string
+        applied int    // migrations applied before the database is opened, see setupSchema; 0 for none
+        data    string // run after those migrations
+        tasks   int
+    }{
+        {"new database", 0, "", 0},
+        {"baseline schema", 1, baselineData, 2},
+        {"baseline schema without schema_version", -1, baselineData, 2},
+        {"partly migrated", 3, baselineData, 2},
+        {"up to date", len(migrations), "", 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            path := filepath.Join(t.TempDir(), dbFileName)
+            if tt.applied != 0 {
+                setupSchema(t, path, tt.applied, tt.data)
+            }
+
+            tm, err := NewTodoManager(path)
+            if err != nil {
+                t.Fatalf("NewTodoManager: %v", err)
+            }
+            defer tm.Close()
+
+            version, err := tm.SchemaVersion()
+            if err != nil {
+                t.Fatal(err)
+            }
+            if version != LatestSchemaVersion() {
+                t.Errorf("got schema version %d, want %d", version, LatestSchemaVersion())
+            }
+            if applied, err := tm.Migrate(); err != nil || len(applied) != 0 {
+                t.Errorf("second Migrate applied %v, %v; want nothing", applied, err)
+            }
+
+            tasks, err := tm.GetTasks(TaskFilter{Status: "all", SortBy: "id", IncludeNotes: true})
+            if err != nil {
+                t.Fatalf("GetTasks: %v", err)
+            }
+            if len(tasks) != tt.tasks {
+                t.Fatalf("got %d tasks, want %d", len(tasks), tt.tasks)
+            }
+            if tt.tasks == 0 {
+                return
+            }
+            for _, task := range tasks {
+                if !task.UUID.Valid || task.UUID.String == "" {
+                    t.Errorf("task %d has no UUID", task.ID)
+                }
+            }
+            first := tasks[0]
+            if first.ProjectName.String != "home" || len(first.Contexts) != 1 || first.Contexts[0] != "phone" {
+                t.Errorf("task 1 lost its project or context: %+v", first)
+            }
+            if len(first.Notes) != 1 || first.Notes[0].UUID == "" {
+                t.Errorf("task 1 lost its note or the note has no UUID: %+v", first.Notes)
+            }
+            if first.Recurrence.String != "weekly" || first.RecurrenceAnchor.String != AnchorScheduled {
+                t.Errorf("task 1 recurrence is %q anchored on %q", first.Recurrence.String, first.RecurrenceAnchor.String)
+            }
+
+            cal, err := loadWorkCalendar(tm.db)
+            if err != nil {
+                t.Fatal(err)
+            }
+            hours := cal.hours[time.Monday]
+            if hours.StartHour != 9 || hours.StartMinute != 0 || hours.EndHour != 17 || hours.EndMinute != 0 || hours.BreakMinutes != 0 {
+                t.Errorf("got Monday working hours %+v", hours)
+            }
+            if _, ok := cal.holidays["2024-12-25"]; !ok {
+                t.Errorf("holiday lost: %v", cal.holidays)
+            }
+
+            // Changes to the migrated data are logged for sync
+            if _, err := tm.UpdateTasks([]int64{first.ID}, TaskPatch{Title: strPtr("call the plumber")}); err != nil {
+                t.Fatalf("UpdateTasks: %v", err)
+            }
+            device, err := tm.DeviceID()
+            if err != nil {
+                t.Fatal(err)
+            }
+            changes, err := tm.ChangesSince(device, 0)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(changes) == 0 {
+                t.Error("no changes logged after the upgrade")
+            }
+        })
+    }
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+    path := filepath.Join(t.TempDir(), dbFileName)
+    tm, err := NewTodoManager(path)
+    if err != nil {
+        t.Fatalf("NewTodoManager: %v", err)
+    }
+    if _, err := tm.db.Exec("INSERT INTO schema_version (version, name, applied_at) VALUES (?, 'from the future', ?)", LatestSchemaVersion()+1, time.Now().UTC()); err != nil {
+        t.Fatal(err)
+    }
+    tm.Close()
+
+    if _, err := NewTodoManager(path); !errors.Is(err, ErrSchemaTooNew) {
+        t.Errorf("got %v, want ErrSchemaTooNew", err)
+    }
+}
+
+// setupSchema applies the first n migrations to a new database and runs data on it. A
+// negative n creates the tables of the first migration without recording it, as todo did
+// before the schema was versioned.
+func setupSchema(t *testing.T, path string, n int, data string) {
+    t.Helper()
+    tm, err := OpenTodoManager(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer tm.Close()
+
+    if n < 0 {
+        tx, err := tm.db.Begin()
+        if err != nil {
+            t.Fatal(err)
+        }
+        if err := migrateInitialSchema(tx); err != nil {
+            t.Fatal(err)
+        }
+        if err := tx.Commit(); err != nil {
+            t.Fatal(err)
+        }
+    } else {
+        if err := tm.ensureSchemaVersionTable(); err != nil {
+            t.Fatal(err)
+        }
+        for _, m := range migrations[:n] {
+            if _, err := tm.applyMigration(m); err != nil {
+                t.Fatal(err)
+            }
+        }
+    }
+    if data != "" {
+        if _, err := tm.db.Exec(data); err != nil {
+            t.Fatalf("inserting test data: %v", err)
+        }
+    }
+}
+
+func TestMigrateRemovesOrphanRows(t *testing.T) {
+    // Task 1 is still there, the rows of task 2 and context 2 were left behind by older versions
+    const orphans = `
+INSERT INTO contexts (id, name) VALUES (1, 'phone');
+INSERT INTO tags (id, name) VALUES (1, 'urgent');
+INSERT INTO tasks (id, title, status) VALUES (1, 'call plumber', 'pending');
+INSERT INTO task_contexts (task_id, context_id) VALUES (1, 1), (2, 1);
+INSERT INTO task_tags (task_id, tag_id) VALUES (1, 1), (2, 1);
+INSERT INTO task_notes (task_id, timestamp, description) VALUES (1, '2024-03-01 09:00:00', 'kept'), (2, '2024-03-01 09:00:00', 'left behind');
+INSERT INTO time_entries (task_id, start_time, end_time) VALUES (1, '2024-03-01 09:00:00', '2024-03-01 10:00:00'), (2, '2024-03-01 09:00:00', '2024-03-01 10:00:00');
+INSERT INTO task_dependencies (task_id, depends_on_id) VALUES (1, 2), (2, 1);
+INSERT INTO context_hours (context_id, day_of_week, start_hour, end_hour) VALUES (1, 1, 9, 12), (2, 1, 9, 12);
+`
+    path := filepath.Join(t.TempDir(), dbFileName)
+    setupSchema(t, path, 14, orphans)
+    tm, err := NewTodoManager(path)
+    if err != nil {
+        t.Fatalf("NewTodoManager: %v", err)
+    }
+    defer tm.Close()
+
+    if rows := taskRows(t, tm.db, 2); len(rows) != 0 {
+        t.Errorf("rows of task 2 left: %v", rows)
+    }
+    want := map[string]int{"task_contexts": 1, "task_tags": 1, "task_notes": 1, "time_entries": 1}
+    if rows := taskRows(t, tm.db, 1); len(rows) != len(want) {
+        t.Errorf("got rows of task 1 %v, want %v", rows, want)
+    }
+    var hours int
+    if err := tm.db.QueryRow("SELECT COUNT(*) FROM context_hours").Scan(&hours); err != nil {
+        t.Fatal(err)
+    }
+    if hours != 1 {
+        t.Errorf("got %d context hours, want 1", hours)
+    }
+}
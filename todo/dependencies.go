@@ -0,0 +1,264 @@
+package todo
+
+import (
+    "database/sql"
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// Dependencies order tasks: a task that depends on other tasks is blocked until all of them
+// are completed or cancelled. They are stored in the task_dependencies table, which never
+// contains a cycle when written through TodoManager.
+
+// getDependencies returns the IDs of the tasks a task depends on, and those of them that
+// are still open and so block it.
+func getDependencies(q queryer, id int64) (dependsOn, blockedBy []int64, err error) {
+    rows, err := q.Query(`
+        SELECT d.depends_on_id, t.status FROM task_dependencies d
+        JOIN tasks t ON d.depends_on_id = t.id
+        WHERE d.task_id = ?
+        ORDER BY d.depends_on_id
+    `, id)
+    if err != nil {
+        return nil, nil, fmt.Errorf("error getting dependencies of task %d: %w", id, err)
+    }
+    defer rows.Close()
+
+    dependsOn, blockedBy = []int64{}, []int64{}
+    for rows.Next() {
+        var dep int64
+        var status string
+        if err := rows.Scan(&dep, &status); err != nil {
+            return nil, nil, fmt.Errorf("error scanning dependency of task %d: %w", id, err)
+        }
+        dependsOn = append(dependsOn, dep)
+        if isOpen(status) {
+            blockedBy = append(blockedBy, dep)
+        }
+    }
+    return dependsOn, blockedBy, rows.Err()
+}
+
+// getDependents returns the IDs of the tasks that depend on a task.
+func getDependents(q queryer, id int64) ([]int64, error) {
+    return queryIDs(q, "SELECT task_id FROM task_dependencies WHERE depends_on_id = ? ORDER BY task_id", id)
+}
+
+// checkDependencies reports dependencies on tasks that do not exist, on the task itself,
+// or on tasks that already depend on it, directly or through other tasks.
+func checkDependencies(q queryer, id int64, dependsOn []int64) error {
+    var p problems
+    for _, dep := range dependsOn {
+        if dep == id {
+            p.add("task %d cannot depend on itself", id)
+            continue
+        }
+        var exists int
+        if err := q.QueryRow("SELECT COUNT(*) FROM tasks WHERE id = ?", dep).Scan(&exists); err != nil {
+            return fmt.Errorf("error looking up task %d: %w", dep, err)
+        }
+        if exists == 0 {
+            p.add("task %d cannot depend on task %d, which does not exist", id, dep)
+            continue
+        }
+        path, err := dependencyPath(q, dep, id)
+        if err != nil {
+            return err
+        }
+        if path != nil {
+            return fmt.Errorf("%w: task %d cannot depend on task %d, which already depends on it (%s)", ErrDependencyCycle, id, dep, formatIDs(path, " -> "))
+        }
+    }
+    return p.err()
+}
+
+// dependencyPath returns the shortest chain of dependencies leading from one task to another,
+// both included, or nil when from does not depend on to.
+func dependencyPath(q queryer, from, to int64) ([]int64, error) {
+    previous := map[int64]int64{from: 0}
+    queue := []int64{from}
+    for len(queue) > 0 {
+        id := queue[0]
+        queue = queue[1:]
+        deps, err := queryIDs(q, "SELECT depends_on_id FROM task_dependencies WHERE task_id = ?", id)
+        if err != nil {
+            return nil, err
+        }
+        for _, dep := range deps {
+            if _, seen := previous[dep]; seen {
+                continue
+            }
+            previous[dep] = id
+            if dep == to {
+                path := []int64{to}
+                for step := id; step != 0; step = previous[step] {
+                    path = append([]int64{step}, path...)
+                }
+                return path, nil
+            }
+            queue = append(queue, dep)
+        }
+    }
+    return nil, nil
+}
+
+// setDependencies replaces the dependencies of a task after checking them.
+func setDependencies(tx *sql.Tx, id int64, dependsOn []int64) error {
+    if err := checkDependencies(tx, id, dependsOn); err != nil {
+        return err
+    }
+    if _, err := tx.Exec("DELETE FROM task_dependencies WHERE task_id = ?", id); err != nil {
+        return fmt.Errorf("failed to clear dependencies of task %d: %w", id, err)
+    }
+    for _, dep := range dependsOn {
+        if _, err := tx.Exec("INSERT OR IGNORE INTO task_dependencies (task_id, depends_on_id) VALUES (?, ?)", id, dep); err != nil {
+            return fmt.Errorf("failed to add dependency of task %d on task %d: %w", id, dep, err)
+        }
+    }
+    return nil
+}
+
+// updateDependencies applies the replace, clear, add and remove operations of a patch to the
+// dependencies of a task.
+func updateDependencies(tx *sql.Tx, id int64, current []int64, patch TaskPatch) error {
+    if !patch.ClearDependsOn && patch.DependsOn == nil && len(patch.AddDependsOn) == 0 && len(patch.RemoveDependsOn) == 0 {
+        return nil
+    }
+    return setDependencies(tx, id, patchedDependencies(current, patch))
+}
+
+// patchedDependencies returns the dependencies of a task once a patch is applied.
+func patchedDependencies(current []int64, patch TaskPatch) []int64 {
+    if patch.ClearDependsOn {
+        return []int64{}
+    }
+    if patch.DependsOn != nil {
+        return *patch.DependsOn
+    }
+    deps := map[int64]bool{}
+    for _, dep := range current {
+        deps[dep] = true
+    }
+    for _, dep := range patch.AddDependsOn {
+        deps[dep] = true
+    }
+    for _, dep := range patch.RemoveDependsOn {
+        delete(deps, dep)
+    }
+    result := []int64{}
+    for dep := range deps {
+        result = append(result, dep)
+    }
+    sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+    return result
+}
+
+// openBlockers returns the dependencies that are still open.
+func openBlockers(q queryer, dependsOn []int64) ([]int64, error) {
+    var blockers []int64
+    for _, dep := range dependsOn {
+        var status string
+        err := q.QueryRow("SELECT status FROM tasks WHERE id = ?", dep).Scan(&status)
+        if err == sql.ErrNoRows {
+            continue
+        } else if err != nil {
+            return nil, fmt.Errorf("error looking up task %d: %w", dep, err)
+        }
+        if isOpen(status) {
+            blockers = append(blockers, dep)
+        }
+    }
+    return blockers, nil
+}
+
+// dependencyOrder orders task IDs by ID, except that tasks come after the tasks they depend
+// on that are in the list too, so an update can complete a task and its dependencies at once.
+func dependencyOrder(q queryer, ids []int64) ([]int64, error) {
+    sorted := append([]int64{}, ids...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+    inList := map[int64]bool{}
+    for _, id := range sorted {
+        inList[id] = true
+    }
+
+    ordered := make([]int64, 0, len(sorted))
+    visited := map[int64]bool{}
+    var visit func(id int64) error
+    visit = func(id int64) error {
+        if visited[id] {
+            return nil
+        }
+        visited[id] = true
+        deps, err := queryIDs(q, "SELECT depends_on_id FROM task_dependencies WHERE task_id = ? ORDER BY depends_on_id", id)
+        if err != nil {
+            return err
+        }
+        for _, dep := range deps {
+            if inList[dep] {
+                if err := visit(dep); err != nil {
+                    return err
+                }
+            }
+        }
+        ordered = append(ordered, id)
+        return nil
+    }
+    for _, id := range sorted {
+        if err := visit(id); err != nil {
+            return nil, err
+        }
+    }
+    return ordered, nil
+}
+
+// blockedError reports a task that cannot be completed because of its open dependencies.
+func blockedError(id int64, blockers []int64) error {
+    return fmt.Errorf("task %d: %w by open tasks %s", id, ErrBlocked, formatIDs(blockers, ", "))
+}
+
+// removeDependencies deletes the dependencies of a task and on it, before the task itself is
+// deleted, and returns the tasks that depended on it.
+func removeDependencies(tx *sql.Tx, id int64) ([]int64, error) {
+    dependents, err := getDependents(tx, id)
+    if err != nil {
+        return nil, err
+    }
+    if _, err := tx.Exec("DELETE FROM task_dependencies WHERE task_id = ? OR depends_on_id = ?", id, id); err != nil {
+        return nil, fmt.Errorf("failed to remove dependencies of task %d: %w", id, err)
+    }
+    return dependents, nil
+}
+
+// dependencyUUIDs returns the sorted UUIDs of the tasks a task depends on, as logged for sync.
+func dependencyUUIDs(q queryer, id int64) ([]string, error) {
+    rows, err := q.Query(`
+        SELECT t.uuid FROM task_dependencies d
+        JOIN tasks t ON d.depends_on_id = t.id
+        WHERE d.task_id = ? AND t.uuid IS NOT NULL
+        ORDER BY t.uuid
+    `, id)
+    if err != nil {
+        return nil, fmt.Errorf("error getting dependencies of task %d: %w", id, err)
+    }
+    defer rows.Close()
+    uuids := []string{}
+    for rows.Next() {
+        var uuid string
+        if err := rows.Scan(&uuid); err != nil {
+            return nil, fmt.Errorf("error scanning dependency of task %d: %w", id, err)
+        }
+        uuids = append(uuids, uuid)
+    }
+    return uuids, rows.Err()
+}
+
+// formatIDs joins task IDs with a separator.
+func formatIDs(ids []int64, sep string) string {
+    parts := make([]string, len(ids))
+    for i, id := range ids {
+        parts[i] = strconv.FormatInt(id, 10)
+    }
+    return strings.Join(parts, sep)
+}
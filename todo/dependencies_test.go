@@ -0,0 +1,152 @@
+package todo
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+)
+
+// addChain adds the tasks 1 to 4, where 2 depends on 1 and 3 depends on 2; 4 stands alone.
+func addChain(t *testing.T, tm *TodoManager) {
+    t.Helper()
+    inputs := []TaskInput{
+        {Title: "write changelog"},
+        {Title: "tag release", DependsOn: []int64{1}},
+        {Title: "publish", DependsOn: []int64{2}},
+        {Title: "announce"},
+    }
+    for _, in := range inputs {
+        if _, err := tm.AddTask(in); err != nil {
+            t.Fatalf("AddTask(%s): %v", in.Title, err)
+        }
+    }
+}
+
+func TestCheckDependencies(t *testing.T) {
+    tm := newTestManager(t)
+    addChain(t, tm)
+    tests := []struct {
+        id        int64
+        dependsOn []int64
+        err       error
+    }{
+        {3, []int64{1, 4}, nil},
+        {4, []int64{3}, nil},
+        {1, []int64{1}, ErrInvalidInput},
+        {1, []int64{9}, ErrInvalidInput},
+        {1, []int64{2}, ErrDependencyCycle},
+        {1, []int64{4, 3}, ErrDependencyCycle},
+    }
+    for _, tt := range tests {
+        if err := checkDependencies(tm.db, tt.id, tt.dependsOn); !errors.Is(err, tt.err) {
+            t.Errorf("checkDependencies(%d, %v) = %v, want %v", tt.id, tt.dependsOn, err, tt.err)
+        }
+    }
+}
+
+func TestDependencyPath(t *testing.T) {
+    tm := newTestManager(t)
+    addChain(t, tm)
+    tests := []struct {
+        from, to int64
+        want     string
+    }{
+        {3, 1, "[3 2 1]"},
+        {2, 1, "[2 1]"},
+        {1, 3, "[]"},
+        {4, 1, "[]"},
+    }
+    for _, tt := range tests {
+        path, err := dependencyPath(tm.db, tt.from, tt.to)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got := fmt.Sprint(path); got != tt.want {
+            t.Errorf("dependencyPath(%d, %d) = %s, want %s", tt.from, tt.to, got, tt.want)
+        }
+    }
+}
+
+func TestPatchedDependencies(t *testing.T) {
+    tests := []struct {
+        name  string
+        patch TaskPatch
+        want  string
+    }{
+        {"unchanged", TaskPatch{}, "[2 5]"},
+        {"replaced", TaskPatch{DependsOn: &[]int64{7}}, "[7]"},
+        {"cleared", TaskPatch{ClearDependsOn: true, AddDependsOn: []int64{7}}, "[]"},
+        {"added and removed", TaskPatch{AddDependsOn: []int64{1, 5}, RemoveDependsOn: []int64{2}}, "[1 5]"},
+    }
+    for _, tt := range tests {
+        if got := fmt.Sprint(patchedDependencies([]int64{2, 5}, tt.patch)); got != tt.want {
+            t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestCompleteBlockedTask(t *testing.T) {
+    tests := []struct {
+        name   string
+        ids    []int64
+        patch  TaskPatch
+        before []int64 // tasks completed first
+        err    error   // of task 3
+    }{
+        {name: "blocked", ids: []int64{3}, err: ErrBlocked},
+        {name: "blocked by a task that is still open", ids: []int64{3}, before: []int64{1}, err: ErrBlocked},
+        {name: "blockers completed", ids: []int64{3}, before: []int64{1, 2}},
+        {name: "with its blockers", ids: []int64{3, 2, 1}},
+        {name: "ignoring blockers", ids: []int64{3}, patch: TaskPatch{IgnoreBlockers: true}},
+        {name: "dependency removed", ids: []int64{3}, patch: TaskPatch{RemoveDependsOn: []int64{2}}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            addChain(t, tm)
+            for _, id := range tt.before {
+                update(t, tm, id, TaskPatch{Status: strPtr("completed")})
+            }
+            patch := tt.patch
+            patch.Status = strPtr("completed")
+            results, err := tm.UpdateTasks(tt.ids, patch)
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, result := range results {
+                want := error(nil)
+                if result.TaskID == 3 {
+                    want = tt.err
+                }
+                if !errors.Is(result.Err, want) {
+                    t.Errorf("task %d: got %v, want %v", result.TaskID, result.Err, want)
+                }
+            }
+            task, err := tm.GetTask(3)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if completed := task.Status == "completed"; completed != (tt.err == nil) {
+                t.Errorf("task 3 is %s", task.Status)
+            }
+        })
+    }
+}
+
+func TestBlockedBy(t *testing.T) {
+    tm := newTestManager(t)
+    addChain(t, tm)
+    update(t, tm, 3, TaskPatch{AddDependsOn: []int64{1}})
+    update(t, tm, 1, TaskPatch{Status: strPtr("cancelled")})
+    if err := tm.DeleteTask(2, false, ""); err != nil {
+        t.Fatal(err)
+    }
+    task, err := tm.GetTask(3)
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Cancelled tasks no longer block, and deleted ones are no dependencies
+    if fmt.Sprint(task.DependsOn) != "[1]" || len(task.BlockedBy) != 0 {
+        t.Errorf("got dependencies %v, blocked by %v", task.DependsOn, task.BlockedBy)
+    }
+}
@@ -0,0 +1,104 @@
+package todo
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// Sentinel errors returned by TodoManager. Use errors.Is to test for them,
+// since they are usually wrapped with details about the failing record.
+var (
+    ErrTaskNotFound         = errors.New("task not found")
+    ErrNoteNotFound         = errors.New("note not found")
+    ErrHolidayNotFound      = errors.New("holiday not found")
+    ErrWorkingHoursNotFound = errors.New("working hours not found")
+    ErrNoChanges            = errors.New("no update parameters provided")
+    ErrInvalidDate          = errors.New("invalid date")
+    ErrInvalidInput         = errors.New("invalid input")
+    ErrConstraint           = errors.New("constraint violation")
+    ErrSchemaTooNew         = errors.New("database schema is newer than this binary supports")
+    ErrNotFound             = errors.New("not found")
+    ErrAmbiguousID          = errors.New("ambiguous ID: more than one record matches")
+    ErrNotRecurring         = errors.New("task is not part of a recurring series")
+    ErrBlocked              = errors.New("task is blocked")
+    ErrDependencyCycle      = errors.New("dependency cycle")
+    ErrTimerRunning         = errors.New("a timer is already running")
+    ErrNoTimerRunning       = errors.New("no timer is running")
+    ErrOutsideWorkingHours  = errors.New("outside working hours")
+)
+
+// DateError reports a date/time value that could not be parsed.
+// It matches ErrInvalidDate with errors.Is.
+type DateError struct {
+    Field string // e.g. "start date", "due date"
+    Value string
+    Err   error
+}
+
+func (e *DateError) Error() string {
+    return fmt.Sprintf("invalid %s '%s': %v", e.Field, e.Value, e.Err)
+}
+
+func (e *DateError) Unwrap() error {
+    return e.Err
+}
+
+func (e *DateError) Is(target error) bool {
+    return target == ErrInvalidDate
+}
+
+// ConstraintError wraps a database constraint violation, such as a duplicate holiday date.
+// It matches ErrConstraint with errors.Is.
+type ConstraintError struct {
+    Err error
+}
+
+func (e *ConstraintError) Error() string {
+    return fmt.Sprintf("constraint violation: %v", e.Err)
+}
+
+func (e *ConstraintError) Unwrap() error {
+    return e.Err
+}
+
+func (e *ConstraintError) Is(target error) bool {
+    return target == ErrConstraint
+}
+
+// ValidationError collects every problem found while validating a TaskInput or TaskPatch,
+// so they can be reported at once. It matches ErrInvalidInput with errors.Is, and also
+// ErrInvalidDate when one of the problems is a date.
+type ValidationError struct {
+    Problems []error
+}
+
+func (e *ValidationError) Error() string {
+    messages := make([]string, len(e.Problems))
+    for i, p := range e.Problems {
+        messages[i] = p.Error()
+    }
+    return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+    return e.Problems
+}
+
+func (e *ValidationError) Is(target error) bool {
+    return target == ErrInvalidInput
+}
+
+// isConstraintError reports whether a database error is a constraint violation.
+// The SQLite driver reports these as e.g. "UNIQUE constraint failed: holidays.date".
+func isConstraintError(err error) bool {
+    return err != nil && strings.Contains(err.Error(), "constraint failed")
+}
+
+// wrapDBError converts constraint violations into ConstraintError and leaves other errors untouched.
+func wrapDBError(err error) error {
+    if isConstraintError(err) {
+        return &ConstraintError{Err: err}
+    }
+    return err
+}
@@ -0,0 +1,211 @@
+package todo
+
+import (
+    "database/sql"
+    "fmt"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// An estimate is the working time a task is expected to take, given as hours and minutes
+// (3h, 90m, 1h30m) or working days (2d, 1.5d). A working day is the average length of the
+// days in working_hours, breaks excluded, or 8 hours while none are defined. Estimates are
+// stored in minutes, so changing the working hours later does not change them.
+
+// defaultWorkingDay is the length of a working day while no working hours are defined.
+const defaultWorkingDay = 8 * time.Hour
+
+var estimatePart = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([dhm])`)
+
+// parseEstimate parses an estimate, counting days as day of working time.
+func parseEstimate(s string, day time.Duration) (time.Duration, error) {
+    rest := strings.ToLower(strings.TrimSpace(s))
+    if rest == "" {
+        return 0, fmt.Errorf("%w: estimate is empty", ErrInvalidInput)
+    }
+    var total time.Duration
+    for rest != "" {
+        m := estimatePart.FindStringSubmatch(rest)
+        if m == nil {
+            return 0, fmt.Errorf("%w: invalid estimate '%s' (e.g., '3h', '1h30m', '2d')", ErrInvalidInput, s)
+        }
+        n, _ := strconv.ParseFloat(m[1], 64)
+        unit := map[string]time.Duration{"d": day, "h": time.Hour, "m": time.Minute}[m[2]]
+        total += time.Duration(n * float64(unit))
+        rest = strings.TrimSpace(rest[len(m[0]):])
+    }
+    if total < time.Minute {
+        return 0, fmt.Errorf("%w: estimate '%s' must be at least a minute", ErrInvalidInput, s)
+    }
+    return total.Round(time.Minute), nil
+}
+
+func (p *problems) checkEstimate(estimate string) {
+    if estimate == "" || strings.EqualFold(strings.TrimSpace(estimate), "none") {
+        return
+    }
+    if _, err := parseEstimate(estimate, defaultWorkingDay); err != nil {
+        p.add("%s", strings.TrimPrefix(err.Error(), ErrInvalidInput.Error()+": "))
+    }
+}
+
+// workingDayLength returns the average working time of the days with working hours.
+func workingDayLength(q queryer) (time.Duration, error) {
+    hours, err := getWorkingHours(q)
+    if err != nil {
+        return 0, err
+    }
+    var total time.Duration
+    days := 0
+    for _, wh := range hours {
+        length := time.Duration((wh.EndHour*60+wh.EndMinute)-(wh.StartHour*60+wh.StartMinute)-wh.BreakMinutes) * time.Minute
+        if length > 0 {
+            total += length
+            days++
+        }
+    }
+    if days == 0 {
+        return defaultWorkingDay, nil
+    }
+    return total / time.Duration(days), nil
+}
+
+// resolveEstimate converts an estimate to the minutes stored in the estimate_minutes column;
+// an empty estimate or "none" is no estimate.
+func resolveEstimate(q queryer, estimate string) (sql.NullInt64, error) {
+    if estimate == "" || strings.EqualFold(strings.TrimSpace(estimate), "none") {
+        return sql.NullInt64{}, nil
+    }
+    day, err := workingDayLength(q)
+    if err != nil {
+        return sql.NullInt64{}, err
+    }
+    d, err := parseEstimate(estimate, day)
+    if err != nil {
+        return sql.NullInt64{}, err
+    }
+    return sql.NullInt64{Int64: int64(d / time.Minute), Valid: true}, nil
+}
+
+// EstimateDuration returns the estimate of a task, 0 when it has none.
+func (task Task) EstimateDuration() time.Duration {
+    if !task.Estimate.Valid {
+        return 0
+    }
+    return time.Duration(task.Estimate.Int64) * time.Minute
+}
+
+// FormatEstimate formats working time in hours and minutes, e.g. "12h 30m", since days of
+// working time depend on the working hours.
+func FormatEstimate(d time.Duration) string {
+    d = d.Round(time.Minute)
+    hours, minutes := int(d/time.Hour), int(d%time.Hour/time.Minute)
+    switch {
+    case hours == 0:
+        return fmt.Sprintf("%dm", minutes)
+    case minutes == 0:
+        return fmt.Sprintf("%dh", hours)
+    }
+    return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
+// AccuracyGroup compares the estimates of the completed tasks of a project or tag with the
+// working time they actually took, from their start to their end date.
+type AccuracyGroup struct {
+    Name      string
+    Tasks     int
+    Overruns  int // tasks that took longer than estimated
+    Estimated time.Duration
+    Actual    time.Duration
+}
+
+// Ratio returns the actual over the estimated working time: above 1 when tasks took longer.
+func (g AccuracyGroup) Ratio() float64 {
+    if g.Estimated == 0 {
+        return 0
+    }
+    return float64(g.Actual) / float64(g.Estimated)
+}
+
+func (g *AccuracyGroup) add(estimated, actual time.Duration) {
+    g.Tasks++
+    g.Estimated += estimated
+    g.Actual += actual
+    if actual > estimated {
+        g.Overruns++
+    }
+}
+
+// AccuracyReport groups the completed tasks with an estimate by project and by tag. A task
+// with several tags counts for each of them.
+type AccuracyReport struct {
+    Total     AccuracyGroup
+    ByProject []AccuracyGroup // "" for tasks without a project
+    ByTag     []AccuracyGroup // "" for tasks without tags
+}
+
+// EstimateAccuracy compares the estimates of the completed tasks with a start and an end date
+// against the working time between them, as computed by CalculateWorkingHoursDuration.
+func (tm *TodoManager) EstimateAccuracy() (*AccuracyReport, error) {
+    tasks, err := tm.GetTasks(TaskFilter{Status: "completed", SortBy: "id"})
+    if err != nil {
+        return nil, err
+    }
+    workingHours, err := tm.GetWorkingHours()
+    if err != nil {
+        return nil, err
+    }
+    holidays, err := tm.GetHolidaysMap()
+    if err != nil {
+        return nil, err
+    }
+
+    report := &AccuracyReport{}
+    projects := map[string]*AccuracyGroup{}
+    tags := map[string]*AccuracyGroup{}
+    group := func(groups map[string]*AccuracyGroup, name string) *AccuracyGroup {
+        if groups[name] == nil {
+            groups[name] = &AccuracyGroup{Name: name}
+        }
+        return groups[name]
+    }
+    for _, task := range tasks {
+        if !task.Estimate.Valid || !task.StartDate.Valid || !task.EndDate.Valid {
+            continue
+        }
+        estimated := task.EstimateDuration()
+        actual := CalculateWorkingHoursDuration(tm.db, task.StartDate, task.EndDate, workingHours, holidays)
+        report.Total.add(estimated, actual)
+        group(projects, task.ProjectName.String).add(estimated, actual)
+        if len(task.Tags) == 0 {
+            group(tags, "").add(estimated, actual)
+        }
+        for _, tag := range task.Tags {
+            group(tags, tag).add(estimated, actual)
+        }
+    }
+    report.ByProject = sortedGroups(projects)
+    report.ByTag = sortedGroups(tags)
+    return report, nil
+}
+
+// sortedGroups orders groups by name, with the unnamed group last.
+func sortedGroups(groups map[string]*AccuracyGroup) []AccuracyGroup {
+    result := make([]AccuracyGroup, 0, len(groups))
+    for _, g := range groups {
+        result = append(result, *g)
+    }
+    sort.Slice(result, func(i, j int) bool { return namedFirst(result[i].Name, result[j].Name) })
+    return result
+}
+
+// namedFirst orders group names alphabetically, with the unnamed group last.
+func namedFirst(a, b string) bool {
+    if (a == "") != (b == "") {
+        return b == ""
+    }
+    return a < b
+}
@@ -0,0 +1,138 @@
+package todo
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestParseEstimate(t *testing.T) {
+    tests := []struct {
+        estimate string
+        want     time.Duration
+        ok       bool
+    }{
+        {"3h", 3 * time.Hour, true},
+        {"90m", 90 * time.Minute, true},
+        {"1h30m", 90 * time.Minute, true},
+        {" 1H 30M ", 90 * time.Minute, true},
+        {"2d", 14 * time.Hour, true},
+        {"1.5d", 10*time.Hour + 30*time.Minute, true},
+        {"0.5h", 30 * time.Minute, true},
+        {"", 0, false},
+        {"0m", 0, false},
+        {"3 hours", 0, false},
+        {"2w", 0, false},
+        {"h", 0, false},
+    }
+    for _, tt := range tests {
+        got, err := parseEstimate(tt.estimate, 7*time.Hour)
+        if (err == nil) != tt.ok || got != tt.want {
+            t.Errorf("parseEstimate(%q) = %s, %v, want %s and ok %v", tt.estimate, got, err, tt.want, tt.ok)
+        }
+        if err != nil && !errors.Is(err, ErrInvalidInput) {
+            t.Errorf("parseEstimate(%q) = %v, want ErrInvalidInput", tt.estimate, err)
+        }
+    }
+}
+
+func TestFormatEstimate(t *testing.T) {
+    tests := []struct {
+        d    time.Duration
+        want string
+    }{
+        {0, "0m"},
+        {45 * time.Minute, "45m"},
+        {3 * time.Hour, "3h"},
+        {26*time.Hour + 5*time.Minute, "26h 5m"},
+        {89*time.Minute + 40*time.Second, "1h 30m"},
+    }
+    for _, tt := range tests {
+        if got := FormatEstimate(tt.d); got != tt.want {
+            t.Errorf("FormatEstimate(%s) = %q, want %q", tt.d, got, tt.want)
+        }
+    }
+}
+
+func TestEstimateInWorkingDays(t *testing.T) {
+    tests := []struct {
+        name     string
+        hours    [][2]int // start and end hour of the days from Monday, with an hour's break
+        estimate string
+        want     int64 // minutes
+    }{
+        {"no working hours", nil, "2d", 16 * 60},
+        {"equal days", [][2]int{{9, 17}, {9, 17}, {9, 17}, {9, 17}, {9, 17}}, "2d", 14 * 60},
+        {"short Friday", [][2]int{{9, 17}, {9, 17}, {9, 17}, {9, 17}, {9, 13}}, "1d", 372},
+        {"hours", [][2]int{{9, 17}}, "1h30m", 90},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            for i, h := range tt.hours {
+                if _, err := tm.SetWorkingHours(int(time.Monday)+i, h[0], 0, h[1], 0, 60); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            task, err := tm.AddTask(TaskInput{Title: "write report", Estimate: tt.estimate})
+            if err != nil {
+                t.Fatal(err)
+            }
+            if task.Estimate.Int64 != tt.want {
+                t.Errorf("got %d minutes, want %d", task.Estimate.Int64, tt.want)
+            }
+        })
+    }
+}
+
+func TestEstimateAccuracy(t *testing.T) {
+    tm := newTestManager(t)
+    for day := time.Monday; day <= time.Friday; day++ {
+        if _, err := tm.SetWorkingHours(int(day), 9, 0, 17, 0, 0); err != nil {
+            t.Fatal(err)
+        }
+    }
+    inputs := []TaskInput{
+        {Title: "paint door", Project: "home", Tags: []string{"diy"}, Estimate: "2h",
+            StartDate: strPtr("2026-03-04 09:00:00"), EndDate: strPtr("2026-03-04 12:00:00")},
+        {Title: "fix shelf", Project: "home", Tags: []string{"diy", "quick"}, Estimate: "4h",
+            StartDate: strPtr("2026-03-05 09:00:00"), EndDate: strPtr("2026-03-05 11:00:00")},
+        {Title: "call plumber", Estimate: "1h", StartDate: strPtr("2026-03-06 09:00:00"), EndDate: strPtr("2026-03-06 10:00:00")},
+        {Title: "file taxes", StartDate: strPtr("2026-03-06 09:00:00"), EndDate: strPtr("2026-03-06 10:00:00")},
+        {Title: "write report", Project: "home", Estimate: "1h", StartDate: strPtr("2026-03-06 09:00:00")},
+    }
+    for _, in := range inputs {
+        if _, err := tm.AddTask(in); err != nil {
+            t.Fatalf("AddTask(%s): %v", in.Title, err)
+        }
+    }
+    report, err := tm.EstimateAccuracy()
+    if err != nil {
+        t.Fatal(err)
+    }
+    format := func(g AccuracyGroup) string {
+        return fmt.Sprintf("%s: %d tasks, %d over, %s of %s", g.Name, g.Tasks, g.Overruns, FormatEstimate(g.Actual), FormatEstimate(g.Estimated))
+    }
+    tests := []struct {
+        name   string
+        groups []AccuracyGroup
+        want   []string
+    }{
+        {"total", []AccuracyGroup{report.Total}, []string{": 3 tasks, 1 over, 6h of 7h"}},
+        {"by project", report.ByProject, []string{"home: 2 tasks, 1 over, 5h of 6h", ": 1 tasks, 0 over, 1h of 1h"}},
+        {"by tag", report.ByTag, []string{"diy: 2 tasks, 1 over, 5h of 6h", "quick: 1 tasks, 0 over, 2h of 4h", ": 1 tasks, 0 over, 1h of 1h"}},
+    }
+    for _, tt := range tests {
+        var got []string
+        for _, g := range tt.groups {
+            got = append(got, format(g))
+        }
+        if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+            t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+        }
+    }
+    if ratio := report.ByTag[1].Ratio(); ratio != 0.5 {
+        t.Errorf("got ratio %.2f for quick, want 0.50", ratio)
+    }
+}
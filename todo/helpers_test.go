@@ -0,0 +1,57 @@
+package todo
+
+import (
+    "fmt"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// newTestManager opens a new, fully migrated database in a temporary directory.
+func newTestManager(t *testing.T) *TodoManager {
+    t.Helper()
+    tm, err := NewTodoManager(filepath.Join(t.TempDir(), dbFileName))
+    if err != nil {
+        t.Fatalf("NewTodoManager: %v", err)
+    }
+    t.Cleanup(func() { tm.Close() })
+    return tm
+}
+
+// localTime parses a "2006-01-02 15:04" time in local time.
+func localTime(t *testing.T, value string) time.Time {
+    t.Helper()
+    parsed, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local)
+    if err != nil {
+        t.Fatalf("bad test time %q: %v", value, err)
+    }
+    return parsed
+}
+
+func strPtr(s string) *string {
+    return &s
+}
+
+// transferSummary formats the fields of a task that exports and imports carry, to compare a
+// task with what reading it back gives. The interval only counts for recurring tasks, where
+// none means 1.
+func transferSummary(task Task) string {
+    date := func(nt NullableTime) string {
+        if !nt.Valid {
+            return "-"
+        }
+        return nt.Time.UTC().Format("2006-01-02 15:04:05")
+    }
+    interval := ""
+    if task.Recurrence.String != "" {
+        interval = "1"
+        if task.RecurrenceInterval.Int64 > 1 {
+            interval = fmt.Sprint(task.RecurrenceInterval.Int64)
+        }
+    }
+    return fmt.Sprintf("%s|%s|%s|%s|%s|%d|%s/%s|%s|%s|start %s|due %s|end %s|waiting %s to %s|%v|%v|%s", task.Title,
+        task.Description.String, task.Status, task.ProjectName.String, task.Priority.String, task.Estimate.Int64,
+        task.Recurrence.String, interval, task.RecurrenceShift.String, task.RecurrenceAnchor.String, date(task.StartDate),
+        date(task.DueDate), date(task.EndDate), date(task.StartWaitingDate), date(task.EndWaitingDate), task.Contexts, task.Tags,
+        task.UUID.String)
+}
@@ -0,0 +1,457 @@
+package todo
+
+import (
+    "bufio"
+    "database/sql"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// iCalendar (RFC 5545) support: tasks are exchanged as VTODO components and holidays as
+// all-day VEVENT components. Properties without an RFC 5545 equivalent (project, contexts,
+// the waiting status) use X-TODO-* extension properties, which calendar clients ignore.
+
+const (
+    icalDateTime = "20060102T150405Z"
+    icalDate     = "20060102"
+)
+
+// icalStatuses maps task statuses to VTODO STATUS values.
+var icalStatuses = map[string]string{
+    "pending":   "NEEDS-ACTION",
+    "waiting":   "NEEDS-ACTION",
+    "completed": "COMPLETED",
+    "cancelled": "CANCELLED",
+}
+
+// icalPriorities maps task priorities to VTODO PRIORITY values; on import 1 to 4 is high,
+// 5 medium and 6 to 9 low.
+var icalPriorities = map[string]string{PriorityHigh: "1", PriorityMedium: "5", PriorityLow: "9"}
+
+// ICalendarFields are the task fields an iCalendar file holds, for ImportTasks.
+const ICalendarFields = FieldDescription | FieldProject | FieldStatus | FieldPriority | FieldStartDate | FieldDueDate |
+    FieldEndDate | FieldRecurrence | FieldContexts | FieldTags
+
+// Calendar holds the tasks and holidays read from an iCalendar file.
+type Calendar struct {
+    Tasks    []Task
+    Holidays []Holiday
+    Warnings []string // components or properties that could not be fully translated
+}
+
+// WriteICalendar writes tasks as VTODO components and holidays as all-day VEVENT components.
+//
+// Recurrence becomes an RRULE, the status a VTODO STATUS, the H, M and L priorities PRIORITY
+// 1, 5 and 9, tags CATEGORIES, and the end date of a completed task COMPLETED. The workdaily
+// pattern, which no RRULE expresses, is also kept in X-TODO-RECURRENCE and X-TODO-INTERVAL,
+// and the end date of a cancelled task in X-TODO-END.
+func WriteICalendar(w io.Writer, tasks []Task, holidays []Holiday) error {
+    iw := &icalWriter{w: bufio.NewWriter(w)}
+    now := time.Now().UTC().Format(icalDateTime)
+
+    iw.line("BEGIN", "VCALENDAR")
+    iw.line("VERSION", "2.0")
+    iw.line("PRODID", "-//igorp74//ToDo//EN")
+    iw.line("CALSCALE", "GREGORIAN")
+
+    for _, task := range tasks {
+        iw.line("BEGIN", "VTODO")
+        iw.line("UID", icalUID(task.UUID.String, fmt.Sprintf("task-%d@todo", task.ID)))
+        iw.line("DTSTAMP", now)
+        iw.line("SUMMARY", icalEscape(task.Title))
+        if task.Description.Valid && task.Description.String != "" {
+            iw.line("DESCRIPTION", icalEscape(task.Description.String))
+        }
+        iw.time("DTSTART", task.StartDate)
+        iw.time("DUE", task.DueDate)
+        if task.Status == "completed" {
+            iw.time("COMPLETED", task.EndDate)
+        } else if task.Status == "cancelled" {
+            iw.time("X-TODO-END", task.EndDate)
+        }
+        iw.line("STATUS", icalStatuses[task.Status])
+        if task.Status == "waiting" {
+            iw.line("X-TODO-STATUS", task.Status)
+        }
+        if priority, ok := icalPriorities[task.Priority.String]; ok {
+            iw.line("PRIORITY", priority)
+        }
+        if task.Recurrence.Valid && task.Recurrence.String != "" {
+            rule, err := ParseRecurrence(task.Recurrence.String, int(task.RecurrenceInterval.Int64))
+            if err == nil && rule.Freq == freqWorkDaily {
+                // Holidays are not part of the rule, so this is only close for every working day
+                if rule.Interval == 1 {
+                    iw.line("RRULE", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR")
+                }
+                iw.line("X-TODO-RECURRENCE", "workdaily")
+                if rule.Interval > 1 {
+                    iw.line("X-TODO-INTERVAL", strconv.Itoa(rule.Interval))
+                }
+            } else if err == nil {
+                iw.line("RRULE", rule.String())
+            }
+        }
+        if len(task.Tags) > 0 {
+            iw.line("CATEGORIES", icalEscapeList(task.Tags))
+        }
+        if task.ProjectName.Valid && task.ProjectName.String != "" {
+            iw.line("X-TODO-PROJECT", icalEscape(task.ProjectName.String))
+        }
+        if len(task.Contexts) > 0 {
+            iw.line("X-TODO-CONTEXTS", icalEscapeList(task.Contexts))
+        }
+        iw.line("END", "VTODO")
+    }
+
+    for _, h := range holidays {
+        iw.line("BEGIN", "VEVENT")
+        iw.line("UID", icalUID(h.UUID, fmt.Sprintf("holiday-%s@todo", h.Date.Time.Format(icalDate))))
+        iw.line("DTSTAMP", now)
+        iw.line("DTSTART;VALUE=DATE", h.Date.Time.Format(icalDate))
+        iw.line("DTEND;VALUE=DATE", h.Date.Time.AddDate(0, 0, 1).Format(icalDate))
+        iw.line("SUMMARY", icalEscape(h.Name))
+        iw.line("CATEGORIES", "HOLIDAY")
+        iw.line("TRANSP", "TRANSPARENT")
+        iw.line("END", "VEVENT")
+    }
+
+    iw.line("END", "VCALENDAR")
+    if iw.err != nil {
+        return iw.err
+    }
+    return iw.w.Flush()
+}
+
+// icalUID returns the UUID of a record as its UID, or fallback for records without one.
+func icalUID(uuid, fallback string) string {
+    if uuid != "" {
+        return uuid
+    }
+    return fallback
+}
+
+// icalWriter writes folded content lines, keeping the first write error.
+type icalWriter struct {
+    w   *bufio.Writer
+    err error
+}
+
+// line writes a "NAME:value" content line, folded at 75 octets as RFC 5545 requires.
+func (iw *icalWriter) line(name, value string) {
+    if iw.err != nil {
+        return
+    }
+    s := name + ":" + value
+    var sb strings.Builder
+    width := 0
+    for _, r := range s {
+        size := utf8.RuneLen(r)
+        if width+size > 75 {
+            sb.WriteString("\r\n ")
+            width = 1
+        }
+        sb.WriteRune(r)
+        width += size
+    }
+    sb.WriteString("\r\n")
+    _, iw.err = iw.w.WriteString(sb.String())
+}
+
+func (iw *icalWriter) time(name string, nt NullableTime) {
+    if nt.Valid {
+        iw.line(name, nt.Time.UTC().Format(icalDateTime))
+    }
+}
+
+var (
+    icalEscaper   = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+    icalUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+)
+
+func icalEscape(s string) string { return icalEscaper.Replace(s) }
+
+func icalEscapeList(values []string) string {
+    escaped := make([]string, len(values))
+    for i, v := range values {
+        escaped[i] = icalEscape(v)
+    }
+    return strings.Join(escaped, ",")
+}
+
+// icalSplitList splits a comma-separated text list, honouring escaped commas.
+func icalSplitList(value string) []string {
+    values := []string{}
+    var current strings.Builder
+    for i := 0; i < len(value); i++ {
+        switch {
+        case value[i] == '\\' && i+1 < len(value):
+            current.WriteByte(value[i])
+            current.WriteByte(value[i+1])
+            i++
+        case value[i] == ',':
+            if v := strings.TrimSpace(icalUnescaper.Replace(current.String())); v != "" {
+                values = append(values, v)
+            }
+            current.Reset()
+        default:
+            current.WriteByte(value[i])
+        }
+    }
+    if v := strings.TrimSpace(icalUnescaper.Replace(current.String())); v != "" {
+        values = append(values, v)
+    }
+    return values
+}
+
+// icalProperty is one unfolded content line.
+type icalProperty struct {
+    name   string
+    params map[string]string
+    value  string
+}
+
+// ReadICalendar reads VTODO components as tasks and all-day VEVENT components as holidays,
+// one holiday per day of the event. A UID that is a UUID is kept as the record's UUID, so
+// importing an exported calendar again updates the same tasks. Timed events and other components are skipped with a warning.
+// An RRULE made of FREQ and INTERVAL becomes a plain pattern and other rules are kept as they
+// are (see ParseRecurrence); rules using parts todo does not support are dropped with a warning.
+// Invalid dates are reported at once in a ValidationError.
+func ReadICalendar(r io.Reader) (*Calendar, error) {
+    lines, err := icalUnfold(r)
+    if err != nil {
+        return nil, err
+    }
+
+    var p problems
+    cal := &Calendar{Tasks: []Task{}, Holidays: []Holiday{}}
+    var component []icalProperty
+    kind := ""
+    nested := 0 // depth of sub-components such as VALARM, whose properties are skipped
+    for _, l := range lines {
+        prop := parseICalLine(l)
+        switch {
+        case prop.name == "BEGIN" && kind == "" && (prop.value == "VTODO" || prop.value == "VEVENT"):
+            kind, component = prop.value, nil
+        case prop.name == "BEGIN" && kind != "":
+            nested++
+        case prop.name == "END" && nested > 0:
+            nested--
+        case nested > 0:
+        case prop.name == "END" && prop.value == kind:
+            if kind == "VTODO" {
+                cal.Tasks = append(cal.Tasks, p.icalTask(cal, component))
+            } else {
+                cal.Holidays = append(cal.Holidays, p.icalHolidays(cal, component)...)
+            }
+            kind = ""
+        case kind != "":
+            component = append(component, prop)
+        }
+    }
+    return cal, p.err()
+}
+
+// icalUnfold reads content lines, joining folded continuation lines.
+func icalUnfold(r io.Reader) ([]string, error) {
+    var lines []string
+    scanner := bufio.NewScanner(r)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    for scanner.Scan() {
+        l := strings.TrimRight(scanner.Text(), "\r")
+        if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+            lines[len(lines)-1] += l[1:]
+            continue
+        }
+        if l != "" {
+            lines = append(lines, l)
+        }
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("error reading calendar: %w", err)
+    }
+    return lines, nil
+}
+
+// parseICalLine splits "NAME;PARAM=value:value" into its parts.
+func parseICalLine(l string) icalProperty {
+    prop := icalProperty{params: map[string]string{}}
+    // The value starts at the first colon outside a quoted parameter value
+    inQuotes := false
+    split := len(l)
+    for i, r := range l {
+        if r == '"' {
+            inQuotes = !inQuotes
+        } else if r == ':' && !inQuotes {
+            split = i
+            break
+        }
+    }
+    head := l[:split]
+    if split < len(l) {
+        prop.value = l[split+1:]
+    }
+    parts := strings.Split(head, ";")
+    prop.name = strings.ToUpper(parts[0])
+    for _, param := range parts[1:] {
+        if k, v, ok := strings.Cut(param, "="); ok {
+            prop.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+        }
+    }
+    return prop
+}
+
+// icalTask converts the properties of a VTODO into a task.
+func (p *problems) icalTask(cal *Calendar, props []icalProperty) Task {
+    task := Task{Status: "pending", Contexts: []string{}, Tags: []string{}}
+    workdaily := false
+    interval := sql.NullInt64{Int64: 1, Valid: true}
+    for _, prop := range props {
+        switch prop.name {
+        case "UID":
+            if IsUUID(prop.value) {
+                task.UUID = nullString(prop.value)
+            }
+        case "SUMMARY":
+            task.Title = icalUnescaper.Replace(prop.value)
+        case "DESCRIPTION":
+            task.Description = nullString(icalUnescaper.Replace(prop.value))
+        case "DTSTART":
+            task.StartDate = p.icalTime(prop)
+        case "DUE":
+            task.DueDate = p.icalTime(prop)
+        case "COMPLETED":
+            task.EndDate = p.icalTime(prop)
+        case "STATUS":
+            switch strings.ToUpper(prop.value) {
+            case "COMPLETED":
+                task.Status = "completed"
+            case "CANCELLED":
+                task.Status = "cancelled"
+            }
+        case "X-TODO-STATUS":
+            task.Status = prop.value
+        case "PRIORITY":
+            switch n, _ := strconv.Atoi(strings.TrimSpace(prop.value)); {
+            case n >= 1 && n <= 4:
+                task.Priority = nullString(PriorityHigh)
+            case n == 5:
+                task.Priority = nullString(PriorityMedium)
+            case n >= 6 && n <= 9:
+                task.Priority = nullString(PriorityLow)
+            }
+        case "RRULE":
+            task.Recurrence, task.RecurrenceInterval = icalRecurrence(cal, prop.value)
+        case "X-TODO-RECURRENCE":
+            workdaily = strings.EqualFold(prop.value, "workdaily")
+        case "X-TODO-INTERVAL":
+            if n, err := strconv.Atoi(strings.TrimSpace(prop.value)); err == nil && n > 0 {
+                interval = sql.NullInt64{Int64: int64(n), Valid: true}
+            }
+        case "X-TODO-END":
+            task.EndDate = p.icalTime(prop)
+        case "CATEGORIES":
+            task.Tags = append(task.Tags, icalSplitList(prop.value)...)
+        case "X-TODO-PROJECT":
+            task.ProjectName = nullString(icalUnescaper.Replace(prop.value))
+        case "X-TODO-CONTEXTS":
+            task.Contexts = append(task.Contexts, icalSplitList(prop.value)...)
+        }
+    }
+    if workdaily {
+        // The RRULE, if any, only comes close
+        task.Recurrence, task.RecurrenceInterval = sql.NullString{String: "workdaily", Valid: true}, interval
+    }
+    if task.Status == "completed" && !task.EndDate.Valid {
+        task.EndDate = NullableTime{Time: time.Now().UTC(), Valid: true}
+    }
+    return task
+}
+
+// icalRecurrence translates an RRULE into a recurrence pattern and interval. Rules made of
+// FREQ and INTERVAL only become the plain daily, weekly, monthly and yearly patterns; other
+// rules are kept as they are.
+func icalRecurrence(cal *Calendar, value string) (sql.NullString, sql.NullInt64) {
+    rule, err := ParseRecurrence(value, 1)
+    if err != nil {
+        cal.Warnings = append(cal.Warnings, fmt.Sprintf("unsupported RRULE '%s' ignored: %v", value, err))
+        return sql.NullString{}, sql.NullInt64{}
+    }
+    interval := sql.NullInt64{Int64: int64(rule.Interval), Valid: true}
+    if rule.isPlain() {
+        for pattern, freq := range rruleFrequencies {
+            if freq == rule.Freq {
+                return sql.NullString{String: pattern, Valid: true}, interval
+            }
+        }
+    }
+    return sql.NullString{String: rule.String(), Valid: true}, sql.NullInt64{Int64: 1, Valid: true}
+}
+
+// icalHolidays converts an all-day VEVENT into one holiday per day it covers.
+func (p *problems) icalHolidays(cal *Calendar, props []icalProperty) []Holiday {
+    var name, uuid string
+    var start, end *icalProperty
+    for i, prop := range props {
+        switch prop.name {
+        case "UID":
+            if IsUUID(prop.value) {
+                uuid = prop.value
+            }
+        case "SUMMARY":
+            name = icalUnescaper.Replace(prop.value)
+        case "DTSTART":
+            start = &props[i]
+        case "DTEND":
+            end = &props[i]
+        }
+    }
+    if start == nil || (start.params["VALUE"] != "DATE" && len(start.value) != len(icalDate)) {
+        cal.Warnings = append(cal.Warnings, fmt.Sprintf("event '%s' is not an all-day event, skipped", name))
+        return nil
+    }
+
+    first, err := time.Parse(icalDate, start.value)
+    if err != nil {
+        *p = append(*p, &DateError{Field: fmt.Sprintf("start of event '%s'", name), Value: start.value, Err: err})
+        return nil
+    }
+    last := first
+    if end != nil {
+        if t, err := time.Parse(icalDate, end.value); err == nil && t.After(first) {
+            last = t.AddDate(0, 0, -1) // DTEND is exclusive
+        }
+    }
+
+    var holidays []Holiday
+    for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+        holidays = append(holidays, Holiday{Date: NullableTime{Time: d, Valid: true}, Name: name})
+    }
+    if len(holidays) == 1 {
+        holidays[0].UUID = uuid // A UID can only identify a single-day holiday
+    }
+    return holidays
+}
+
+// icalTime parses a DATE-TIME in UTC, with a TZID, or floating (local time), or a DATE.
+func (p *problems) icalTime(prop icalProperty) NullableTime {
+    loc := time.Local
+    if tzid := prop.params["TZID"]; tzid != "" {
+        if l, err := time.LoadLocation(tzid); err == nil {
+            loc = l
+        }
+    }
+    if strings.HasSuffix(prop.value, "Z") {
+        loc = time.UTC
+    }
+    for _, layout := range []string{icalDateTime, "20060102T150405", icalDate} {
+        if t, err := time.ParseInLocation(layout, prop.value, loc); err == nil {
+            return NullableTime{Time: t.UTC(), Valid: true}
+        }
+    }
+    *p = append(*p, &DateError{Field: strings.ToLower(prop.name), Value: prop.value, Err: fmt.Errorf("expected YYYYMMDDTHHMMSSZ or YYYYMMDD")})
+    return NullableTime{}
+}
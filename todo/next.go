@@ -0,0 +1,206 @@
+package todo
+
+import (
+    "database/sql"
+    "fmt"
+    "sort"
+    "time"
+)
+
+// The next tasks are the ones that can be worked on right now: pending, not waiting, started,
+// not blocked by their dependencies and, for tasks with contexts, inside the hours of one of
+// their contexts. Contexts without hours of their own can be worked on at any time.
+
+// SetContextHours sets the hours of a day of the week in which the tasks of a context can be done.
+// It returns true if a new entry was created and false if an existing one was updated.
+func (tm *TodoManager) SetContextHours(context string, dayOfWeek, startHour, startMinute, endHour, endMinute int) (bool, error) {
+    if err := checkWorkingHours(dayOfWeek, startHour, startMinute, endHour, endMinute, 0); err != nil {
+        return false, err
+    }
+    contextID, err := contextID(tm.db, context)
+    if err != nil {
+        return false, err
+    }
+    var exists int
+    if err := tm.db.QueryRow("SELECT COUNT(*) FROM context_hours WHERE context_id = ? AND day_of_week = ?", contextID, dayOfWeek).Scan(&exists); err != nil {
+        return false, fmt.Errorf("error looking up hours of context %s: %w", context, err)
+    }
+    _, err = tm.db.Exec(`
+        INSERT INTO context_hours (context_id, day_of_week, start_hour, start_minute, end_hour, end_minute)
+        VALUES (?, ?, ?, ?, ?, ?)
+        ON CONFLICT(context_id, day_of_week) DO UPDATE SET
+            start_hour = excluded.start_hour, start_minute = excluded.start_minute,
+            end_hour = excluded.end_hour, end_minute = excluded.end_minute
+    `, contextID, dayOfWeek, startHour, startMinute, endHour, endMinute)
+    if err != nil {
+        return false, fmt.Errorf("error setting hours of context %s: %w", context, wrapDBError(err))
+    }
+    return exists == 0, nil
+}
+
+// DeleteContextHours deletes the hours of a context for a specific day of the week.
+func (tm *TodoManager) DeleteContextHours(context string, dayOfWeek int) error {
+    if dayOfWeek < 0 || dayOfWeek > 6 {
+        return fmt.Errorf("%w: invalid day of week %d. Must be 0-6 (Sunday-Saturday)", ErrInvalidInput, dayOfWeek)
+    }
+    contextID, err := contextID(tm.db, context)
+    if err != nil {
+        return err
+    }
+    res, err := tm.db.Exec("DELETE FROM context_hours WHERE context_id = ? AND day_of_week = ?", contextID, dayOfWeek)
+    if err != nil {
+        return fmt.Errorf("error deleting hours of context %s for day %d: %w", context, dayOfWeek, err)
+    }
+    if n, _ := res.RowsAffected(); n == 0 {
+        return fmt.Errorf("context %s, day %d (%s): %w", context, dayOfWeek, time.Weekday(dayOfWeek).String(), ErrWorkingHoursNotFound)
+    }
+    return nil
+}
+
+// DeleteAllContextHours deletes every day of the hours of a context, so its tasks can be done
+// at any time again, and returns how many were removed.
+func (tm *TodoManager) DeleteAllContextHours(context string) (int64, error) {
+    contextID, err := contextID(tm.db, context)
+    if err != nil {
+        return 0, err
+    }
+    res, err := tm.db.Exec("DELETE FROM context_hours WHERE context_id = ?", contextID)
+    if err != nil {
+        return 0, fmt.Errorf("error deleting hours of context %s: %w", context, err)
+    }
+    return res.RowsAffected()
+}
+
+// GetContextHours fetches the hours of every context that has them, by context name.
+func (tm *TodoManager) GetContextHours() (map[string]map[time.Weekday]WorkingHours, error) {
+    return getContextHours(tm.db)
+}
+
+func getContextHours(q queryer) (map[string]map[time.Weekday]WorkingHours, error) {
+    hours := make(map[string]map[time.Weekday]WorkingHours)
+    rows, err := q.Query(`
+        SELECT c.name, h.day_of_week, h.start_hour, h.start_minute, h.end_hour, h.end_minute
+        FROM context_hours h JOIN contexts c ON h.context_id = c.id
+    `)
+    if err != nil {
+        return nil, fmt.Errorf("failed to query context hours: %w", err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var context string
+        var wh WorkingHours
+        if err := rows.Scan(&context, &wh.DayOfWeek, &wh.StartHour, &wh.StartMinute, &wh.EndHour, &wh.EndMinute); err != nil {
+            return nil, fmt.Errorf("failed to scan context hours: %w", err)
+        }
+        if hours[context] == nil {
+            hours[context] = make(map[time.Weekday]WorkingHours)
+        }
+        hours[context][time.Weekday(wh.DayOfWeek)] = wh
+    }
+    return hours, rows.Err()
+}
+
+// contextID returns the ID of an existing context.
+func contextID(q queryer, name string) (int64, error) {
+    var id int64
+    err := q.QueryRow("SELECT id FROM contexts WHERE name = ?", name).Scan(&id)
+    if err == sql.ErrNoRows {
+        return 0, fmt.Errorf("context '%s': %w", name, ErrNotFound)
+    } else if err != nil {
+        return 0, fmt.Errorf("error looking up context %s: %w", name, err)
+    }
+    return id, nil
+}
+
+// withinHours reports whether a time falls inside the hours of its day of the week.
+func withinHours(hours map[time.Weekday]WorkingHours, t time.Time) bool {
+    wh, ok := hours[t.Weekday()]
+    if !ok {
+        return false
+    }
+    minute := t.Hour()*60 + t.Minute()
+    return minute >= wh.StartHour*60+wh.StartMinute && minute < wh.EndHour*60+wh.EndMinute
+}
+
+// isActionable reports whether a task can be worked on at a given time.
+func isActionable(task *Task, contextHours map[string]map[time.Weekday]WorkingHours, now time.Time) bool {
+    if task.Status != "pending" || len(task.BlockedBy) > 0 {
+        return false
+    }
+    if task.StartDate.Valid && task.StartDate.Time.After(now) {
+        return false
+    }
+    if task.StartWaitingDate.Valid && !task.StartWaitingDate.Time.After(now) &&
+        (!task.EndWaitingDate.Valid || task.EndWaitingDate.Time.After(now)) {
+        return false
+    }
+    if len(task.Contexts) == 0 {
+        return true
+    }
+    for _, context := range task.Contexts {
+        hours, ok := contextHours[context]
+        if !ok || withinHours(hours, now.Local()) {
+            return true
+        }
+    }
+    return false
+}
+
+// priorityRank orders priorities from high to none.
+var priorityRank = map[string]int{PriorityHigh: 0, PriorityMedium: 1, PriorityLow: 2}
+
+// NextTasks returns up to n tasks matching a filter that can be worked on at a given time,
+// ranked by due date, earliest first and tasks without one last, then by priority and urgency.
+// The status of the filter is ignored, since only pending tasks can be worked on; n <= 0
+// returns every such task.
+func (tm *TodoManager) NextTasks(filter TaskFilter, now time.Time, n int) ([]Task, error) {
+    filter.Status = "pending"
+    filter.ExpandUntil = NullableTime{}
+    tasks, err := tm.GetTasks(filter)
+    if err != nil {
+        return nil, err
+    }
+    contextHours, err := getContextHours(tm.db)
+    if err != nil {
+        return nil, err
+    }
+
+    next := []Task{}
+    for i := range tasks {
+        if isActionable(&tasks[i], contextHours, now) {
+            next = append(next, tasks[i])
+        }
+    }
+    sort.SliceStable(next, func(i, j int) bool { return dueFirst(&next[i], &next[j]) })
+    if n > 0 && len(next) > n {
+        next = next[:n]
+    }
+    return next, nil
+}
+
+// dueFirst orders tasks by due date, earliest first and tasks without one last, then by
+// priority and urgency.
+func dueFirst(a, b *Task) bool {
+    if a.DueDate.Valid != b.DueDate.Valid {
+        return a.DueDate.Valid
+    }
+    if a.DueDate.Valid && !a.DueDate.Time.Equal(b.DueDate.Time) {
+        return a.DueDate.Time.Before(b.DueDate.Time)
+    }
+    if pa, pb := rankPriority(a), rankPriority(b); pa != pb {
+        return pa < pb
+    }
+    if a.Urgency != b.Urgency {
+        return a.Urgency > b.Urgency
+    }
+    return a.ID < b.ID
+}
+
+// rankPriority returns the position of the priority of a task in priorityRank, after all of them for none.
+func rankPriority(task *Task) int {
+    if rank, ok := priorityRank[task.Priority.String]; ok {
+        return rank
+    }
+    return len(priorityRank)
+}
@@ -0,0 +1,114 @@
+package todo
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestIsActionable(t *testing.T) {
+    // Wednesday 4 March 2026 at 10:00; the office is open 9 to 17 and the evening is 18 to 22 on Wednesdays
+    now := localTime(t, "2026-03-04 10:00")
+    at := func(value string) NullableTime { return NullableTime{Time: localTime(t, value).UTC(), Valid: true} }
+    contextHours := map[string]map[time.Weekday]WorkingHours{
+        "office":  {time.Wednesday: {StartHour: 9, EndHour: 17}},
+        "evening": {time.Wednesday: {StartHour: 18, EndHour: 22}, time.Thursday: {StartHour: 0, EndHour: 23, EndMinute: 59}},
+    }
+    tests := []struct {
+        name string
+        task Task
+        want bool
+    }{
+        {"pending", Task{Status: "pending"}, true},
+        {"completed", Task{Status: "completed"}, false},
+        {"waiting status", Task{Status: "waiting"}, false},
+        {"blocked", Task{Status: "pending", BlockedBy: []int64{2}}, false},
+        {"started", Task{Status: "pending", StartDate: at("2026-03-04 10:00")}, true},
+        {"not started", Task{Status: "pending", StartDate: at("2026-03-04 10:01")}, false},
+        {"waiting", Task{Status: "pending", StartWaitingDate: at("2026-03-03 10:00")}, false},
+        {"waiting until later", Task{Status: "pending", StartWaitingDate: at("2026-03-03 10:00"), EndWaitingDate: at("2026-03-04 12:00")}, false},
+        {"done waiting", Task{Status: "pending", StartWaitingDate: at("2026-03-03 10:00"), EndWaitingDate: at("2026-03-04 09:00")}, true},
+        {"waiting later", Task{Status: "pending", StartWaitingDate: at("2026-03-05 10:00")}, true},
+        {"inside the hours of its context", Task{Status: "pending", Contexts: []string{"office"}}, true},
+        {"outside the hours of its context", Task{Status: "pending", Contexts: []string{"evening"}}, false},
+        {"inside the hours of one of its contexts", Task{Status: "pending", Contexts: []string{"evening", "office"}}, true},
+        {"context without hours", Task{Status: "pending", Contexts: []string{"evening", "phone"}}, true},
+    }
+    for _, tt := range tests {
+        if got := isActionable(&tt.task, contextHours, now); got != tt.want {
+            t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestWithinHours(t *testing.T) {
+    hours := map[time.Weekday]WorkingHours{time.Wednesday: {StartHour: 9, StartMinute: 30, EndHour: 17}}
+    tests := []struct {
+        at   string
+        want bool
+    }{
+        {"2026-03-04 09:29", false},
+        {"2026-03-04 09:30", true},
+        {"2026-03-04 16:59", true},
+        {"2026-03-04 17:00", false},
+        {"2026-03-05 10:00", false}, // Thursday has no hours
+    }
+    for _, tt := range tests {
+        if got := withinHours(hours, localTime(t, tt.at)); got != tt.want {
+            t.Errorf("withinHours(%s) = %v, want %v", tt.at, got, tt.want)
+        }
+    }
+}
+
+func TestNextTasks(t *testing.T) {
+    tm := newTestManager(t)
+    // Tasks start when they are added unless told otherwise
+    started := strPtr("2026-03-01 08:00:00")
+    inputs := []TaskInput{
+        {Title: "tidy desk", StartDate: started},
+        {Title: "call plumber", StartDate: started, Priority: "H", Contexts: []string{"phone"}},
+        {Title: "review budget", StartDate: started, Priority: "L", DueDate: strPtr("2026-03-06 17:00:00"), Contexts: []string{"office"}},
+        {Title: "send invoice", StartDate: started, Priority: "H", DueDate: strPtr("2026-03-06 17:00:00")},
+        {Title: "water plants", StartDate: started, DueDate: strPtr("2026-03-05 08:00:00"), Contexts: []string{"home"}},
+        {Title: "pay invoice", StartDate: started, DueDate: strPtr("2026-03-04 17:00:00"), DependsOn: []int64{4}},
+        {Title: "plan trip", StartDate: strPtr("2026-03-10 09:00:00")},
+        {Title: "file taxes", StartDate: started, Status: "completed"},
+    }
+    for _, in := range inputs {
+        if _, err := tm.AddTask(in); err != nil {
+            t.Fatalf("AddTask(%s): %v", in.Title, err)
+        }
+    }
+    if _, err := tm.SetContextHours("office", int(time.Wednesday), 9, 0, 17, 0); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := tm.SetContextHours("home", int(time.Wednesday), 18, 0, 22, 0); err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        name   string
+        filter TaskFilter
+        now    string
+        n      int
+        want   string
+    }{
+        {"at the office", TaskFilter{}, "2026-03-04 10:00", 0, "[4 3 2 1]"},
+        {"at home", TaskFilter{}, "2026-03-04 19:00", 0, "[5 4 2 1]"},
+        {"top two", TaskFilter{}, "2026-03-04 19:00", 2, "[5 4]"},
+        {"filtered", TaskFilter{Context: "phone", Status: "completed"}, "2026-03-04 10:00", 0, "[2]"},
+        {"started later", TaskFilter{}, "2026-03-11 19:00", 0, "[5 4 2 1 7]"},
+    }
+    for _, tt := range tests {
+        tasks, err := tm.NextTasks(tt.filter, localTime(t, tt.now), tt.n)
+        if err != nil {
+            t.Fatal(err)
+        }
+        var got []int64
+        for _, task := range tasks {
+            got = append(got, task.ID)
+        }
+        if fmt.Sprint(got) != tt.want {
+            t.Errorf("%s: got %v, want %s", tt.name, got, tt.want)
+        }
+    }
+}
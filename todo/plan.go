@@ -0,0 +1,192 @@
+package todo
+
+import (
+    "fmt"
+    "sort"
+    "time"
+)
+
+// The plan lays the work left on open tasks end-to-end over the working time ahead, most
+// pressing first, to show which tasks will not be done by their due date at the current
+// capacity. The work left on a task is its estimate minus the time tracked on it. Every day
+// offers its working hours minus its break, as in CalculateWorkingHoursDuration, with nothing
+// on holidays; the break is taken in the middle of the working hours. A task is not started
+// before its start date; dependencies are not taken into account.
+
+// PlannedTask is an open task placed in the plan.
+type PlannedTask struct {
+    Task   Task
+    Work   time.Duration // work left: the estimate minus the tracked time
+    Start  time.Time     // when work on the task begins
+    Finish time.Time     // when the work left is done
+    Late   bool          // finishes after the due date
+}
+
+// Plan is the schedule of the open tasks with an estimate.
+type Plan struct {
+    From        time.Time
+    Tasks       []PlannedTask // in the order they are worked on
+    Unestimated []Task        // open tasks without an estimate, which are not planned
+}
+
+// Work returns the total work left on the planned tasks.
+func (p *Plan) Work() time.Duration {
+    var total time.Duration
+    for _, t := range p.Tasks {
+        total += t.Work
+    }
+    return total
+}
+
+// Late returns the planned tasks that finish after their due date.
+func (p *Plan) Late() []PlannedTask {
+    late := []PlannedTask{}
+    for _, t := range p.Tasks {
+        if t.Late {
+            late = append(late, t)
+        }
+    }
+    return late
+}
+
+// PlanTasks schedules the open tasks matching a filter from a given time on, ordered by due
+// date, earliest first and tasks without one last, then by priority and urgency. The status
+// of the filter is ignored. It fails with ErrWorkingHoursNotFound when no working hours are
+// defined, since there is no capacity to plan.
+func (tm *TodoManager) PlanTasks(filter TaskFilter, from time.Time) (*Plan, error) {
+    filter.Status = "all"
+    filter.ExpandUntil = NullableTime{}
+    tasks, err := tm.GetTasks(filter)
+    if err != nil {
+        return nil, err
+    }
+    cal, err := loadWorkCalendar(tm.db)
+    if err != nil {
+        return nil, err
+    }
+    if len(cal.hours) == 0 {
+        return nil, fmt.Errorf("%w: define them with 'todo workhours set' to plan", ErrWorkingHoursNotFound)
+    }
+
+    plan := &Plan{From: from, Tasks: []PlannedTask{}, Unestimated: []Task{}}
+    open := []Task{}
+    for _, task := range tasks {
+        if !isOpen(task.Status) {
+            continue
+        }
+        if !task.Estimate.Valid {
+            plan.Unestimated = append(plan.Unestimated, task)
+            continue
+        }
+        open = append(open, task)
+    }
+    sort.SliceStable(open, func(i, j int) bool { return dueFirst(&open[i], &open[j]) })
+
+    c := newCapacity(cal, from)
+    for _, task := range open {
+        work := task.EstimateDuration() - task.Tracked
+        if work < 0 {
+            work = 0
+        }
+        notBefore := from
+        if task.StartDate.Valid && task.StartDate.Time.After(from) {
+            notBefore = task.StartDate.Time
+        }
+        start, finish, err := c.schedule(work, notBefore)
+        if err != nil {
+            return nil, fmt.Errorf("task %d: %w", task.ID, err)
+        }
+        plan.Tasks = append(plan.Tasks, PlannedTask{
+            Task:   task,
+            Work:   work,
+            Start:  start,
+            Finish: finish,
+            Late:   task.DueDate.Valid && finish.After(task.DueDate.Time),
+        })
+    }
+    return plan, nil
+}
+
+// capacity walks through the working time ahead, one day at a time. The break of a day is
+// taken in the middle of its working hours, so it is charged once however the day is split
+// between tasks.
+type capacity struct {
+    cal *workCalendar
+    day time.Time // local midnight of the current day
+    at  time.Time // work on the current day goes on from this time
+}
+
+func newCapacity(cal *workCalendar, from time.Time) *capacity {
+    from = from.Local()
+    return &capacity{cal: cal, day: time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local), at: from}
+}
+
+// periods returns the working periods of the current day, before and after its break. Holidays
+// and days without working hours, or whose break takes them up, have none.
+func (c *capacity) periods() [][2]time.Time {
+    if _, ok := c.cal.holidays[c.day.Format("2006-01-02")]; ok {
+        return nil
+    }
+    start, end, ok := workingWindow(c.day, c.cal.hours)
+    if !ok {
+        return nil
+    }
+    brk := time.Duration(c.cal.hours[c.day.Weekday()].BreakMinutes) * time.Minute
+    if brk <= 0 {
+        return [][2]time.Time{{start, end}}
+    }
+    if end.Sub(start) <= brk {
+        return nil
+    }
+    breakStart := start.Add((end.Sub(start) - brk) / 2)
+    return [][2]time.Time{{start, breakStart}, {breakStart.Add(brk), end}}
+}
+
+// nextDay moves to the start of the following day.
+func (c *capacity) nextDay() {
+    c.day = c.day.AddDate(0, 0, 1)
+    c.at = c.day
+}
+
+// schedule places work at the first working time available, but not before notBefore, and
+// returns when it begins and ends.
+func (c *capacity) schedule(work time.Duration, notBefore time.Time) (time.Time, time.Time, error) {
+    notBefore = notBefore.Local()
+    for !notBefore.Before(c.day.AddDate(0, 0, 1)) {
+        c.nextDay()
+    }
+    c.at = MaxTime(c.at, notBefore)
+    if work <= 0 {
+        return c.at, c.at, nil
+    }
+
+    var start time.Time
+    started := false
+    for i := 0; i < maxWorkdaySearch; i++ {
+        for _, p := range c.periods() {
+            from := MaxTime(p[0], c.at)
+            if !from.Before(p[1]) {
+                continue
+            }
+            if !started {
+                start, started = from, true
+            }
+            take := minDuration(p[1].Sub(from), work)
+            c.at = from.Add(take)
+            work -= take
+            if work <= 0 {
+                return start, c.at, nil
+            }
+        }
+        c.nextDay()
+    }
+    return time.Time{}, time.Time{}, fmt.Errorf("%w: not enough working time in the next %d days", ErrInvalidInput, maxWorkdaySearch)
+}
+
+// minDuration returns the shorter of two durations.
+func minDuration(a, b time.Duration) time.Duration {
+    if a < b {
+        return a
+    }
+    return b
+}
@@ -0,0 +1,129 @@
+package todo
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestPlanTasks(t *testing.T) {
+    // Monday to Friday 9:00 to 17:00 with a break from 12:30 to 13:30, and a holiday on
+    // Wednesday 2026-03-04
+    type task struct {
+        estimate, start, due string
+    }
+    type planned struct {
+        start, finish string
+        late          bool
+    }
+    tests := []struct {
+        name  string
+        from  string
+        tasks []task // in the order they are planned
+        want  []planned
+    }{
+        {
+            name:  "before the break",
+            from:  "2026-03-02 09:00",
+            tasks: []task{{"2h", "", ""}},
+            want:  []planned{{"2026-03-02 09:00", "2026-03-02 11:00", false}},
+        },
+        {
+            name:  "across the break",
+            from:  "2026-03-02 09:00",
+            tasks: []task{{"5h", "", ""}},
+            want:  []planned{{"2026-03-02 09:00", "2026-03-02 15:00", false}},
+        },
+        {
+            name:  "break charged once between tasks",
+            from:  "2026-03-02 09:00",
+            tasks: []task{{"3h", "", "2026-03-03 17:00:00"}, {"3h", "", "2026-03-04 17:00:00"}},
+            want: []planned{
+                {"2026-03-02 09:00", "2026-03-02 12:00", false},
+                {"2026-03-02 12:00", "2026-03-02 16:00", false},
+            },
+        },
+        {
+            name:  "start date in the break",
+            from:  "2026-03-02 09:00",
+            tasks: []task{{"1h", "2026-03-02 13:00:00", ""}},
+            want:  []planned{{"2026-03-02 13:30", "2026-03-02 14:30", false}},
+        },
+        {
+            name:  "late",
+            from:  "2026-03-02 09:00",
+            tasks: []task{{"4h", "", "2026-03-02 12:00:00"}},
+            want:  []planned{{"2026-03-02 09:00", "2026-03-02 14:00", true}},
+        },
+        {
+            name:  "over a holiday",
+            from:  "2026-03-03 09:00",
+            tasks: []task{{"10h", "", "2026-03-04 17:00:00"}},
+            want:  []planned{{"2026-03-03 09:00", "2026-03-05 12:00", true}},
+        },
+        {
+            name:  "starting on a holiday",
+            from:  "2026-03-04 10:00",
+            tasks: []task{{"1h", "", ""}},
+            want:  []planned{{"2026-03-05 09:00", "2026-03-05 10:00", false}},
+        },
+        {
+            name:  "over the weekend",
+            from:  "2026-03-06 15:00",
+            tasks: []task{{"4h", "", "2026-03-09 12:00:00"}},
+            want:  []planned{{"2026-03-06 15:00", "2026-03-09 11:00", false}},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            for day := 1; day <= 5; day++ {
+                if _, err := tm.SetWorkingHours(day, 9, 0, 17, 0, 60); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            if _, err := tm.AddHoliday("2026-03-04", "holiday"); err != nil {
+                t.Fatal(err)
+            }
+            if _, err := tm.AddTask(TaskInput{Title: "not estimated", StartDate: strPtr("2026-03-01")}); err != nil {
+                t.Fatal(err)
+            }
+            for _, in := range tt.tasks {
+                input := TaskInput{Title: "task", Estimate: in.estimate, StartDate: strPtr("2026-03-01")}
+                if in.start != "" {
+                    input.StartDate = strPtr(in.start)
+                }
+                if in.due != "" {
+                    input.DueDate = strPtr(in.due)
+                }
+                if _, err := tm.AddTask(input); err != nil {
+                    t.Fatalf("AddTask: %v", err)
+                }
+            }
+
+            plan, err := tm.PlanTasks(TaskFilter{}, localTime(t, tt.from))
+            if err != nil {
+                t.Fatalf("PlanTasks: %v", err)
+            }
+            if len(plan.Unestimated) != 1 {
+                t.Errorf("got %d tasks without an estimate, want 1", len(plan.Unestimated))
+            }
+            if len(plan.Tasks) != len(tt.want) {
+                t.Fatalf("got %d planned tasks, want %d", len(plan.Tasks), len(tt.want))
+            }
+            for i, want := range tt.want {
+                got := plan.Tasks[i]
+                if !got.Start.Equal(localTime(t, want.start)) || !got.Finish.Equal(localTime(t, want.finish)) || got.Late != want.late {
+                    t.Errorf("task %d: got %s to %s (late %v), want %s to %s (late %v)", i+1,
+                        got.Start.Format("2006-01-02 15:04"), got.Finish.Format("2006-01-02 15:04"), got.Late, want.start, want.finish, want.late)
+                }
+            }
+        })
+    }
+}
+
+func TestPlanTasksWithoutWorkingHours(t *testing.T) {
+    tm := newTestManager(t)
+    if _, err := tm.PlanTasks(TaskFilter{}, localTime(t, "2026-03-02 09:00")); !errors.Is(err, ErrWorkingHoursNotFound) {
+        t.Errorf("got %v, want ErrWorkingHoursNotFound", err)
+    }
+}
@@ -0,0 +1,120 @@
+package todo
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Recurrence anchors choose what the next instance of a recurring task is computed from.
+const (
+    AnchorScheduled  = ""           // the due date (or start date) of the completed instance
+    AnchorCompletion = "completion" // the day the instance was completed
+)
+
+// validAnchors lists the recurrence anchors accepted by TaskInput and TaskPatch.
+var validAnchors = []string{"scheduled", AnchorCompletion}
+
+// normalizeAnchor returns the stored form of a recurrence anchor: "scheduled", the default,
+// is stored as no anchor.
+func normalizeAnchor(anchor string) string {
+    anchor = strings.ToLower(strings.TrimSpace(anchor))
+    if anchor == "scheduled" {
+        return AnchorScheduled
+    }
+    return anchor
+}
+
+func (p *problems) checkAnchor(anchor string) {
+    if anchor != "" && !contains(validAnchors, strings.ToLower(strings.TrimSpace(anchor))) {
+        p.add("unknown recurrence anchor '%s' (expected %s)", anchor, strings.Join(validAnchors, ", "))
+    }
+}
+
+// recurrenceAnchor returns the date a recurring task repeats from: its due date, or its
+// start date when it has none, in local time.
+func recurrenceAnchor(task *Task) time.Time {
+    if task.DueDate.Valid {
+        return task.DueDate.Time.Local()
+    }
+    return task.StartDate.Time.Local()
+}
+
+// recurrenceBase returns the time the next occurrence is computed from. For tasks anchored
+// on completion it is the completion day at the time of day of the scheduled date, so
+// "every 3 days" counts from when the task was actually done.
+func recurrenceBase(task *Task, completedAt time.Time) time.Time {
+    anchor := recurrenceAnchor(task)
+    if task.RecurrenceAnchor.String != AnchorCompletion {
+        return anchor
+    }
+    done := completedAt.Local()
+    return time.Date(done.Year(), done.Month(), done.Day(), anchor.Hour(), anchor.Minute(), anchor.Second(), 0, time.Local)
+}
+
+// nextOccurrence returns the first occurrence of the rule after base, moved off non-working
+// days when the task has a shift policy. Shifted instances are no occurrences of the rule
+// themselves, so for scheduled series the rule is evaluated from the first instance of the
+// series, which keeps e.g. "monthly on the 31st" from drifting to the 29th after one shift.
+func nextOccurrence(q queryer, rule *RRule, task *Task, base time.Time) (time.Time, bool, error) {
+    policy := task.RecurrenceShift.String
+    var cal *workCalendar
+    if rule.Freq == freqWorkDaily || policy != ShiftNone {
+        var err error
+        if cal, err = loadWorkCalendar(q); err != nil {
+            return time.Time{}, false, err
+        }
+    }
+    if rule.Freq == freqWorkDaily {
+        next, err := cal.addWorkingDays(base, rule.Interval)
+        return next, err == nil, err
+    }
+    if policy == ShiftNone {
+        next, ok := rule.Next(base, base)
+        return next, ok, nil
+    }
+
+    start := base
+    if task.OriginalTaskID.Valid && task.RecurrenceAnchor.String == AnchorScheduled {
+        if original, err := getTask(q, task.OriginalTaskID.Int64); err == nil && !recurrenceAnchor(original).After(base) {
+            start = recurrenceAnchor(original)
+        }
+    }
+    after := base
+    for i := 0; i < rruleMaxPeriods; i++ {
+        occurrence, ok := rule.Next(start, after)
+        if !ok {
+            return time.Time{}, false, nil
+        }
+        shifted, err := cal.shift(occurrence, policy)
+        if err != nil {
+            return time.Time{}, false, err
+        }
+        if shifted.After(base) {
+            return shifted, true, nil
+        }
+        after = occurrence
+    }
+    return time.Time{}, false, nil
+}
+
+// countSeries counts the instances of the recurring series a task belongs to, including the first one.
+func countSeries(q queryer, task *Task) (int, error) {
+    originalID := task.ID
+    if task.OriginalTaskID.Valid {
+        originalID = task.OriginalTaskID.Int64
+    }
+    var n int
+    if err := q.QueryRow("SELECT COUNT(*) FROM tasks WHERE id = ? OR original_task_id = ?", originalID, originalID).Scan(&n); err != nil {
+        return 0, fmt.Errorf("error counting recurring series of task %d: %w", task.ID, err)
+    }
+    return n, nil
+}
+
+// calendarDays returns the number of calendar days from the local date of a to the local date of b.
+func calendarDays(a, b time.Time) int {
+    a, b = a.Local(), b.Local()
+    da := time.Date(a.Year(), a.Month(), a.Day(), 12, 0, 0, 0, time.UTC)
+    db := time.Date(b.Year(), b.Month(), b.Day(), 12, 0, 0, 0, time.UTC)
+    return int(db.Sub(da).Hours() / 24)
+}
@@ -0,0 +1,93 @@
+package todo
+
+import (
+    "database/sql"
+    "testing"
+)
+
+func TestRecurrenceBase(t *testing.T) {
+    tests := []struct {
+        name   string
+        due    string // empty for a task with only a start date
+        anchor string
+        done   string
+        want   string
+    }{
+        {"scheduled", "2026-03-02 17:00", AnchorScheduled, "2026-03-12 10:00", "2026-03-02 17:00"},
+        {"scheduled without a due date", "", AnchorScheduled, "2026-03-12 10:00", "2026-03-01 09:00"},
+        {"completion", "2026-03-02 17:00", AnchorCompletion, "2026-03-12 10:00", "2026-03-12 17:00"},
+        {"completion before the due date", "2026-03-02 17:00", AnchorCompletion, "2026-02-27 10:00", "2026-02-27 17:00"},
+        {"completion without a due date", "", AnchorCompletion, "2026-03-12 10:00", "2026-03-12 09:00"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            task := &Task{StartDate: NullableTime{Time: localTime(t, "2026-03-01 09:00").UTC(), Valid: true},
+                RecurrenceAnchor: sql.NullString{String: tt.anchor, Valid: tt.anchor != ""}}
+            if tt.due != "" {
+                task.DueDate = NullableTime{Time: localTime(t, tt.due).UTC(), Valid: true}
+            }
+            if got := recurrenceBase(task, localTime(t, tt.done).UTC()); !got.Equal(localTime(t, tt.want)) {
+                t.Errorf("got %s, want %s", got.Format("2006-01-02 15:04"), tt.want)
+            }
+        })
+    }
+}
+
+func TestCompleteAnchored(t *testing.T) {
+    // Every 3 days, due on 2 March and completed on 12 March; the next instance keeps the
+    // offsets of the start and waiting dates to the due date
+    type dates struct{ start, due, startWaiting, endWaiting string }
+    tests := []struct {
+        anchor string
+        done   string
+        want   dates
+    }{
+        {"", "2026-03-12 10:00:00", dates{"2026-03-04 09:00", "2026-03-05 17:00", "2026-03-05 08:00", "2026-03-06 12:00"}},
+        {"scheduled", "2026-03-12 10:00:00", dates{"2026-03-04 09:00", "2026-03-05 17:00", "2026-03-05 08:00", "2026-03-06 12:00"}},
+        {"completion", "2026-03-12 10:00:00", dates{"2026-03-14 09:00", "2026-03-15 17:00", "2026-03-15 08:00", "2026-03-16 12:00"}},
+        {"completion", "2026-02-27 10:00:00", dates{"2026-03-01 09:00", "2026-03-02 17:00", "2026-03-02 08:00", "2026-03-03 12:00"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.anchor+" "+tt.done, func(t *testing.T) {
+            tm := newTestManager(t)
+            task, err := tm.AddTask(TaskInput{Title: "water plants", Recurrence: "daily", RecurrenceInterval: 3, RecurrenceAnchor: tt.anchor,
+                StartDate: strPtr("2026-03-01 09:00:00"), DueDate: strPtr("2026-03-02 17:00:00"),
+                StartWaiting: strPtr("2026-03-02 08:00:00"), EndWaiting: strPtr("2026-03-03 12:00:00")})
+            if err != nil {
+                t.Fatal(err)
+            }
+            update(t, tm, task.ID, TaskPatch{EndDate: strPtr(tt.done)})
+            next, err := tm.GetTask(task.ID + 1)
+            if err != nil {
+                t.Fatalf("no next instance: %v", err)
+            }
+            format := func(nt NullableTime) string { return nt.Time.Local().Format("2006-01-02 15:04") }
+            got := dates{format(next.StartDate), format(next.DueDate), format(next.StartWaitingDate), format(next.EndWaitingDate)}
+            if got != tt.want {
+                t.Errorf("got %+v, want %+v", got, tt.want)
+            }
+            if next.RecurrenceAnchor.String != normalizeAnchor(tt.anchor) {
+                t.Errorf("next instance anchored on %q, want %q", next.RecurrenceAnchor.String, normalizeAnchor(tt.anchor))
+            }
+        })
+    }
+}
+
+func TestCheckAnchor(t *testing.T) {
+    tests := []struct {
+        anchor string
+        ok     bool
+    }{
+        {"", true},
+        {"scheduled", true},
+        {" Completion ", true},
+        {"due", false},
+    }
+    for _, tt := range tests {
+        var p problems
+        p.checkAnchor(tt.anchor)
+        if (p.err() == nil) != tt.ok {
+            t.Errorf("checkAnchor(%q) = %v, want ok %v", tt.anchor, p.err(), tt.ok)
+        }
+    }
+}
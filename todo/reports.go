@@ -0,0 +1,324 @@
+package todo
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "time"
+)
+
+// Burndown and throughput count tasks over time from their dates, since the history of their
+// status is not stored: a task is added on its start date, or from the beginning when it has
+// none, and completed on the end date of a completed task. A cancelled task leaves the scope
+// on its end date; without one it is left out. Waiting tasks count as open.
+
+// defaultBurndownDays is the number of days covered by a burndown without a start.
+const defaultBurndownDays = 14
+
+// defaultThroughputPeriods is the number of periods covered by a throughput without a start.
+const defaultThroughputPeriods = 8
+
+// BurndownPoint is the state of the scope at the end of a day.
+type BurndownPoint struct {
+    Date      time.Time // local midnight of the day
+    Open      int
+    Completed int
+}
+
+// ThroughputPeriod counts the tasks added and completed in a day, week or month.
+type ThroughputPeriod struct {
+    Start     time.Time // local midnight of the first day
+    End       time.Time // local midnight of the day after the last one
+    Added     int
+    Completed int
+    Open      int // open at the end of the period
+}
+
+// Burndown returns the open and completed tasks matching a filter at the end of every day from
+// from to to. A zero to is now and a zero from is 14 days before to. The status of the filter
+// is ignored.
+func (tm *TodoManager) Burndown(filter TaskFilter, from, to time.Time) ([]BurndownPoint, error) {
+    if to.IsZero() {
+        to = time.Now()
+    }
+    last := localDay(to)
+    first := last.AddDate(0, 0, 1-defaultBurndownDays)
+    if !from.IsZero() {
+        first = localDay(from)
+    }
+    if first.After(last) {
+        return nil, fmt.Errorf("%w: start %s is after end %s", ErrInvalidInput, first.Format("2006-01-02"), last.Format("2006-01-02"))
+    }
+    tasks, err := tm.reportTasks(filter)
+    if err != nil {
+        return nil, err
+    }
+
+    points := []BurndownPoint{}
+    for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+        open, completed := countScope(tasks, day.AddDate(0, 0, 1))
+        points = append(points, BurndownPoint{Date: day, Open: open, Completed: completed})
+    }
+    return points, nil
+}
+
+// Throughput returns the tasks matching a filter added and completed in every period from the
+// one containing from to the one containing to; by is day, week (starting on Monday) or month.
+// A zero to is now and a zero from covers the last 8 periods up to to. The status of the filter
+// is ignored.
+func (tm *TodoManager) Throughput(filter TaskFilter, by string, from, to time.Time) ([]ThroughputPeriod, error) {
+    if by != "day" && by != "week" && by != "month" {
+        return nil, fmt.Errorf("%w: invalid period '%s' (day, week, month)", ErrInvalidInput, by)
+    }
+    if to.IsZero() {
+        to = time.Now()
+    }
+    last := periodStart(to, by)
+    first := addPeriods(last, by, 1-defaultThroughputPeriods)
+    if !from.IsZero() {
+        first = periodStart(from, by)
+    }
+    if first.After(last) {
+        return nil, fmt.Errorf("%w: start %s is after end %s", ErrInvalidInput, from.Local().Format("2006-01-02"), to.Local().Format("2006-01-02"))
+    }
+    tasks, err := tm.reportTasks(filter)
+    if err != nil {
+        return nil, err
+    }
+
+    periods := []ThroughputPeriod{}
+    for start := first; !start.After(last); start = addPeriods(start, by, 1) {
+        p := ThroughputPeriod{Start: start, End: addPeriods(start, by, 1)}
+        for _, task := range tasks {
+            if within(task.StartDate, p.Start, p.End) {
+                p.Added++
+            }
+            if task.Status == "completed" && within(task.EndDate, p.Start, p.End) {
+                p.Completed++
+            }
+        }
+        p.Open, _ = countScope(tasks, p.End)
+        periods = append(periods, p)
+    }
+    return periods, nil
+}
+
+// reportTasks returns the stored tasks of any status matching a filter.
+func (tm *TodoManager) reportTasks(filter TaskFilter) ([]Task, error) {
+    filter.Status = "all"
+    filter.ExpandUntil = NullableTime{}
+    filter.IncludeNotes = false
+    return tm.GetTasks(filter)
+}
+
+// countScope counts the tasks open and completed just before a time. Completed tasks without
+// an end date count as completed from the beginning.
+func countScope(tasks []Task, before time.Time) (open, completed int) {
+    for _, task := range tasks {
+        if task.StartDate.Valid && !task.StartDate.Time.Before(before) {
+            continue
+        }
+        ended := !task.EndDate.Valid || task.EndDate.Time.Before(before)
+        switch task.Status {
+        case "completed":
+            if ended {
+                completed++
+            } else {
+                open++
+            }
+        case "cancelled":
+            if !ended {
+                open++
+            }
+        default:
+            open++
+        }
+    }
+    return open, completed
+}
+
+// within reports whether a date falls in [start, end).
+func within(t NullableTime, start, end time.Time) bool {
+    return t.Valid && !t.Time.Before(start) && t.Time.Before(end)
+}
+
+// localDay returns local midnight of the day of t.
+func localDay(t time.Time) time.Time {
+    t = t.Local()
+    return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// periodStart returns local midnight of the first day of the period containing t.
+func periodStart(t time.Time, by string) time.Time {
+    day := localDay(t)
+    switch by {
+    case "week":
+        return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+    case "month":
+        return day.AddDate(0, 0, 1-day.Day())
+    }
+    return day
+}
+
+// addPeriods moves the start of a period n periods ahead, or back when n is negative.
+func addPeriods(start time.Time, by string, n int) time.Time {
+    switch by {
+    case "week":
+        return start.AddDate(0, 0, 7*n)
+    case "month":
+        return start.AddDate(0, n, 0)
+    }
+    return start.AddDate(0, 0, n)
+}
+
+// The lead time of a completed task runs from its start date to its end date. The part of it
+// spent waiting is the overlap with the waiting period of the task, from start_waiting_date to
+// end_waiting_date. Working time is counted as CalculateWorkingHoursDuration does.
+
+// LeadTimes summarizes the lead times of a group of tasks in calendar or in working time.
+type LeadTimes struct {
+    Median  time.Duration
+    P85     time.Duration // 85% of the tasks took at most this long
+    P95     time.Duration
+    Total   time.Duration // sum of the lead times
+    Waiting time.Duration // sum of the time spent waiting within them
+}
+
+// WaitingShare returns the part of the total lead time spent waiting, from 0 to 1.
+func (l LeadTimes) WaitingShare() float64 {
+    if l.Total == 0 {
+        return 0
+    }
+    return float64(l.Waiting) / float64(l.Total)
+}
+
+// CycleTimeGroup summarizes the lead times of the completed tasks of a project, context or tag.
+type CycleTimeGroup struct {
+    Name     string
+    Tasks    int
+    Calendar LeadTimes
+    Working  LeadTimes
+}
+
+// CycleTimeReport groups the completed tasks by project, by context and by tag. A task with
+// several contexts or tags counts for each of them.
+type CycleTimeReport struct {
+    Total     CycleTimeGroup
+    ByProject []CycleTimeGroup // "" for tasks without a project
+    ByContext []CycleTimeGroup // "" for tasks without contexts
+    ByTag     []CycleTimeGroup // "" for tasks without tags
+}
+
+// leadTimeSamples collects the lead times of a group before they are summarized.
+type leadTimeSamples struct {
+    calendar, working               []time.Duration
+    calendarWaiting, workingWaiting time.Duration
+}
+
+func (s *leadTimeSamples) add(calendar, working, calendarWaiting, workingWaiting time.Duration) {
+    s.calendar = append(s.calendar, calendar)
+    s.working = append(s.working, working)
+    s.calendarWaiting += calendarWaiting
+    s.workingWaiting += workingWaiting
+}
+
+func (s *leadTimeSamples) group(name string) CycleTimeGroup {
+    return CycleTimeGroup{
+        Name:     name,
+        Tasks:    len(s.calendar),
+        Calendar: summarizeLeadTimes(s.calendar, s.calendarWaiting),
+        Working:  summarizeLeadTimes(s.working, s.workingWaiting),
+    }
+}
+
+// CycleTime summarizes the lead times of the completed tasks matching a filter that have a
+// start and an end date. The status of the filter is ignored.
+func (tm *TodoManager) CycleTime(filter TaskFilter) (*CycleTimeReport, error) {
+    filter.Status = "completed"
+    filter.ExpandUntil = NullableTime{}
+    filter.IncludeNotes = false
+    tasks, err := tm.GetTasks(filter)
+    if err != nil {
+        return nil, err
+    }
+    cal, err := loadWorkCalendar(tm.db)
+    if err != nil {
+        return nil, err
+    }
+
+    total := &leadTimeSamples{}
+    projects := map[string]*leadTimeSamples{}
+    contexts := map[string]*leadTimeSamples{}
+    tags := map[string]*leadTimeSamples{}
+    add := func(groups map[string]*leadTimeSamples, names []string, calendar, working, calendarWaiting, workingWaiting time.Duration) {
+        if len(names) == 0 {
+            names = []string{""}
+        }
+        for _, name := range names {
+            if groups[name] == nil {
+                groups[name] = &leadTimeSamples{}
+            }
+            groups[name].add(calendar, working, calendarWaiting, workingWaiting)
+        }
+    }
+    for _, task := range tasks {
+        if !task.StartDate.Valid || !task.EndDate.Valid || task.EndDate.Time.Before(task.StartDate.Time) {
+            continue
+        }
+        calendar := task.EndDate.Time.Sub(task.StartDate.Time)
+        working := CalculateWorkingHoursDuration(tm.db, task.StartDate, task.EndDate, cal.hours, cal.holidays)
+        var calendarWaiting, workingWaiting time.Duration
+        if task.StartWaitingDate.Valid && task.EndWaitingDate.Valid {
+            from := MaxTime(task.StartDate.Time, task.StartWaitingDate.Time)
+            to := MinTime(task.EndDate.Time, task.EndWaitingDate.Time)
+            if from.Before(to) {
+                calendarWaiting = to.Sub(from)
+                workingWaiting = CalculateWorkingHoursDuration(tm.db, NullableTime{Time: from, Valid: true}, NullableTime{Time: to, Valid: true}, cal.hours, cal.holidays)
+            }
+        }
+
+        total.add(calendar, working, calendarWaiting, workingWaiting)
+        project := []string{}
+        if task.ProjectName.Valid {
+            project = append(project, task.ProjectName.String)
+        }
+        add(projects, project, calendar, working, calendarWaiting, workingWaiting)
+        add(contexts, task.Contexts, calendar, working, calendarWaiting, workingWaiting)
+        add(tags, task.Tags, calendar, working, calendarWaiting, workingWaiting)
+    }
+    return &CycleTimeReport{
+        Total:     total.group(""),
+        ByProject: cycleTimeGroups(projects),
+        ByContext: cycleTimeGroups(contexts),
+        ByTag:     cycleTimeGroups(tags),
+    }, nil
+}
+
+// cycleTimeGroups summarizes the samples of every group, ordered by name with the unnamed
+// group last.
+func cycleTimeGroups(samples map[string]*leadTimeSamples) []CycleTimeGroup {
+    groups := make([]CycleTimeGroup, 0, len(samples))
+    for name, s := range samples {
+        groups = append(groups, s.group(name))
+    }
+    sort.Slice(groups, func(i, j int) bool { return namedFirst(groups[i].Name, groups[j].Name) })
+    return groups
+}
+
+// summarizeLeadTimes computes the percentiles of lead times with the nearest-rank method.
+func summarizeLeadTimes(durations []time.Duration, waiting time.Duration) LeadTimes {
+    l := LeadTimes{Waiting: waiting}
+    if len(durations) == 0 {
+        return l
+    }
+    sorted := append([]time.Duration(nil), durations...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+    for _, d := range sorted {
+        l.Total += d
+    }
+    rank := func(p float64) time.Duration {
+        return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+    }
+    l.Median, l.P85, l.P95 = rank(0.5), rank(0.85), rank(0.95)
+    return l
+}
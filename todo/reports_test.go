@@ -0,0 +1,241 @@
+package todo
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestPeriodStart(t *testing.T) {
+    tests := []struct {
+        at   string
+        by   string
+        want string
+    }{
+        {"2026-03-04 15:30", "day", "2026-03-04 00:00"},
+        {"2026-03-04 15:30", "week", "2026-03-02 00:00"},
+        {"2026-03-02 00:00", "week", "2026-03-02 00:00"},
+        {"2026-03-08 23:59", "week", "2026-03-02 00:00"}, // Sunday ends the week
+        {"2026-03-31 12:00", "month", "2026-03-01 00:00"},
+    }
+    for _, tt := range tests {
+        if got := periodStart(localTime(t, tt.at), tt.by); !got.Equal(localTime(t, tt.want)) {
+            t.Errorf("periodStart(%s, %s) = %s, want %s", tt.at, tt.by, got.Format("2006-01-02 15:04"), tt.want)
+        }
+    }
+}
+
+func TestCountScope(t *testing.T) {
+    at := func(value string) NullableTime { return NullableTime{Time: localTime(t, value).UTC(), Valid: true} }
+    tasks := []Task{
+        {Status: "pending", StartDate: at("2026-03-02 09:00")},
+        {Status: "waiting", StartDate: at("2026-03-04 09:00")},
+        {Status: "completed", StartDate: at("2026-03-02 09:00"), EndDate: at("2026-03-03 12:00")},
+        {Status: "completed"}, // completed from the beginning
+        {Status: "cancelled", StartDate: at("2026-03-02 09:00"), EndDate: at("2026-03-04 12:00")},
+        {Status: "cancelled"}, // never in scope
+    }
+    tests := []struct {
+        before          string
+        open, completed int
+    }{
+        {"2026-03-02 09:00", 0, 1},
+        {"2026-03-03 00:00", 3, 1},
+        {"2026-03-04 00:00", 2, 2},
+        {"2026-03-05 00:00", 2, 2},
+    }
+    for _, tt := range tests {
+        open, completed := countScope(tasks, localTime(t, tt.before))
+        if open != tt.open || completed != tt.completed {
+            t.Errorf("before %s: got %d open and %d completed, want %d and %d", tt.before, open, completed, tt.open, tt.completed)
+        }
+    }
+}
+
+// addReportTasks adds the tasks of the web project from Monday 2 March 2026, and one of
+// another project.
+func addReportTasks(t *testing.T, tm *TodoManager) {
+    t.Helper()
+    inputs := []TaskInput{
+        {Title: "design page", Project: "web", StartDate: strPtr("2026-03-02 09:00:00"), EndDate: strPtr("2026-03-04 10:00:00")},
+        {Title: "write copy", Project: "web", StartDate: strPtr("2026-03-03 09:00:00")},
+        {Title: "add carousel", Project: "web", StartDate: strPtr("2026-03-03 09:00:00"), EndDate: strPtr("2026-03-05 12:00:00"), Status: "cancelled"},
+        {Title: "fix footer", Project: "web", StartDate: strPtr("2026-03-05 09:00:00"), EndDate: strPtr("2026-03-05 15:00:00")},
+        {Title: "water plants", Project: "home", StartDate: strPtr("2026-03-02 09:00:00")},
+    }
+    for _, in := range inputs {
+        if _, err := tm.AddTask(in); err != nil {
+            t.Fatalf("AddTask(%s): %v", in.Title, err)
+        }
+    }
+}
+
+func TestBurndown(t *testing.T) {
+    tm := newTestManager(t)
+    addReportTasks(t, tm)
+    tests := []struct {
+        name     string
+        filter   TaskFilter
+        from, to string
+        want     string // open/completed at the end of each day
+        err      error
+    }{
+        {"project", TaskFilter{Project: "web"}, "2026-03-02 00:00", "2026-03-06 12:00", "[1/0 3/0 2/1 1/2 1/2]", nil},
+        {"all", TaskFilter{Status: "pending"}, "2026-03-02 00:00", "2026-03-03 12:00", "[2/0 4/0]", nil},
+        {"default start", TaskFilter{Project: "web"}, "", "2026-03-06 12:00", "[0/0 0/0 0/0 0/0 0/0 0/0 0/0 0/0 0/0 1/0 3/0 2/1 1/2 1/2]", nil},
+        {"start after end", TaskFilter{}, "2026-03-06 00:00", "2026-03-02 00:00", "", ErrInvalidInput},
+    }
+    for _, tt := range tests {
+        var from time.Time
+        if tt.from != "" {
+            from = localTime(t, tt.from)
+        }
+        points, err := tm.Burndown(tt.filter, from, localTime(t, tt.to))
+        if !errors.Is(err, tt.err) {
+            t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+            continue
+        }
+        if err != nil {
+            continue
+        }
+        var got []string
+        for _, p := range points {
+            got = append(got, fmt.Sprintf("%d/%d", p.Open, p.Completed))
+        }
+        if fmt.Sprint(got) != tt.want {
+            t.Errorf("%s: got %v, want %s", tt.name, got, tt.want)
+        }
+        if last := points[len(points)-1].Date; !last.Equal(localDay(localTime(t, tt.to))) {
+            t.Errorf("%s: last point on %s", tt.name, last)
+        }
+    }
+}
+
+func TestThroughput(t *testing.T) {
+    tm := newTestManager(t)
+    addReportTasks(t, tm)
+    tests := []struct {
+        name     string
+        by       string
+        from, to string
+        want     string // added/completed/open of each period
+        err      error
+    }{
+        {"by day", "day", "2026-03-02 00:00", "2026-03-05 18:00", "[1/0/1 2/0/3 0/1/2 1/1/1]", nil},
+        {"by week", "week", "2026-03-04 00:00", "2026-03-12 00:00", "[4/2/1 0/0/1]", nil},
+        {"by month", "month", "2026-02-15 00:00", "2026-03-31 00:00", "[0/0/0 4/2/1]", nil},
+        {"default start", "week", "", "2026-03-04 00:00", "[0/0/0 0/0/0 0/0/0 0/0/0 0/0/0 0/0/0 0/0/0 4/2/1]", nil},
+        {"unknown period", "year", "", "2026-03-04 00:00", "", ErrInvalidInput},
+        {"start after end", "day", "2026-03-06 00:00", "2026-03-02 00:00", "", ErrInvalidInput},
+    }
+    for _, tt := range tests {
+        var from time.Time
+        if tt.from != "" {
+            from = localTime(t, tt.from)
+        }
+        periods, err := tm.Throughput(TaskFilter{Project: "web"}, tt.by, from, localTime(t, tt.to))
+        if !errors.Is(err, tt.err) {
+            t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+            continue
+        }
+        var got []string
+        for _, p := range periods {
+            got = append(got, fmt.Sprintf("%d/%d/%d", p.Added, p.Completed, p.Open))
+        }
+        if err == nil && fmt.Sprint(got) != tt.want {
+            t.Errorf("%s: got %v, want %s", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestSummarizeLeadTimes(t *testing.T) {
+    hours := func(values ...int) []time.Duration {
+        durations := []time.Duration{}
+        for _, v := range values {
+            durations = append(durations, time.Duration(v)*time.Hour)
+        }
+        return durations
+    }
+    twenty := hours(20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1)
+    tests := []struct {
+        name      string
+        durations []time.Duration
+        want      string // median, p85, p95 and total in hours
+    }{
+        {"none", nil, "0 0 0 0"},
+        {"one", hours(5), "5 5 5 5"},
+        {"two", hours(8, 2), "2 8 8 10"},
+        {"unsorted", hours(66, 4, 24), "24 66 66 94"},
+        {"twenty", twenty, "10 17 19 210"},
+    }
+    for _, tt := range tests {
+        l := summarizeLeadTimes(tt.durations, time.Hour)
+        got := fmt.Sprintf("%g %g %g %g", l.Median.Hours(), l.P85.Hours(), l.P95.Hours(), l.Total.Hours())
+        if got != tt.want {
+            t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestCycleTime(t *testing.T) {
+    tm := newTestManager(t)
+    for day := time.Monday; day <= time.Friday; day++ {
+        if _, err := tm.SetWorkingHours(int(day), 9, 0, 17, 0, 0); err != nil {
+            t.Fatal(err)
+        }
+    }
+    inputs := []TaskInput{
+        // 24h, 8h of it working time, with 2h of waiting
+        {Title: "fix login", Project: "web", Contexts: []string{"office"}, Tags: []string{"bug"},
+            StartDate: strPtr("2026-03-04 09:00:00"), EndDate: strPtr("2026-03-05 09:00:00"),
+            StartWaiting: strPtr("2026-03-04 13:00:00"), EndWaiting: strPtr("2026-03-04 15:00:00")},
+        // 4h
+        {Title: "fix menu", Project: "web", Tags: []string{"bug", "ui"},
+            StartDate: strPtr("2026-03-05 09:00:00"), EndDate: strPtr("2026-03-05 13:00:00")},
+        // 66h over the weekend, 2h of it working time
+        {Title: "renew domain", StartDate: strPtr("2026-03-06 16:00:00"), EndDate: strPtr("2026-03-09 10:00:00")},
+        {Title: "write copy", Project: "web", StartDate: strPtr("2026-03-02 09:00:00")},
+    }
+    for _, in := range inputs {
+        if _, err := tm.AddTask(in); err != nil {
+            t.Fatalf("AddTask(%s): %v", in.Title, err)
+        }
+    }
+    report, err := tm.CycleTime(TaskFilter{Status: "pending"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    format := func(g CycleTimeGroup) string {
+        return fmt.Sprintf("%s: %d, calendar %g/%g/%g waiting %.2f, working %g/%g/%g waiting %.2f", g.Name, g.Tasks,
+            g.Calendar.Median.Hours(), g.Calendar.P85.Hours(), g.Calendar.P95.Hours(), g.Calendar.WaitingShare(),
+            g.Working.Median.Hours(), g.Working.P85.Hours(), g.Working.P95.Hours(), g.Working.WaitingShare())
+    }
+    tests := []struct {
+        name   string
+        groups []CycleTimeGroup
+        want   []string
+    }{
+        {"total", []CycleTimeGroup{report.Total}, []string{
+            ": 3, calendar 24/66/66 waiting 0.02, working 4/8/8 waiting 0.14"}},
+        {"by project", report.ByProject, []string{
+            "web: 2, calendar 4/24/24 waiting 0.07, working 4/8/8 waiting 0.17",
+            ": 1, calendar 66/66/66 waiting 0.00, working 2/2/2 waiting 0.00"}},
+        {"by context", report.ByContext, []string{
+            "office: 1, calendar 24/24/24 waiting 0.08, working 8/8/8 waiting 0.25",
+            ": 2, calendar 4/66/66 waiting 0.00, working 2/4/4 waiting 0.00"}},
+        {"by tag", report.ByTag, []string{
+            "bug: 2, calendar 4/24/24 waiting 0.07, working 4/8/8 waiting 0.17",
+            "ui: 1, calendar 4/4/4 waiting 0.00, working 4/4/4 waiting 0.00",
+            ": 1, calendar 66/66/66 waiting 0.00, working 2/2/2 waiting 0.00"}},
+    }
+    for _, tt := range tests {
+        var got []string
+        for _, g := range tt.groups {
+            got = append(got, format(g))
+        }
+        if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+            t.Errorf("%s:\ngot  %q\nwant %q", tt.name, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,515 @@
+package todo
+
+import (
+    "fmt"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Recurrence rules follow RFC 5545 (section 3.3.10), for example
+//
+//    FREQ=MONTHLY;BYDAY=TU;BYSETPOS=2              every 2nd Tuesday
+//    FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1 last weekday of the month
+//    FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20270630     Mon/Wed/Fri until 2027-06-30
+//    FREQ=DAILY;INTERVAL=3;COUNT=10                every 3 days, 10 times
+//
+// The plain daily, weekly, monthly and yearly patterns are shorthands for FREQ=DAILY and so on,
+// with the interval taken from the recurrence_interval column. workdaily repeats every
+// interval working days, which has no RRULE equivalent.
+
+// rruleFrequencies maps the recurrence keywords to RRULE FREQ values and back.
+var rruleFrequencies = map[string]string{
+    "daily":   "DAILY",
+    "weekly":  "WEEKLY",
+    "monthly": "MONTHLY",
+    "yearly":  "YEARLY",
+}
+
+// freqWorkDaily is the frequency of the workdaily pattern. It is not a valid RRULE FREQ.
+const freqWorkDaily = "WORKDAILY"
+
+// rruleWeekdays maps RRULE weekday codes to weekdays.
+var rruleWeekdays = map[string]time.Weekday{
+    "SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+    "TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// rruleMaxPeriods bounds the search for the next occurrence, so rules that never match
+// (such as BYMONTH=2;BYMONTHDAY=30) end instead of looping forever.
+const rruleMaxPeriods = 10000
+
+// ByDay is a BYDAY entry: a weekday with an optional ordinal, e.g. 2TU (second Tuesday)
+// or -1FR (last Friday). N is 0 for every such weekday in the period.
+type ByDay struct {
+    N       int
+    Weekday time.Weekday
+}
+
+// RRule is a parsed recurrence rule.
+type RRule struct {
+    Freq       string // DAILY, WEEKLY, MONTHLY or YEARLY
+    Interval   int
+    ByDay      []ByDay
+    ByMonthDay []int // 1 to 31, or -1 (last day) to -31
+    ByMonth    []int
+    BySetPos   []int
+    Until      NullableTime // last possible occurrence, inclusive
+    Count      int          // number of occurrences in the series, 0 for no limit
+    WeekStart  time.Weekday
+}
+
+// IsRRule reports whether a recurrence is a rule rather than one of the plain keywords.
+func IsRRule(recurrence string) bool {
+    recurrence = strings.ToLower(strings.TrimSpace(recurrence))
+    _, keyword := rruleFrequencies[recurrence]
+    return recurrence != "" && !keyword && recurrence != "workdaily"
+}
+
+// ParseRecurrence parses a recurrence keyword (daily, weekly, monthly, yearly, workdaily) or an RRULE
+// value, with or without the "RRULE:" prefix. interval is used by the keywords, and by
+// rules without an INTERVAL part; values below 1 count as 1.
+func ParseRecurrence(recurrence string, interval int) (*RRule, error) {
+    if interval < 1 {
+        interval = 1
+    }
+    recurrence = strings.TrimSpace(recurrence)
+    if freq, ok := rruleFrequencies[strings.ToLower(recurrence)]; ok {
+        return &RRule{Freq: freq, Interval: interval, WeekStart: time.Monday}, nil
+    }
+    if strings.EqualFold(recurrence, "workdaily") {
+        return &RRule{Freq: freqWorkDaily, Interval: interval, WeekStart: time.Monday}, nil
+    }
+
+    rule := &RRule{Interval: interval, WeekStart: time.Monday}
+    value := recurrence
+    if len(value) >= 6 && strings.EqualFold(value[:6], "RRULE:") {
+        value = value[6:]
+    }
+    if !strings.Contains(value, "=") {
+        return nil, fmt.Errorf("%w: unknown recurrence '%s' (expected daily, weekly, monthly, yearly, workdaily or a rule such as FREQ=MONTHLY;BYDAY=2TU)", ErrInvalidInput, recurrence)
+    }
+
+    var p problems
+    seen := map[string]bool{}
+    for _, part := range strings.Split(value, ";") {
+        key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+        key, val = strings.ToUpper(strings.TrimSpace(key)), strings.ToUpper(strings.TrimSpace(val))
+        if !ok || val == "" {
+            p.add("invalid rule part '%s' (expected NAME=VALUE)", part)
+            continue
+        }
+        if seen[key] {
+            p.add("%s given more than once", key)
+        }
+        seen[key] = true
+
+        switch key {
+        case "FREQ":
+            for _, freq := range rruleFrequencies {
+                if freq == val {
+                    rule.Freq = freq
+                }
+            }
+            if rule.Freq == "" {
+                p.add("unsupported FREQ '%s' (expected DAILY, WEEKLY, MONTHLY or YEARLY)", val)
+            }
+        case "INTERVAL":
+            rule.Interval = p.rruleInt(key, val, 1, 0)
+        case "COUNT":
+            rule.Count = p.rruleInt(key, val, 1, 0)
+        case "UNTIL":
+            rule.Until = p.rruleUntil(val)
+        case "BYDAY":
+            for _, day := range strings.Split(val, ",") {
+                rule.ByDay = append(rule.ByDay, p.rruleByDay(day))
+            }
+        case "BYMONTHDAY":
+            for _, n := range strings.Split(val, ",") {
+                rule.ByMonthDay = append(rule.ByMonthDay, p.rruleInt(key, n, -31, 31))
+            }
+        case "BYMONTH":
+            for _, n := range strings.Split(val, ",") {
+                rule.ByMonth = append(rule.ByMonth, p.rruleInt(key, n, 1, 12))
+            }
+        case "BYSETPOS":
+            for _, n := range strings.Split(val, ",") {
+                rule.BySetPos = append(rule.BySetPos, p.rruleInt(key, n, -366, 366))
+            }
+        case "WKST":
+            day, ok := rruleWeekdays[val]
+            if !ok {
+                p.add("invalid WKST '%s'", val)
+            }
+            rule.WeekStart = day
+        default:
+            p.add("unsupported rule part %s (supported: FREQ, INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, BYSETPOS, UNTIL, COUNT, WKST)", key)
+        }
+    }
+
+    if !seen["FREQ"] {
+        p.add("FREQ is required")
+    }
+    if rule.Count > 0 && rule.Until.Valid {
+        p.add("COUNT and UNTIL cannot be combined")
+    }
+    if rule.Freq == "WEEKLY" && len(rule.ByMonthDay) > 0 {
+        p.add("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+    }
+    for _, d := range rule.ByDay {
+        if d.N != 0 && rule.Freq != "MONTHLY" && rule.Freq != "YEARLY" {
+            p.add("numbered BYDAY values such as 2TU need FREQ=MONTHLY or FREQ=YEARLY")
+            break
+        }
+    }
+    if len(rule.BySetPos) > 0 && len(rule.ByDay) == 0 && len(rule.ByMonthDay) == 0 && len(rule.ByMonth) == 0 {
+        p.add("BYSETPOS needs BYDAY, BYMONTHDAY or BYMONTH")
+    }
+    if err := p.err(); err != nil {
+        return nil, err
+    }
+    return rule, nil
+}
+
+func (p *problems) rruleInt(key, value string, min, max int) int {
+    n, err := strconv.Atoi(value)
+    if err != nil || n < min || (max != 0 && n > max) || n == 0 {
+        p.add("invalid %s value '%s'", key, value)
+        return 0
+    }
+    return n
+}
+
+// rruleByDay parses a BYDAY entry such as TU, 2TU or -1FR.
+func (p *problems) rruleByDay(value string) ByDay {
+    if len(value) < 2 {
+        p.add("invalid BYDAY value '%s'", value)
+        return ByDay{}
+    }
+    day, ok := rruleWeekdays[value[len(value)-2:]]
+    if !ok {
+        p.add("invalid BYDAY value '%s'", value)
+        return ByDay{}
+    }
+    n := 0
+    if prefix := value[:len(value)-2]; prefix != "" {
+        n = p.rruleInt("BYDAY", prefix, -53, 53)
+    }
+    return ByDay{N: n, Weekday: day}
+}
+
+// rruleUntil parses an UNTIL value: a date (YYYYMMDD or YYYY-MM-DD, inclusive, in local time)
+// or a UTC date-time (YYYYMMDDTHHMMSSZ).
+func (p *problems) rruleUntil(value string) NullableTime {
+    if t, err := time.Parse("20060102T150405Z", value); err == nil {
+        return NullableTime{Time: t.UTC(), Valid: true}
+    }
+    for _, layout := range []string{"20060102", "2006-01-02"} {
+        if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+            endOfDay := t.AddDate(0, 0, 1).Add(-time.Second)
+            return NullableTime{Time: endOfDay.UTC(), Valid: true}
+        }
+    }
+    p.add("invalid UNTIL '%s' (expected YYYYMMDD or YYYYMMDDTHHMMSSZ)", value)
+    return NullableTime{}
+}
+
+// String formats the rule as an RRULE value, without the "RRULE:" prefix.
+func (r *RRule) String() string {
+    parts := []string{"FREQ=" + r.Freq}
+    if r.Interval > 1 {
+        parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+    }
+    if len(r.ByMonth) > 0 {
+        parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+    }
+    if len(r.ByMonthDay) > 0 {
+        parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+    }
+    if len(r.ByDay) > 0 {
+        days := make([]string, len(r.ByDay))
+        for i, d := range r.ByDay {
+            days[i] = weekdayCode(d.Weekday)
+            if d.N != 0 {
+                days[i] = strconv.Itoa(d.N) + days[i]
+            }
+        }
+        parts = append(parts, "BYDAY="+strings.Join(days, ","))
+    }
+    if len(r.BySetPos) > 0 {
+        parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+    }
+    if r.WeekStart != time.Monday {
+        parts = append(parts, "WKST="+weekdayCode(r.WeekStart))
+    }
+    if r.Until.Valid {
+        parts = append(parts, "UNTIL="+r.Until.Time.UTC().Format("20060102T150405Z"))
+    }
+    if r.Count > 0 {
+        parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+    }
+    return strings.Join(parts, ";")
+}
+
+// isPlain reports whether the rule only has FREQ and INTERVAL, like the recurrence keywords.
+func (r *RRule) isPlain() bool {
+    return len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && len(r.ByMonth) == 0 && len(r.BySetPos) == 0 &&
+        !r.Until.Valid && r.Count == 0 && r.WeekStart == time.Monday
+}
+
+func weekdayCode(day time.Weekday) string {
+    for code, d := range rruleWeekdays {
+        if d == day {
+            return code
+        }
+    }
+    return ""
+}
+
+func joinInts(values []int) string {
+    s := make([]string, len(values))
+    for i, v := range values {
+        s[i] = strconv.Itoa(v)
+    }
+    return strings.Join(s, ",")
+}
+
+// normalizeRecurrence returns the form a validated recurrence is stored in: keywords in lower
+// case and rules in the canonical form written by RRule.String.
+func normalizeRecurrence(recurrence string) string {
+    if !IsRRule(recurrence) {
+        return strings.ToLower(strings.TrimSpace(recurrence))
+    }
+    rule, err := ParseRecurrence(recurrence, 1)
+    if err != nil {
+        return strings.TrimSpace(recurrence)
+    }
+    return rule.String()
+}
+
+// Next returns the first occurrence of the rule after the given time, counting periods from
+// start, which is taken to be an occurrence itself (the current instance of the series).
+// Occurrences keep the local time of day of start. It returns false when the rule has no
+// further occurrence, because of UNTIL or because nothing matches.
+func (r *RRule) Next(start, after time.Time) (time.Time, bool) {
+    if r.Freq == freqWorkDaily {
+        return time.Time{}, false // needs the working days, see workCalendar
+    }
+    start = start.Local()
+    period := r.periodStart(start)
+    for i := 0; i < rruleMaxPeriods; i++ {
+        for _, candidate := range r.occurrences(period, start) {
+            if r.Until.Valid && candidate.After(r.Until.Time) {
+                return time.Time{}, false
+            }
+            if candidate.After(after) {
+                return candidate, true
+            }
+        }
+        period = r.nextPeriod(period)
+        if r.Until.Valid && period.After(r.Until.Time) {
+            return time.Time{}, false
+        }
+    }
+    return time.Time{}, false
+}
+
+// periodStart returns the local midnight starting the day, week, month or year containing t.
+func (r *RRule) periodStart(t time.Time) time.Time {
+    day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+    switch r.Freq {
+    case "WEEKLY":
+        offset := (int(day.Weekday()) - int(r.WeekStart) + 7) % 7
+        return day.AddDate(0, 0, -offset)
+    case "MONTHLY":
+        return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+    case "YEARLY":
+        return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.Local)
+    }
+    return day
+}
+
+func (r *RRule) nextPeriod(period time.Time) time.Time {
+    switch r.Freq {
+    case "WEEKLY":
+        return period.AddDate(0, 0, 7*r.Interval)
+    case "MONTHLY":
+        return period.AddDate(0, r.Interval, 0)
+    case "YEARLY":
+        return period.AddDate(r.Interval, 0, 0)
+    }
+    return period.AddDate(0, 0, r.Interval)
+}
+
+// occurrences lists the occurrences within the period starting at period, in order.
+func (r *RRule) occurrences(period, start time.Time) []time.Time {
+    var days []time.Time
+    switch r.Freq {
+    case "DAILY":
+        if r.matchesMonth(period) && r.matchesMonthDay(period) && r.matchesWeekday(period) {
+            days = append(days, period)
+        }
+    case "WEEKLY":
+        for i := 0; i < 7; i++ {
+            day := period.AddDate(0, 0, i)
+            if !r.matchesMonth(day) {
+                continue
+            }
+            if len(r.ByDay) > 0 && r.matchesWeekday(day) || len(r.ByDay) == 0 && day.Weekday() == start.Weekday() {
+                days = append(days, day)
+            }
+        }
+    case "MONTHLY":
+        if r.matchesMonth(period) {
+            days = r.monthDays(period, start)
+        }
+    case "YEARLY":
+        days = r.yearDays(period, start)
+    }
+
+    days = r.applySetPos(days)
+    result := make([]time.Time, len(days))
+    for i, day := range days {
+        result[i] = time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), 0, time.Local)
+    }
+    return result
+}
+
+// monthDays lists the days of the month starting at month that match BYMONTHDAY and BYDAY,
+// or the day of month of start when neither is given (skipping months that are too short).
+func (r *RRule) monthDays(month, start time.Time) []time.Time {
+    var days []time.Time
+    last := month.AddDate(0, 1, -1).Day()
+    for d := 1; d <= last; d++ {
+        day := month.AddDate(0, 0, d-1)
+        switch {
+        case len(r.ByMonthDay) == 0 && len(r.ByDay) == 0:
+            if d == start.Day() {
+                days = append(days, day)
+            }
+        case r.matchesMonthDay(day) && r.matchesNthWeekday(day, month, month.AddDate(0, 1, 0)):
+            days = append(days, day)
+        }
+    }
+    return days
+}
+
+// yearDays lists the matching days of the year starting at year. Without BYMONTH, numbered
+// BYDAY values count within the year; with it, within each month.
+func (r *RRule) yearDays(year, start time.Time) []time.Time {
+    if len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+        day := time.Date(year.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+        if day.Month() != start.Month() { // February 29 in a common year
+            return nil
+        }
+        return []time.Time{day}
+    }
+    if len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 {
+        var days []time.Time
+        end := year.AddDate(1, 0, 0)
+        for day := year; day.Before(end); day = day.AddDate(0, 0, 1) {
+            if r.matchesNthWeekday(day, year, end) {
+                days = append(days, day)
+            }
+        }
+        return days
+    }
+
+    var days []time.Time
+    for m := 1; m <= 12; m++ {
+        month := time.Date(year.Year(), time.Month(m), 1, 0, 0, 0, 0, time.Local)
+        if !r.matchesMonth(month) {
+            continue
+        }
+        if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+            // BYMONTH alone: the day of month of start in each listed month
+            if day := month.AddDate(0, 0, start.Day()-1); day.Month() == month.Month() {
+                days = append(days, day)
+            }
+            continue
+        }
+        days = append(days, r.monthDays(month, start)...)
+    }
+    return days
+}
+
+// applySetPos keeps only the BYSETPOS positions of the sorted days of a period.
+func (r *RRule) applySetPos(days []time.Time) []time.Time {
+    if len(r.BySetPos) == 0 || len(days) == 0 {
+        return days
+    }
+    var picked []time.Time
+    seen := map[int]bool{}
+    for _, pos := range r.BySetPos {
+        i := pos - 1
+        if pos < 0 {
+            i = len(days) + pos
+        }
+        if i >= 0 && i < len(days) && !seen[i] {
+            seen[i] = true
+            picked = append(picked, days[i])
+        }
+    }
+    sort.Slice(picked, func(a, b int) bool { return picked[a].Before(picked[b]) })
+    return picked
+}
+
+func (r *RRule) matchesMonth(day time.Time) bool {
+    if len(r.ByMonth) == 0 {
+        return true
+    }
+    for _, m := range r.ByMonth {
+        if time.Month(m) == day.Month() {
+            return true
+        }
+    }
+    return false
+}
+
+func (r *RRule) matchesMonthDay(day time.Time) bool {
+    if len(r.ByMonthDay) == 0 {
+        return true
+    }
+    last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.Local).Day()
+    for _, d := range r.ByMonthDay {
+        if d == day.Day() || d < 0 && last+d+1 == day.Day() {
+            return true
+        }
+    }
+    return false
+}
+
+// matchesWeekday checks BYDAY ignoring ordinals, for the DAILY and WEEKLY frequencies.
+func (r *RRule) matchesWeekday(day time.Time) bool {
+    if len(r.ByDay) == 0 {
+        return true
+    }
+    for _, d := range r.ByDay {
+        if d.Weekday == day.Weekday() {
+            return true
+        }
+    }
+    return false
+}
+
+// matchesNthWeekday checks BYDAY with ordinals counted within [from, to).
+func (r *RRule) matchesNthWeekday(day, from, to time.Time) bool {
+    if len(r.ByDay) == 0 {
+        return true
+    }
+    for _, d := range r.ByDay {
+        if d.Weekday != day.Weekday() {
+            continue
+        }
+        if d.N == 0 {
+            return true
+        }
+        nth := int(day.Sub(from).Hours()/24+0.5)/7 + 1
+        nthFromEnd := -(int(to.Sub(day).Hours()/24+0.5)-1)/7 - 1
+        if d.N == nth || d.N == nthFromEnd {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,135 @@
+package todo
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestParseRecurrence(t *testing.T) {
+    tests := []struct {
+        recurrence string
+        interval   int
+        want       string // canonical form, empty when parsing fails
+    }{
+        {"daily", 0, "FREQ=DAILY"},
+        {"Weekly", 2, "FREQ=WEEKLY;INTERVAL=2"},
+        {"RRULE:freq=monthly;byday=tu;bysetpos=2", 1, "FREQ=MONTHLY;BYDAY=TU;BYSETPOS=2"},
+        {"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", 1, "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1"},
+        {"FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", 1, "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH"},
+        {"FREQ=DAILY;INTERVAL=3;COUNT=10", 5, "FREQ=DAILY;INTERVAL=3;COUNT=10"},
+        {"FREQ=WEEKLY;WKST=SU", 1, "FREQ=WEEKLY;WKST=SU"},
+
+        {"sometimes", 1, ""},
+        {"FREQ=HOURLY", 1, ""},
+        {"BYDAY=MO", 1, ""},
+        {"FREQ=DAILY;FREQ=WEEKLY", 1, ""},
+        {"FREQ=DAILY;INTERVAL=0", 1, ""},
+        {"FREQ=WEEKLY;BYDAY=2TU", 1, ""},
+        {"FREQ=WEEKLY;BYMONTHDAY=1", 1, ""},
+        {"FREQ=MONTHLY;BYSETPOS=1", 1, ""},
+        {"FREQ=DAILY;COUNT=3;UNTIL=20260101", 1, ""},
+        {"FREQ=DAILY;UNTIL=tomorrow", 1, ""},
+        {"FREQ=DAILY;BYHOUR=9", 1, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.recurrence, func(t *testing.T) {
+            rule, err := ParseRecurrence(tt.recurrence, tt.interval)
+            if tt.want == "" {
+                if !errors.Is(err, ErrInvalidInput) {
+                    t.Fatalf("got %v, %v; want ErrInvalidInput", rule, err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("unexpected error: %v", err)
+            }
+            if got := rule.String(); got != tt.want {
+                t.Errorf("got %s, want %s", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestRRuleNext(t *testing.T) {
+    tests := []struct {
+        name       string
+        recurrence string
+        interval   int
+        start      string
+        after      string
+        want       string // empty when there is no next occurrence
+    }{
+        {"every 3 days", "daily", 3, "2026-01-01 10:00", "2026-01-01 10:00", "2026-01-04 10:00"},
+        {"monthly skips short months", "monthly", 1, "2026-01-31 09:00", "2026-01-31 09:00", "2026-03-31 09:00"},
+        {"second Tuesday by position", "FREQ=MONTHLY;BYDAY=TU;BYSETPOS=2", 1, "2026-01-13 09:00", "2026-01-13 09:00", "2026-02-10 09:00"},
+        {"second Tuesday by ordinal", "FREQ=MONTHLY;BYDAY=2TU", 1, "2026-01-13 09:00", "2026-03-20 00:00", "2026-04-14 09:00"},
+        {"last Friday", "FREQ=MONTHLY;BYDAY=-1FR", 1, "2026-01-30 17:00", "2026-01-30 17:00", "2026-02-27 17:00"},
+        {"last weekday", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", 1, "2026-01-30 17:00", "2026-01-30 17:00", "2026-02-27 17:00"},
+        {"fourth Thursday of November", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", 1, "2026-11-26 12:00", "2026-11-26 12:00", "2027-11-25 12:00"},
+        {"weekly on several days", "FREQ=WEEKLY;BYDAY=MO,WE,FR", 1, "2026-01-12 09:00", "2026-01-12 09:00", "2026-01-14 09:00"},
+        {"until is inclusive", "FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20260114", 1, "2026-01-12 09:00", "2026-01-12 09:00", "2026-01-14 09:00"},
+        {"past until", "FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20260114", 1, "2026-01-12 09:00", "2026-01-14 09:00", ""},
+        {"never matches", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", 1, "2026-01-01 09:00", "2026-01-01 09:00", ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            rule, err := ParseRecurrence(tt.recurrence, tt.interval)
+            if err != nil {
+                t.Fatalf("ParseRecurrence: %v", err)
+            }
+            got, ok := rule.Next(localTime(t, tt.start), localTime(t, tt.after))
+            if tt.want == "" {
+                if ok {
+                    t.Fatalf("got %s, want no occurrence", got)
+                }
+                return
+            }
+            if !ok {
+                t.Fatalf("got no occurrence, want %s", tt.want)
+            }
+            if want := localTime(t, tt.want); !got.Equal(want) {
+                t.Errorf("got %s, want %s", got, want)
+            }
+        })
+    }
+}
+
+func TestRecurrenceCount(t *testing.T) {
+    tests := []struct {
+        recurrence string
+        instances  int
+    }{
+        {"FREQ=DAILY;COUNT=1", 1},
+        {"FREQ=DAILY;COUNT=3", 3},
+        {"FREQ=WEEKLY;BYDAY=MO,FR;COUNT=2", 2},
+    }
+    for _, tt := range tests {
+        t.Run(tt.recurrence, func(t *testing.T) {
+            tm := newTestManager(t)
+            task, err := tm.AddTask(TaskInput{Title: "series", DueDate: strPtr("2026-01-05 09:00:00"), Recurrence: tt.recurrence})
+            if err != nil {
+                t.Fatalf("AddTask: %v", err)
+            }
+            id, instances := task.ID, 1
+            for id != 0 {
+                results, err := tm.UpdateTasks([]int64{id}, TaskPatch{Status: strPtr("completed")})
+                if err != nil {
+                    t.Fatalf("completing task %d: %v", id, err)
+                }
+                if results[0].Err != nil {
+                    t.Fatalf("completing task %d: %v", id, results[0].Err)
+                }
+                id = results[0].NextTaskID
+                if id != 0 {
+                    instances++
+                }
+                if instances > tt.instances {
+                    break
+                }
+            }
+            if instances != tt.instances {
+                t.Errorf("got %d instances, want %d", instances, tt.instances)
+            }
+        })
+    }
+}
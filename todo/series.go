@@ -0,0 +1,334 @@
+package todo
+
+import (
+    "database/sql"
+    "fmt"
+    "sort"
+    "time"
+)
+
+// Series is a recurring task together with every instance created from it. The instances
+// are linked to the first one through original_task_id.
+type Series struct {
+    ID        int64       // ID of the first instance
+    Instances []Task      // every instance, oldest first
+    Upcoming  []time.Time // projected dates of the occurrences after the open instance
+}
+
+// Open returns the instances that are still pending or waiting, oldest first.
+func (s *Series) Open() []Task {
+    var open []Task
+    for _, t := range s.Instances {
+        if isOpen(t.Status) {
+            open = append(open, t)
+        }
+    }
+    return open
+}
+
+func isOpen(status string) bool {
+    return status == "pending" || status == "waiting"
+}
+
+// seriesID returns the ID of the first instance of the series a task belongs to. Tasks that
+// neither recur nor are linked to a series report ErrNotRecurring.
+func seriesID(q queryer, id int64) (int64, error) {
+    task, err := getTask(q, id)
+    if err != nil {
+        return 0, err
+    }
+    if task.OriginalTaskID.Valid {
+        return task.OriginalTaskID.Int64, nil
+    }
+    if task.Recurrence.Valid && task.Recurrence.String != "" {
+        return id, nil
+    }
+    var instances int
+    if err := q.QueryRow("SELECT COUNT(*) FROM tasks WHERE original_task_id = ?", id).Scan(&instances); err != nil {
+        return 0, fmt.Errorf("error looking up instances of task %d: %w", id, err)
+    }
+    if instances == 0 {
+        return 0, fmt.Errorf("task %d: %w", id, ErrNotRecurring)
+    }
+    return id, nil
+}
+
+// seriesTasks fetches every instance of a series, oldest first.
+func seriesTasks(q queryer, id int64) ([]Task, error) {
+    ids, err := queryIDs(q, "SELECT id FROM tasks WHERE id = ? OR original_task_id = ?", id, id)
+    if err != nil {
+        return nil, fmt.Errorf("error listing instances of series %d: %w", id, err)
+    }
+    tasks := make([]Task, 0, len(ids))
+    for _, taskID := range ids {
+        task, err := getTask(q, taskID)
+        if err != nil {
+            return nil, err
+        }
+        tasks = append(tasks, *task)
+    }
+    sort.SliceStable(tasks, func(i, j int) bool {
+        a, b := recurrenceAnchor(&tasks[i]), recurrenceAnchor(&tasks[j])
+        if !a.Equal(b) {
+            return a.Before(b)
+        }
+        return tasks[i].ID < tasks[j].ID
+    })
+    return tasks, nil
+}
+
+// GetSeries fetches the series of any of its instances, with up to upcoming projected
+// occurrences after its newest open instance.
+func (tm *TodoManager) GetSeries(id int64, upcoming int) (*Series, error) {
+    sid, err := seriesID(tm.db, id)
+    if err != nil {
+        return nil, err
+    }
+    series := &Series{ID: sid}
+    if series.Instances, err = seriesTasks(tm.db, sid); err != nil {
+        return nil, err
+    }
+    if open := series.Open(); len(open) > 0 && upcoming > 0 {
+        last := open[len(open)-1]
+        if series.Upcoming, err = projectOccurrences(tm.db, &last, len(series.Instances), upcoming, time.Time{}); err != nil {
+            return nil, err
+        }
+    }
+    return series, nil
+}
+
+// ProjectOccurrences returns the dates the next instances of a recurring task would get, as
+// if each was completed on time: at most n of them (0 for no limit) up to until (zero for no
+// limit). At least one of the limits must be set.
+func (tm *TodoManager) ProjectOccurrences(task *Task, n int, until time.Time) ([]time.Time, error) {
+    if !task.Recurrence.Valid || task.Recurrence.String == "" {
+        return nil, nil
+    }
+    instances, err := countSeries(tm.db, task)
+    if err != nil {
+        return nil, err
+    }
+    return projectOccurrences(tm.db, task, instances, n, until)
+}
+
+// projectOccurrences implements ProjectOccurrences for a series that has instances instances.
+func projectOccurrences(q queryer, task *Task, instances, n int, until time.Time) ([]time.Time, error) {
+    if n <= 0 && until.IsZero() {
+        return nil, fmt.Errorf("%w: projecting occurrences needs a count or an end date", ErrInvalidInput)
+    }
+    rule, err := ParseRecurrence(task.Recurrence.String, int(task.RecurrenceInterval.Int64))
+    if err != nil {
+        return nil, nil // Unknown patterns never create instances either
+    }
+
+    var dates []time.Time
+    current := *task
+    if !current.OriginalTaskID.Valid && current.ID != 0 {
+        // Keep shifted projections in the rhythm of the series, as nextOccurrence does for instances
+        current.OriginalTaskID = sql.NullInt64{Int64: current.ID, Valid: true}
+    }
+    for n <= 0 || len(dates) < n {
+        if rule.Count > 0 && instances+len(dates) >= rule.Count {
+            break
+        }
+        anchor := recurrenceAnchor(&current)
+        next, ok, err := nextOccurrence(q, rule, &current, anchor)
+        if err != nil {
+            return nil, err
+        }
+        if !ok || (!until.IsZero() && next.After(until)) {
+            break
+        }
+        dates = append(dates, next)
+        if current.DueDate.Valid {
+            current.DueDate = NullableTime{Time: next.UTC(), Valid: true}
+        } else {
+            current.StartDate = NullableTime{Time: next.UTC(), Valid: true}
+        }
+    }
+    return dates, nil
+}
+
+// EditSeries applies a patch to every open instance of a series, so that the instances
+// created after them inherit the change. Only the title, description, project, contexts,
+// tags and recurrence can be changed this way; dates and status belong to single instances.
+func (tm *TodoManager) EditSeries(id int64, patch TaskPatch) ([]UpdateResult, error) {
+    if patch.StartDate != nil || patch.DueDate != nil || patch.EndDate != nil || patch.Status != nil ||
+        patch.StartWaiting != nil || patch.EndWaiting != nil || patch.ClearStartDate || patch.ClearDueDate ||
+        patch.ClearEndDate || patch.ClearWaiting || patch.ClearRecurrence {
+        return nil, fmt.Errorf("%w: a series edit cannot change dates, status or end the recurrence; use update or series stop", ErrInvalidInput)
+    }
+    series, err := tm.GetSeries(id, 0)
+    if err != nil {
+        return nil, err
+    }
+    open := series.Open()
+    if len(open) == 0 {
+        return nil, fmt.Errorf("%w: series %d has no open instances to edit", ErrInvalidInput, series.ID)
+    }
+    ids := make([]int64, len(open))
+    for i, t := range open {
+        ids[i] = t.ID
+    }
+    return tm.UpdateTasks(ids, patch)
+}
+
+// StopSeries ends a series: its open instances stop recurring, so completing them creates
+// no further instances. With cancel they are cancelled as well. It returns the IDs of the
+// instances that were changed.
+func (tm *TodoManager) StopSeries(id int64, cancel bool) ([]int64, error) {
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    sid, err := seriesID(tx, id)
+    if err != nil {
+        return nil, err
+    }
+    tasks, err := seriesTasks(tx, sid)
+    if err != nil {
+        return nil, err
+    }
+    var stopped []int64
+    for _, t := range tasks {
+        if !isOpen(t.Status) {
+            continue
+        }
+        query := "UPDATE tasks SET recurrence = NULL, recurrence_interval = NULL, recurrence_shift = NULL, recurrence_anchor = NULL WHERE id = ?"
+        args := []any{t.ID}
+        if cancel {
+            query = "UPDATE tasks SET recurrence = NULL, recurrence_interval = NULL, recurrence_shift = NULL, recurrence_anchor = NULL, status = 'cancelled', end_date = ? WHERE id = ?"
+            args = []any{time.Now().UTC(), t.ID}
+        }
+        if _, err := tx.Exec(query, args...); err != nil {
+            return nil, fmt.Errorf("error stopping task %d: %w", t.ID, err)
+        }
+        if err := tm.logTask(tx, t.ID); err != nil {
+            return nil, err
+        }
+        stopped = append(stopped, t.ID)
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return stopped, nil
+}
+
+// SkipSeries skips the next occurrence of a series: its oldest open instance is cancelled
+// without being completed, and the instance after it is created from the schedule. It returns
+// the ID of the skipped instance and of the new one, which is 0 when the series ended.
+func (tm *TodoManager) SkipSeries(id int64) (skippedID, nextID int64, err error) {
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return 0, 0, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    sid, err := seriesID(tx, id)
+    if err != nil {
+        return 0, 0, err
+    }
+    tasks, err := seriesTasks(tx, sid)
+    if err != nil {
+        return 0, 0, err
+    }
+    var skipped *Task
+    for i := range tasks {
+        if isOpen(tasks[i].Status) {
+            skipped = &tasks[i]
+            break
+        }
+    }
+    if skipped == nil {
+        return 0, 0, fmt.Errorf("%w: series %d has no open instance to skip", ErrInvalidInput, sid)
+    }
+
+    _, err = tx.Exec("UPDATE tasks SET status = 'cancelled', end_date = ? WHERE id = ?", time.Now().UTC(), skipped.ID)
+    if err != nil {
+        return 0, 0, fmt.Errorf("error skipping task %d: %w", skipped.ID, err)
+    }
+    if err := tm.logTask(tx, skipped.ID); err != nil {
+        return 0, 0, err
+    }
+    if skipped.Recurrence.Valid && skipped.Recurrence.String != "" {
+        if nextID, err = tm.createNextRecurrence(tx, skipped, true); err != nil {
+            return 0, 0, err
+        }
+    }
+    if err := tx.Commit(); err != nil {
+        return 0, 0, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return skipped.ID, nextID, nil
+}
+
+// openRecurringClause selects the open instances of recurring series, which the next
+// occurrences are projected from.
+const openRecurringClause = "t.status IN ('pending', 'waiting') AND t.recurrence IS NOT NULL AND t.recurrence != ''"
+
+// projectTasks returns the occurrences, up to filter.ExpandUntil, of the recurring series whose
+// open instance matches the filter apart from its dates and status. The occurrences are checked
+// against the date filters like stored tasks, and are always pending.
+func (tm *TodoManager) projectTasks(filter TaskFilter) ([]Task, error) {
+    if filter.Status != "" && filter.Status != "all" && filter.Status != "pending" {
+        return nil, nil
+    }
+    source := TaskFilter{IDs: filter.IDs, Project: filter.Project, Context: filter.Context, Tag: filter.Tag, Search: filter.Search}
+    open, err := tm.queryTasks(source, openRecurringClause)
+    if err != nil {
+        return nil, err
+    }
+
+    // Project every series once, from its newest open instance
+    newest := make(map[int64]int)
+    for i := range open {
+        sid := open[i].ID
+        if open[i].OriginalTaskID.Valid {
+            sid = open[i].OriginalTaskID.Int64
+        }
+        if j, ok := newest[sid]; !ok || recurrenceAnchor(&open[i]).After(recurrenceAnchor(&open[j])) {
+            newest[sid] = i
+        }
+    }
+    var projected []Task
+    for _, i := range newest {
+        task := &open[i]
+        dates, err := tm.ProjectOccurrences(task, 0, filter.ExpandUntil.Time)
+        if err != nil {
+            return nil, err
+        }
+        anchor := recurrenceAnchor(task)
+        for _, date := range dates {
+            occurrence := projectTask(task, calendarDays(anchor, date))
+            if filter.matchesDates(&occurrence) {
+                projected = append(projected, occurrence)
+            }
+        }
+    }
+    sortTasks(projected, "id", "asc")
+    return projected, nil
+}
+
+// projectTask returns the occurrence of a recurring task days calendar days later, with every
+// date moved as createNextRecurrence would move it.
+func projectTask(task *Task, days int) Task {
+    shift := func(t NullableTime) NullableTime {
+        if !t.Valid {
+            return t
+        }
+        return NullableTime{Time: t.Time.Local().AddDate(0, 0, days).UTC(), Valid: true}
+    }
+    occurrence := *task
+    occurrence.UUID = sql.NullString{}
+    occurrence.Status = "pending"
+    occurrence.StartDate = shift(task.StartDate)
+    occurrence.DueDate = shift(task.DueDate)
+    occurrence.EndDate = NullableTime{}
+    occurrence.StartWaitingDate = shift(task.StartWaitingDate)
+    occurrence.EndWaitingDate = shift(task.EndWaitingDate)
+    occurrence.Notes = nil
+    occurrence.DependsOn, occurrence.BlockedBy = nil, nil
+    occurrence.Projected = true
+    return occurrence
+}
@@ -0,0 +1,275 @@
+package todo
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+    "time"
+)
+
+// addSeries adds a weekly task due on Monday 2 March 2026 and completes it, so that the
+// series has a completed instance 1 and an open instance 2 due on 9 March.
+func addSeries(t *testing.T, tm *TodoManager) {
+    t.Helper()
+    if _, err := tm.AddTask(TaskInput{Title: "water plants", Project: "home", Tags: []string{"chores"}, Recurrence: "weekly",
+        DueDate: strPtr("2026-03-02 17:00:00")}); err != nil {
+        t.Fatal(err)
+    }
+    update(t, tm, 1, TaskPatch{Status: strPtr("completed")})
+}
+
+func TestGetSeries(t *testing.T) {
+    tm := newTestManager(t)
+    addSeries(t, tm)
+    if _, err := tm.AddTask(TaskInput{Title: "call plumber"}); err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        id       int64
+        upcoming int
+        err      error
+        want     []string // due dates of the instances, then of the upcoming occurrences
+    }{
+        {1, 0, nil, []string{"2026-03-02 17:00", "2026-03-09 17:00"}},
+        {2, 2, nil, []string{"2026-03-02 17:00", "2026-03-09 17:00", "2026-03-16 17:00", "2026-03-23 17:00"}},
+        {3, 0, ErrNotRecurring, nil},
+        {9, 0, ErrTaskNotFound, nil},
+    }
+    for _, tt := range tests {
+        series, err := tm.GetSeries(tt.id, tt.upcoming)
+        if !errors.Is(err, tt.err) {
+            t.Errorf("GetSeries(%d): got %v, want %v", tt.id, err, tt.err)
+            continue
+        }
+        if err != nil {
+            continue
+        }
+        var got []string
+        for _, task := range series.Instances {
+            got = append(got, task.DueDate.Time.Local().Format("2006-01-02 15:04"))
+        }
+        for _, date := range series.Upcoming {
+            got = append(got, date.Format("2006-01-02 15:04"))
+        }
+        if series.ID != 1 || len(series.Open()) != 1 || strings.Join(got, " ") != strings.Join(tt.want, " ") {
+            t.Errorf("GetSeries(%d): got series %d with dates %v, want series 1 with %v", tt.id, series.ID, got, tt.want)
+        }
+    }
+}
+
+func TestSeriesCommands(t *testing.T) {
+    type state struct {
+        status  string
+        project string
+        recurs  bool
+    }
+    tests := []struct {
+        name string
+        do   func(tm *TodoManager) error
+        err  error
+        want map[int64]state // after the open instance, if any, was completed
+    }{
+        {
+            name: "edit",
+            do: func(tm *TodoManager) error {
+                _, err := tm.EditSeries(1, TaskPatch{Project: strPtr("garden")})
+                return err
+            },
+            want: map[int64]state{1: {"completed", "home", true}, 2: {"completed", "garden", true}, 3: {"pending", "garden", true}},
+        },
+        {
+            name: "edit dates",
+            do: func(tm *TodoManager) error {
+                _, err := tm.EditSeries(1, TaskPatch{DueDate: strPtr("2026-03-10 17:00:00")})
+                return err
+            },
+            err:  ErrInvalidInput,
+            want: map[int64]state{2: {"completed", "home", true}, 3: {"pending", "home", true}},
+        },
+        {
+            name: "stop",
+            do: func(tm *TodoManager) error {
+                _, err := tm.StopSeries(2, false)
+                return err
+            },
+            want: map[int64]state{1: {"completed", "home", true}, 2: {"completed", "home", false}},
+        },
+        {
+            name: "stop and cancel",
+            do: func(tm *TodoManager) error {
+                _, err := tm.StopSeries(2, true)
+                return err
+            },
+            want: map[int64]state{2: {"cancelled", "home", false}},
+        },
+        {
+            name: "skip",
+            do: func(tm *TodoManager) error {
+                skipped, next, err := tm.SkipSeries(1)
+                if err == nil && (skipped != 2 || next != 3) {
+                    t.Errorf("skipped %d for %d, want 2 for 3", skipped, next)
+                }
+                return err
+            },
+            want: map[int64]state{2: {"cancelled", "home", true}, 3: {"completed", "home", true}, 4: {"pending", "home", true}},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            addSeries(t, tm)
+            if err := tt.do(tm); !errors.Is(err, tt.err) {
+                t.Fatalf("got %v, want %v", err, tt.err)
+            }
+            series, err := tm.GetSeries(1, 0)
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, task := range series.Open() {
+                update(t, tm, task.ID, TaskPatch{Status: strPtr("completed")})
+            }
+            if series, err = tm.GetSeries(1, 0); err != nil {
+                t.Fatal(err)
+            }
+            for id, want := range tt.want {
+                task, err := tm.GetTask(id)
+                if err != nil {
+                    t.Fatalf("task %d: %v", id, err)
+                }
+                got := state{task.Status, task.ProjectName.String, task.Recurrence.String != ""}
+                if got != want {
+                    t.Errorf("task %d: got %+v, want %+v", id, got, want)
+                }
+            }
+            if _, err := tm.GetTask(int64(len(series.Instances) + 1)); !errors.Is(err, ErrTaskNotFound) {
+                t.Errorf("got more instances than %d: %v", len(series.Instances), err)
+            }
+        })
+    }
+}
+
+func TestSkipSeriesKeepsSchedule(t *testing.T) {
+    // Skipping never counts from the day of the skip, even for series anchored on completion
+    tm := newTestManager(t)
+    if _, err := tm.AddTask(TaskInput{Title: "water plants", Recurrence: "daily", RecurrenceInterval: 3, RecurrenceAnchor: AnchorCompletion,
+        DueDate: strPtr("2020-03-02 17:00:00")}); err != nil {
+        t.Fatal(err)
+    }
+    if _, _, err := tm.SkipSeries(1); err != nil {
+        t.Fatal(err)
+    }
+    next, err := tm.GetTask(2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !next.DueDate.Time.Equal(localTime(t, "2020-03-05 17:00")) {
+        t.Errorf("next instance due %s, want 2020-03-05 17:00", next.DueDate.Time.Local().Format("2006-01-02 15:04"))
+    }
+}
+
+func TestGetTasksExpandRecurring(t *testing.T) {
+    // A weekly series due on 2 March and a series of three daily instances from 2 March, each
+    // with one instance stored, and a task that does not recur
+    type projection struct {
+        id  int64
+        due string
+    }
+    tests := []struct {
+        name   string
+        filter TaskFilter
+        stored int
+        want   []projection
+    }{
+        {
+            name:   "all",
+            filter: TaskFilter{SortBy: "due_date"},
+            stored: 3,
+            want:   []projection{{2, "2026-03-03 09:00"}, {2, "2026-03-04 09:00"}, {1, "2026-03-09 17:00"}, {1, "2026-03-16 17:00"}},
+        },
+        {
+            name:   "project",
+            filter: TaskFilter{Project: "home"},
+            stored: 2,
+            want:   []projection{{1, "2026-03-09 17:00"}, {1, "2026-03-16 17:00"}},
+        },
+        {
+            name:   "due dates",
+            filter: TaskFilter{DueAfter: NullableTime{Time: localTime(t, "2026-03-04 12:00").UTC(), Valid: true}},
+            want:   []projection{{1, "2026-03-09 17:00"}, {1, "2026-03-16 17:00"}},
+        },
+        {
+            name:   "completed",
+            filter: TaskFilter{Status: "completed"},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            inputs := []TaskInput{
+                {Title: "water plants", Project: "home", Recurrence: "weekly", StartDate: strPtr("2026-03-01 09:00:00"), DueDate: strPtr("2026-03-02 17:00:00")},
+                {Title: "stretch", Project: "work", Recurrence: "FREQ=DAILY;COUNT=3", DueDate: strPtr("2026-03-02 09:00:00")},
+                {Title: "call plumber", Project: "home"},
+            }
+            for _, in := range inputs {
+                if _, err := tm.AddTask(in); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            filter := tt.filter
+            filter.ExpandUntil = NullableTime{Time: localTime(t, "2026-03-20 23:59").UTC(), Valid: true}
+            tasks, err := tm.GetTasks(filter)
+            if err != nil {
+                t.Fatal(err)
+            }
+            stored := 0
+            var got []projection
+            for _, task := range tasks {
+                if !task.Projected {
+                    stored++
+                    continue
+                }
+                if task.Status != "pending" || task.UUID.Valid {
+                    t.Errorf("projected task %d is %s with UUID %v", task.ID, task.Status, task.UUID)
+                }
+                got = append(got, projection{task.ID, task.DueDate.Time.Local().Format("2006-01-02 15:04")})
+            }
+            if stored != tt.stored || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+                t.Errorf("got %d stored tasks and projections %v, want %d and %v", stored, got, tt.stored, tt.want)
+            }
+            if all, err := tm.GetTasks(TaskFilter{}); err != nil || len(all) != 3 {
+                t.Errorf("projections were stored: got %d tasks, %v", len(all), err)
+            }
+        })
+    }
+}
+
+func TestProjectOccurrences(t *testing.T) {
+    tm := newTestManager(t)
+    task, err := tm.AddTask(TaskInput{Title: "stretch", Recurrence: "FREQ=DAILY;COUNT=4", DueDate: strPtr("2026-03-02 09:00:00")})
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        n     int
+        until string
+        want  int
+        err   error
+    }{
+        {n: 2, want: 2},
+        {n: 5, want: 3}, // the series ends after 4 instances
+        {until: "2026-03-03 12:00", want: 1},
+        {n: 1, until: "2026-03-10 12:00", want: 1},
+        {err: ErrInvalidInput},
+    }
+    for _, tt := range tests {
+        var until time.Time
+        if tt.until != "" {
+            until = localTime(t, tt.until)
+        }
+        dates, err := tm.ProjectOccurrences(task, tt.n, until)
+        if !errors.Is(err, tt.err) || len(dates) != tt.want {
+            t.Errorf("ProjectOccurrences(%d, %q) = %v, %v, want %d dates and %v", tt.n, tt.until, dates, err, tt.want, tt.err)
+        }
+    }
+}
@@ -0,0 +1,154 @@
+package todo
+
+import (
+    "database/sql"
+    "fmt"
+    "strings"
+)
+
+// Subtasks break a task into steps: a task with a parent_id is a subtask of that task, and
+// subtasks can have subtasks of their own. The hierarchy never contains a cycle when written
+// through TodoManager.
+
+// Policies for the subtasks of a task passed to DeleteTask.
+const (
+    ChildrenOrphan   = ""         // move the subtasks up to the parent of the task, or to the top level
+    ChildrenDelete   = "delete"   // delete the subtasks at every level below the task
+    ChildrenComplete = "complete" // complete the open subtasks at every level below the task
+)
+
+// validChildPolicies lists the policies accepted by DeleteTask; "orphan" is the default.
+var validChildPolicies = []string{"orphan", ChildrenDelete, ChildrenComplete}
+
+// descendantsClause selects the subtasks of a task at every level below it.
+func descendantsClause(id int64) string {
+    return fmt.Sprintf(`t.id IN (
+        WITH RECURSIVE descendants(id) AS (
+            SELECT id FROM tasks WHERE parent_id = %d
+            UNION SELECT s.id FROM tasks s JOIN descendants d ON s.parent_id = d.id
+        )
+        SELECT id FROM descendants
+    )`, id)
+}
+
+// getDescendants returns the IDs of the subtasks of a task at every level below it, deepest first.
+func getDescendants(q queryer, id int64) ([]int64, error) {
+    return queryIDs(q, `
+        WITH RECURSIVE descendants(id, depth) AS (
+            SELECT id, 1 FROM tasks WHERE parent_id = ?
+            UNION SELECT s.id, d.depth + 1 FROM tasks s JOIN descendants d ON s.parent_id = d.id
+        )
+        SELECT id FROM descendants ORDER BY depth DESC, id
+    `, id)
+}
+
+// GetSubtasks returns the subtasks of a task at every level below it, in any status, ordered by ID.
+func (tm *TodoManager) GetSubtasks(id int64) ([]Task, error) {
+    return tm.queryTasks(TaskFilter{SortBy: "id"}, descendantsClause(id))
+}
+
+// checkParent reports a parent that does not exist, is the task itself, or is one of its subtasks.
+// id is 0 for a task that is not stored yet.
+func checkParent(q queryer, id, parent int64) error {
+    var p problems
+    var exists int
+    if err := q.QueryRow("SELECT COUNT(*) FROM tasks WHERE id = ?", parent).Scan(&exists); err != nil {
+        return fmt.Errorf("error looking up task %d: %w", parent, err)
+    }
+    switch {
+    case exists == 0:
+        p.add("parent task %d does not exist", parent)
+    case parent == id:
+        p.add("task %d cannot be a subtask of itself", id)
+    case id != 0:
+        descendants, err := getDescendants(q, id)
+        if err != nil {
+            return err
+        }
+        for _, d := range descendants {
+            if d == parent {
+                p.add("task %d cannot be a subtask of task %d, which is one of its own subtasks", id, parent)
+                break
+            }
+        }
+    }
+    return p.err()
+}
+
+func (p *problems) checkChildPolicy(policy string) {
+    if policy != "" && !contains(validChildPolicies, strings.ToLower(strings.TrimSpace(policy))) {
+        p.add("unknown subtask policy '%s' (expected %s)", policy, strings.Join(validChildPolicies, ", "))
+    }
+}
+
+// normalizeChildPolicy returns the policy constant for a user-given policy: "orphan" is ChildrenOrphan.
+func normalizeChildPolicy(policy string) string {
+    policy = strings.ToLower(strings.TrimSpace(policy))
+    if policy == "orphan" {
+        return ChildrenOrphan
+    }
+    return policy
+}
+
+// handleChildren applies a subtask policy to the subtasks of a task that is being deleted or
+// completed, and returns the subtasks that moved and are left, for the caller to log; completed
+// subtasks are logged as they are completed. Orphaning only applies when the task is deleted,
+// since the subtasks of a completed task keep their parent.
+func (tm *TodoManager) handleChildren(tx *sql.Tx, id int64, policy string, deleting bool) ([]int64, error) {
+    descendants, err := getDescendants(tx, id)
+    if err != nil || len(descendants) == 0 {
+        return nil, err
+    }
+
+    changed := make(map[int64]bool)
+    switch policy {
+    case ChildrenDelete:
+        for _, d := range descendants {
+            if err := tm.deleteTask(tx, d); err != nil {
+                return nil, err
+            }
+        }
+        return nil, nil
+    case ChildrenComplete:
+        // Completed like any other task, so blockers are checked, recurring subtasks get their
+        // next instance and the change is logged; subtasks that depend on others come later
+        ordered, err := dependencyOrder(tx, descendants)
+        if err != nil {
+            return nil, err
+        }
+        for _, d := range ordered {
+            var status string
+            if err := tx.QueryRow("SELECT status FROM tasks WHERE id = ?", d).Scan(&status); err != nil {
+                return nil, fmt.Errorf("error looking up subtask %d: %w", d, err)
+            }
+            if !isOpen(status) {
+                continue
+            }
+            if _, err := tm.completeTask(tx, d); err != nil {
+                return nil, fmt.Errorf("error completing subtask %d: %w", d, err)
+            }
+        }
+    }
+
+    if deleting {
+        // The direct subtasks move up to the parent of the deleted task
+        children, err := queryIDs(tx, "SELECT id FROM tasks WHERE parent_id = ?", id)
+        if err != nil {
+            return nil, err
+        }
+        if _, err := tx.Exec("UPDATE tasks SET parent_id = (SELECT parent_id FROM tasks WHERE id = ?) WHERE parent_id = ?", id, id); err != nil {
+            return nil, fmt.Errorf("error moving subtasks of task %d: %w", id, err)
+        }
+        for _, c := range children {
+            changed[c] = true
+        }
+    }
+
+    result := []int64{}
+    for _, d := range descendants {
+        if changed[d] {
+            result = append(result, d)
+        }
+    }
+    return result, nil
+}
@@ -0,0 +1,194 @@
+package todo
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestDeleteTaskChildren(t *testing.T) {
+    // Task 1 has the subtasks 2, 4 and 5, and 2 has the subtask 3. 4 recurs weekly, 5 is
+    // cancelled, and 6 is a task of its own.
+    type state struct {
+        status string // empty when the task is gone
+        parent int64
+    }
+    tests := []struct {
+        name      string
+        complete  bool
+        children  string
+        dependsOn [][2]int64 // task, task it depends on
+        err       error
+        want      map[int64]state
+        tasks     int // in the database afterwards
+    }{
+        {
+            name:     "delete and orphan",
+            children: "orphan",
+            want:     map[int64]state{1: {}, 2: {"pending", 0}, 3: {"pending", 2}, 4: {"pending", 0}, 5: {"cancelled", 0}},
+            tasks:    5,
+        },
+        {
+            name:     "delete with subtasks",
+            children: ChildrenDelete,
+            want:     map[int64]state{1: {}, 2: {}, 3: {}, 4: {}, 5: {}},
+            tasks:    1,
+        },
+        {
+            name:     "complete without subtasks",
+            complete: true,
+            want:     map[int64]state{1: {"completed", 0}, 2: {"pending", 1}, 3: {"pending", 2}, 4: {"pending", 1}},
+            tasks:    6,
+        },
+        {
+            name:     "complete with subtasks",
+            complete: true,
+            children: ChildrenComplete,
+            want:     map[int64]state{1: {"completed", 0}, 2: {"completed", 1}, 3: {"completed", 2}, 4: {"completed", 1}, 5: {"cancelled", 1}},
+            tasks:    7, // with the next instance of 4
+        },
+        {
+            name:      "subtasks completed in dependency order",
+            complete:  true,
+            children:  ChildrenComplete,
+            dependsOn: [][2]int64{{2, 4}, {1, 3}},
+            want:      map[int64]state{1: {"completed", 0}, 2: {"completed", 1}, 3: {"completed", 2}, 4: {"completed", 1}},
+            tasks:     7,
+        },
+        {
+            name:      "subtask blocked",
+            complete:  true,
+            children:  ChildrenComplete,
+            dependsOn: [][2]int64{{3, 6}},
+            err:       ErrBlocked,
+            want:      map[int64]state{1: {"pending", 0}, 2: {"pending", 1}, 3: {"pending", 2}, 4: {"pending", 1}},
+            tasks:     6,
+        },
+        {
+            name:      "blocked by a subtask",
+            complete:  true,
+            dependsOn: [][2]int64{{1, 3}},
+            err:       ErrBlocked,
+            want:      map[int64]state{1: {"pending", 0}},
+            tasks:     6,
+        },
+        {
+            name:     "unknown policy",
+            children: "adopt",
+            err:      ErrInvalidInput,
+            want:     map[int64]state{1: {"pending", 0}},
+            tasks:    6,
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            inputs := []TaskInput{
+                {Title: "move house"},
+                {Title: "pack", ParentID: 1},
+                {Title: "label boxes", ParentID: 2},
+                {Title: "hire a van", ParentID: 1, Recurrence: "weekly", DueDate: strPtr("2026-03-06 17:00:00")},
+                {Title: "cancel internet", ParentID: 1, Status: "cancelled"},
+                {Title: "water the plants"},
+            }
+            for _, in := range inputs {
+                if _, err := tm.AddTask(in); err != nil {
+                    t.Fatalf("AddTask(%s): %v", in.Title, err)
+                }
+            }
+            for _, dep := range tt.dependsOn {
+                update(t, tm, dep[0], TaskPatch{AddDependsOn: []int64{dep[1]}})
+            }
+
+            if err := tm.DeleteTask(1, tt.complete, tt.children); !errors.Is(err, tt.err) {
+                t.Fatalf("got %v, want %v", err, tt.err)
+            }
+            tasks, err := tm.GetTasks(TaskFilter{Status: "all"})
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(tasks) != tt.tasks {
+                t.Errorf("got %d tasks, want %d", len(tasks), tt.tasks)
+            }
+            for id, want := range tt.want {
+                task, err := tm.GetTask(id)
+                if want.status == "" {
+                    if !errors.Is(err, ErrTaskNotFound) {
+                        t.Errorf("task %d: got %v, want it deleted", id, err)
+                    }
+                    continue
+                }
+                if err != nil {
+                    t.Fatal(err)
+                }
+                if task.Status != want.status || task.ParentID.Int64 != want.parent {
+                    t.Errorf("task %d: got %s with parent %d, want %s with parent %d", id, task.Status, task.ParentID.Int64, want.status, want.parent)
+                }
+                if want.status == "completed" && !task.EndDate.Valid {
+                    t.Errorf("task %d completed without an end date", id)
+                }
+            }
+        })
+    }
+}
+
+func TestCompletedSubtasksAreLogged(t *testing.T) {
+    tm := newTestManager(t)
+    parent, err := tm.AddTask(TaskInput{Title: "move house"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    child, err := tm.AddTask(TaskInput{Title: "pack", ParentID: parent.ID})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := tm.DeleteTask(parent.ID, true, ChildrenComplete); err != nil {
+        t.Fatal(err)
+    }
+    device, err := tm.DeviceID()
+    if err != nil {
+        t.Fatal(err)
+    }
+    changes, err := tm.ChangesSince(device, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    logged := map[string]string{}
+    for _, c := range changes {
+        if c.Field == "status" {
+            logged[c.EntityUUID] = string(c.Value)
+        }
+    }
+    for _, task := range []*Task{parent, child} {
+        if logged[task.UUID.String] != `"completed"` {
+            t.Errorf("completion of task %d not logged, last status %s", task.ID, logged[task.UUID.String])
+        }
+    }
+}
+
+func TestCheckParent(t *testing.T) {
+    tm := newTestManager(t)
+    for _, in := range []TaskInput{{Title: "move house"}, {Title: "pack", ParentID: 1}, {Title: "label boxes", ParentID: 2}} {
+        if _, err := tm.AddTask(in); err != nil {
+            t.Fatal(err)
+        }
+    }
+    tests := []struct {
+        id, parent int64
+        ok         bool
+    }{
+        {3, 1, true},
+        {0, 3, true},
+        {1, 9, false},
+        {2, 2, false},
+        {1, 3, false},
+    }
+    for _, tt := range tests {
+        err := checkParent(tm.db, tt.id, tt.parent)
+        if (err == nil) != tt.ok {
+            t.Errorf("checkParent(%d, %d) = %v, want ok %v", tt.id, tt.parent, err, tt.ok)
+        }
+        if err != nil && !errors.Is(err, ErrInvalidInput) {
+            t.Errorf("checkParent(%d, %d) = %v, want ErrInvalidInput", tt.id, tt.parent, err)
+        }
+    }
+}
@@ -0,0 +1,736 @@
+package todo
+
+import (
+    "bufio"
+    "bytes"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Sync exchanges change logs through a shared directory, e.g. a folder kept in step by a file
+// synchronization service, a USB stick or a network share. Every device appends its own
+// changes to <directory>/<device ID>.jsonl, one JSON encoded Change per line, and never
+// writes the files of other devices, so the files cannot get into conflict themselves.
+
+// syncFileExt is the extension of the change log files in a sync directory.
+const syncFileExt = ".jsonl"
+
+// SyncConflict reports a field that was changed on this device and on another one without
+// either seeing the other change. The change made last wins. For contexts and tags, only a
+// name added on one device and removed on the other conflicts.
+type SyncConflict struct {
+    Entity       string // EntityTask or EntityNote
+    EntityUUID   string
+    Title        string // Title of the task, or of the task the note belongs to
+    Field        string // empty for a record deleted on one device and changed on the other
+    LocalValue   json.RawMessage // for contexts and tags, the name with + when added or - when removed
+    RemoteValue  json.RawMessage
+    RemoteDevice string
+    RemoteWon    bool
+}
+
+// SyncResult reports what Sync exchanged.
+type SyncResult struct {
+    DeviceID  string
+    Exported  int      // changes of this device written to the sync directory
+    Applied   int      // changes of other devices merged into this database
+    Skipped   int      // changes of other devices that lost to a later change, or hit a deleted record
+    Devices   []string // other devices whose logs were found in the sync directory
+    Conflicts []SyncConflict
+}
+
+// Sync merges this database with the change logs of other devices in dir.
+//
+// First, tasks and notes changed without going through TodoManager are logged, and the
+// changes of this device missing from its log file in dir are appended to it. Then the
+// changes of every other device not merged yet are applied in time order, field by field:
+// a change that builds on the value this database has is applied, and when two devices
+// changed the same field independently the later change wins (last-writer-wins) and a
+// SyncConflict is reported if the values differ. Contexts and tags are merged name by
+// name, so a tag added on one device and another removed elsewhere are both kept. Deleting a
+// task or note wins over any change to it. dir must exist; nothing but the files in it is needed, so two local
+// directories work as well as a shared drive.
+func (tm *TodoManager) Sync(dir string) (*SyncResult, error) {
+    info, err := os.Stat(dir)
+    if err != nil {
+        return nil, fmt.Errorf("error opening sync directory: %w", err)
+    }
+    if !info.IsDir() {
+        return nil, fmt.Errorf("%w: %s is not a directory", ErrInvalidInput, dir)
+    }
+
+    device, err := tm.logPendingChanges()
+    if err != nil {
+        return nil, err
+    }
+    result := &SyncResult{DeviceID: device}
+
+    // Export: append the changes of this device the log file does not have yet
+    ownFile := filepath.Join(dir, device+syncFileExt)
+    own, err := readChangeFile(ownFile)
+    if err != nil && !os.IsNotExist(err) {
+        return nil, err
+    }
+    var exportedSeq int64
+    for _, c := range own {
+        if c.Seq > exportedSeq {
+            exportedSeq = c.Seq
+        }
+    }
+    pending, err := tm.ChangesSince(device, exportedSeq)
+    if err != nil {
+        return nil, err
+    }
+    if err := appendChangeFile(ownFile, pending); err != nil {
+        return nil, err
+    }
+    result.Exported = len(pending)
+
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    // Import: collect the changes of other devices that were not merged yet
+    files, err := filepath.Glob(filepath.Join(dir, "*"+syncFileExt))
+    if err != nil {
+        return nil, fmt.Errorf("error listing sync directory: %w", err)
+    }
+    var incoming []Change
+    lastSeq := map[string]int64{}
+    for _, file := range files {
+        if file == ownFile {
+            continue
+        }
+        changes, err := readChangeFile(file)
+        if err != nil {
+            return nil, err
+        }
+        for _, c := range changes {
+            if c.DeviceID == device {
+                continue // A copy of this device's own log
+            }
+            seen, ok := lastSeq[c.DeviceID]
+            if !ok {
+                result.Devices = append(result.Devices, c.DeviceID)
+                err := tx.QueryRow("SELECT last_seq FROM sync_peers WHERE device_id = ?", c.DeviceID).Scan(&seen)
+                if err != nil && err != sql.ErrNoRows {
+                    return nil, fmt.Errorf("error reading sync state: %w", err)
+                }
+                lastSeq[c.DeviceID] = seen
+            }
+            if c.Seq > seen {
+                incoming = append(incoming, c)
+            }
+        }
+    }
+    sort.SliceStable(incoming, func(i, j int) bool {
+        return changeBefore(incoming[i], incoming[j])
+    })
+
+    for _, c := range incoming {
+        if c.Seq <= lastSeq[c.DeviceID] {
+            continue // Listed twice, e.g. in a copy of the device's log
+        }
+        lastSeq[c.DeviceID] = c.Seq
+        applied, conflict, err := tm.applyChange(tx, c)
+        if err != nil {
+            return nil, fmt.Errorf("error applying change %s from device %s: %w", c.UUID, c.DeviceID, err)
+        }
+        if applied {
+            result.Applied++
+        } else {
+            result.Skipped++
+        }
+        if conflict != nil {
+            result.Conflicts = append(result.Conflicts, *conflict)
+        }
+    }
+
+    // Links are set once the whole batch is in, since a task may arrive after the tasks
+    // referring to it
+    if err := tm.applyPendingLinks(tx); err != nil {
+        return nil, err
+    }
+
+    now := time.Now().UTC()
+    for peer, seq := range lastSeq {
+        _, err := tx.Exec(`
+            INSERT INTO sync_peers (device_id, last_seq, synced_at) VALUES (?, ?, ?)
+            ON CONFLICT(device_id) DO UPDATE SET last_seq = excluded.last_seq, synced_at = excluded.synced_at
+        `, peer, seq, now)
+        if err != nil {
+            return nil, fmt.Errorf("error saving sync state: %w", err)
+        }
+    }
+    for i := range result.Conflicts {
+        result.Conflicts[i].Title = conflictTitle(tx, result.Conflicts[i])
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return result, nil
+}
+
+// logPendingChanges logs the changes made outside TodoManager and returns the device ID.
+// They are committed before anything is exported, so the exported log never changes.
+func (tm *TodoManager) logPendingChanges() (string, error) {
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return "", fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    device, err := deviceID(tx)
+    if err != nil {
+        return "", err
+    }
+    if err := tm.logAll(tx); err != nil {
+        return "", err
+    }
+    if err := tx.Commit(); err != nil {
+        return "", fmt.Errorf("error committing transaction: %w", err)
+    }
+    return device, nil
+}
+
+// changeBefore orders changes by time, then device and sequence number, which is also
+// the order in which last-writer-wins picks the winner.
+func changeBefore(a, b Change) bool {
+    if !a.ChangedAt.Equal(b.ChangedAt) {
+        return a.ChangedAt.Before(b.ChangedAt)
+    }
+    if a.DeviceID != b.DeviceID {
+        return a.DeviceID < b.DeviceID
+    }
+    return a.Seq < b.Seq
+}
+
+// applyChange merges a change of another device into the database. It returns whether the
+// change was applied, and the conflict it caused, if any.
+func (tm *TodoManager) applyChange(tx *sql.Tx, c Change) (bool, *SyncConflict, error) {
+    var exists int
+    if err := tx.QueryRow("SELECT COUNT(*) FROM change_log WHERE uuid = ?", c.UUID).Scan(&exists); err != nil {
+        return false, nil, fmt.Errorf("error reading change log: %w", err)
+    }
+    if exists > 0 {
+        return false, nil, nil
+    }
+    latest, deleted, err := latestChanges(tx, c.EntityUUID)
+    if err != nil {
+        return false, nil, err
+    }
+    if deleted {
+        return false, nil, nil // Deleting wins over any change
+    }
+
+    if c.Op == OpDelete {
+        var conflict *SyncConflict
+        if newest := newestChange(latest); newest.UUID != "" && newest.UUID != c.Prev && newest.DeviceID != c.DeviceID {
+            // Changed here without the other device seeing it before deleting
+            conflict = &SyncConflict{Entity: c.Entity, EntityUUID: c.EntityUUID, RemoteDevice: c.DeviceID, RemoteWon: true}
+            conflict.Title = conflictTitle(tx, *conflict) // The record is gone after this
+        }
+        if err := deleteEntity(tx, c.Entity, c.EntityUUID); err != nil {
+            return false, nil, err
+        }
+        return true, conflict, insertChange(tx, c)
+    }
+
+    if c.Entity == EntityTask && contains(nameFields, c.Field) {
+        return tm.applyNameChange(tx, c)
+    }
+
+    var conflict *SyncConflict
+    if l, ok := latest[c.Field]; ok && l.UUID != c.Prev {
+        // Both devices changed the field without seeing each other's change
+        remoteWon := changeBefore(l, c)
+        if !bytes.Equal(l.Value, c.Value) {
+            conflict = &SyncConflict{
+                Entity: c.Entity, EntityUUID: c.EntityUUID, Field: c.Field,
+                LocalValue: l.Value, RemoteValue: c.Value, RemoteDevice: c.DeviceID, RemoteWon: remoteWon,
+            }
+        }
+        if !remoteWon {
+            return false, conflict, nil
+        }
+    }
+
+    var applied bool
+    if c.Entity == EntityTask {
+        applied, err = tm.applyTaskField(tx, c.EntityUUID, c.Field, c.Value)
+    } else {
+        applied, err = applyNoteField(tx, c.EntityUUID, c.Field, c.Value)
+    }
+    if err != nil || !applied {
+        return false, conflict, err
+    }
+    return true, conflict, insertChange(tx, c)
+}
+
+// applyNameChange merges a change of the contexts or tags of a task. Every name is merged on
+// its own, so names added and removed on different devices are all kept; whole lists set by
+// earlier versions are merged by their time.
+func (tm *TodoManager) applyNameChange(tx *sql.Tx, c Change) (bool, *SyncConflict, error) {
+    before, err := loggedNames(tx, c.EntityUUID, c.Field)
+    if err != nil {
+        return false, nil, err
+    }
+    var conflict *SyncConflict
+    if c.Op != OpSet {
+        var name string
+        if err := json.Unmarshal(c.Value, &name); err != nil {
+            return false, nil, fmt.Errorf("invalid %s of task %s: %w", c.Field, c.EntityUUID, err)
+        }
+        l := before[name]
+        remoteWon := l.change.UUID == "" || changeBefore(l.change, c)
+        if l.change.UUID != "" && l.change.UUID != c.Prev && l.change.DeviceID != c.DeviceID && l.member != (c.Op == OpAdd) {
+            // Added on one device and removed on the other without seeing each other's change
+            conflict = &SyncConflict{
+                Entity: c.Entity, EntityUUID: c.EntityUUID, Field: c.Field,
+                LocalValue: nameValue(name, l.member), RemoteValue: nameValue(name, c.Op == OpAdd),
+                RemoteDevice: c.DeviceID, RemoteWon: remoteWon,
+            }
+        }
+        if !remoteWon {
+            return false, conflict, nil
+        }
+    }
+
+    if err := insertChange(tx, c); err != nil {
+        return false, nil, err
+    }
+    after, err := loggedNames(tx, c.EntityUUID, c.Field)
+    if err != nil {
+        return false, nil, err
+    }
+    names := []string{}
+    for name, state := range after {
+        if state.member {
+            names = append(names, name)
+        }
+    }
+    if _, err := tm.applyTaskField(tx, c.EntityUUID, c.Field, jsonNames(names)); err != nil {
+        return false, nil, err
+    }
+    return true, conflict, nil
+}
+
+// nameValue describes a name added to (+) or removed from (-) a contexts or tags field.
+func nameValue(name string, added bool) json.RawMessage {
+    if added {
+        return jsonValue("+" + name)
+    }
+    return jsonValue("-" + name)
+}
+
+// deleteEntity deletes a task or note by UUID, if it exists.
+func deleteEntity(tx *sql.Tx, entity, uuid string) error {
+    table := "tasks"
+    if entity == EntityNote {
+        table = "task_notes"
+    }
+    if entity == EntityTask {
+        _, err := tx.Exec(`
+            DELETE FROM task_dependencies
+            WHERE task_id IN (SELECT id FROM tasks WHERE uuid = ?) OR depends_on_id IN (SELECT id FROM tasks WHERE uuid = ?)
+        `, uuid, uuid)
+        if err != nil {
+            return fmt.Errorf("error removing dependencies of task %s: %w", uuid, err)
+        }
+        for _, rowTable := range taskRowTables {
+            if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE task_id IN (SELECT id FROM tasks WHERE uuid = ?)", rowTable), uuid); err != nil {
+                return fmt.Errorf("error removing rows of task %s from %s: %w", uuid, rowTable, err)
+            }
+        }
+        // Subtasks move up when their parent is deleted; the device that deleted it logged where to
+        if _, err := tx.Exec("UPDATE tasks SET parent_id = NULL WHERE parent_id IN (SELECT id FROM tasks WHERE uuid = ?)", uuid); err != nil {
+            return fmt.Errorf("error detaching subtasks of task %s: %w", uuid, err)
+        }
+    }
+    if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE uuid = ?", table), uuid); err != nil {
+        return fmt.Errorf("error deleting %s %s: %w", entity, uuid, err)
+    }
+    return nil
+}
+
+// taskColumns maps the task fields stored directly in a column to that column.
+var taskColumns = map[string]string{
+    "title": "title", "description": "description", "status": "status", "recurrence": "recurrence",
+    "recurrence_interval": "recurrence_interval", "recurrence_shift": "recurrence_shift",
+    "recurrence_anchor": "recurrence_anchor", "priority": "priority", "estimate": "estimate_minutes",
+    "start_date": "start_date", "due_date": "due_date", "end_date": "end_date",
+    "start_waiting_date": "start_waiting_date", "end_waiting_date": "end_waiting_date",
+}
+
+// applyTaskField sets one field of a task, creating the task when it does not exist yet.
+// Fields unknown to this version are ignored.
+func (tm *TodoManager) applyTaskField(tx *sql.Tx, uuid, field string, value json.RawMessage) (bool, error) {
+    var id int64
+    err := tx.QueryRow("SELECT id FROM tasks WHERE uuid = ?", uuid).Scan(&id)
+    if err == sql.ErrNoRows {
+        // The other fields of the new task follow in the same batch of changes
+        res, err := tx.Exec("INSERT INTO tasks (title, status, uuid) VALUES ('', 'pending', ?)", uuid)
+        if err != nil {
+            return false, fmt.Errorf("error adding task %s: %w", uuid, wrapDBError(err))
+        }
+        if id, err = res.LastInsertId(); err != nil {
+            return false, fmt.Errorf("error getting last insert ID: %w", err)
+        }
+    } else if err != nil {
+        return false, fmt.Errorf("error looking up task %s: %w", uuid, err)
+    }
+
+    switch field {
+    case "start_date", "due_date", "end_date", "start_waiting_date", "end_waiting_date":
+        t, err := decodeTime(value)
+        if err != nil {
+            return false, fmt.Errorf("invalid %s of task %s: %w", field, uuid, err)
+        }
+        sqlTime, _ := t.Value()
+        _, err = tx.Exec(fmt.Sprintf("UPDATE tasks SET %s = ? WHERE id = ?", taskColumns[field]), sqlTime, id)
+        return err == nil, wrapDBError(err)
+    case "recurrence_interval", "estimate":
+        var n *int64
+        if err := json.Unmarshal(value, &n); err != nil {
+            return false, fmt.Errorf("invalid %s of task %s: %w", field, uuid, err)
+        }
+        _, err = tx.Exec(fmt.Sprintf("UPDATE tasks SET %s = ? WHERE id = ?", taskColumns[field]), n, id)
+        return err == nil, wrapDBError(err)
+    case "title", "description", "status", "recurrence", "recurrence_shift", "recurrence_anchor", "priority":
+        var s *string
+        if err := json.Unmarshal(value, &s); err != nil {
+            return false, fmt.Errorf("invalid %s of task %s: %w", field, uuid, err)
+        }
+        _, err = tx.Exec(fmt.Sprintf("UPDATE tasks SET %s = ? WHERE id = ?", taskColumns[field]), s, id)
+        return err == nil, wrapDBError(err)
+    case "project":
+        var name *string
+        if err := json.Unmarshal(value, &name); err != nil {
+            return false, fmt.Errorf("invalid project of task %s: %w", uuid, err)
+        }
+        projectID := sql.NullInt64{}
+        if name != nil && *name != "" {
+            pid, err := tm.getID(tx, "projects", *name)
+            if err != nil {
+                return false, fmt.Errorf("error getting project ID: %w", err)
+            }
+            projectID = sql.NullInt64{Int64: pid, Valid: true}
+        }
+        _, err = tx.Exec("UPDATE tasks SET project_id = ? WHERE id = ?", projectID, id)
+        return err == nil, wrapDBError(err)
+    case "original_task", "parent":
+        var linked *string
+        if err := json.Unmarshal(value, &linked); err != nil {
+            return false, fmt.Errorf("invalid %s of task %s: %w", strings.ReplaceAll(field, "_", " "), uuid, err)
+        }
+        linkedID := sql.NullInt64{}
+        if linked != nil {
+            var lid int64
+            if err := tx.QueryRow("SELECT id FROM tasks WHERE uuid = ?", *linked).Scan(&lid); err == nil {
+                linkedID = sql.NullInt64{Int64: lid, Valid: true}
+            } else if err != sql.ErrNoRows {
+                return false, fmt.Errorf("error looking up task %s: %w", *linked, err)
+            }
+        }
+        column := "original_task_id"
+        if field == "parent" {
+            column = "parent_id"
+        }
+        _, err = tx.Exec(fmt.Sprintf("UPDATE tasks SET %s = ? WHERE id = ?", column), linkedID, id)
+        return err == nil, wrapDBError(err)
+    case "contexts", "tags":
+        var names []string
+        if err := json.Unmarshal(value, &names); err != nil {
+            return false, fmt.Errorf("invalid %s of task %s: %w", field, uuid, err)
+        }
+        nameIDs, err := tm.getIDs(tx, field, names)
+        if err != nil {
+            return false, fmt.Errorf("error getting %s IDs: %w", field, err)
+        }
+        joinTable, foreignKey := "task_contexts", "context_id"
+        if field == "tags" {
+            joinTable, foreignKey = "task_tags", "tag_id"
+        }
+        if err := tm.associateTaskWithNames(tx, id, nameIDs, joinTable, foreignKey); err != nil {
+            return false, fmt.Errorf("error associating %s: %w", field, err)
+        }
+        return true, nil
+    case "depends_on":
+        var uuids []string
+        if err := json.Unmarshal(value, &uuids); err != nil {
+            return false, fmt.Errorf("invalid dependencies of task %s: %w", uuid, err)
+        }
+        // Dependencies on tasks unknown here are left out until applyPendingLinks finds them
+        if _, err := tx.Exec("DELETE FROM task_dependencies WHERE task_id = ?", id); err != nil {
+            return false, fmt.Errorf("error clearing dependencies of task %s: %w", uuid, err)
+        }
+        for _, dep := range uuids {
+            _, err := tx.Exec("INSERT OR IGNORE INTO task_dependencies (task_id, depends_on_id) SELECT ?, id FROM tasks WHERE uuid = ?", id, dep)
+            if err != nil {
+                return false, fmt.Errorf("error adding dependency of task %s: %w", uuid, err)
+            }
+        }
+        return true, nil
+    }
+    return false, nil
+}
+
+// applyPendingLinks sets the winning value of every link field again, so that links to tasks
+// that were unknown when the link arrived, in this batch or an earlier one, are set once the
+// tasks they refer to are there; links to tasks still unknown stay pending. The change log is
+// up to date with the database at this point, so links set already do not change.
+func (tm *TodoManager) applyPendingLinks(tx *sql.Tx) error {
+    rows, err := tx.Query(changeSelect+`
+        WHERE entity = ? AND op = ? AND field IN ('original_task', 'parent', 'depends_on')
+          AND NOT EXISTS (SELECT 1 FROM change_log d WHERE d.entity_uuid = change_log.entity_uuid AND d.op = 'delete')
+          AND EXISTS (SELECT 1 FROM tasks t WHERE t.uuid = change_log.entity_uuid)
+        ORDER BY changed_at, device_id, seq
+    `, EntityTask, OpSet)
+    if err != nil {
+        return fmt.Errorf("error reading change log: %w", err)
+    }
+    changes, err := scanChanges(rows)
+    if err != nil {
+        return err
+    }
+    type link struct{ task, field string }
+    latest := map[link]Change{}
+    var order []link
+    for _, c := range changes {
+        l := link{c.EntityUUID, c.Field}
+        if _, ok := latest[l]; !ok {
+            order = append(order, l)
+        }
+        latest[l] = c
+    }
+    for _, l := range order {
+        c := latest[l]
+        if _, err := tm.applyTaskField(tx, c.EntityUUID, c.Field, c.Value); err != nil {
+            return fmt.Errorf("error applying %s of task %s: %w", strings.ReplaceAll(c.Field, "_", " "), c.EntityUUID, err)
+        }
+    }
+    return nil
+}
+
+// applyNoteField sets one field of a note, creating the note when it does not exist yet.
+// A note whose task is unknown or deleted is skipped.
+func applyNoteField(tx *sql.Tx, uuid, field string, value json.RawMessage) (bool, error) {
+    var id int64
+    err := tx.QueryRow("SELECT id FROM task_notes WHERE uuid = ?", uuid).Scan(&id)
+    if err == sql.ErrNoRows {
+        taskValue := value
+        if field != "task" {
+            latest, _, err := latestChanges(tx, uuid)
+            if err != nil {
+                return false, err
+            }
+            taskValue = latest["task"].Value
+        }
+        var taskUUID *string
+        if taskValue == nil || json.Unmarshal(taskValue, &taskUUID) != nil || taskUUID == nil {
+            return false, nil
+        }
+        var taskID int64
+        if err := tx.QueryRow("SELECT id FROM tasks WHERE uuid = ?", *taskUUID).Scan(&taskID); err == sql.ErrNoRows {
+            return false, nil
+        } else if err != nil {
+            return false, fmt.Errorf("error looking up task %s: %w", *taskUUID, err)
+        }
+        // The timestamp and description follow in the same batch of changes
+        res, err := tx.Exec("INSERT INTO task_notes (task_id, timestamp, description, uuid) VALUES (?, ?, '', ?)", taskID, time.Now().UTC(), uuid)
+        if err != nil {
+            return false, fmt.Errorf("error adding note %s: %w", uuid, wrapDBError(err))
+        }
+        if field == "task" {
+            return true, nil
+        }
+        if id, err = res.LastInsertId(); err != nil {
+            return false, fmt.Errorf("error getting last insert ID: %w", err)
+        }
+    } else if err != nil {
+        return false, fmt.Errorf("error looking up note %s: %w", uuid, err)
+    }
+
+    switch field {
+    case "task":
+        var taskUUID *string
+        if err := json.Unmarshal(value, &taskUUID); err != nil || taskUUID == nil {
+            return false, nil
+        }
+        res, err := tx.Exec("UPDATE task_notes SET task_id = (SELECT id FROM tasks WHERE uuid = ?) WHERE id = ? AND EXISTS (SELECT 1 FROM tasks WHERE uuid = ?)", *taskUUID, id, *taskUUID)
+        if err != nil {
+            return false, fmt.Errorf("error moving note %s: %w", uuid, err)
+        }
+        n, _ := res.RowsAffected()
+        return n > 0, nil
+    case "timestamp":
+        t, err := decodeTime(value)
+        if err != nil || !t.Valid {
+            return false, fmt.Errorf("invalid timestamp of note %s", uuid)
+        }
+        sqlTime, _ := t.Value()
+        _, err = tx.Exec("UPDATE task_notes SET timestamp = ? WHERE id = ?", sqlTime, id)
+        return err == nil, wrapDBError(err)
+    case "description":
+        var description string
+        if err := json.Unmarshal(value, &description); err != nil {
+            return false, fmt.Errorf("invalid description of note %s: %w", uuid, err)
+        }
+        _, err = tx.Exec("UPDATE task_notes SET description = ? WHERE id = ?", description, id)
+        return err == nil, wrapDBError(err)
+    }
+    return false, nil
+}
+
+// decodeTime decodes a time logged by jsonTime.
+func decodeTime(value json.RawMessage) (NullableTime, error) {
+    var s *string
+    if err := json.Unmarshal(value, &s); err != nil {
+        return NullableTime{}, err
+    }
+    if s == nil {
+        return NullableTime{}, nil
+    }
+    t, err := time.Parse(time.RFC3339Nano, *s)
+    if err != nil {
+        return NullableTime{}, err
+    }
+    return NullableTime{Time: t.UTC(), Valid: true}, nil
+}
+
+// conflictTitle looks up the title of the task a conflict is about.
+func conflictTitle(q queryer, c SyncConflict) string {
+    query := "SELECT title FROM tasks WHERE uuid = ?"
+    if c.Entity == EntityNote {
+        query = "SELECT t.title FROM task_notes n JOIN tasks t ON n.task_id = t.id WHERE n.uuid = ?"
+    }
+    var title string
+    if err := q.QueryRow(query, c.EntityUUID).Scan(&title); err != nil {
+        return c.Title
+    }
+    return title
+}
+
+// readChangeFile reads a change log file. A last line without a newline is ignored, since
+// it may still be in transit from another device.
+func readChangeFile(path string) ([]Change, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    var changes []Change
+    r := bufio.NewReader(f)
+    for line := 1; ; line++ {
+        b, err := r.ReadBytes('\n')
+        if err == io.EOF {
+            return changes, nil
+        } else if err != nil {
+            return nil, fmt.Errorf("error reading %s: %w", path, err)
+        }
+        if len(bytes.TrimSpace(b)) == 0 {
+            continue
+        }
+        var c Change
+        if err := json.Unmarshal(b, &c); err != nil {
+            return nil, fmt.Errorf("%w: %s line %d: %v", ErrInvalidInput, path, line, err)
+        }
+        if err := validateChange(c); err != nil {
+            return nil, fmt.Errorf("%w: %s line %d: %v", ErrInvalidInput, path, line, err)
+        }
+        changes = append(changes, c)
+    }
+}
+
+// completeLinesEnd returns the offset just after the last newline of a file, or 0 if it has none.
+func completeLinesEnd(f *os.File) (int64, error) {
+    info, err := f.Stat()
+    if err != nil {
+        return 0, err
+    }
+    buf := make([]byte, 4096)
+    for end := info.Size(); end > 0; {
+        start := end - int64(len(buf))
+        if start < 0 {
+            start = 0
+        }
+        n, err := f.ReadAt(buf[:end-start], start)
+        if err != nil && err != io.EOF {
+            return 0, err
+        }
+        if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+            return start + int64(i) + 1, nil
+        }
+        end = start
+    }
+    return 0, nil
+}
+
+// validateChange checks the fields every change must have.
+func validateChange(c Change) error {
+    switch {
+    case !IsUUID(c.UUID) || !IsUUID(c.EntityUUID):
+        return fmt.Errorf("change without a valid UUID")
+    case c.DeviceID == "" || strings.ContainsAny(c.DeviceID, `/\`):
+        return fmt.Errorf("change %s has an invalid device", c.UUID)
+    case c.Seq <= 0:
+        return fmt.Errorf("change %s has an invalid sequence number", c.UUID)
+    case c.Entity != EntityTask && c.Entity != EntityNote:
+        return fmt.Errorf("change %s has an unknown entity '%s'", c.UUID, c.Entity)
+    case c.Op != OpSet && c.Op != OpAdd && c.Op != OpRemove && c.Op != OpDelete:
+        return fmt.Errorf("change %s has an unknown operation '%s'", c.UUID, c.Op)
+    case c.Op == OpSet && (c.Field == "" || c.Value == nil):
+        return fmt.Errorf("change %s sets no field", c.UUID)
+    case (c.Op == OpAdd || c.Op == OpRemove) && (c.Entity != EntityTask || !contains(nameFields, c.Field) || c.Value == nil):
+        return fmt.Errorf("change %s adds or removes a name outside contexts and tags", c.UUID)
+    }
+    return nil
+}
+
+// appendChangeFile appends changes to a change log file, one JSON object per line. A last
+// line cut short, e.g. by a crash while writing, is removed first, so that the changes do not
+// continue it; readChangeFile skips such a line, and the changes on it are written again.
+func appendChangeFile(path string, changes []Change) error {
+    if len(changes) == 0 {
+        return nil
+    }
+    f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+    if err != nil {
+        return fmt.Errorf("error opening %s: %w", path, err)
+    }
+    end, err := completeLinesEnd(f)
+    if err == nil {
+        err = f.Truncate(end)
+    }
+    if err == nil {
+        _, err = f.Seek(end, io.SeekStart)
+    }
+    if err != nil {
+        f.Close()
+        return fmt.Errorf("error preparing %s for writing: %w", path, err)
+    }
+    w := bufio.NewWriter(f)
+    enc := json.NewEncoder(w)
+    for _, c := range changes {
+        if err := enc.Encode(c); err != nil {
+            f.Close()
+            return fmt.Errorf("error writing %s: %w", path, err)
+        }
+    }
+    if err := w.Flush(); err != nil {
+        f.Close()
+        return fmt.Errorf("error writing %s: %w", path, err)
+    }
+    return f.Close()
+}
@@ -0,0 +1,371 @@
+package todo
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "testing"
+    "time"
+)
+
+func TestSync(t *testing.T) {
+    rename := func(title string) func(t *testing.T, tm *TodoManager, id int64) {
+        return func(t *testing.T, tm *TodoManager, id int64) {
+            update(t, tm, id, TaskPatch{Title: strPtr(title)})
+        }
+    }
+    tests := []struct {
+        name      string
+        a, b      func(t *testing.T, tm *TodoManager, id int64) // changes to the shared task, b last
+        title     string                                         // of the task on both devices, empty when deleted
+        conflicts [2]int                                         // reported by a and b
+        check     func(t *testing.T, task *Task)
+    }{
+        {
+            name:  "different fields merge",
+            a:     rename("book the flights"),
+            b:     func(t *testing.T, tm *TodoManager, id int64) { update(t, tm, id, TaskPatch{Priority: strPtr("H"), AddTags: []string{"travel"}}) },
+            title: "book the flights",
+            check: func(t *testing.T, task *Task) {
+                if task.Priority.String != "H" || len(task.Tags) != 1 || task.Tags[0] != "travel" {
+                    t.Errorf("got priority %v and tags %v", task.Priority, task.Tags)
+                }
+            },
+        },
+        {
+            name:      "later change wins",
+            a:         rename("book the flights"),
+            b:         rename("book the train"),
+            title:     "book the train",
+            conflicts: [2]int{1, 1},
+        },
+        {
+            name:  "same change is no conflict",
+            a:     rename("book the train"),
+            b:     rename("book the train"),
+            title: "book the train",
+        },
+        {
+            name: "completion and due date merge",
+            a:    func(t *testing.T, tm *TodoManager, id int64) { update(t, tm, id, TaskPatch{EndDate: strPtr("2026-03-02 10:00:00")}) },
+            b:    func(t *testing.T, tm *TodoManager, id int64) { update(t, tm, id, TaskPatch{DueDate: strPtr("2026-03-01 17:00:00")}) },
+            title: "book travel",
+            check: func(t *testing.T, task *Task) {
+                if task.Status != "completed" || !task.DueDate.Time.Equal(localTime(t, "2026-03-01 17:00")) {
+                    t.Errorf("got status %s, due %v", task.Status, task.DueDate)
+                }
+            },
+        },
+        {
+            name: "notes of both devices are kept",
+            a: func(t *testing.T, tm *TodoManager, id int64) {
+                if _, err := tm.AddNoteToTask(id, "window seat", "", false); err != nil {
+                    t.Fatal(err)
+                }
+            },
+            b: func(t *testing.T, tm *TodoManager, id int64) {
+                if _, err := tm.AddNoteToTask(id, "no red-eye", "", false); err != nil {
+                    t.Fatal(err)
+                }
+            },
+            title: "book travel",
+            check: func(t *testing.T, task *Task) {
+                if len(task.Notes) != 2 {
+                    t.Errorf("got notes %+v", task.Notes)
+                }
+            },
+        },
+        {
+            name: "deletion wins over a later change",
+            a: func(t *testing.T, tm *TodoManager, id int64) {
+                if err := tm.DeleteTask(id, false, ""); err != nil {
+                    t.Fatal(err)
+                }
+            },
+            b:         rename("book the train"),
+            conflicts: [2]int{0, 1}, // a does not apply changes to the deleted task
+        },
+        {
+            name:  "names added and removed on both devices merge",
+            a:     func(t *testing.T, tm *TodoManager, id int64) { update(t, tm, id, TaskPatch{AddContexts: []string{"phone"}, AddTags: []string{"travel"}}) },
+            b:     func(t *testing.T, tm *TodoManager, id int64) { update(t, tm, id, TaskPatch{RemoveContexts: []string{"computer"}, AddTags: []string{"work"}}) },
+            title: "book travel",
+            check: func(t *testing.T, task *Task) {
+                if fmt.Sprint(task.Contexts, sortedNames(task.Tags)) != "[phone] [travel work]" {
+                    t.Errorf("got contexts %v and tags %v", task.Contexts, task.Tags)
+                }
+            },
+        },
+        {
+            name: "name removed on one device and added back on the other",
+            a:    func(t *testing.T, tm *TodoManager, id int64) { update(t, tm, id, TaskPatch{RemoveContexts: []string{"computer"}}) },
+            b: func(t *testing.T, tm *TodoManager, id int64) {
+                update(t, tm, id, TaskPatch{RemoveContexts: []string{"computer"}})
+                update(t, tm, id, TaskPatch{AddContexts: []string{"computer"}})
+            },
+            title:     "book travel",
+            conflicts: [2]int{0, 1}, // b added it back without seeing the removal of a
+            check: func(t *testing.T, task *Task) {
+                if fmt.Sprint(task.Contexts) != "[computer]" {
+                    t.Errorf("got contexts %v", task.Contexts)
+                }
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            dir := t.TempDir()
+            a, b := newTestManager(t), newTestManager(t)
+            task, err := a.AddTask(TaskInput{Title: "book travel", Project: "holiday", Contexts: []string{"computer"}})
+            if err != nil {
+                t.Fatal(err)
+            }
+            sync(t, a, dir)
+            sync(t, b, dir)
+            copied := findByUUID(t, b, task.UUID.String)
+            if copied == nil {
+                t.Fatal("task not synced to the second device")
+            }
+
+            tt.a(t, a, task.ID)
+            time.Sleep(10 * time.Millisecond) // so the change of b is the later one
+            tt.b(t, b, copied.ID)
+
+            sync(t, a, dir)
+            conflictsB := len(sync(t, b, dir).Conflicts)
+            conflictsA := len(sync(t, a, dir).Conflicts)
+            if got := [2]int{conflictsA, conflictsB}; got != tt.conflicts {
+                t.Errorf("got %v conflicts, want %v", got, tt.conflicts)
+            }
+
+            onA, onB := findByUUID(t, a, task.UUID.String), findByUUID(t, b, task.UUID.String)
+            if tt.title == "" {
+                if onA != nil || onB != nil {
+                    t.Fatalf("deleted task still there: %v, %v", onA, onB)
+                }
+                return
+            }
+            if onA == nil || onB == nil {
+                t.Fatalf("task missing: %v, %v", onA, onB)
+            }
+            if onA.Title != tt.title {
+                t.Errorf("got title %q, want %q", onA.Title, tt.title)
+            }
+            if sa, sb := taskSummary(onA), taskSummary(onB); sa != sb {
+                t.Errorf("devices did not converge:\n%s\n%s", sa, sb)
+            }
+            if tt.check != nil {
+                tt.check(t, onA)
+            }
+
+            // Nothing is left to exchange
+            if r := sync(t, b, dir); r.Exported != 0 || r.Applied != 0 {
+                t.Errorf("second round exported %d and applied %d changes", r.Exported, r.Applied)
+            }
+        })
+    }
+}
+
+func TestSyncNamesSetByEarlierVersions(t *testing.T) {
+    dir := t.TempDir()
+    tm := newTestManager(t)
+    task, err := tm.AddTask(TaskInput{Title: "book travel", Tags: []string{"holiday"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    sync(t, tm, dir)
+
+    // Another device, running an earlier version, replaced the whole list
+    const other = "0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10"
+    id, err := NewUUID()
+    if err != nil {
+        t.Fatal(err)
+    }
+    set := Change{UUID: id, DeviceID: other, Seq: 1, ChangedAt: time.Now().UTC().Add(time.Minute), Entity: EntityTask,
+        EntityUUID: task.UUID.String, Op: OpSet, Field: "tags", Value: jsonValue([]string{"travel", "work"})}
+    if err := appendChangeFile(filepath.Join(dir, other+syncFileExt), []Change{set}); err != nil {
+        t.Fatal(err)
+    }
+    if r := sync(t, tm, dir); r.Applied != 1 {
+        t.Fatalf("applied %d changes, want 1", r.Applied)
+    }
+    if got := findByUUID(t, tm, task.UUID.String); fmt.Sprint(sortedNames(got.Tags)) != "[travel work]" {
+        t.Errorf("got tags %v", got.Tags)
+    }
+
+    // Changes made here from then on add and remove single names
+    update(t, tm, task.ID, TaskPatch{RemoveTags: []string{"work"}})
+    device, err := tm.DeviceID()
+    if err != nil {
+        t.Fatal(err)
+    }
+    changes, err := tm.ChangesSince(device, 0)
+    if err != nil {
+        t.Fatal(err)
+    }
+    last := changes[len(changes)-1]
+    if last.Op != OpRemove || string(last.Value) != `"work"` || last.Prev != set.UUID {
+        t.Errorf("got change %+v", last)
+    }
+}
+
+func TestAppendChangeFile(t *testing.T) {
+    var changes []Change
+    for seq := int64(1); seq <= 3; seq++ {
+        id, err := NewUUID()
+        if err != nil {
+            t.Fatal(err)
+        }
+        changes = append(changes, Change{UUID: id, DeviceID: "device", Seq: seq, ChangedAt: time.Now().UTC(), Entity: EntityTask,
+            EntityUUID: id, Op: OpSet, Field: "title", Value: jsonValue("book travel")})
+    }
+    first, err := json.Marshal(changes[0])
+    if err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        name    string
+        written string // in the file before changes 2 and 3 are appended; empty for no file
+        seqs    string
+    }{
+        {"new file", "", "[2 3]"},
+        {"complete lines", string(first) + "\n", "[1 2 3]"},
+        {"line cut short", string(first) + "\n" + string(first[:20]), "[1 2 3]"},
+        {"only a line cut short", string(first[:20]), "[2 3]"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            path := filepath.Join(t.TempDir(), "device"+syncFileExt)
+            if tt.written != "" {
+                if err := os.WriteFile(path, []byte(tt.written), 0o644); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            if err := appendChangeFile(path, changes[1:]); err != nil {
+                t.Fatal(err)
+            }
+            read, err := readChangeFile(path)
+            if err != nil {
+                t.Fatalf("readChangeFile: %v", err)
+            }
+            seqs := []int64{}
+            for _, c := range read {
+                seqs = append(seqs, c.Seq)
+            }
+            if fmt.Sprint(seqs) != tt.seqs {
+                t.Errorf("got changes %v, want %s", seqs, tt.seqs)
+            }
+        })
+    }
+}
+
+func TestSyncLinks(t *testing.T) {
+    dir := t.TempDir()
+    a, b := newTestManager(t), newTestManager(t)
+    parent, err := a.AddTask(TaskInput{Title: "move house"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    first, err := a.AddTask(TaskInput{Title: "pack", ParentID: parent.ID})
+    if err != nil {
+        t.Fatal(err)
+    }
+    second, err := a.AddTask(TaskInput{Title: "hire a van", ParentID: parent.ID, DependsOn: []int64{first.ID}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    // Tasks on b get other IDs than on a
+    if _, err := b.AddTask(TaskInput{Title: "water the plants"}); err != nil {
+        t.Fatal(err)
+    }
+    sync(t, a, dir)
+    sync(t, b, dir)
+
+    tests := []struct {
+        task      *Task
+        parent    *Task
+        dependsOn []*Task
+    }{
+        {parent, nil, nil},
+        {first, parent, nil},
+        {second, parent, []*Task{first}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.task.Title, func(t *testing.T) {
+            got := findByUUID(t, b, tt.task.UUID.String)
+            if got == nil {
+                t.Fatal("not synced")
+            }
+            var wantParent int64
+            if tt.parent != nil {
+                wantParent = findByUUID(t, b, tt.parent.UUID.String).ID
+            }
+            if got.ParentID.Int64 != wantParent {
+                t.Errorf("got parent %d, want %d", got.ParentID.Int64, wantParent)
+            }
+            if len(got.DependsOn) != len(tt.dependsOn) {
+                t.Fatalf("got dependencies %v, want %d", got.DependsOn, len(tt.dependsOn))
+            }
+            for i, dep := range tt.dependsOn {
+                if want := findByUUID(t, b, dep.UUID.String).ID; got.DependsOn[i] != want {
+                    t.Errorf("got dependency %d, want %d", got.DependsOn[i], want)
+                }
+            }
+        })
+    }
+}
+
+func update(t *testing.T, tm *TodoManager, id int64, patch TaskPatch) {
+    t.Helper()
+    results, err := tm.UpdateTasks([]int64{id}, patch)
+    if err != nil {
+        t.Fatalf("UpdateTasks: %v", err)
+    }
+    if results[0].Err != nil {
+        t.Fatalf("UpdateTasks: %v", results[0].Err)
+    }
+}
+
+func sync(t *testing.T, tm *TodoManager, dir string) *SyncResult {
+    t.Helper()
+    result, err := tm.Sync(dir)
+    if err != nil {
+        t.Fatalf("Sync: %v", err)
+    }
+    return result
+}
+
+// findByUUID returns the task with a UUID, or nil if there is none.
+func findByUUID(t *testing.T, tm *TodoManager, uuid string) *Task {
+    t.Helper()
+    tasks, err := tm.GetTasks(TaskFilter{Status: "all", IncludeNotes: true})
+    if err != nil {
+        t.Fatalf("GetTasks: %v", err)
+    }
+    for i := range tasks {
+        if tasks[i].UUID.String == uuid {
+            return &tasks[i]
+        }
+    }
+    return nil
+}
+
+// taskSummary formats the synced fields of a task, which must be the same on every device.
+// Contexts and tags are sets, whose order depends on the database.
+func taskSummary(task *Task) string {
+    notes := []string{}
+    for _, n := range task.Notes {
+        notes = append(notes, n.UUID+" "+n.Description.String)
+    }
+    return fmt.Sprintf("%s|%s|%s|%s|%s|%s|%s|%v|%v|%v", task.Title, task.Status, task.ProjectName.String, task.Priority.String,
+        task.StartDate.Time.UTC(), task.DueDate.Time.UTC(), task.EndDate.Time.UTC(), sortedNames(task.Contexts), sortedNames(task.Tags), notes)
+}
+
+// sortedNames returns a sorted copy of names.
+func sortedNames(names []string) []string {
+    sorted := append([]string{}, names...)
+    sort.Strings(sorted)
+    return sorted
+}
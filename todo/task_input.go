@@ -0,0 +1,296 @@
+package todo
+
+import (
+    "errors"
+    "fmt"
+    "strings"
+)
+
+// validStatuses lists the task statuses accepted by TaskInput and TaskPatch.
+var validStatuses = []string{"pending", "completed", "cancelled", "waiting"}
+
+// TaskInput describes a new task for AddTask.
+// Date fields are optional: nil means "not given", and a pointer to an empty string means "now".
+type TaskInput struct {
+    Title              string // required
+    Description        string
+    Project            string // created if it does not exist
+    StartDate          *string // defaults to now when nil
+    DueDate            *string
+    EndDate            *string // completion date; sets status to completed unless Status is cancelled
+    Recurrence         string  // daily, weekly, monthly, yearly or an RRULE such as FREQ=MONTHLY;BYDAY=2TU
+    RecurrenceInterval int     // e.g. 2 for every 2 days; 0 leaves it unset
+    RecurrenceShift    string  // next or previous working day for instances on non-working days; empty or none keeps them
+    RecurrenceAnchor   string  // scheduled (default) or completion
+    Contexts           []string
+    Tags               []string
+    StartWaiting       *string // a start without an end puts the task in waiting status unless Status or EndDate is given
+    EndWaiting         *string
+    Status             string // pending (default), completed, cancelled, waiting
+    Priority           string // H, M or L, also high, medium or low; empty or none for no priority
+    Estimate           string // working time such as 3h, 1h30m or 2d (working days); empty or none for no estimate
+    DependsOn          []int64 // IDs of the tasks that must be done first
+    ParentID           int64   // ID of the task this one is a subtask of; 0 for a top-level task
+}
+
+// TaskPatch describes changes to existing tasks for UpdateTasks.
+// A nil pointer leaves the field unchanged. For dates, a pointer to an empty string means "now";
+// for Description, Project and Recurrence it clears the value.
+type TaskPatch struct {
+    Title              *string
+    Description        *string
+    Project            *string
+    StartDate          *string
+    DueDate            *string
+    EndDate            *string // also completes the task unless Status is cancelled
+    Status             *string
+    Priority           *string // H, M or L; none clears it
+    Estimate           *string // e.g. 3h or 2d; none clears it
+    Recurrence         *string
+    RecurrenceInterval *int
+    RecurrenceShift    *string // next, previous or none
+    RecurrenceAnchor   *string // scheduled or completion
+    StartWaiting       *string // also sets status to waiting unless Status is given
+    EndWaiting         *string // also sets a waiting task back to pending unless Status is given
+
+    Contexts       *[]string // replaces all contexts
+    Tags           *[]string // replaces all tags
+    AddContexts    []string
+    RemoveContexts []string
+    AddTags        []string
+    RemoveTags     []string
+
+    DependsOn       *[]int64 // replaces all dependencies
+    AddDependsOn    []int64
+    RemoveDependsOn []int64
+    IgnoreBlockers  bool // completes tasks even while tasks they depend on are open
+
+    ParentID *int64 // makes the tasks subtasks of this task
+
+    ClearProject    bool
+    ClearContexts   bool
+    ClearTags       bool
+    ClearStartDate  bool
+    ClearDueDate    bool
+    ClearEndDate    bool
+    ClearRecurrence bool
+    ClearWaiting    bool
+    ClearDependsOn  bool
+    ClearParent     bool // makes the tasks top-level tasks
+    ClearPriority   bool
+    ClearEstimate   bool
+}
+
+// resolvedInput holds the parsed dates and final status of a TaskInput.
+type resolvedInput struct {
+    startDate, dueDate, endDate, startWaiting, endWaiting NullableTime
+    status                                                string
+}
+
+// resolvedPatch holds the parsed dates of a TaskPatch; a date is Valid only when the patch sets it.
+type resolvedPatch struct {
+    startDate, dueDate, endDate, startWaiting, endWaiting NullableTime
+}
+
+// problems collects validation errors so they can be reported all at once.
+type problems []error
+
+func (p *problems) add(format string, args ...any) {
+    *p = append(*p, fmt.Errorf("%w: "+format, append([]any{ErrInvalidInput}, args...)...))
+}
+
+// date parses an optional date, recording a DateError if it is invalid.
+func (p *problems) date(field string, value *string) NullableTime {
+    if value == nil {
+        return NullableTime{}
+    }
+    parsed, err := resolveDate(field, *value)
+    if err != nil {
+        *p = append(*p, err)
+    }
+    return parsed
+}
+
+func (p problems) err() error {
+    if len(p) == 0 {
+        return nil
+    }
+    return &ValidationError{Problems: p}
+}
+
+func contains(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+func (p *problems) checkStatus(status string) {
+    if !contains(validStatuses, status) {
+        p.add("unknown status '%s' (expected %s)", status, strings.Join(validStatuses, ", "))
+    }
+}
+
+// checkEndStatus reports an end date given together with the status of an open task.
+func (p *problems) checkEndStatus(status string) {
+    if isOpen(status) {
+        p.add("a %s task cannot have an end date (use completed or cancelled)", status)
+    }
+}
+
+func (p *problems) checkRecurrence(recurrence string, interval int) {
+    if strings.TrimSpace(recurrence) != "" {
+        if _, err := ParseRecurrence(recurrence, 1); err != nil {
+            var v *ValidationError
+            if errors.As(err, &v) {
+                *p = append(*p, v.Problems...)
+            } else {
+                *p = append(*p, err)
+            }
+        }
+    }
+    if interval < 0 {
+        p.add("recurrence interval must be positive, got %d", interval)
+    }
+}
+
+// Validate checks a TaskInput and reports every problem found in a single ValidationError.
+func (in TaskInput) Validate() error {
+    _, err := in.resolve()
+    return err
+}
+
+// resolve validates the input and computes its dates and final status.
+func (in TaskInput) resolve() (resolvedInput, error) {
+    var p problems
+    var r resolvedInput
+
+    if strings.TrimSpace(in.Title) == "" {
+        p.add("task title is required")
+    }
+
+    if in.StartDate != nil {
+        r.startDate = p.date("start date", in.StartDate)
+    } else {
+        // If not explicitly set, set to current UTC time (original default behavior)
+        r.startDate, _ = resolveDate("start date", "")
+    }
+    r.dueDate = p.date("due date", in.DueDate)
+    r.endDate = p.date("end date", in.EndDate)
+    r.startWaiting = p.date("start waiting date", in.StartWaiting)
+    r.endWaiting = p.date("end waiting date", in.EndWaiting)
+
+    r.status = in.Status
+    if r.status == "" {
+        r.status = "pending"
+    } else if in.EndDate != nil {
+        p.checkEndStatus(r.status)
+    }
+    p.checkStatus(r.status)
+    p.checkPriority(in.Priority)
+    p.checkEstimate(in.Estimate)
+    p.checkRecurrence(in.Recurrence, in.RecurrenceInterval)
+    p.checkShift(in.RecurrenceShift)
+    p.checkAnchor(in.RecurrenceAnchor)
+
+    // If end_date is set, and status is not explicitly provided, set status to 'completed'.
+    // Otherwise a waiting start without an end puts the task in waiting status, unless the
+    // status is given, as UpdateTasks does
+    if in.EndDate != nil && in.Status == "" {
+        r.status = "completed"
+    } else if in.Status == "" && r.startWaiting.Valid && !r.endWaiting.Valid {
+        r.status = "waiting"
+    }
+
+    return r, p.err()
+}
+
+// Validate checks a TaskPatch and reports every problem found in a single ValidationError.
+func (pt TaskPatch) Validate() error {
+    _, err := pt.resolve()
+    return err
+}
+
+// resolve validates the patch and parses its dates.
+func (pt TaskPatch) resolve() (resolvedPatch, error) {
+    var p problems
+    var r resolvedPatch
+
+    if pt.Title != nil && strings.TrimSpace(*pt.Title) == "" {
+        p.add("task title cannot be empty")
+    }
+    r.startDate = p.date("start date", pt.StartDate)
+    r.dueDate = p.date("due date", pt.DueDate)
+    r.endDate = p.date("end date", pt.EndDate)
+    r.startWaiting = p.date("start waiting date", pt.StartWaiting)
+    r.endWaiting = p.date("end waiting date", pt.EndWaiting)
+
+    if pt.Status != nil {
+        p.checkStatus(*pt.Status)
+        if pt.EndDate != nil {
+            p.checkEndStatus(*pt.Status)
+        }
+    }
+    if pt.Priority != nil {
+        p.checkPriority(*pt.Priority)
+    }
+    if pt.Estimate != nil {
+        p.checkEstimate(*pt.Estimate)
+    }
+    if pt.Recurrence != nil {
+        p.checkRecurrence(*pt.Recurrence, 0)
+    }
+    if pt.RecurrenceInterval != nil && *pt.RecurrenceInterval <= 0 {
+        p.add("recurrence interval must be positive, got %d", *pt.RecurrenceInterval)
+    }
+    if pt.RecurrenceShift != nil {
+        p.checkShift(*pt.RecurrenceShift)
+    }
+    if pt.RecurrenceAnchor != nil {
+        p.checkAnchor(*pt.RecurrenceAnchor)
+    }
+
+    // A field cannot be set and cleared at the same time
+    conflicts := []struct {
+        set, clear bool
+        name       string
+    }{
+        {pt.Project != nil && *pt.Project != "", pt.ClearProject, "project"},
+        {pt.Contexts != nil || len(pt.AddContexts) > 0, pt.ClearContexts, "contexts"},
+        {pt.Tags != nil || len(pt.AddTags) > 0, pt.ClearTags, "tags"},
+        {pt.StartDate != nil, pt.ClearStartDate, "start date"},
+        {pt.DueDate != nil, pt.ClearDueDate, "due date"},
+        {pt.EndDate != nil, pt.ClearEndDate, "end date"},
+        {pt.Recurrence != nil && *pt.Recurrence != "", pt.ClearRecurrence, "recurrence"},
+        {pt.RecurrenceShift != nil, pt.ClearRecurrence, "recurrence shift"},
+        {pt.RecurrenceAnchor != nil, pt.ClearRecurrence, "recurrence anchor"},
+        {pt.StartWaiting != nil || pt.EndWaiting != nil, pt.ClearWaiting, "waiting period"},
+        {pt.DependsOn != nil || len(pt.AddDependsOn) > 0, pt.ClearDependsOn, "dependencies"},
+        {pt.ParentID != nil, pt.ClearParent, "parent"},
+        {pt.Priority != nil && normalizePriority(*pt.Priority) != "", pt.ClearPriority, "priority"},
+        {pt.Estimate != nil && !strings.EqualFold(strings.TrimSpace(*pt.Estimate), "none"), pt.ClearEstimate, "estimate"},
+    }
+    for _, c := range conflicts {
+        if c.set && c.clear {
+            p.add("%s cannot be set and cleared at the same time", c.name)
+        }
+    }
+
+    return r, p.err()
+}
+
+// IsEmpty reports whether the patch contains no changes at all.
+func (pt TaskPatch) IsEmpty() bool {
+    return pt.Title == nil && pt.Description == nil && pt.Project == nil &&
+        pt.StartDate == nil && pt.DueDate == nil && pt.EndDate == nil && pt.Status == nil && pt.Priority == nil && pt.Estimate == nil &&
+        pt.Recurrence == nil && pt.RecurrenceInterval == nil && pt.RecurrenceShift == nil && pt.RecurrenceAnchor == nil &&
+        pt.StartWaiting == nil && pt.EndWaiting == nil &&
+        pt.Contexts == nil && pt.Tags == nil &&
+        len(pt.AddContexts) == 0 && len(pt.RemoveContexts) == 0 && len(pt.AddTags) == 0 && len(pt.RemoveTags) == 0 &&
+        pt.DependsOn == nil && len(pt.AddDependsOn) == 0 && len(pt.RemoveDependsOn) == 0 && pt.ParentID == nil &&
+        !pt.ClearProject && !pt.ClearContexts && !pt.ClearTags && !pt.ClearStartDate && !pt.ClearDueDate &&
+        !pt.ClearEndDate && !pt.ClearRecurrence && !pt.ClearWaiting && !pt.ClearDependsOn && !pt.ClearParent &&
+        !pt.ClearPriority && !pt.ClearEstimate
+}
@@ -0,0 +1,241 @@
+package todo
+
+import (
+    "errors"
+    "testing"
+)
+
+func TestTaskPatchValidate(t *testing.T) {
+    ptr := func(n int64) *int64 { return &n }
+    zero := 0
+    tags := []string{"home"}
+    tests := []struct {
+        name     string
+        patch    TaskPatch
+        problems int // 0 when the patch is valid
+        date     bool
+    }{
+        {"empty patch", TaskPatch{}, 0, false},
+        {"dates", TaskPatch{DueDate: strPtr("2026-03-01 17:00:00"), StartDate: strPtr("")}, 0, false},
+        {"priority none cleared", TaskPatch{Priority: strPtr("none"), ClearPriority: true}, 0, false},
+        {"estimate none cleared", TaskPatch{Estimate: strPtr("none"), ClearEstimate: true}, 0, false},
+        {"empty project cleared", TaskPatch{Project: strPtr(""), ClearProject: true}, 0, false},
+
+        {"empty title", TaskPatch{Title: strPtr("  ")}, 1, false},
+        {"bad date", TaskPatch{DueDate: strPtr("2026-13-45")}, 1, true},
+        {"bad waiting date", TaskPatch{StartWaiting: strPtr("next week")}, 1, true},
+        {"unknown status", TaskPatch{Status: strPtr("done")}, 1, false},
+        {"unknown priority", TaskPatch{Priority: strPtr("urgent")}, 1, false},
+        {"bad estimate", TaskPatch{Estimate: strPtr("soon")}, 1, false},
+        {"bad recurrence", TaskPatch{Recurrence: strPtr("FREQ=HOURLY")}, 1, false},
+        {"zero interval", TaskPatch{RecurrenceInterval: &zero}, 1, false},
+        {"unknown shift", TaskPatch{RecurrenceShift: strPtr("sideways")}, 1, false},
+        {"unknown anchor", TaskPatch{RecurrenceAnchor: strPtr("whenever")}, 1, false},
+        {"due date set and cleared", TaskPatch{DueDate: strPtr("2026-03-01"), ClearDueDate: true}, 1, false},
+        {"tags set and cleared", TaskPatch{Tags: &tags, ClearTags: true}, 1, false},
+        {"tags added and cleared", TaskPatch{AddTags: tags, ClearTags: true}, 1, false},
+        {"parent set and cleared", TaskPatch{ParentID: ptr(1), ClearParent: true}, 1, false},
+        {"dependencies set and cleared", TaskPatch{AddDependsOn: []int64{1}, ClearDependsOn: true}, 1, false},
+        {"priority set and cleared", TaskPatch{Priority: strPtr("H"), ClearPriority: true}, 1, false},
+        {"recurrence shift with cleared recurrence", TaskPatch{RecurrenceShift: strPtr("next"), ClearRecurrence: true}, 1, false},
+        {"waiting set and cleared", TaskPatch{EndWaiting: strPtr(""), ClearWaiting: true}, 1, false},
+        {"end date of a pending task", TaskPatch{EndDate: strPtr(""), Status: strPtr("pending")}, 1, false},
+        {"end date of a waiting task", TaskPatch{EndDate: strPtr(""), Status: strPtr("waiting")}, 1, false},
+        {"every problem reported", TaskPatch{Title: strPtr(""), EndDate: strPtr("yesterday"), Status: strPtr("done")}, 3, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := tt.patch.Validate()
+            if tt.problems == 0 {
+                if err != nil {
+                    t.Fatalf("unexpected error: %v", err)
+                }
+                return
+            }
+            var v *ValidationError
+            if !errors.As(err, &v) {
+                t.Fatalf("got %v, want a ValidationError", err)
+            }
+            if len(v.Problems) != tt.problems {
+                t.Errorf("got %d problems (%v), want %d", len(v.Problems), err, tt.problems)
+            }
+            if !errors.Is(err, ErrInvalidInput) {
+                t.Errorf("%v does not match ErrInvalidInput", err)
+            }
+            if errors.Is(err, ErrInvalidDate) != tt.date {
+                t.Errorf("%v matches ErrInvalidDate: %v, want %v", err, !tt.date, tt.date)
+            }
+        })
+    }
+}
+
+func TestAddTaskStatus(t *testing.T) {
+    tests := []struct {
+        name   string
+        input  TaskInput
+        status string // empty when the input is refused
+    }{
+        {"pending by default", TaskInput{Title: "a"}, "pending"},
+        {"end date completes", TaskInput{Title: "a", EndDate: strPtr("2026-03-02 10:00:00")}, "completed"},
+        {"end date of a cancelled task", TaskInput{Title: "a", EndDate: strPtr("2026-03-02 10:00:00"), Status: "cancelled"}, "cancelled"},
+        {"end date of a pending task", TaskInput{Title: "a", EndDate: strPtr("2026-03-02 10:00:00"), Status: "pending"}, ""},
+        {"end date of a waiting task", TaskInput{Title: "a", EndDate: strPtr("2026-03-02 10:00:00"), Status: "waiting"}, ""},
+        {"waiting without an end", TaskInput{Title: "a", StartWaiting: strPtr("2026-03-02 10:00:00")}, "waiting"},
+        {"done waiting", TaskInput{Title: "a", StartWaiting: strPtr("2026-03-02 10:00:00"), EndWaiting: strPtr("2026-03-03 10:00:00")}, "pending"},
+        {"completed after waiting", TaskInput{Title: "a", EndDate: strPtr("2026-03-04 10:00:00"),
+            StartWaiting: strPtr("2026-03-02 10:00:00"), EndWaiting: strPtr("2026-03-03 10:00:00")}, "completed"},
+        {"waiting with a status", TaskInput{Title: "a", StartWaiting: strPtr("2026-03-02 10:00:00"), Status: "pending"}, "pending"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            task, err := tm.AddTask(tt.input)
+            if tt.status == "" {
+                var v *ValidationError
+                if !errors.As(err, &v) {
+                    t.Fatalf("got %v, want a ValidationError", err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("AddTask: %v", err)
+            }
+            if task.Status != tt.status {
+                t.Errorf("got status %s, want %s", task.Status, tt.status)
+            }
+        })
+    }
+}
+
+func TestTaskPatchResolve(t *testing.T) {
+    r, err := TaskPatch{DueDate: strPtr("2026-03-01 17:00:00")}.resolve()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want := localTime(t, "2026-03-01 17:00"); !r.dueDate.Valid || !r.dueDate.Time.Equal(want) {
+        t.Errorf("got due date %v, want %v", r.dueDate, want)
+    }
+    if r.startDate.Valid || r.endDate.Valid || r.startWaiting.Valid || r.endWaiting.Valid {
+        t.Errorf("dates not in the patch are set: %+v", r)
+    }
+}
+
+func TestUpdateTasks(t *testing.T) {
+    tests := []struct {
+        name  string
+        input TaskInput // the task updated, added after a pending task with ID 1
+        patch TaskPatch
+        err   error // of the result of the task
+        check func(t *testing.T, task *Task)
+    }{
+        {
+            name:  "end date completes",
+            input: TaskInput{Title: "write report"},
+            patch: TaskPatch{EndDate: strPtr("2026-03-02 10:00:00")},
+            check: func(t *testing.T, task *Task) {
+                if task.Status != "completed" || !task.EndDate.Time.Equal(localTime(t, "2026-03-02 10:00")) {
+                    t.Errorf("got status %s ending %v", task.Status, task.EndDate)
+                }
+            },
+        },
+        {
+            name:  "status given with end date",
+            input: TaskInput{Title: "write report"},
+            patch: TaskPatch{EndDate: strPtr(""), Status: strPtr("cancelled")},
+            check: func(t *testing.T, task *Task) {
+                if task.Status != "cancelled" || !task.EndDate.Valid {
+                    t.Errorf("got status %s ending %v", task.Status, task.EndDate)
+                }
+            },
+        },
+        {
+            name:  "start of waiting",
+            input: TaskInput{Title: "write report"},
+            patch: TaskPatch{StartWaiting: strPtr("")},
+            check: func(t *testing.T, task *Task) {
+                if task.Status != "waiting" {
+                    t.Errorf("got status %s", task.Status)
+                }
+            },
+        },
+        {
+            name:  "end of waiting",
+            input: TaskInput{Title: "write report", StartWaiting: strPtr("")},
+            patch: TaskPatch{EndWaiting: strPtr("")},
+            check: func(t *testing.T, task *Task) {
+                if task.Status != "pending" || !task.EndWaitingDate.Valid {
+                    t.Errorf("got status %s, waiting until %v", task.Status, task.EndWaitingDate)
+                }
+            },
+        },
+        {
+            name:  "clear due date and tags",
+            input: TaskInput{Title: "write report", DueDate: strPtr("2026-03-01"), Tags: []string{"work", "q1"}},
+            patch: TaskPatch{ClearDueDate: true, RemoveTags: []string{"q1"}, AddTags: []string{"urgent"}},
+            check: func(t *testing.T, task *Task) {
+                if task.DueDate.Valid || len(task.Tags) != 2 || contains(task.Tags, "q1") || !contains(task.Tags, "urgent") {
+                    t.Errorf("got due date %v and tags %v", task.DueDate, task.Tags)
+                }
+            },
+        },
+        {
+            name:  "priority and estimate",
+            input: TaskInput{Title: "write report"},
+            patch: TaskPatch{Priority: strPtr("high"), Estimate: strPtr("1h30m")},
+            check: func(t *testing.T, task *Task) {
+                if task.Priority.String != "H" || task.Estimate.Int64 != 90 {
+                    t.Errorf("got priority %v and estimate %v", task.Priority, task.Estimate)
+                }
+            },
+        },
+        {
+            name:  "no changes",
+            input: TaskInput{Title: "write report"},
+            patch: TaskPatch{},
+            err:   ErrNoChanges,
+        },
+        {
+            name:  "blocked",
+            input: TaskInput{Title: "write report", DependsOn: []int64{1}},
+            patch: TaskPatch{Status: strPtr("completed")},
+            err:   ErrBlocked,
+        },
+        {
+            name:  "blockers ignored",
+            input: TaskInput{Title: "write report", DependsOn: []int64{1}},
+            patch: TaskPatch{Status: strPtr("completed"), IgnoreBlockers: true},
+            check: func(t *testing.T, task *Task) {
+                if task.Status != "completed" {
+                    t.Errorf("got status %s", task.Status)
+                }
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            if _, err := tm.AddTask(TaskInput{Title: "gather numbers"}); err != nil {
+                t.Fatal(err)
+            }
+            task, err := tm.AddTask(tt.input)
+            if err != nil {
+                t.Fatalf("AddTask: %v", err)
+            }
+            results, err := tm.UpdateTasks([]int64{task.ID}, tt.patch)
+            if err != nil {
+                t.Fatalf("UpdateTasks: %v", err)
+            }
+            if len(results) != 1 || !errors.Is(results[0].Err, tt.err) {
+                t.Fatalf("got results %+v, want error %v", results, tt.err)
+            }
+            if tt.check == nil {
+                return
+            }
+            updated, err := tm.GetTask(task.ID)
+            if err != nil {
+                t.Fatal(err)
+            }
+            tt.check(t, updated)
+        })
+    }
+}
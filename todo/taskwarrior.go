@@ -0,0 +1,409 @@
+package todo
+
+import (
+    "bufio"
+    "database/sql"
+    "encoding/json"
+    "fmt"
+    "io"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Taskwarrior support: tasks are exchanged in the JSON format of 'task export' and 'task import'.
+// See https://taskwarrior.org/docs/design/task/ for the format.
+
+const taskwarriorTime = "20060102T150405Z"
+
+// taskwarriorTask is a task as written by 'task export'. Unknown attributes are ignored.
+type taskwarriorTask struct {
+    UUID        string                  `json:"uuid"`
+    Description string                  `json:"description"`
+    Status      string                  `json:"status"`
+    Entry       string                  `json:"entry,omitempty"`
+    Modified    string                  `json:"modified,omitempty"`
+    Due         string                  `json:"due,omitempty"`
+    Wait        string                  `json:"wait,omitempty"`
+    End         string                  `json:"end,omitempty"`
+    Project     string                  `json:"project,omitempty"`
+    Priority    string                  `json:"priority,omitempty"`
+    Tags        []string                `json:"tags,omitempty"`
+    Recur       string                  `json:"recur,omitempty"`
+    Parent      string                  `json:"parent,omitempty"` // The recurring template of an instance
+    Depends     taskwarriorUUIDs        `json:"depends,omitempty"`
+    Annotations []taskwarriorAnnotation `json:"annotations,omitempty"`
+    // User defined attributes for what Taskwarrior has no attribute for
+    Notes          string `json:"todo_description,omitempty"` // Task.Description
+    TodoParent     string `json:"todo_parent,omitempty"`      // The UUID of the parent task
+    TodoRecurrence string `json:"todo_recurrence,omitempty"`  // A recurrence recur cannot express, such as an RRULE
+    TodoInterval   int64  `json:"todo_interval,omitempty"`    // The interval of todo_recurrence, when above 1
+    TodoAnchor     string `json:"todo_anchor,omitempty"`      // Task.RecurrenceAnchor
+    TodoShift      string `json:"todo_shift,omitempty"`       // Task.RecurrenceShift
+    TodoEstimate   int64  `json:"todo_estimate,omitempty"`    // Task.Estimate in minutes
+    TodoWaitEnd    string `json:"todo_wait_end,omitempty"`    // Task.EndWaitingDate
+}
+
+// TaskwarriorFields are the task fields a Taskwarrior export holds, for ImportTasks; what
+// Taskwarrior has no attribute for is kept in user defined attributes.
+const TaskwarriorFields = AllTaskFields
+
+// taskwarriorUUIDs is a list of task UUIDs, written by Taskwarrior 2.6 and later as a JSON
+// array and by older versions as a comma-separated string.
+type taskwarriorUUIDs []string
+
+func (u *taskwarriorUUIDs) UnmarshalJSON(b []byte) error {
+    var list []string
+    if err := json.Unmarshal(b, &list); err == nil {
+        *u = list
+        return nil
+    }
+    var joined string
+    if err := json.Unmarshal(b, &joined); err != nil {
+        return fmt.Errorf("depends must be a list of UUIDs: %w", err)
+    }
+    *u = splitNames(joined)
+    return nil
+}
+
+type taskwarriorAnnotation struct {
+    Entry       string `json:"entry"`
+    Description string `json:"description"`
+}
+
+// taskwarriorStatuses maps task statuses to Taskwarrior statuses; cancelled tasks are deleted ones.
+var taskwarriorStatuses = map[string]string{
+    "pending":   "pending",
+    "waiting":   "waiting",
+    "completed": "completed",
+    "cancelled": "deleted",
+}
+
+// taskwarriorUnits maps recurrence patterns to Taskwarrior duration units.
+var taskwarriorUnits = map[string]string{"daily": "days", "weekly": "weeks", "monthly": "months", "yearly": "years"}
+
+// taskwarriorNamedPeriods are the named recurrence periods Taskwarrior accepts besides "<n><unit>".
+var taskwarriorNamedPeriods = map[string]struct {
+    recurrence string
+    interval   int64
+}{
+    "daily": {"daily", 1}, "day": {"daily", 1},
+    "weekly": {"weekly", 1}, "week": {"weekly", 1}, "biweekly": {"weekly", 2}, "fortnight": {"weekly", 2},
+    "monthly": {"monthly", 1}, "month": {"monthly", 1}, "bimonthly": {"monthly", 2},
+    "quarterly": {"monthly", 3}, "semiannual": {"monthly", 6},
+    "yearly": {"yearly", 1}, "year": {"yearly", 1}, "annual": {"yearly", 1}, "biannual": {"yearly", 2}, "biyearly": {"yearly", 2},
+    "weekdays": {"workdaily", 1},
+}
+
+var taskwarriorPeriod = regexp.MustCompile(`^(\d*)\s*(d|days?|w|wks?|weeks?|mo|mos|months?|q|qtrs?|quarters?|y|yrs?|years?)$`)
+
+// WriteTasksTaskwarrior writes tasks as a JSON array accepted by 'task import'.
+//
+// Every task must have a UUID (see AssignTaskUUIDs) so that Taskwarrior updates the same
+// tasks on a repeated import. The start date becomes entry, the start of the waiting period
+// wait, notes annotations, and contexts tags prefixed with '@'. The description is kept in
+// the todo_description user defined attribute, since Taskwarrior uses 'description' for the title.
+// Dependencies become depends, and the parent of a subtask the todo_parent attribute; links to
+// tasks that are not exported are left out. Recurrences recur cannot express, such as rules and
+// workdaily with an interval, are kept in todo_recurrence and todo_interval, and the anchor,
+// shift policy, estimate and end of the waiting period in todo_anchor, todo_shift,
+// todo_estimate and todo_wait_end.
+func WriteTasksTaskwarrior(w io.Writer, tasks []Task) error {
+    now := time.Now().UTC().Format(taskwarriorTime)
+    uuids := map[int64]string{}
+    for _, task := range tasks {
+        uuids[task.ID] = task.UUID.String
+    }
+    out := make([]taskwarriorTask, 0, len(tasks))
+    for _, task := range tasks {
+        if !task.UUID.Valid || task.UUID.String == "" {
+            return fmt.Errorf("%w: task %d has no UUID", ErrInvalidInput, task.ID)
+        }
+        tw := taskwarriorTask{
+            UUID:         task.UUID.String,
+            Description:  task.Title,
+            Status:       taskwarriorStatuses[task.Status],
+            Entry:        formatTaskwarriorTime(task.StartDate),
+            Modified:     now,
+            Due:          formatTaskwarriorTime(task.DueDate),
+            Wait:         formatTaskwarriorTime(task.StartWaitingDate),
+            End:          formatTaskwarriorTime(task.EndDate),
+            Project:      task.ProjectName.String,
+            Priority:     task.Priority.String,
+            Notes:        task.Description.String,
+            TodoAnchor:   task.RecurrenceAnchor.String,
+            TodoShift:    task.RecurrenceShift.String,
+            TodoEstimate: task.Estimate.Int64,
+            TodoWaitEnd:  formatTaskwarriorTime(task.EndWaitingDate),
+        }
+        if tw.Entry == "" {
+            tw.Entry = now
+        }
+        // Taskwarrior requires an end date on completed and deleted tasks
+        if (task.Status == "completed" || task.Status == "cancelled") && tw.End == "" {
+            tw.End = now
+        }
+        tw.Tags = append(tw.Tags, task.Tags...)
+        for _, c := range task.Contexts {
+            tw.Tags = append(tw.Tags, "@"+c)
+        }
+        if task.Recurrence.String == "workdaily" && task.RecurrenceInterval.Int64 <= 1 {
+            tw.Recur = "weekdays"
+        } else if unit, ok := taskwarriorUnits[task.Recurrence.String]; ok {
+            if task.RecurrenceInterval.Valid && task.RecurrenceInterval.Int64 > 1 {
+                tw.Recur = fmt.Sprintf("%d%s", task.RecurrenceInterval.Int64, unit)
+            } else {
+                tw.Recur = task.Recurrence.String
+            }
+        } else if task.Recurrence.String != "" {
+            tw.TodoRecurrence = task.Recurrence.String
+            if task.RecurrenceInterval.Int64 > 1 {
+                tw.TodoInterval = task.RecurrenceInterval.Int64
+            }
+        }
+        for _, dep := range task.DependsOn {
+            if uuid := uuids[dep]; uuid != "" {
+                tw.Depends = append(tw.Depends, uuid)
+            }
+        }
+        if task.ParentID.Valid {
+            tw.TodoParent = uuids[task.ParentID.Int64]
+        }
+        for _, n := range task.Notes {
+            tw.Annotations = append(tw.Annotations, taskwarriorAnnotation{Entry: formatTaskwarriorTime(n.Timestamp), Description: n.Description.String})
+        }
+        out = append(out, tw)
+    }
+
+    enc := json.NewEncoder(w)
+    enc.SetIndent("", "  ")
+    return enc.Encode(out)
+}
+
+func formatTaskwarriorTime(nt NullableTime) string {
+    if !nt.Valid {
+        return ""
+    }
+    return nt.Time.UTC().Format(taskwarriorTime)
+}
+
+// ReadTasksTaskwarrior reads the output of 'task export', either a JSON array or one
+// JSON object per line as written by older Taskwarrior versions, ready for ImportTasks.
+//
+// UUIDs are kept, so importing the same tasks again updates them. Tags starting with '@'
+// become contexts, deleted tasks become cancelled ones, and a wait date puts a pending
+// task in waiting status until then, unless todo_wait_end ends the waiting period. Recurring templates are only imported when none of
+// their instances are part of the export; recurrence periods that cannot be represented
+// are dropped with a warning, unless the todo_recurrence attribute written by
+// WriteTasksTaskwarrior holds the recurrence. Dependencies and parents are linked to the tasks
+// of the export with that UUID; links to tasks missing from it are dropped with a warning.
+func ReadTasksTaskwarrior(r io.Reader) ([]Task, []string, error) {
+    records, err := decodeTaskwarrior(r)
+    if err != nil {
+        return nil, nil, err
+    }
+
+    parents := map[string]bool{}
+    for _, tw := range records {
+        if tw.Parent != "" {
+            parents[tw.Parent] = true
+        }
+    }
+
+    var p problems
+    var warnings []string
+    tasks := []Task{}
+    links := []taskwarriorTask{} // The record of every task, to link them once all have an ID
+    for i, tw := range records {
+        at := fmt.Sprintf("task %d", i+1)
+        if tw.UUID != "" {
+            at = "task " + tw.UUID
+        }
+        if tw.Status == "recurring" && parents[tw.UUID] {
+            continue // Its instances are imported instead
+        }
+
+        task := Task{
+            ID:               int64(len(tasks) + 1), // Only used to link dependencies and parents
+            Title:            tw.Description,
+            Description:      nullString(tw.Notes),
+            ProjectName:      nullString(tw.Project),
+            UUID:             nullString(tw.UUID),
+            StartDate:        p.taskwarriorTime(at, "entry", tw.Entry),
+            DueDate:          p.taskwarriorTime(at, "due", tw.Due),
+            EndDate:          p.taskwarriorTime(at, "end", tw.End),
+            StartWaitingDate: p.taskwarriorTime(at, "wait", tw.Wait),
+            EndWaitingDate:   p.taskwarriorTime(at, "todo_wait_end", tw.TodoWaitEnd),
+            RecurrenceAnchor: nullString(normalizeAnchor(tw.TodoAnchor)),
+            RecurrenceShift:  nullString(tw.TodoShift),
+            Contexts:         []string{},
+            Tags:             []string{},
+        }
+        for _, tag := range tw.Tags {
+            if len(tag) > 1 && tag[0] == '@' {
+                task.Contexts = append(task.Contexts, tag[1:])
+            } else {
+                task.Tags = append(task.Tags, tag)
+            }
+        }
+        for _, a := range tw.Annotations {
+            task.Notes = append(task.Notes, Note{
+                Timestamp:   p.taskwarriorTime(at, "annotation entry", a.Entry),
+                Description: sql.NullString{String: a.Description, Valid: true},
+            })
+        }
+
+        switch tw.Status {
+        case "completed":
+            task.Status = "completed"
+        case "deleted":
+            task.Status = "cancelled"
+        case "waiting":
+            task.Status = "waiting"
+        case "pending", "recurring", "":
+            task.Status = "pending"
+            // As for new tasks, a waiting period with an end leaves the task pending
+            if task.StartWaitingDate.Valid && task.StartWaitingDate.Time.After(time.Now()) && !task.EndWaitingDate.Valid {
+                task.Status = "waiting"
+            }
+        default:
+            p.add("%s: unknown status '%s'", at, tw.Status)
+        }
+
+        if priority := normalizePriority(tw.Priority); contains(validPriorities, priority) {
+            task.Priority = nullString(priority)
+        } else if priority != "" {
+            warnings = append(warnings, fmt.Sprintf("%s: priority '%s' is not supported, dropped", at, tw.Priority))
+        }
+
+        if tw.TodoEstimate != 0 {
+            task.Estimate = sql.NullInt64{Int64: tw.TodoEstimate, Valid: true}
+        }
+        if tw.TodoRecurrence != "" {
+            interval := tw.TodoInterval
+            if interval < 1 {
+                interval = 1
+            }
+            task.Recurrence = sql.NullString{String: normalizeRecurrence(tw.TodoRecurrence), Valid: true}
+            task.RecurrenceInterval = sql.NullInt64{Int64: interval, Valid: true}
+        } else if tw.Recur != "" {
+            recurrence, interval, ok := parseTaskwarriorPeriod(tw.Recur)
+            if ok {
+                task.Recurrence = sql.NullString{String: recurrence, Valid: true}
+                task.RecurrenceInterval = sql.NullInt64{Int64: interval, Valid: true}
+            } else {
+                warnings = append(warnings, fmt.Sprintf("%s: recurrence '%s' is not supported, dropped", at, tw.Recur))
+            }
+        }
+        tasks = append(tasks, task)
+        links = append(links, tw)
+    }
+
+    ids := map[string]int64{}
+    for _, task := range tasks {
+        if task.UUID.Valid {
+            ids[task.UUID.String] = task.ID
+        }
+    }
+    for i, tw := range links {
+        tasks[i].DependsOn = []int64{}
+        for _, dep := range tw.Depends {
+            if id, ok := ids[dep]; ok {
+                tasks[i].DependsOn = append(tasks[i].DependsOn, id)
+            } else {
+                warnings = append(warnings, fmt.Sprintf("task %s: dependency on task %s is not part of the import, dropped", tw.UUID, dep))
+            }
+        }
+        if tw.TodoParent == "" {
+            continue
+        }
+        if id, ok := ids[tw.TodoParent]; ok {
+            tasks[i].ParentID = sql.NullInt64{Int64: id, Valid: true}
+        } else {
+            warnings = append(warnings, fmt.Sprintf("task %s: parent task %s is not part of the import, link dropped", tw.UUID, tw.TodoParent))
+        }
+    }
+    return tasks, warnings, p.err()
+}
+
+// decodeTaskwarrior decodes a JSON array of tasks, or a stream of JSON task objects.
+func decodeTaskwarrior(r io.Reader) ([]taskwarriorTask, error) {
+    br := bufio.NewReader(r)
+    for {
+        b, err := br.Peek(1)
+        if err == io.EOF {
+            return []taskwarriorTask{}, nil
+        } else if err != nil {
+            return nil, fmt.Errorf("error reading tasks: %w", err)
+        }
+        if !strings.ContainsRune(" \t\r\n", rune(b[0])) {
+            break
+        }
+        br.ReadByte()
+    }
+
+    dec := json.NewDecoder(br)
+    records := []taskwarriorTask{}
+    if b, _ := br.Peek(1); b[0] == '[' {
+        if err := dec.Decode(&records); err != nil {
+            return nil, fmt.Errorf("%w: invalid Taskwarrior JSON: %v", ErrInvalidInput, err)
+        }
+        return records, nil
+    }
+    for {
+        var tw taskwarriorTask
+        if err := dec.Decode(&tw); err == io.EOF {
+            return records, nil
+        } else if err != nil {
+            return nil, fmt.Errorf("%w: invalid Taskwarrior JSON: %v", ErrInvalidInput, err)
+        }
+        records = append(records, tw)
+    }
+}
+
+// parseTaskwarriorPeriod translates a Taskwarrior recurrence period such as "weekly",
+// "biweekly" or "3days" into a recurrence pattern and interval.
+func parseTaskwarriorPeriod(period string) (string, int64, bool) {
+    period = strings.ToLower(strings.TrimSpace(period))
+    if named, ok := taskwarriorNamedPeriods[period]; ok {
+        return named.recurrence, named.interval, true
+    }
+    m := taskwarriorPeriod.FindStringSubmatch(period)
+    if m == nil {
+        return "", 0, false
+    }
+    n := int64(1)
+    if m[1] != "" {
+        n, _ = strconv.ParseInt(m[1], 10, 64)
+    }
+    if n <= 0 {
+        return "", 0, false
+    }
+    switch m[2][0] {
+    case 'd':
+        return "daily", n, true
+    case 'w':
+        return "weekly", n, true
+    case 'm':
+        return "monthly", n, true
+    case 'q':
+        return "monthly", 3 * n, true
+    default:
+        return "yearly", n, true
+    }
+}
+
+// taskwarriorTime parses a Taskwarrior timestamp (YYYYMMDDTHHMMSSZ, or RFC 3339 as a fallback).
+func (p *problems) taskwarriorTime(at, field, value string) NullableTime {
+    if value == "" {
+        return NullableTime{}
+    }
+    for _, layout := range []string{taskwarriorTime, time.RFC3339} {
+        if t, err := time.Parse(layout, value); err == nil {
+            return NullableTime{Time: t.UTC(), Valid: true}
+        }
+    }
+    *p = append(*p, &DateError{Field: at + " " + field, Value: value, Err: fmt.Errorf("expected YYYYMMDDTHHMMSSZ")})
+    return NullableTime{}
+}
@@ -0,0 +1,181 @@
+package todo
+
+import (
+    "bytes"
+    "database/sql"
+    "errors"
+    "fmt"
+    "strings"
+    "testing"
+)
+
+func TestTaskwarriorRoundTrip(t *testing.T) {
+    at := func(value string) NullableTime {
+        return NullableTime{Time: localTime(t, value).UTC(), Valid: true}
+    }
+    interval := func(n int64) sql.NullInt64 { return sql.NullInt64{Int64: n, Valid: true} }
+    tests := []struct {
+        name string
+        task Task
+    }{
+        {
+            name: "open task",
+            task: Task{Title: "call plumber", Description: nullString("about the leak"), Status: "pending", ProjectName: nullString("home"),
+                Priority: nullString("H"), StartDate: at("2026-03-01 08:00"), DueDate: at("2026-03-04 17:00"),
+                Contexts: []string{"phone"}, Tags: []string{"urgent"}, Estimate: interval(90)},
+        },
+        {
+            name: "cancelled task",
+            task: Task{Title: "call plumber", Status: "cancelled", StartDate: at("2026-03-01 08:00"), EndDate: at("2026-03-02 10:00")},
+        },
+        {
+            name: "waiting task",
+            task: Task{Title: "get quote", Status: "waiting", StartDate: at("2026-03-01 08:00"), StartWaitingDate: at("2099-03-02 09:00"),
+                EndWaitingDate: at("2099-03-09 09:00")},
+        },
+        {
+            name: "period",
+            task: Task{Title: "water plants", Status: "pending", StartDate: at("2026-03-01 08:00"), Recurrence: nullString("weekly"),
+                RecurrenceInterval: interval(2), RecurrenceAnchor: nullString(AnchorCompletion)},
+        },
+        {
+            name: "working days",
+            task: Task{Title: "check backups", Status: "pending", StartDate: at("2026-03-01 08:00"), Recurrence: nullString("workdaily"),
+                RecurrenceInterval: interval(1), RecurrenceShift: nullString("next")},
+        },
+        {
+            name: "working days with an interval",
+            task: Task{Title: "check backups", Status: "pending", StartDate: at("2026-03-01 08:00"), Recurrence: nullString("workdaily"),
+                RecurrenceInterval: interval(3)},
+        },
+        {
+            name: "rule",
+            task: Task{Title: "pay rent", Status: "pending", StartDate: at("2026-03-01 08:00"), Recurrence: nullString("FREQ=MONTHLY;BYDAY=-1FR"),
+                RecurrenceInterval: interval(1), RecurrenceShift: nullString("previous")},
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            task := tt.task
+            task.ID = 1
+            task.UUID = nullString("0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10")
+            var buf bytes.Buffer
+            if err := WriteTasksTaskwarrior(&buf, []Task{task}); err != nil {
+                t.Fatal(err)
+            }
+            tasks, warnings, err := ReadTasksTaskwarrior(&buf)
+            if err != nil || len(warnings) != 0 {
+                t.Fatalf("got %v, warnings %v", err, warnings)
+            }
+            if len(tasks) != 1 {
+                t.Fatalf("got %d tasks", len(tasks))
+            }
+            if got, want := transferSummary(tasks[0]), transferSummary(task); got != want {
+                t.Errorf("got  %s\nwant %s", got, want)
+            }
+        })
+    }
+}
+
+func TestReadTasksTaskwarrior(t *testing.T) {
+    tests := []struct {
+        name     string
+        json     string
+        warnings int
+        check    func(t *testing.T, tasks []Task)
+    }{
+        {
+            name: "one object per line",
+            json: `{"uuid":"0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10","description":"a","status":"pending","tags":["@phone","home"]}
+{"uuid":"1b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10","description":"b","status":"deleted","depends":"0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10"}`,
+            check: func(t *testing.T, tasks []Task) {
+                if len(tasks) != 2 || tasks[0].Contexts[0] != "phone" || tasks[0].Tags[0] != "home" {
+                    t.Fatalf("got %+v", tasks)
+                }
+                if tasks[1].Status != "cancelled" || len(tasks[1].DependsOn) != 1 || tasks[1].DependsOn[0] != tasks[0].ID {
+                    t.Errorf("got status %s and dependencies %v", tasks[1].Status, tasks[1].DependsOn)
+                }
+            },
+        },
+        {
+            name: "template with instances",
+            json: `[{"uuid":"0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10","description":"a","status":"recurring","recur":"biweekly"},
+{"uuid":"1b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10","description":"a","status":"pending","recur":"biweekly","parent":"0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10"}]`,
+            check: func(t *testing.T, tasks []Task) {
+                if len(tasks) != 1 || tasks[0].Recurrence.String != "weekly" || tasks[0].RecurrenceInterval.Int64 != 2 {
+                    t.Errorf("got %+v", tasks)
+                }
+            },
+        },
+        {
+            name: "periods",
+            json: `[{"description":"a","recur":"quarterly"},{"description":"b","recur":"3d"},{"description":"c","recur":"weekdays"}]`,
+            check: func(t *testing.T, tasks []Task) {
+                got := []string{}
+                for _, task := range tasks {
+                    got = append(got, task.Recurrence.String+fmt.Sprintf("/%d", task.RecurrenceInterval.Int64))
+                }
+                if strings.Join(got, " ") != "monthly/3 daily/3 workdaily/1" {
+                    t.Errorf("got %v", got)
+                }
+            },
+        },
+        {
+            name:     "dropped",
+            json:     `[{"description":"a","recur":"2h","priority":"X","depends":["0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10"]}]`,
+            warnings: 3,
+        },
+        {
+            name: "empty",
+            json: " \n",
+            check: func(t *testing.T, tasks []Task) {
+                if len(tasks) != 0 {
+                    t.Errorf("got %+v", tasks)
+                }
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tasks, warnings, err := ReadTasksTaskwarrior(strings.NewReader(tt.json))
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(warnings) != tt.warnings {
+                t.Errorf("got warnings %v, want %d", warnings, tt.warnings)
+            }
+            if tt.check != nil {
+                tt.check(t, tasks)
+            }
+        })
+    }
+}
+
+func TestReadTasksTaskwarriorErrors(t *testing.T) {
+    tests := []struct {
+        name string
+        json string
+        date bool
+    }{
+        {"invalid JSON", `[{"description":}]`, false},
+        {"unknown status", `[{"description":"a","status":"done"}]`, false},
+        {"bad date", `[{"description":"a","due":"tomorrow"}]`, true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            _, _, err := ReadTasksTaskwarrior(strings.NewReader(tt.json))
+            if !errors.Is(err, ErrInvalidInput) {
+                t.Fatalf("got %v, want ErrInvalidInput", err)
+            }
+            if errors.Is(err, ErrInvalidDate) != tt.date {
+                t.Errorf("%v matches ErrInvalidDate: %v, want %v", err, !tt.date, tt.date)
+            }
+        })
+    }
+}
+
+func TestWriteTasksTaskwarriorRequiresUUID(t *testing.T) {
+    if err := WriteTasksTaskwarrior(&bytes.Buffer{}, []Task{{ID: 1, Title: "a"}}); !errors.Is(err, ErrInvalidInput) {
+        t.Errorf("got %v, want ErrInvalidInput", err)
+    }
+}
@@ -0,0 +1,238 @@
+package todo
+
+import (
+    "database/sql"
+    "fmt"
+    "time"
+)
+
+// Time tracking records the effort spent on a task as time entries, apart from its start and
+// end dates, which only give the elapsed time. An entry is either a timer, started and stopped
+// on the task, or a duration logged afterwards. Only one timer can run at a time.
+
+// TimeEntry is an interval of work on a task.
+type TimeEntry struct {
+    ID     int64
+    TaskID int64
+    Start  NullableTime
+    End    NullableTime // not valid while the timer is running
+}
+
+// Duration returns the length of the entry; a running timer counts up to now.
+func (e TimeEntry) Duration(now time.Time) time.Duration {
+    end := now
+    if e.End.Valid {
+        end = e.End.Time
+    }
+    if end.Before(e.Start.Time) {
+        return 0
+    }
+    return end.Sub(e.Start.Time)
+}
+
+// StartTimer starts a timer on an open task. It fails with ErrTimerRunning while another
+// timer is running, on this task or another one.
+func (tm *TodoManager) StartTimer(taskID int64) (TimeEntry, error) {
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return TimeEntry{}, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    task, err := getTask(tx, taskID)
+    if err != nil {
+        return TimeEntry{}, err
+    }
+    if !isOpen(task.Status) {
+        return TimeEntry{}, fmt.Errorf("%w: task %d is %s", ErrInvalidInput, taskID, task.Status)
+    }
+    running, err := runningTimer(tx)
+    if err != nil {
+        return TimeEntry{}, err
+    }
+    if running != nil {
+        return TimeEntry{}, fmt.Errorf("%w on task %d since %s", ErrTimerRunning, running.TaskID, running.Start.Time.Local().Format("2006-01-02 15:04"))
+    }
+
+    entry := TimeEntry{TaskID: taskID, Start: NullableTime{Time: time.Now().UTC(), Valid: true}}
+    if entry.ID, err = insertTimeEntry(tx, entry); err != nil {
+        return TimeEntry{}, err
+    }
+    if err := tx.Commit(); err != nil {
+        return TimeEntry{}, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return entry, nil
+}
+
+// StopTimer stops the running timer and returns its entry. It fails with ErrNoTimerRunning
+// when no timer is running.
+func (tm *TodoManager) StopTimer() (TimeEntry, error) {
+    running, err := runningTimer(tm.db)
+    if err != nil {
+        return TimeEntry{}, err
+    }
+    if running == nil {
+        return TimeEntry{}, ErrNoTimerRunning
+    }
+    running.End = NullableTime{Time: MaxTime(time.Now().UTC(), running.Start.Time), Valid: true}
+    sqlEnd, _ := running.End.Value()
+    if _, err := tm.db.Exec("UPDATE time_entries SET end_time = ? WHERE id = ?", sqlEnd, running.ID); err != nil {
+        return TimeEntry{}, fmt.Errorf("error stopping timer of task %d: %w", running.TaskID, err)
+    }
+    return *running, nil
+}
+
+// LogTime records a duration of work on a task, done after the timer was forgotten or away
+// from the computer. The entry starts at start, or ends now when start is empty.
+func (tm *TodoManager) LogTime(taskID int64, d time.Duration, start string) (TimeEntry, error) {
+    if d <= 0 {
+        return TimeEntry{}, fmt.Errorf("%w: logged time must be positive, got %s", ErrInvalidInput, d)
+    }
+    entry := TimeEntry{TaskID: taskID}
+    if start == "" {
+        end := time.Now().UTC()
+        entry.Start = NullableTime{Time: end.Add(-d), Valid: true}
+        entry.End = NullableTime{Time: end, Valid: true}
+    } else {
+        var err error
+        if entry.Start, err = resolveDate("start time", start); err != nil {
+            return TimeEntry{}, err
+        }
+        entry.End = NullableTime{Time: entry.Start.Time.Add(d), Valid: true}
+    }
+
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return TimeEntry{}, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    if _, err := getTask(tx, taskID); err != nil {
+        return TimeEntry{}, err
+    }
+    if entry.ID, err = insertTimeEntry(tx, entry); err != nil {
+        return TimeEntry{}, err
+    }
+    if err := tx.Commit(); err != nil {
+        return TimeEntry{}, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return entry, nil
+}
+
+// RunningTimer returns the entry of the running timer, or nil when no timer is running.
+func (tm *TodoManager) RunningTimer() (*TimeEntry, error) {
+    return runningTimer(tm.db)
+}
+
+func runningTimer(q queryer) (*TimeEntry, error) {
+    var e TimeEntry
+    err := q.QueryRow("SELECT id, task_id, start_time, end_time FROM time_entries WHERE end_time IS NULL").Scan(&e.ID, &e.TaskID, &e.Start, &e.End)
+    if err == sql.ErrNoRows {
+        return nil, nil
+    } else if err != nil {
+        return nil, fmt.Errorf("error looking up running timer: %w", err)
+    }
+    return &e, nil
+}
+
+func insertTimeEntry(tx *sql.Tx, e TimeEntry) (int64, error) {
+    sqlStart, _ := e.Start.Value()
+    sqlEnd, _ := e.End.Value()
+    res, err := tx.Exec("INSERT INTO time_entries (task_id, start_time, end_time) VALUES (?, ?, ?)", e.TaskID, sqlStart, sqlEnd)
+    if err != nil {
+        return 0, fmt.Errorf("error adding time entry to task %d: %w", e.TaskID, wrapDBError(err))
+    }
+    return res.LastInsertId()
+}
+
+// GetTimeEntries fetches the time entries of a task, oldest first.
+func (tm *TodoManager) GetTimeEntries(taskID int64) ([]TimeEntry, error) {
+    return getTimeEntries(tm.db, taskID)
+}
+
+func getTimeEntries(q queryer, taskID int64) ([]TimeEntry, error) {
+    rows, err := q.Query("SELECT id, task_id, start_time, end_time FROM time_entries WHERE task_id = ? ORDER BY start_time, id", taskID)
+    if err != nil {
+        return nil, fmt.Errorf("error querying time entries of task %d: %w", taskID, err)
+    }
+    defer rows.Close()
+
+    entries := []TimeEntry{}
+    for rows.Next() {
+        var e TimeEntry
+        if err := rows.Scan(&e.ID, &e.TaskID, &e.Start, &e.End); err != nil {
+            return nil, fmt.Errorf("error scanning time entry: %w", err)
+        }
+        entries = append(entries, e)
+    }
+    return entries, rows.Err()
+}
+
+// PomodoroNote returns the note added to a task when its nth pomodoro is completed. Pomodoros
+// are numbered over the whole life of the task, not per focus session.
+func PomodoroNote(n int) string {
+    return fmt.Sprintf("Pomodoro %d completed", n)
+}
+
+// CompletedPomodoros returns the number of the last pomodoro completed on a task, as found in
+// its pomodoro notes, or 0 when there is none.
+func (tm *TodoManager) CompletedPomodoros(taskID int64) (int, error) {
+    rows, err := tm.db.Query("SELECT description FROM task_notes WHERE task_id = ? AND description LIKE 'Pomodoro % completed'", taskID)
+    if err != nil {
+        return 0, fmt.Errorf("error querying notes of task %d: %w", taskID, err)
+    }
+    defer rows.Close()
+
+    last := 0
+    for rows.Next() {
+        var description string
+        if err := rows.Scan(&description); err != nil {
+            return 0, fmt.Errorf("error scanning note: %w", err)
+        }
+        var n int
+        if _, err := fmt.Sscanf(description, "Pomodoro %d completed", &n); err == nil && description == PomodoroNote(n) && n > last {
+            last = n
+        }
+    }
+    return last, rows.Err()
+}
+
+// trackedTime sums up the time entries of a task and reports whether its timer is running.
+func trackedTime(q queryer, taskID int64, now time.Time) (time.Duration, bool, error) {
+    entries, err := getTimeEntries(q, taskID)
+    if err != nil {
+        return 0, false, err
+    }
+    var total time.Duration
+    running := false
+    for _, e := range entries {
+        total += e.Duration(now)
+        running = running || !e.End.Valid
+    }
+    return total, running, nil
+}
+
+// TrackedWorkingTime sums up the time entries of a task that fall within working hours,
+// leaving out time tracked outside them and on holidays. Breaks are not subtracted, since
+// the time of day they are taken is unknown.
+func (tm *TodoManager) TrackedWorkingTime(taskID int64, workingHours map[time.Weekday]WorkingHours, holidays map[string]Holiday) (time.Duration, error) {
+    entries, err := getTimeEntries(tm.db, taskID)
+    if err != nil {
+        return 0, err
+    }
+    withoutBreaks := make(map[time.Weekday]WorkingHours, len(workingHours))
+    for day, wh := range workingHours {
+        wh.BreakMinutes = 0
+        withoutBreaks[day] = wh
+    }
+    now := NullableTime{Time: time.Now().UTC(), Valid: true}
+    var total time.Duration
+    for _, e := range entries {
+        end := e.End
+        if !end.Valid {
+            end = now
+        }
+        total += CalculateWorkingHoursDuration(tm.db, e.Start, end, withoutBreaks, holidays)
+    }
+    return total, nil
+}
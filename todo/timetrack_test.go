@@ -0,0 +1,213 @@
+package todo
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestCompletedPomodoros(t *testing.T) {
+    tests := []struct {
+        name  string
+        notes []string
+        want  int
+    }{
+        {"no notes", nil, 0},
+        {"other notes", []string{"asked for a quote", "Pomodoro done"}, 0},
+        {"one session", []string{PomodoroNote(1), PomodoroNote(2)}, 2},
+        {"a note deleted", []string{PomodoroNote(1), PomodoroNote(3)}, 3},
+        {"not a pomodoro note", []string{PomodoroNote(1), "Pomodoro 7 completed twice"}, 1},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            for _, title := range []string{"write report", "call plumber"} {
+                if _, err := tm.AddTask(TaskInput{Title: title}); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            for _, note := range tt.notes {
+                if _, err := tm.AddNoteToTask(1, note, "", false); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            // Pomodoros of other tasks do not count
+            if _, err := tm.AddNoteToTask(2, PomodoroNote(5), "", false); err != nil {
+                t.Fatal(err)
+            }
+            got, err := tm.CompletedPomodoros(1)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if got != tt.want {
+                t.Errorf("got %d, want %d", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestTimeEntryDuration(t *testing.T) {
+    at := func(value string) NullableTime { return NullableTime{Time: localTime(t, value).UTC(), Valid: true} }
+    now := localTime(t, "2026-03-04 12:00")
+    tests := []struct {
+        name  string
+        entry TimeEntry
+        want  time.Duration
+    }{
+        {"stopped", TimeEntry{Start: at("2026-03-04 09:00"), End: at("2026-03-04 10:30")}, 90 * time.Minute},
+        {"running", TimeEntry{Start: at("2026-03-04 11:15")}, 45 * time.Minute},
+        {"started later", TimeEntry{Start: at("2026-03-04 13:00")}, 0},
+    }
+    for _, tt := range tests {
+        if got := tt.entry.Duration(now); got != tt.want {
+            t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestTimers(t *testing.T) {
+    // Each step starts a timer on a task, or stops the running timer for task 0
+    type step struct {
+        task int64
+        err  error
+    }
+    tests := []struct {
+        name    string
+        steps   []step
+        running int64 // task of the timer running afterwards
+        entries int   // of task 1
+    }{
+        {"start", []step{{1, nil}}, 1, 1},
+        {"start and stop", []step{{1, nil}, {0, nil}}, 0, 1},
+        {"start twice", []step{{1, nil}, {1, ErrTimerRunning}}, 1, 1},
+        {"start on another task", []step{{1, nil}, {2, ErrTimerRunning}}, 1, 1},
+        {"switch tasks", []step{{1, nil}, {0, nil}, {2, nil}}, 2, 1},
+        {"stop without a timer", []step{{0, ErrNoTimerRunning}}, 0, 0},
+        {"completed task", []step{{3, ErrInvalidInput}}, 0, 0},
+        {"unknown task", []step{{9, ErrTaskNotFound}}, 0, 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            for _, in := range []TaskInput{{Title: "write report"}, {Title: "call plumber"}, {Title: "file taxes", Status: "completed"}} {
+                if _, err := tm.AddTask(in); err != nil {
+                    t.Fatal(err)
+                }
+            }
+            for _, s := range tt.steps {
+                var err error
+                if s.task == 0 {
+                    _, err = tm.StopTimer()
+                } else {
+                    _, err = tm.StartTimer(s.task)
+                }
+                if !errors.Is(err, s.err) {
+                    t.Fatalf("task %d: got %v, want %v", s.task, err, s.err)
+                }
+            }
+            running, err := tm.RunningTimer()
+            if err != nil {
+                t.Fatal(err)
+            }
+            got := int64(0)
+            if running != nil {
+                got = running.TaskID
+            }
+            if got != tt.running {
+                t.Errorf("timer running on task %d, want %d", got, tt.running)
+            }
+            task := trackedTask(t, tm, 1)
+            entries, err := tm.GetTimeEntries(1)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(entries) != tt.entries || task.TimerRunning != (tt.running == 1) {
+                t.Errorf("task 1 has %d entries and timer running %v", len(entries), task.TimerRunning)
+            }
+        })
+    }
+}
+
+func TestLogTime(t *testing.T) {
+    tests := []struct {
+        name     string
+        duration time.Duration
+        start    string
+        err      error
+        want     string // start of the entry, if given
+    }{
+        {"ending now", 90 * time.Minute, "", nil, ""},
+        {"from a start time", 90 * time.Minute, "2026-03-04 09:00:00", nil, "2026-03-04 09:00"},
+        {"no time", 0, "", ErrInvalidInput, ""},
+        {"negative", -time.Hour, "", ErrInvalidInput, ""},
+        {"bad start", time.Hour, "after lunch", ErrInvalidDate, ""},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            if _, err := tm.AddTask(TaskInput{Title: "write report"}); err != nil {
+                t.Fatal(err)
+            }
+            entry, err := tm.LogTime(1, tt.duration, tt.start)
+            if !errors.Is(err, tt.err) {
+                t.Fatalf("got %v, want %v", err, tt.err)
+            }
+            task := trackedTask(t, tm, 1)
+            if tt.err != nil {
+                if task.Tracked != 0 {
+                    t.Errorf("tracked %s after a failed log", task.Tracked)
+                }
+                return
+            }
+            if task.Tracked != tt.duration || task.TimerRunning {
+                t.Errorf("tracked %s with timer running %v, want %s", task.Tracked, task.TimerRunning, tt.duration)
+            }
+            if tt.want != "" && !entry.Start.Time.Equal(localTime(t, tt.want)) {
+                t.Errorf("entry starts at %s, want %s", entry.Start.Time.Local(), tt.want)
+            }
+        })
+    }
+}
+
+func TestTrackedWorkingTime(t *testing.T) {
+    tm := newTestManager(t)
+    if _, err := tm.AddTask(TaskInput{Title: "write report"}); err != nil {
+        t.Fatal(err)
+    }
+    logs := []struct {
+        start    string
+        duration time.Duration
+    }{
+        {"2026-03-04 08:00:00", 3 * time.Hour}, // Wednesday, 2h of it working time
+        {"2026-03-05 16:00:00", 2 * time.Hour}, // Thursday, 1h of it working time
+        {"2026-03-07 10:00:00", 2 * time.Hour}, // Saturday
+        {"2026-03-09 10:00:00", 2 * time.Hour}, // holiday
+    }
+    for _, l := range logs {
+        if _, err := tm.LogTime(1, l.duration, l.start); err != nil {
+            t.Fatal(err)
+        }
+    }
+    workingHours := map[time.Weekday]WorkingHours{}
+    for day := time.Monday; day <= time.Friday; day++ {
+        workingHours[day] = WorkingHours{DayOfWeek: int(day), StartHour: 9, EndHour: 17, BreakMinutes: 30}
+    }
+    holidays := map[string]Holiday{"2026-03-09": {Name: "spring"}}
+    got, err := tm.TrackedWorkingTime(1, workingHours, holidays)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got != 3*time.Hour {
+        t.Errorf("got %s of working time, want 3h", got)
+    }
+}
+
+// trackedTask fetches a task the way it is listed, with its tracked time.
+func trackedTask(t *testing.T, tm *TodoManager, id int64) Task {
+    t.Helper()
+    tasks, err := tm.GetTasks(TaskFilter{IDs: []int64{id}})
+    if err != nil || len(tasks) != 1 {
+        t.Fatalf("got %d tasks, %v", len(tasks), err)
+    }
+    return tasks[0]
+}
@@ -0,0 +1,289 @@
+package todo
+
+import (
+    "bufio"
+    "database/sql"
+    "fmt"
+    "io"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// todo.txt lines look like
+//
+//    x 2024-01-05 2024-01-01 (A) Call Mom +Family @phone due:2024-01-04 rec:1w tag:urgent
+//
+// where "x <date>" marks completion, the next date is the creation date and (A) is the priority.
+// See https://github.com/todotxt/todo.txt for the format.
+
+const todoTxtDate = "2006-01-02"
+
+var (
+    todoTxtPriority  = regexp.MustCompile(`^\(([A-Z])\)$`)
+    todoTxtDateWord  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+    todoTxtKeyValue  = regexp.MustCompile(`^([^\s:]+):([^\s:/][^\s:]*)$`)
+    todoTxtRecurring = regexp.MustCompile(`^(\+?)(\d*)([dwmyb])$`)
+)
+
+// TodoTxtFields are the task fields a todo.txt file holds, for ImportTasks. Its dates have no
+// time of day.
+const TodoTxtFields = FieldProject | FieldStatus | FieldPriority | FieldStartDate | FieldDueDate | FieldEndDate |
+    FieldRecurrence | FieldRecurrenceAnchor | FieldContexts | FieldTags | FieldDatesByDay
+
+// todoTxtPriorities maps task priorities to todo.txt priorities; other letters are kept as pri: tags.
+var todoTxtPriorities = map[string]string{PriorityHigh: "A", PriorityMedium: "B", PriorityLow: "C"}
+
+// todoTxtUnits maps recurrence patterns to rec: extension units and back; b stands for business days.
+var todoTxtUnits = map[string]string{"daily": "d", "weekly": "w", "monthly": "m", "yearly": "y", "workdaily": "b"}
+
+// WriteTasksTodoTxt writes tasks in todo.txt format, one task per line.
+//
+// The project becomes +project and contexts become @context, with spaces replaced by
+// underscores. Tags are written as tag:name extensions, except tags that already look like
+// key:value, which are written as is. The H, M and L priorities become (A), (B) and (C), or a
+// pri: extension on completed tasks as todo.txt has no priority for them; without a priority,
+// a pri:A tag becomes the (A) priority of an open task. Cancelled tasks are marked done like
+// completed ones, with their end date.
+// The due date is written as due:, recurrence as rec: (e.g. rec:+2w, see formatTodoTxtRecurrence)
+// or rrule:, and the waiting and cancelled statuses, which todo.txt has no notation for, as status:. The task UUID is
+// written as uuid:, so importing the file again updates the same tasks. Descriptions and
+// notes are not part of the format and are left out.
+func WriteTasksTodoTxt(w io.Writer, tasks []Task) error {
+    bw := bufio.NewWriter(w)
+    for _, task := range tasks {
+        if _, err := fmt.Fprintln(bw, formatTodoTxt(task)); err != nil {
+            return fmt.Errorf("error writing task %d: %w", task.ID, err)
+        }
+    }
+    return bw.Flush()
+}
+
+// formatTodoTxt formats a single task as a todo.txt line.
+func formatTodoTxt(task Task) string {
+    var parts, extensions []string
+    done := task.Status == "completed" || task.Status == "cancelled"
+    priority := ""
+    if letter, ok := todoTxtPriorities[task.Priority.String]; ok {
+        if done {
+            extensions = append(extensions, "pri:"+letter)
+        } else {
+            priority = "(" + letter + ")"
+        }
+    }
+    for _, tag := range task.Tags {
+        if strings.HasPrefix(tag, "pri:") && len(tag) == 5 && !done && priority == "" {
+            priority = "(" + tag[4:] + ")"
+        } else if todoTxtKeyValue.MatchString(tag) {
+            extensions = append(extensions, tag)
+        } else {
+            extensions = append(extensions, "tag:"+todoTxtWord(tag))
+        }
+    }
+
+    if done {
+        parts = append(parts, "x")
+        if task.EndDate.Valid {
+            parts = append(parts, task.EndDate.Time.Local().Format(todoTxtDate))
+        }
+    } else if priority != "" {
+        parts = append(parts, priority)
+    }
+    if task.StartDate.Valid {
+        parts = append(parts, task.StartDate.Time.Local().Format(todoTxtDate))
+    }
+
+    parts = append(parts, strings.Fields(task.Title)...)
+    if task.ProjectName.Valid && task.ProjectName.String != "" {
+        parts = append(parts, "+"+todoTxtWord(task.ProjectName.String))
+    }
+    for _, c := range task.Contexts {
+        parts = append(parts, "@"+todoTxtWord(c))
+    }
+
+    if task.DueDate.Valid {
+        parts = append(parts, "due:"+task.DueDate.Time.Local().Format(todoTxtDate))
+    }
+    if task.Recurrence.Valid && task.Recurrence.String != "" {
+        parts = append(parts, formatTodoTxtRecurrence(task)...)
+    }
+    if task.Status == "waiting" || task.Status == "cancelled" {
+        parts = append(parts, "status:"+task.Status)
+    }
+    if task.UUID.Valid && task.UUID.String != "" {
+        parts = append(parts, "uuid:"+task.UUID.String)
+    }
+    parts = append(parts, extensions...)
+    return strings.Join(parts, " ")
+}
+
+// formatTodoTxtRecurrence formats the recurrence of a task. Plain patterns become rec:, in the
+// strict form (rec:+1w) for tasks recurring from their scheduled date and in the normal form
+// (rec:1w) for tasks recurring from completion, as todo.txt tools read them. Other rules are
+// written as an rrule: extension, followed by anchor:completion when they recur from completion.
+func formatTodoTxtRecurrence(task Task) []string {
+    rule, err := ParseRecurrence(task.Recurrence.String, int(task.RecurrenceInterval.Int64))
+    if err != nil {
+        return nil
+    }
+    completion := task.RecurrenceAnchor.String == AnchorCompletion
+    if rule.isPlain() {
+        strict := "+"
+        if completion {
+            strict = ""
+        }
+        return []string{fmt.Sprintf("rec:%s%d%s", strict, rule.Interval, todoTxtUnits[strings.ToLower(rule.Freq)])}
+    }
+    words := []string{"rrule:" + rule.String()}
+    if completion {
+        words = append(words, "anchor:"+AnchorCompletion)
+    }
+    return words
+}
+
+// todoTxtWord replaces whitespace so a name stays a single todo.txt word.
+func todoTxtWord(name string) string {
+    return strings.Join(strings.Fields(name), "_")
+}
+
+// ReadTasksTodoTxt reads tasks in todo.txt format, ready for ImportTasks.
+//
+// The first +project becomes the project (further ones stay in the title), every @context
+// becomes a context, and completion and creation dates become the end and start dates.
+// The due:, rec: (d, w, m, y and b units; strict rec:+1w recurs from the scheduled date and
+// rec:1w from completion), rrule:, anchor:, status: and uuid: extensions map onto their columns,
+// tag:name becomes a tag, the (A), (B) and (C) priorities, or pri:A to pri:C, become the H, M
+// and L priorities and other priorities pri:D tags, and any other key:value
+// extension is kept as a tag named after it. Every problem is reported at once in a ValidationError.
+func ReadTasksTodoTxt(r io.Reader) ([]Task, error) {
+    var p problems
+    tasks := []Task{}
+    scanner := bufio.NewScanner(r)
+    for line := 1; scanner.Scan(); line++ {
+        text := strings.TrimSpace(scanner.Text())
+        if text == "" {
+            continue
+        }
+        task := p.parseTodoTxt(fmt.Sprintf("line %d", line), text)
+        tasks = append(tasks, task)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("error reading tasks: %w", err)
+    }
+    return tasks, p.err()
+}
+
+// parseTodoTxt parses a single todo.txt line.
+func (p *problems) parseTodoTxt(at, text string) Task {
+    task := Task{Status: "pending", Contexts: []string{}, Tags: []string{}}
+    words := strings.Fields(text)
+
+    // Leading markers: completion, priority and dates, in that order
+    if len(words) > 0 && words[0] == "x" {
+        task.Status = "completed"
+        words = words[1:]
+        if len(words) > 0 && todoTxtDateWord.MatchString(words[0]) {
+            task.EndDate = p.todoTxtDate(at, "completion date", words[0])
+            words = words[1:]
+        }
+    }
+    if len(words) > 0 {
+        if m := todoTxtPriority.FindStringSubmatch(words[0]); m != nil {
+            setTodoTxtPriority(&task, m[1])
+            words = words[1:]
+        }
+    }
+    if len(words) > 0 && todoTxtDateWord.MatchString(words[0]) {
+        task.StartDate = p.todoTxtDate(at, "creation date", words[0])
+        words = words[1:]
+    }
+
+    var title []string
+    for _, word := range words {
+        switch {
+        case len(word) > 1 && word[0] == '+' && !task.ProjectName.Valid:
+            task.ProjectName = sql.NullString{String: word[1:], Valid: true}
+        case len(word) > 1 && word[0] == '@':
+            task.Contexts = append(task.Contexts, word[1:])
+        case todoTxtKeyValue.MatchString(word):
+            m := todoTxtKeyValue.FindStringSubmatch(word)
+            p.todoTxtExtension(at, &task, m[1], m[2])
+        default:
+            title = append(title, word)
+        }
+    }
+    task.Title = strings.Join(title, " ")
+    if task.Title == "" {
+        p.add("%s: task title is required", at)
+    }
+    return task
+}
+
+// todoTxtExtension applies a key:value extension to a task.
+func (p *problems) todoTxtExtension(at string, task *Task, key, value string) {
+    switch key {
+    case "due":
+        task.DueDate = p.todoTxtDate(at, "due date", value)
+    case "rec":
+        m := todoTxtRecurring.FindStringSubmatch(value)
+        if m == nil {
+            p.add("%s: unsupported recurrence 'rec:%s' (expected e.g. rec:1d, rec:2w, rec:1m, rec:1y, rec:5b)", at, value)
+            return
+        }
+        for pattern, unit := range todoTxtUnits {
+            if unit == m[3] {
+                task.Recurrence = sql.NullString{String: pattern, Valid: true}
+            }
+        }
+        interval := int64(1)
+        if m[2] != "" {
+            interval, _ = strconv.ParseInt(m[2], 10, 64)
+        }
+        task.RecurrenceInterval = sql.NullInt64{Int64: interval, Valid: true}
+        if m[1] == "" {
+            task.RecurrenceAnchor = nullString(AnchorCompletion) // Not strict: from the completion date
+        }
+    case "rrule":
+        rule, err := ParseRecurrence(value, 1)
+        if err != nil {
+            p.add("%s: %s", at, strings.TrimPrefix(err.Error(), ErrInvalidInput.Error()+": "))
+            return
+        }
+        task.Recurrence = sql.NullString{String: rule.String(), Valid: true}
+        task.RecurrenceInterval = sql.NullInt64{Int64: 1, Valid: true}
+    case "anchor":
+        task.RecurrenceAnchor = nullString(normalizeAnchor(value))
+    case "status":
+        task.Status = value
+    case "uuid":
+        task.UUID = nullString(value)
+    case "tag":
+        task.Tags = append(task.Tags, value)
+    case "pri":
+        setTodoTxtPriority(task, value)
+    default:
+        task.Tags = append(task.Tags, key+":"+value)
+    }
+}
+
+// setTodoTxtPriority sets the priority of a task from a todo.txt priority letter.
+func setTodoTxtPriority(task *Task, letter string) {
+    for priority, l := range todoTxtPriorities {
+        if l == letter {
+            task.Priority = nullString(priority)
+            return
+        }
+    }
+    task.Tags = append(task.Tags, "pri:"+letter)
+}
+
+// todoTxtDate parses a YYYY-MM-DD todo.txt date in local time.
+func (p *problems) todoTxtDate(at, field, value string) NullableTime {
+    t, err := time.ParseInLocation(todoTxtDate, value, time.Local)
+    if err != nil {
+        *p = append(*p, &DateError{Field: at + " " + field, Value: value, Err: err})
+        return NullableTime{}
+    }
+    return NullableTime{Time: t.UTC(), Valid: true}
+}
@@ -0,0 +1,188 @@
+package todo
+
+import (
+    "database/sql"
+    "errors"
+    "strings"
+    "testing"
+)
+
+func TestFormatTodoTxt(t *testing.T) {
+    day := func(value string) NullableTime {
+        return NullableTime{Time: localTime(t, value+" 00:00").UTC(), Valid: true}
+    }
+    tests := []struct {
+        name string
+        task Task
+        want string
+    }{
+        {
+            name: "open task",
+            task: Task{Title: "Call Mom", Status: "pending", Priority: nullString("H"), StartDate: day("2024-01-01"),
+                ProjectName: nullString("Family"), Contexts: []string{"phone"}, DueDate: day("2024-01-04"), Tags: []string{"urgent"}},
+            want: "(A) 2024-01-01 Call Mom +Family @phone due:2024-01-04 tag:urgent",
+        },
+        {
+            name: "completed task",
+            task: Task{Title: "Call Mom", Status: "completed", Priority: nullString("L"), StartDate: day("2024-01-01"), EndDate: day("2024-01-05")},
+            want: "x 2024-01-05 2024-01-01 Call Mom pri:C",
+        },
+        {
+            name: "names with spaces and statuses",
+            task: Task{Title: "Wait  for reply", Status: "waiting", ProjectName: nullString("Big house"), Contexts: []string{"at home"}, Tags: []string{"k:v"}},
+            want: "Wait for reply +Big_house @at_home status:waiting k:v",
+        },
+        {
+            name: "recurring from the scheduled date",
+            task: Task{Title: "Water plants", Status: "pending", Recurrence: nullString("weekly"), RecurrenceInterval: sql.NullInt64{Int64: 2, Valid: true}},
+            want: "Water plants rec:+2w",
+        },
+        {
+            name: "recurring from completion",
+            task: Task{Title: "Haircut", Status: "pending", Recurrence: nullString("monthly"), RecurrenceAnchor: nullString(AnchorCompletion)},
+            want: "Haircut rec:1m",
+        },
+        {
+            name: "working days",
+            task: Task{Title: "Check backups", Status: "pending", Recurrence: nullString("workdaily"), RecurrenceInterval: sql.NullInt64{Int64: 3, Valid: true}},
+            want: "Check backups rec:+3b",
+        },
+        {
+            name: "plain rule",
+            task: Task{Title: "Report", Status: "pending", Recurrence: nullString("FREQ=MONTHLY;INTERVAL=3")},
+            want: "Report rec:+3m",
+        },
+        {
+            name: "rule",
+            task: Task{Title: "Gym", Status: "pending", Recurrence: nullString("FREQ=WEEKLY;BYDAY=MO,WE"), RecurrenceAnchor: nullString(AnchorCompletion)},
+            want: "Gym rrule:FREQ=WEEKLY;BYDAY=MO,WE anchor:completion",
+        },
+        {
+            name: "uuid",
+            task: Task{Title: "Call Mom", Status: "cancelled", UUID: nullString("0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10")},
+            want: "x Call Mom status:cancelled uuid:0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := formatTodoTxt(tt.task); got != tt.want {
+                t.Errorf("got  %s\nwant %s", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestReadTasksTodoTxt(t *testing.T) {
+    tests := []struct {
+        line  string
+        check func(t *testing.T, task Task)
+    }{
+        {
+            "x 2024-01-05 2024-01-01 Call Mom +Family +Friends @phone due:2024-01-04",
+            func(t *testing.T, task Task) {
+                if task.Status != "completed" || task.Title != "Call Mom +Friends" || task.ProjectName.String != "Family" ||
+                    len(task.Contexts) != 1 || task.Contexts[0] != "phone" {
+                    t.Errorf("got %+v", task)
+                }
+                if !task.EndDate.Time.Equal(localTime(t, "2024-01-05 00:00")) || !task.StartDate.Time.Equal(localTime(t, "2024-01-01 00:00")) ||
+                    !task.DueDate.Time.Equal(localTime(t, "2024-01-04 00:00")) {
+                    t.Errorf("got dates %v, %v, %v", task.StartDate, task.DueDate, task.EndDate)
+                }
+            },
+        },
+        {
+            "(B) Plan trip tag:travel pri:A color:blue",
+            func(t *testing.T, task Task) {
+                if task.Priority.String != "H" || strings.Join(task.Tags, " ") != "travel color:blue" {
+                    t.Errorf("got priority %v and tags %v", task.Priority, task.Tags)
+                }
+            },
+        },
+        {
+            "(D) Someday",
+            func(t *testing.T, task Task) {
+                if task.Priority.Valid || len(task.Tags) != 1 || task.Tags[0] != "pri:D" {
+                    t.Errorf("got priority %v and tags %v", task.Priority, task.Tags)
+                }
+            },
+        },
+        {
+            "Water plants rec:+2w",
+            func(t *testing.T, task Task) {
+                if task.Recurrence.String != "weekly" || task.RecurrenceInterval.Int64 != 2 || task.RecurrenceAnchor.Valid {
+                    t.Errorf("got %v every %v from %v", task.Recurrence, task.RecurrenceInterval, task.RecurrenceAnchor)
+                }
+            },
+        },
+        {
+            "Haircut rec:b",
+            func(t *testing.T, task Task) {
+                if task.Recurrence.String != "workdaily" || task.RecurrenceInterval.Int64 != 1 || task.RecurrenceAnchor.String != AnchorCompletion {
+                    t.Errorf("got %v every %v from %v", task.Recurrence, task.RecurrenceInterval, task.RecurrenceAnchor)
+                }
+            },
+        },
+        {
+            "Gym rrule:freq=weekly;byday=mo,we anchor:completion",
+            func(t *testing.T, task Task) {
+                if task.Recurrence.String != "FREQ=WEEKLY;BYDAY=MO,WE" || task.RecurrenceAnchor.String != AnchorCompletion {
+                    t.Errorf("got %v from %v", task.Recurrence, task.RecurrenceAnchor)
+                }
+            },
+        },
+        {
+            "x 2024-01-05 Call Mom status:cancelled",
+            func(t *testing.T, task Task) {
+                if task.Status != "cancelled" || !task.EndDate.Time.Equal(localTime(t, "2024-01-05 00:00")) {
+                    t.Errorf("got status %s ending %v", task.Status, task.EndDate)
+                }
+            },
+        },
+        {
+            "Reply status:waiting uuid:0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10",
+            func(t *testing.T, task Task) {
+                if task.Status != "waiting" || task.UUID.String != "0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10" {
+                    t.Errorf("got status %s and UUID %v", task.Status, task.UUID)
+                }
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.line, func(t *testing.T) {
+            tasks, err := ReadTasksTodoTxt(strings.NewReader(tt.line + "\n"))
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(tasks) != 1 {
+                t.Fatalf("got %d tasks", len(tasks))
+            }
+            tt.check(t, tasks[0])
+        })
+    }
+}
+
+func TestReadTasksTodoTxtErrors(t *testing.T) {
+    tests := []struct {
+        text     string
+        problems int
+    }{
+        {"x 2024-13-01 Call Mom", 1},
+        {"Call Mom due:tomorrow", 1},
+        {"Call Mom rec:2h", 1},
+        {"Call Mom rrule:FREQ=HOURLY", 1},
+        {"+Family @phone", 1},
+        {"Call Mom due:2024-02-30\n\nWater plants rec:x", 2},
+    }
+    for _, tt := range tests {
+        t.Run(tt.text, func(t *testing.T) {
+            _, err := ReadTasksTodoTxt(strings.NewReader(tt.text))
+            var v *ValidationError
+            if !errors.As(err, &v) {
+                t.Fatalf("got %v, want a ValidationError", err)
+            }
+            if len(v.Problems) != tt.problems {
+                t.Errorf("got %d problems (%v), want %d", len(v.Problems), err, tt.problems)
+            }
+        })
+    }
+}
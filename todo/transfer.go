@@ -0,0 +1,508 @@
+package todo
+
+import (
+    "database/sql"
+    "fmt"
+    "strings"
+    "time"
+)
+
+// ImportResult reports what ImportTasks created, or would create in a dry run.
+type ImportResult struct {
+    DryRun      bool
+    Tasks       int // tasks created
+    Updated     int // existing tasks updated because their UUID matched
+    Notes       int
+    IDMap       map[int64]int64 // source task ID -> new task ID, for tasks that carried an ID
+    NewProjects []string
+    NewContexts []string
+    NewTags     []string
+    Holidays    int // holidays added by ImportHolidays
+    Skipped     int // holidays skipped because a holiday already exists on that date
+    Warnings    []string // e.g. original_task_id or parent links, or dependencies, that could not be remapped
+}
+
+// TaskFields is a set of task fields, used to tell ImportTasks which fields the format of an
+// import carries. A task updated by an import keeps the fields that are not in the set, since
+// the file could not have held them. The title is always part of an import.
+type TaskFields uint32
+
+const (
+    FieldDescription TaskFields = 1 << iota
+    FieldProject
+    FieldStatus
+    FieldPriority
+    FieldEstimate
+    FieldStartDate
+    FieldDueDate
+    FieldEndDate
+    FieldRecurrence // pattern and interval
+    FieldRecurrenceShift
+    FieldRecurrenceAnchor
+    FieldStartWaiting
+    FieldEndWaiting
+    FieldContexts
+    FieldTags
+    // FieldDatesByDay marks formats that only hold the day of a date: an imported date on the
+    // same day as the date of the updated task keeps the time of day it has.
+    FieldDatesByDay
+
+    // AllTaskFields are the fields of a complete export, such as CSV with every column.
+    AllTaskFields = FieldDescription | FieldProject | FieldStatus | FieldPriority | FieldEstimate | FieldStartDate |
+        FieldDueDate | FieldEndDate | FieldRecurrence | FieldRecurrenceShift | FieldRecurrenceAnchor | FieldStartWaiting |
+        FieldEndWaiting | FieldContexts | FieldTags
+)
+
+// ExportTasks returns every task, ordered by ID, with its contexts, tags and notes populated.
+func (tm *TodoManager) ExportTasks() ([]Task, error) {
+    return tm.GetTasks(TaskFilter{Status: "all", SortBy: "id", IncludeNotes: true})
+}
+
+// ImportTasks inserts tasks read from an export as new tasks, with their project, contexts,
+// tags and notes. Every column is taken as is: dates, status and waiting periods are not
+// recalculated, and no recurrence is triggered.
+//
+// A task whose UUID matches an existing task updates that task instead: the columns,
+// contexts and tags among fields, the fields the format of the import carries, are replaced,
+// and notes it does not have yet are added. Tasks, notes and holidays without a UUID are
+// given a new one.
+//
+// Task.ID is the task's ID in the source and is only used to remap OriginalTaskID and ParentID
+// links and DependsOn to the newly assigned IDs; links to tasks missing from the import are
+// dropped with a warning. A nil DependsOn leaves the dependencies of an updated task as they are.
+// With dryRun the import runs in a transaction that is rolled back, so the result reports
+// exactly what would be created.
+func (tm *TodoManager) ImportTasks(tasks []Task, fields TaskFields, dryRun bool) (*ImportResult, error) {
+    if err := validateImport(tasks); err != nil {
+        return nil, err
+    }
+
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    result := &ImportResult{DryRun: dryRun, IDMap: map[int64]int64{}}
+    if err := tm.importTasks(tx, tasks, fields, result); err != nil {
+        return nil, err
+    }
+    if dryRun {
+        return result, nil // Deferred Rollback discards everything
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return result, nil
+}
+
+// importTasks imports validated tasks in tx, adding what it did to result.
+func (tm *TodoManager) importTasks(tx *sql.Tx, tasks []Task, fields TaskFields, result *ImportResult) error {
+    newIDs := make([]int64, len(tasks))
+    for i, task := range tasks {
+        newID, created, err := tm.importTask(tx, task, fields, result)
+        if err != nil {
+            return fmt.Errorf("error importing task '%s': %w", task.Title, err)
+        }
+        newIDs[i] = newID
+        if task.ID != 0 {
+            result.IDMap[task.ID] = newID
+        }
+        if created {
+            result.Tasks++
+        } else {
+            result.Updated++
+        }
+    }
+
+    // Remap links to the original recurring task once every task has its new ID
+    for i, task := range tasks {
+        if !task.OriginalTaskID.Valid {
+            continue
+        }
+        originalID, ok := result.IDMap[task.OriginalTaskID.Int64]
+        if !ok {
+            result.Warnings = append(result.Warnings, fmt.Sprintf("task '%s': original task %d is not part of the import, link dropped", task.Title, task.OriginalTaskID.Int64))
+            continue
+        }
+        if _, err := tx.Exec("UPDATE tasks SET original_task_id = ? WHERE id = ?", originalID, newIDs[i]); err != nil {
+            return fmt.Errorf("error linking task %d to original task %d: %w", newIDs[i], originalID, err)
+        }
+    }
+
+    // Subtasks are linked to their parent the same way
+    for i, task := range tasks {
+        if !task.ParentID.Valid {
+            continue
+        }
+        parentID, ok := result.IDMap[task.ParentID.Int64]
+        if !ok {
+            result.Warnings = append(result.Warnings, fmt.Sprintf("task '%s': parent task %d is not part of the import, link dropped", task.Title, task.ParentID.Int64))
+            continue
+        }
+        if err := checkParent(tx, newIDs[i], parentID); err != nil {
+            return fmt.Errorf("error importing parent of task '%s': %w", task.Title, err)
+        }
+        if _, err := tx.Exec("UPDATE tasks SET parent_id = ? WHERE id = ?", parentID, newIDs[i]); err != nil {
+            return fmt.Errorf("error linking task %d to parent task %d: %w", newIDs[i], parentID, err)
+        }
+    }
+
+    // Dependencies refer to source IDs too; records that carry none keep the dependencies they have
+    for i, task := range tasks {
+        if task.DependsOn == nil {
+            continue
+        }
+        deps := []int64{}
+        for _, dep := range task.DependsOn {
+            newDep, ok := result.IDMap[dep]
+            if !ok {
+                result.Warnings = append(result.Warnings, fmt.Sprintf("task '%s': dependency on task %d is not part of the import, dropped", task.Title, dep))
+                continue
+            }
+            deps = append(deps, newDep)
+        }
+        if err := setDependencies(tx, newIDs[i], deps); err != nil {
+            return fmt.Errorf("error importing dependencies of task '%s': %w", task.Title, err)
+        }
+    }
+
+    if result.DryRun {
+        return nil // The caller rolls back everything
+    }
+    for _, id := range newIDs {
+        if err := tm.logTask(tx, id); err != nil {
+            return err
+        }
+        if err := tm.logTaskNotes(tx, id); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// ImportHolidays adds holidays read from an export, skipping dates that already have a holiday.
+// With dryRun nothing is written.
+func (tm *TodoManager) ImportHolidays(holidays []Holiday, dryRun bool) (*ImportResult, error) {
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    result := &ImportResult{DryRun: dryRun}
+    if err := importHolidays(tx, holidays, result); err != nil {
+        return nil, err
+    }
+    if dryRun {
+        return result, nil
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return result, nil
+}
+
+// ImportICalendar imports the tasks and holidays of a calendar read by ReadICalendar, like
+// ImportTasks and ImportHolidays, in one transaction: either everything is imported or nothing.
+// The warnings of the calendar come first in the result.
+func (tm *TodoManager) ImportICalendar(cal *Calendar, dryRun bool) (*ImportResult, error) {
+    if err := validateImport(cal.Tasks); err != nil {
+        return nil, err
+    }
+
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return nil, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    result := &ImportResult{DryRun: dryRun, IDMap: map[int64]int64{}}
+    result.Warnings = append(result.Warnings, cal.Warnings...)
+    if err := tm.importTasks(tx, cal.Tasks, ICalendarFields, result); err != nil {
+        return nil, err
+    }
+    if err := importHolidays(tx, cal.Holidays, result); err != nil {
+        return nil, err
+    }
+    if dryRun {
+        return result, nil
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return result, nil
+}
+
+// importHolidays adds holidays in tx, counting them in result.
+func importHolidays(tx *sql.Tx, holidays []Holiday, result *ImportResult) error {
+    for _, h := range holidays {
+        date := h.Date.Time.Format("2006-01-02")
+        var exists int
+        if err := tx.QueryRow("SELECT COUNT(*) FROM holidays WHERE date = ?", date).Scan(&exists); err != nil {
+            return fmt.Errorf("error checking holiday %s: %w", date, err)
+        }
+        if exists > 0 {
+            result.Skipped++
+            continue
+        }
+        uuid, err := newUUIDValue(sql.NullString{String: h.UUID, Valid: h.UUID != ""})
+        if err != nil {
+            return err
+        }
+        if _, err := tx.Exec("INSERT INTO holidays (date, name, uuid) VALUES (?, ?, ?)", date, h.Name, uuid); err != nil {
+            return fmt.Errorf("error adding holiday %s: %w", date, wrapDBError(err))
+        }
+        result.Holidays++
+    }
+    return nil
+}
+
+// validateImport checks every task before anything is written and reports all problems at once.
+func validateImport(tasks []Task) error {
+    var p problems
+    seen := map[int64]bool{}
+    seenUUIDs := map[string]bool{}
+    for i, task := range tasks {
+        record := fmt.Sprintf("record %d", i+1)
+        if strings.TrimSpace(task.Title) == "" {
+            p.add("%s: task title is required", record)
+        }
+        if task.Status != "" && !contains(validStatuses, task.Status) {
+            p.add("%s: unknown status '%s' (expected %s)", record, task.Status, strings.Join(validStatuses, ", "))
+        }
+        if task.Recurrence.Valid && task.Recurrence.String != "" {
+            if _, err := ParseRecurrence(task.Recurrence.String, 1); err != nil {
+                p.add("%s: %s", record, strings.TrimPrefix(err.Error(), ErrInvalidInput.Error()+": "))
+            }
+        }
+        if task.RecurrenceShift.Valid && task.RecurrenceShift.String != "" && !contains(validShifts, task.RecurrenceShift.String) {
+            p.add("%s: unknown recurrence shift '%s' (expected %s)", record, task.RecurrenceShift.String, strings.Join(validShifts, ", "))
+        }
+        if task.RecurrenceAnchor.Valid && task.RecurrenceAnchor.String != "" && !contains(validAnchors, task.RecurrenceAnchor.String) {
+            p.add("%s: unknown recurrence anchor '%s' (expected %s)", record, task.RecurrenceAnchor.String, strings.Join(validAnchors, ", "))
+        }
+        if task.Priority.Valid && task.Priority.String != "" && !contains(validPriorities, task.Priority.String) {
+            p.add("%s: unknown priority '%s' (expected %s)", record, task.Priority.String, strings.Join(validPriorities, ", "))
+        }
+        if task.Estimate.Valid && task.Estimate.Int64 <= 0 {
+            p.add("%s: estimate must be positive, got %d minutes", record, task.Estimate.Int64)
+        }
+        if task.ID != 0 {
+            if seen[task.ID] {
+                p.add("%s: duplicate task ID %d", record, task.ID)
+            }
+            seen[task.ID] = true
+        }
+        if task.UUID.Valid && task.UUID.String != "" {
+            uuid := strings.ToLower(task.UUID.String)
+            if !IsUUID(uuid) {
+                p.add("%s: invalid UUID '%s'", record, task.UUID.String)
+            } else if seenUUIDs[uuid] {
+                p.add("%s: duplicate UUID %s", record, uuid)
+            }
+            seenUUIDs[uuid] = true
+        }
+    }
+    return p.err()
+}
+
+// importTask inserts one imported task with its associations and notes, or updates the
+// fields of the existing task with the same UUID. It returns the task ID and whether the task
+// was created.
+func (tm *TodoManager) importTask(tx *sql.Tx, task Task, fields TaskFields, result *ImportResult) (int64, bool, error) {
+    status := task.Status
+    if status == "" {
+        status = "pending"
+    }
+    uuid := sql.NullString{}
+    if task.UUID.Valid && task.UUID.String != "" {
+        uuid = sql.NullString{String: strings.ToLower(task.UUID.String), Valid: true}
+    }
+
+    var taskID int64
+    created := true
+    if uuid.Valid {
+        err := tx.QueryRow("SELECT id FROM tasks WHERE uuid = ?", uuid).Scan(&taskID)
+        if err == nil {
+            created = false
+        } else if err != sql.ErrNoRows {
+            return 0, false, fmt.Errorf("error looking up task %s: %w", uuid.String, err)
+        }
+    }
+
+    var projectID sql.NullInt64
+    if task.ProjectName.Valid && task.ProjectName.String != "" && (created || fields&FieldProject != 0) {
+        if isNewLabel(tx, "projects", task.ProjectName.String) {
+            result.NewProjects = append(result.NewProjects, task.ProjectName.String)
+        }
+        id, err := tm.getID(tx, "projects", task.ProjectName.String)
+        if err != nil {
+            return 0, false, fmt.Errorf("error getting project ID: %w", err)
+        }
+        projectID = sql.NullInt64{Int64: id, Valid: true}
+    }
+
+    if created {
+        sqlStartDate, _ := task.StartDate.Value()
+        sqlDueDate, _ := task.DueDate.Value()
+        sqlEndDate, _ := task.EndDate.Value()
+        sqlStartWaitingDate, _ := task.StartWaitingDate.Value()
+        sqlEndWaitingDate, _ := task.EndWaitingDate.Value()
+        newUUID, err := newUUIDValue(uuid)
+        if err != nil {
+            return 0, false, err
+        }
+        res, err := tx.Exec(`
+            INSERT INTO tasks (title, description, project_id, start_date, due_date, end_date, recurrence, recurrence_interval, recurrence_shift, recurrence_anchor, status, start_waiting_date, end_waiting_date, uuid, priority, estimate_minutes)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        `,
+            task.Title, task.Description, projectID, sqlStartDate, sqlDueDate, sqlEndDate,
+            task.Recurrence, task.RecurrenceInterval, task.RecurrenceShift, task.RecurrenceAnchor, status, sqlStartWaitingDate, sqlEndWaitingDate, newUUID, task.Priority, task.Estimate,
+        )
+        if err != nil {
+            return 0, false, fmt.Errorf("error adding task: %w", wrapDBError(err))
+        }
+        if taskID, err = res.LastInsertId(); err != nil {
+            return 0, false, fmt.Errorf("error getting last insert ID: %w", err)
+        }
+    } else {
+        existing, err := getTask(tx, taskID)
+        if err != nil {
+            return 0, false, err
+        }
+        sets := []string{"title = ?"}
+        args := []interface{}{task.Title}
+        set := func(field TaskFields, column string, value interface{}) {
+            if fields&field != 0 {
+                sets = append(sets, column+" = ?")
+                args = append(args, value)
+            }
+        }
+        date := func(field TaskFields, column string, imported, current NullableTime) {
+            if fields&FieldDatesByDay != 0 && imported.Valid && current.Valid && sameDay(imported.Time, current.Time) {
+                return
+            }
+            value, _ := imported.Value()
+            set(field, column, value)
+        }
+        set(FieldDescription, "description", task.Description)
+        set(FieldProject, "project_id", projectID)
+        set(FieldStatus, "status", status)
+        set(FieldPriority, "priority", task.Priority)
+        set(FieldEstimate, "estimate_minutes", task.Estimate)
+        date(FieldStartDate, "start_date", task.StartDate, existing.StartDate)
+        date(FieldDueDate, "due_date", task.DueDate, existing.DueDate)
+        date(FieldEndDate, "end_date", task.EndDate, existing.EndDate)
+        set(FieldRecurrence, "recurrence", task.Recurrence)
+        set(FieldRecurrence, "recurrence_interval", task.RecurrenceInterval)
+        set(FieldRecurrenceShift, "recurrence_shift", task.RecurrenceShift)
+        set(FieldRecurrenceAnchor, "recurrence_anchor", task.RecurrenceAnchor)
+        date(FieldStartWaiting, "start_waiting_date", task.StartWaitingDate, existing.StartWaitingDate)
+        date(FieldEndWaiting, "end_waiting_date", task.EndWaitingDate, existing.EndWaitingDate)
+        args = append(args, taskID)
+        if _, err := tx.Exec("UPDATE tasks SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...); err != nil {
+            return 0, false, fmt.Errorf("error updating task %d: %w", taskID, wrapDBError(err))
+        }
+    }
+
+    if created || fields&FieldContexts != 0 {
+        for _, n := range task.Contexts {
+            if isNewLabel(tx, "contexts", n) {
+                result.NewContexts = append(result.NewContexts, n)
+            }
+        }
+        contextIDs, err := tm.getIDs(tx, "contexts", task.Contexts)
+        if err != nil {
+            return 0, false, fmt.Errorf("error getting context ID: %w", err)
+        }
+        if err := tm.associateTaskWithNames(tx, taskID, contextIDs, "task_contexts", "context_id"); err != nil {
+            return 0, false, fmt.Errorf("error associating contexts: %w", err)
+        }
+    }
+
+    if created || fields&FieldTags != 0 {
+        for _, n := range task.Tags {
+            if isNewLabel(tx, "tags", n) {
+                result.NewTags = append(result.NewTags, n)
+            }
+        }
+        tagIDs, err := tm.getIDs(tx, "tags", task.Tags)
+        if err != nil {
+            return 0, false, fmt.Errorf("error getting tag ID: %w", err)
+        }
+        if err := tm.associateTaskWithNames(tx, taskID, tagIDs, "task_tags", "tag_id"); err != nil {
+            return 0, false, fmt.Errorf("error associating tags: %w", err)
+        }
+    }
+
+    // Notes an updated task already has are not added again. Exports may round timestamps
+    // to whole seconds, so they are compared at that precision.
+    existingNotes := map[string]bool{}
+    if !created {
+        rows, err := tx.Query("SELECT timestamp, description FROM task_notes WHERE task_id = ?", taskID)
+        if err != nil {
+            return 0, false, fmt.Errorf("error getting notes for task %d: %w", taskID, err)
+        }
+        for rows.Next() {
+            var timestamp sql.NullTime
+            var description string
+            if err := rows.Scan(&timestamp, &description); err != nil {
+                rows.Close()
+                return 0, false, fmt.Errorf("error scanning note for task %d: %w", taskID, err)
+            }
+            existingNotes[noteKey(timestamp.Time, description)] = true
+        }
+        rows.Close()
+        if err := rows.Err(); err != nil {
+            return 0, false, fmt.Errorf("error getting notes for task %d: %w", taskID, err)
+        }
+    }
+
+    for _, note := range task.Notes {
+        timestamp := note.Timestamp
+        if !timestamp.Valid {
+            timestamp = NullableTime{Time: time.Now().UTC(), Valid: true}
+        }
+        if existingNotes[noteKey(timestamp.Time, note.Description.String)] {
+            continue
+        }
+        if note.UUID != "" {
+            var exists int
+            if err := tx.QueryRow("SELECT COUNT(*) FROM task_notes WHERE uuid = ?", strings.ToLower(note.UUID)).Scan(&exists); err != nil {
+                return 0, false, fmt.Errorf("error looking up note %s: %w", note.UUID, err)
+            }
+            if exists > 0 {
+                continue // Imported before
+            }
+        }
+        noteUUID, err := newUUIDValue(sql.NullString{String: note.UUID, Valid: note.UUID != ""})
+        if err != nil {
+            return 0, false, err
+        }
+        sqlTimestamp, _ := timestamp.Value()
+        if _, err := tx.Exec("INSERT INTO task_notes (task_id, timestamp, description, uuid) VALUES (?, ?, ?, ?)", taskID, sqlTimestamp, note.Description, noteUUID); err != nil {
+            return 0, false, fmt.Errorf("error adding note: %w", wrapDBError(err))
+        }
+        result.Notes++
+    }
+
+    return taskID, created, nil
+}
+
+// sameDay reports whether two times fall on the same local day.
+func sameDay(a, b time.Time) bool {
+    return a.Local().Format("2006-01-02") == b.Local().Format("2006-01-02")
+}
+
+// noteKey identifies a note by its timestamp, to the second, and description.
+func noteKey(timestamp time.Time, description string) string {
+    return timestamp.UTC().Truncate(time.Second).Format(time.RFC3339) + " " + description
+}
+
+// isNewLabel reports whether a name is not yet present in a lookup table (projects, contexts, tags).
+func isNewLabel(tx *sql.Tx, tableName, name string) bool {
+    var id int64
+    err := tx.QueryRow(fmt.Sprintf("SELECT id FROM %s WHERE name = ?", tableName), name).Scan(&id)
+    return err == sql.ErrNoRows
+}
@@ -0,0 +1,268 @@
+package todo
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "testing"
+)
+
+// transferFormats writes tasks in each export format and reads them back with the fields of
+// the format, as the import command does.
+var transferFormats = []struct {
+    name  string
+    write func(w io.Writer, tasks []Task) error
+    read  func(r io.Reader) ([]Task, TaskFields, error)
+}{
+    {
+        "csv",
+        func(w io.Writer, tasks []Task) error { return WriteTasksCSV(w, tasks, ',') },
+        func(r io.Reader) ([]Task, TaskFields, error) { return ReadTasksCSV(r, ',') },
+    },
+    {
+        "tsv",
+        func(w io.Writer, tasks []Task) error { return WriteTasksCSV(w, tasks, '\t') },
+        func(r io.Reader) ([]Task, TaskFields, error) { return ReadTasksCSV(r, '\t') },
+    },
+    {
+        "todotxt",
+        WriteTasksTodoTxt,
+        func(r io.Reader) ([]Task, TaskFields, error) {
+            tasks, err := ReadTasksTodoTxt(r)
+            return tasks, TodoTxtFields, err
+        },
+    },
+    {
+        "ics",
+        func(w io.Writer, tasks []Task) error { return WriteICalendar(w, tasks, nil) },
+        func(r io.Reader) ([]Task, TaskFields, error) {
+            cal, err := ReadICalendar(r)
+            if err != nil {
+                return nil, 0, err
+            }
+            return cal.Tasks, ICalendarFields, nil
+        },
+    },
+    {
+        "taskwarrior",
+        WriteTasksTaskwarrior,
+        func(r io.Reader) ([]Task, TaskFields, error) {
+            tasks, _, err := ReadTasksTaskwarrior(r)
+            return tasks, TaskwarriorFields, err
+        },
+    },
+}
+
+// addTransferTasks adds tasks using every field an export may carry, or lose.
+func addTransferTasks(t *testing.T, tm *TodoManager) {
+    t.Helper()
+    inputs := []TaskInput{
+        {Title: "call plumber", Description: "about the leak", Project: "home", Contexts: []string{"phone"}, Tags: []string{"urgent", "k:v"},
+            Priority: "H", Estimate: "1h30m", StartDate: strPtr("2026-03-01 08:30:00"), DueDate: strPtr("2026-03-04 17:00:00"),
+            Recurrence: "weekly", RecurrenceInterval: 2, RecurrenceAnchor: AnchorCompletion, RecurrenceShift: "next"},
+        {Title: "check backups", StartDate: strPtr("2026-03-01 08:30:00"), Recurrence: "workdaily", RecurrenceInterval: 3,
+            StartWaiting: strPtr("2099-03-02 09:00:00"), EndWaiting: strPtr("2099-03-09 09:00:00")},
+        {Title: "pay rent", StartDate: strPtr("2026-03-01 08:30:00"), Recurrence: "FREQ=MONTHLY;BYDAY=-1FR", ParentID: 1, DependsOn: []int64{2}},
+        {Title: "file taxes", StartDate: strPtr("2026-03-01 08:30:00"), EndDate: strPtr("2026-03-02 10:15:00"), Priority: "L"},
+        {Title: "repaint fence", StartDate: strPtr("2026-03-01 08:30:00"), EndDate: strPtr("2026-03-02 10:15:00"), Status: "cancelled"},
+    }
+    for _, in := range inputs {
+        if _, err := tm.AddTask(in); err != nil {
+            t.Fatalf("AddTask(%s): %v", in.Title, err)
+        }
+    }
+    if _, err := tm.AddNoteToTask(1, "asked for a quote", "", false); err != nil {
+        t.Fatal(err)
+    }
+}
+
+func TestImportRoundTrip(t *testing.T) {
+    for _, format := range transferFormats {
+        t.Run(format.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            addTransferTasks(t, tm)
+            before, err := tm.ExportTasks()
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            var buf bytes.Buffer
+            if err := format.write(&buf, before); err != nil {
+                t.Fatalf("write: %v", err)
+            }
+            tasks, fields, err := format.read(&buf)
+            if err != nil {
+                t.Fatalf("read: %v", err)
+            }
+            result, err := tm.ImportTasks(tasks, fields, false)
+            if err != nil {
+                t.Fatalf("ImportTasks: %v", err)
+            }
+            if result.Tasks != 0 || result.Updated != len(before) || result.Notes != 0 {
+                t.Errorf("created %d tasks and %d notes and updated %d tasks, want only %d updates", result.Tasks, result.Notes, result.Updated, len(before))
+            }
+
+            after, err := tm.ExportTasks()
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(after) != len(before) {
+                t.Fatalf("got %d tasks, want %d", len(after), len(before))
+            }
+            for i := range before {
+                if got, want := roundTripSummary(after[i]), roundTripSummary(before[i]); got != want {
+                    t.Errorf("task %d changed:\ngot  %s\nwant %s", before[i].ID, got, want)
+                }
+            }
+        })
+    }
+}
+
+func TestImportTasksFields(t *testing.T) {
+    tests := []struct {
+        name   string
+        fields TaskFields
+        check  func(t *testing.T, task *Task)
+    }{
+        {
+            name:   "all fields",
+            fields: AllTaskFields,
+            check: func(t *testing.T, task *Task) {
+                if task.Description.Valid || task.Estimate.Valid || len(task.Tags) != 1 || !task.DueDate.Time.Equal(localTime(t, "2026-03-05 00:00")) {
+                    t.Errorf("got description %v, estimate %v, tags %v and due date %v", task.Description, task.Estimate, task.Tags, task.DueDate)
+                }
+            },
+        },
+        {
+            name:   "some fields",
+            fields: FieldDueDate | FieldTags,
+            check: func(t *testing.T, task *Task) {
+                if task.Description.String != "about the leak" || task.Estimate.Int64 != 90 || task.ProjectName.String != "home" ||
+                    len(task.Tags) != 1 || !task.DueDate.Time.Equal(localTime(t, "2026-03-05 00:00")) {
+                    t.Errorf("got %+v", task)
+                }
+            },
+        },
+        {
+            name:   "dates by day",
+            fields: FieldStartDate | FieldDueDate | FieldDatesByDay,
+            check: func(t *testing.T, task *Task) {
+                // The start date is on the same day and keeps its time, the due date moved
+                if !task.StartDate.Time.Equal(localTime(t, "2026-03-01 08:30")) || !task.DueDate.Time.Equal(localTime(t, "2026-03-05 00:00")) {
+                    t.Errorf("got start %v and due %v", task.StartDate, task.DueDate)
+                }
+            },
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            addTransferTasks(t, tm)
+            existing, err := tm.GetTask(1)
+            if err != nil {
+                t.Fatal(err)
+            }
+            imported := Task{Title: "call the plumber", UUID: existing.UUID, Status: "pending", Tags: []string{"later"},
+                StartDate: NullableTime{Time: localTime(t, "2026-03-01 00:00").UTC(), Valid: true},
+                DueDate:   NullableTime{Time: localTime(t, "2026-03-05 00:00").UTC(), Valid: true}}
+            if _, err := tm.ImportTasks([]Task{imported}, tt.fields, false); err != nil {
+                t.Fatalf("ImportTasks: %v", err)
+            }
+            task, err := tm.GetTask(1)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if task.Title != "call the plumber" {
+                t.Errorf("got title %q", task.Title)
+            }
+            tt.check(t, task)
+        })
+    }
+}
+
+func TestReadTasksCSVFields(t *testing.T) {
+    tests := []struct {
+        header string
+        want   TaskFields
+    }{
+        {"title", 0},
+        {"Title,Due_Date,tags,notes", FieldDueDate | FieldTags},
+        {"uuid,title,recurrence,recurrence_interval,status", FieldRecurrence | FieldStatus},
+    }
+    for _, tt := range tests {
+        t.Run(tt.header, func(t *testing.T) {
+            _, fields, err := ReadTasksCSV(bytes.NewBufferString(tt.header+"\n"), ',')
+            if err != nil {
+                t.Fatal(err)
+            }
+            if fields != tt.want {
+                t.Errorf("got fields %b, want %b", fields, tt.want)
+            }
+        })
+    }
+}
+
+// roundTripSummary formats a task with its links and notes, to compare it before and after a round trip.
+func roundTripSummary(task Task) string {
+    return fmt.Sprintf("%s|parent %d|depends on %v|%d notes", transferSummary(task), task.ParentID.Int64, task.DependsOn, len(task.Notes))
+}
+
+func TestImportICalendar(t *testing.T) {
+    const calendar = `BEGIN:VCALENDAR
+BEGIN:VTODO
+UID:0b7c8b0e-6c35-4d51-9d38-8f0e1c7e9a10
+SUMMARY:%s
+END:VTODO
+BEGIN:VEVENT
+SUMMARY:Christmas
+DTSTART;VALUE=DATE:20261225
+DTEND;VALUE=DATE:20261227
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:meeting
+DTSTART:20261201T100000Z
+END:VEVENT
+END:VCALENDAR
+`
+    tests := []struct {
+        name     string
+        summary  string
+        dryRun   bool
+        err      error
+        tasks    int // in the database afterwards
+        holidays int
+    }{
+        {"imported", "buy presents", false, nil, 1, 2},
+        {"dry run", "buy presents", true, nil, 0, 0},
+        {"invalid task adds no holidays", "", false, ErrInvalidInput, 0, 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            cal, err := ReadICalendar(bytes.NewBufferString(fmt.Sprintf(calendar, tt.summary)))
+            if err != nil {
+                t.Fatal(err)
+            }
+            result, err := tm.ImportICalendar(cal, tt.dryRun)
+            if !errors.Is(err, tt.err) {
+                t.Fatalf("got %v, want %v", err, tt.err)
+            }
+            if err == nil && (result.Tasks != 1 || result.Holidays != 2 || len(result.Warnings) != 1) {
+                t.Errorf("got %d tasks, %d holidays and warnings %v", result.Tasks, result.Holidays, result.Warnings)
+            }
+
+            tasks, err := tm.ExportTasks()
+            if err != nil {
+                t.Fatal(err)
+            }
+            holidays, err := tm.GetHolidays()
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(tasks) != tt.tasks || len(holidays) != tt.holidays {
+                t.Errorf("got %d tasks and %d holidays, want %d and %d", len(tasks), len(holidays), tt.tasks, tt.holidays)
+            }
+        })
+    }
+}
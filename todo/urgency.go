@@ -0,0 +1,261 @@
+package todo
+
+import (
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Priorities of a task, highest first.
+const (
+    PriorityHigh   = "H"
+    PriorityMedium = "M"
+    PriorityLow    = "L"
+)
+
+// validPriorities lists the priorities accepted by TaskInput and TaskPatch; "none" clears it.
+var validPriorities = []string{PriorityHigh, PriorityMedium, PriorityLow}
+
+// normalizePriority returns the stored form of a priority: H, M or L, also given as high,
+// medium or low in any case, or "" for none.
+func normalizePriority(priority string) string {
+    switch strings.ToLower(strings.TrimSpace(priority)) {
+    case "h", "high":
+        return PriorityHigh
+    case "m", "medium":
+        return PriorityMedium
+    case "l", "low":
+        return PriorityLow
+    case "none":
+        return ""
+    }
+    return strings.TrimSpace(priority)
+}
+
+func (p *problems) checkPriority(priority string) {
+    if n := normalizePriority(priority); n != "" && !contains(validPriorities, n) {
+        p.add("unknown priority '%s' (expected H, M, L or none)", priority)
+    }
+}
+
+// The urgency of an open task is the sum of its factors, each between 0 and 1, weighed by
+// coefficients stored in the urgency_coefficients table:
+//
+//    priority.H, priority.M, priority.L  1 for a task of that priority
+//    due       0.2 for tasks due in two weeks or more, rising to 1 for tasks overdue by a week
+//    age       the days since the start date, over a year, up to 1
+//    tags      0.8 for one tag, 0.9 for two and 1 for more
+//    project   1 for a task in a project
+//    blocked   1 for a task that depends on open tasks
+//    tag.<name>, project.<name>  1 for a task with that tag, or in that project
+//
+// Completed and cancelled tasks have no urgency.
+
+// defaultUrgencyCoefficients are the coefficients stored in a new database, and those restored
+// by ResetUrgencyCoefficient. Coefficients for single tags and projects have no default.
+var defaultUrgencyCoefficients = map[string]float64{
+    "priority.H": 6.0,
+    "priority.M": 3.9,
+    "priority.L": 1.8,
+    "due":        12.0,
+    "age":        2.0,
+    "tags":       1.0,
+    "project":    1.0,
+    "blocked":    -5.0,
+}
+
+// UrgencyCoefficient is the weight of one factor of the urgency score.
+type UrgencyCoefficient struct {
+    Name    string
+    Value   float64
+    Default bool // the value is the default one
+}
+
+// checkCoefficientName reports names that are neither a known factor nor a tag or project coefficient.
+func checkCoefficientName(name string) error {
+    if _, ok := defaultUrgencyCoefficients[name]; ok {
+        return nil
+    }
+    for _, prefix := range []string{"tag.", "project."} {
+        if strings.HasPrefix(name, prefix) && strings.TrimSpace(name[len(prefix):]) != "" {
+            return nil
+        }
+    }
+    known := make([]string, 0, len(defaultUrgencyCoefficients))
+    for n := range defaultUrgencyCoefficients {
+        known = append(known, n)
+    }
+    sort.Strings(known)
+    return fmt.Errorf("%w: unknown urgency coefficient '%s' (expected %s, tag.<name> or project.<name>)", ErrInvalidInput, name, strings.Join(known, ", "))
+}
+
+// loadUrgencyCoefficients returns the stored coefficients, with the default of any that is missing.
+func loadUrgencyCoefficients(q queryer) (map[string]float64, error) {
+    coefficients := make(map[string]float64, len(defaultUrgencyCoefficients))
+    for name, value := range defaultUrgencyCoefficients {
+        coefficients[name] = value
+    }
+    rows, err := q.Query("SELECT name, value FROM urgency_coefficients")
+    if err != nil {
+        return nil, fmt.Errorf("error loading urgency coefficients: %w", err)
+    }
+    defer rows.Close()
+    for rows.Next() {
+        var name string
+        var value float64
+        if err := rows.Scan(&name, &value); err != nil {
+            return nil, fmt.Errorf("error scanning urgency coefficient: %w", err)
+        }
+        coefficients[name] = value
+    }
+    return coefficients, rows.Err()
+}
+
+// GetUrgencyCoefficients returns every urgency coefficient, ordered by name.
+func (tm *TodoManager) GetUrgencyCoefficients() ([]UrgencyCoefficient, error) {
+    coefficients, err := loadUrgencyCoefficients(tm.db)
+    if err != nil {
+        return nil, err
+    }
+    result := make([]UrgencyCoefficient, 0, len(coefficients))
+    for name, value := range coefficients {
+        def, ok := defaultUrgencyCoefficients[name]
+        result = append(result, UrgencyCoefficient{Name: name, Value: value, Default: ok && def == value})
+    }
+    sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+    return result, nil
+}
+
+// SetUrgencyCoefficient sets the weight of a factor of the urgency score; a negative
+// coefficient lowers the urgency of the tasks it applies to.
+func (tm *TodoManager) SetUrgencyCoefficient(name string, value float64) error {
+    name = strings.TrimSpace(name)
+    if err := checkCoefficientName(name); err != nil {
+        return err
+    }
+    if math.IsNaN(value) || math.IsInf(value, 0) {
+        return fmt.Errorf("%w: urgency coefficient must be a number", ErrInvalidInput)
+    }
+    _, err := tm.db.Exec("INSERT INTO urgency_coefficients (name, value) VALUES (?, ?) ON CONFLICT(name) DO UPDATE SET value = excluded.value", name, value)
+    if err != nil {
+        return fmt.Errorf("error setting urgency coefficient %s: %w", name, err)
+    }
+    return nil
+}
+
+// ResetUrgencyCoefficient restores the default of a coefficient, or removes a tag or project
+// coefficient. An empty name resets every coefficient.
+func (tm *TodoManager) ResetUrgencyCoefficient(name string) error {
+    name = strings.TrimSpace(name)
+    if name != "" {
+        if err := checkCoefficientName(name); err != nil {
+            return err
+        }
+    }
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+
+    if name == "" {
+        _, err = tx.Exec("DELETE FROM urgency_coefficients")
+    } else {
+        _, err = tx.Exec("DELETE FROM urgency_coefficients WHERE name = ?", name)
+    }
+    if err != nil {
+        return fmt.Errorf("error resetting urgency coefficients: %w", err)
+    }
+    if err := seedUrgencyCoefficients(tx); err != nil {
+        return err
+    }
+    if err := tx.Commit(); err != nil {
+        return fmt.Errorf("error committing transaction: %w", err)
+    }
+    return nil
+}
+
+// seedUrgencyCoefficients stores the default of every coefficient that is not stored.
+func seedUrgencyCoefficients(q queryer) error {
+    for name, value := range defaultUrgencyCoefficients {
+        if _, err := q.Exec("INSERT OR IGNORE INTO urgency_coefficients (name, value) VALUES (?, ?)", name, value); err != nil {
+            return fmt.Errorf("error storing urgency coefficient %s: %w", name, err)
+        }
+    }
+    return nil
+}
+
+// scoreTasks sets the urgency of tasks.
+func (tm *TodoManager) scoreTasks(tasks []Task) error {
+    if len(tasks) == 0 {
+        return nil
+    }
+    coefficients, err := loadUrgencyCoefficients(tm.db)
+    if err != nil {
+        return err
+    }
+    for i := range tasks {
+        tasks[i].Urgency = urgency(&tasks[i], coefficients)
+    }
+    return nil
+}
+
+// urgency computes the urgency score of a task.
+func urgency(task *Task, coefficients map[string]float64) float64 {
+    if !isOpen(task.Status) {
+        return 0
+    }
+    score := 0.0
+    if task.Priority.Valid && task.Priority.String != "" {
+        score += coefficients["priority."+task.Priority.String]
+    }
+    score += coefficients["due"] * dueFactor(task)
+    score += coefficients["age"] * ageFactor(task)
+    switch n := len(task.Tags); {
+    case n == 1:
+        score += coefficients["tags"] * 0.8
+    case n == 2:
+        score += coefficients["tags"] * 0.9
+    case n > 2:
+        score += coefficients["tags"]
+    }
+    for _, tag := range task.Tags {
+        score += coefficients["tag."+tag]
+    }
+    if task.ProjectName.Valid && task.ProjectName.String != "" {
+        score += coefficients["project"] + coefficients["project."+task.ProjectName.String]
+    }
+    if len(task.BlockedBy) > 0 {
+        score += coefficients["blocked"]
+    }
+    return math.Round(score*100) / 100
+}
+
+// dueFactor rises linearly from 0.2, two weeks before the due date, to 1, a week after it.
+func dueFactor(task *Task) float64 {
+    if !task.DueDate.Valid {
+        return 0
+    }
+    daysOverdue := -CalculateDurationToDueDate(*task).Hours() / 24
+    if overdue, isOverdue := CalculateTimeDifference(task.DueDate); isOverdue {
+        daysOverdue = overdue.Hours() / 24
+    }
+    switch {
+    case daysOverdue >= 7:
+        return 1
+    case daysOverdue <= -14:
+        return 0.2
+    }
+    return 0.2 + (daysOverdue+14)*0.8/21
+}
+
+// ageFactor is the age of a task in days over a year, up to 1.
+func ageFactor(task *Task) float64 {
+    if !task.StartDate.Valid {
+        return 0
+    }
+    days := time.Since(task.StartDate.Time).Hours() / 24
+    return math.Max(0, math.Min(1, days/365))
+}
@@ -0,0 +1,139 @@
+package todo
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestNormalizePriority(t *testing.T) {
+    tests := []struct {
+        priority string
+        want     string
+        ok       bool
+    }{
+        {"H", PriorityHigh, true},
+        {" medium ", PriorityMedium, true},
+        {"low", PriorityLow, true},
+        {"None", "", true},
+        {"", "", true},
+        {"urgent", "urgent", false},
+    }
+    for _, tt := range tests {
+        var p problems
+        p.checkPriority(tt.priority)
+        if got := normalizePriority(tt.priority); got != tt.want || (p.err() == nil) != tt.ok {
+            t.Errorf("priority %q: got %q and %v, want %q and ok %v", tt.priority, got, p.err(), tt.want, tt.ok)
+        }
+    }
+}
+
+func TestUrgency(t *testing.T) {
+    now := time.Now()
+    at := func(days int) NullableTime { return NullableTime{Time: now.AddDate(0, 0, days).UTC(), Valid: true} }
+    coefficients := map[string]float64{"tag.bug": 2.5, "project.work": -1}
+    for name, value := range defaultUrgencyCoefficients {
+        coefficients[name] = value
+    }
+    tests := []struct {
+        name string
+        task Task
+        want float64
+    }{
+        {"nothing", Task{Status: "pending"}, 0},
+        {"high priority", Task{Status: "pending", Priority: nullString("H")}, 6},
+        {"due in a month", Task{Status: "pending", DueDate: at(30)}, 2.4},
+        {"due in a week", Task{Status: "pending", DueDate: at(7)}, 12 * (0.2 + 7*0.8/21)},
+        {"overdue by a week", Task{Status: "waiting", DueDate: at(-10)}, 12},
+        {"started two years ago", Task{Status: "pending", StartDate: at(-730)}, 2},
+        {"one tag", Task{Status: "pending", Tags: []string{"home"}}, 0.8},
+        {"three tags", Task{Status: "pending", Tags: []string{"home", "bug", "later"}}, 3.5},
+        {"in a project", Task{Status: "pending", ProjectName: nullString("home")}, 1},
+        {"in a weighed project", Task{Status: "pending", ProjectName: nullString("work")}, 0},
+        {"blocked", Task{Status: "pending", Priority: nullString("M"), BlockedBy: []int64{2}}, -1.1},
+        {"completed", Task{Status: "completed", Priority: nullString("H"), DueDate: at(-10)}, 0},
+    }
+    for _, tt := range tests {
+        if got := urgency(&tt.task, coefficients); fmt.Sprintf("%.2f", got) != fmt.Sprintf("%.2f", tt.want) {
+            t.Errorf("%s: got %.2f, want %.2f", tt.name, got, tt.want)
+        }
+    }
+}
+
+func TestUrgencyCoefficients(t *testing.T) {
+    tests := []struct {
+        name  string
+        value float64
+        err   error
+    }{
+        {"due", 20, nil},
+        {"tag.bug", 3, nil},
+        {"project.home", -2, nil},
+        {"tag. ", 1, ErrInvalidInput},
+        {"deadline", 1, ErrInvalidInput},
+    }
+    tm := newTestManager(t)
+    for _, tt := range tests {
+        if err := tm.SetUrgencyCoefficient(tt.name, tt.value); !errors.Is(err, tt.err) {
+            t.Errorf("SetUrgencyCoefficient(%q): got %v, want %v", tt.name, err, tt.err)
+        }
+    }
+    values := func() map[string]UrgencyCoefficient {
+        coefficients, err := tm.GetUrgencyCoefficients()
+        if err != nil {
+            t.Fatal(err)
+        }
+        byName := map[string]UrgencyCoefficient{}
+        for _, c := range coefficients {
+            byName[c.Name] = c
+        }
+        return byName
+    }
+    got := values()
+    if got["due"].Value != 20 || got["due"].Default || got["tag.bug"].Value != 3 || !got["age"].Default {
+        t.Errorf("got %+v", got)
+    }
+
+    if err := tm.ResetUrgencyCoefficient("due"); err != nil {
+        t.Fatal(err)
+    }
+    if got := values(); !got["due"].Default || got["tag.bug"].Value != 3 {
+        t.Errorf("after resetting due: got %+v", got)
+    }
+    if err := tm.ResetUrgencyCoefficient(""); err != nil {
+        t.Fatal(err)
+    }
+    if got := values(); len(got) != len(defaultUrgencyCoefficients) {
+        t.Errorf("after resetting all: got %+v", got)
+    }
+}
+
+func TestSortByUrgency(t *testing.T) {
+    tm := newTestManager(t)
+    inputs := []TaskInput{
+        {Title: "tidy desk", Priority: "L"},
+        {Title: "fix outage", Priority: "H", Tags: []string{"bug"}},
+        {Title: "plan sprint", Priority: "M"},
+        {Title: "deploy fix", Priority: "H", DependsOn: []int64{2}},
+    }
+    for _, in := range inputs {
+        if _, err := tm.AddTask(in); err != nil {
+            t.Fatal(err)
+        }
+    }
+    if err := tm.SetUrgencyCoefficient("tag.bug", 10); err != nil {
+        t.Fatal(err)
+    }
+    tasks, err := tm.GetTasks(TaskFilter{SortBy: "urgency", Order: "desc"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    var got []int64
+    for _, task := range tasks {
+        got = append(got, task.ID)
+    }
+    if fmt.Sprint(got) != "[2 3 1 4]" {
+        t.Errorf("got order %v, want [2 3 1 4]", got)
+    }
+}
@@ -0,0 +1,105 @@
+package todo
+
+import (
+    "crypto/rand"
+    "database/sql"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// NewUUID returns a random (version 4) UUID in its canonical lower-case form.
+func NewUUID() (string, error) {
+    var b [16]byte
+    if _, err := rand.Read(b[:]); err != nil {
+        return "", fmt.Errorf("error generating UUID: %w", err)
+    }
+    b[6] = b[6]&0x0f | 0x40 // Version 4
+    b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+    return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// IsUUID reports whether s is a UUID in canonical form (case-insensitive).
+func IsUUID(s string) bool {
+    return uuidPattern.MatchString(strings.ToLower(s))
+}
+
+// AssignTaskUUIDs gives every task that has none a new UUID and returns how many were assigned.
+// Tasks get a UUID when they are created, so this only matters for rows written by other tools.
+func (tm *TodoManager) AssignTaskUUIDs() (int, error) {
+    var missing int
+    if err := tm.db.QueryRow("SELECT COUNT(*) FROM tasks WHERE uuid IS NULL OR uuid = ''").Scan(&missing); err != nil {
+        return 0, fmt.Errorf("error counting tasks without UUID: %w", err)
+    }
+    if missing == 0 {
+        return 0, nil
+    }
+
+    tx, err := tm.db.Begin()
+    if err != nil {
+        return 0, fmt.Errorf("error starting transaction: %w", err)
+    }
+    defer tx.Rollback()
+    if err := backfillUUIDs(tx, "tasks"); err != nil {
+        return 0, err
+    }
+    if err := tx.Commit(); err != nil {
+        return 0, fmt.Errorf("error committing transaction: %w", err)
+    }
+    return missing, nil
+}
+
+// uuidPrefixPattern matches a (possibly partial) UUID: hexadecimal digits and dashes.
+var uuidPrefixPattern = regexp.MustCompile(`^[0-9a-fA-F][0-9a-fA-F-]*$`)
+
+// MinUUIDPrefix is the shortest UUID prefix accepted by IDByUUIDPrefix.
+const MinUUIDPrefix = 4
+
+// IsUUIDPrefix reports whether s can be used as a UUID prefix.
+func IsUUIDPrefix(s string) bool {
+    return len(s) >= MinUUIDPrefix && uuidPrefixPattern.MatchString(s)
+}
+
+// IDByUUIDPrefix returns the ID of the row in tableName (tasks, task_notes, projects, contexts,
+// tags or holidays) whose UUID starts with prefix. It returns ErrNotFound if no row matches
+// and ErrAmbiguousID if more than one does.
+func (tm *TodoManager) IDByUUIDPrefix(tableName, prefix string) (int64, error) {
+    if !IsUUIDPrefix(prefix) {
+        return 0, fmt.Errorf("%w: '%s' is not a UUID prefix of at least %d hexadecimal digits", ErrInvalidInput, prefix, MinUUIDPrefix)
+    }
+    rows, err := tm.db.Query(fmt.Sprintf("SELECT id FROM %s WHERE uuid LIKE ? LIMIT 2", tableName), strings.ToLower(prefix)+"%")
+    if err != nil {
+        return 0, fmt.Errorf("error looking up UUID %s in %s: %w", prefix, tableName, err)
+    }
+    defer rows.Close()
+
+    var ids []int64
+    for rows.Next() {
+        var id int64
+        if err := rows.Scan(&id); err != nil {
+            return 0, fmt.Errorf("error looking up UUID %s in %s: %w", prefix, tableName, err)
+        }
+        ids = append(ids, id)
+    }
+    if err := rows.Err(); err != nil {
+        return 0, fmt.Errorf("error looking up UUID %s in %s: %w", prefix, tableName, err)
+    }
+    switch len(ids) {
+    case 0:
+        return 0, fmt.Errorf("UUID %s in %s: %w", prefix, tableName, ErrNotFound)
+    case 1:
+        return ids[0], nil
+    default:
+        return 0, fmt.Errorf("UUID prefix %s in %s: %w", prefix, tableName, ErrAmbiguousID)
+    }
+}
+
+// newUUIDValue returns a new UUID for an INSERT, or the given one (lower-cased) when it is set.
+func newUUIDValue(uuid sql.NullString) (string, error) {
+    if uuid.Valid && uuid.String != "" {
+        return strings.ToLower(uuid.String), nil
+    }
+    return NewUUID()
+}
@@ -0,0 +1,133 @@
+package todo
+
+import (
+    "fmt"
+    "strings"
+    "time"
+)
+
+// Shift policies move recurring instances that fall on a non-working day.
+const (
+    ShiftNone     = ""         // keep the date
+    ShiftNext     = "next"     // move to the next working day
+    ShiftPrevious = "previous" // move to the previous working day
+)
+
+// validShifts lists the shift policies accepted by TaskInput and TaskPatch; "none" clears it.
+var validShifts = []string{"none", ShiftNext, ShiftPrevious}
+
+// maxWorkdaySearch bounds the search for a working day, so a calendar without any
+// working day fails instead of looping forever.
+const maxWorkdaySearch = 3660
+
+// workCalendar tells working days from weekends and holidays, based on the working_hours
+// and holidays tables. Without any working hours, Monday to Friday are working days.
+type workCalendar struct {
+    hours    map[time.Weekday]WorkingHours
+    holidays map[string]Holiday
+}
+
+// loadWorkCalendar reads the working hours and holidays.
+func loadWorkCalendar(q queryer) (*workCalendar, error) {
+    hours, err := getWorkingHours(q)
+    if err != nil {
+        return nil, err
+    }
+    holidays, err := getHolidays(q)
+    if err != nil {
+        return nil, err
+    }
+    cal := &workCalendar{hours: hours, holidays: make(map[string]Holiday)}
+    for _, h := range holidays {
+        cal.holidays[h.Date.Time.Format("2006-01-02")] = h
+    }
+    return cal, nil
+}
+
+// isWorkingDay reports whether the local date of t is a working day.
+func (c *workCalendar) isWorkingDay(t time.Time) bool {
+    t = t.Local()
+    if _, ok := c.holidays[t.Format("2006-01-02")]; ok {
+        return false
+    }
+    if len(c.hours) == 0 {
+        return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+    }
+    wh, ok := c.hours[t.Weekday()]
+    return ok && wh.StartHour*60+wh.StartMinute < wh.EndHour*60+wh.EndMinute
+}
+
+// checkWorkingTime reports a time on a holiday or a non-working day, or outside the working
+// hours of its day when working hours are defined.
+func (c *workCalendar) checkWorkingTime(t time.Time) error {
+    t = t.Local()
+    if h, ok := c.holidays[t.Format("2006-01-02")]; ok {
+        return fmt.Errorf("%w: %s is a holiday (%s)", ErrOutsideWorkingHours, t.Format("2006-01-02"), h.Name)
+    }
+    if !c.isWorkingDay(t) {
+        return fmt.Errorf("%w: %s is not a working day", ErrOutsideWorkingHours, t.Weekday())
+    }
+    if len(c.hours) > 0 && !withinHours(c.hours, t) {
+        wh := c.hours[t.Weekday()]
+        return fmt.Errorf("%w: working hours on %s are %02d:%02d-%02d:%02d", ErrOutsideWorkingHours, t.Weekday(), wh.StartHour, wh.StartMinute, wh.EndHour, wh.EndMinute)
+    }
+    return nil
+}
+
+// CheckWorkingTime reports, as ErrOutsideWorkingHours, a time on a holiday or outside the
+// working hours of its day. Without any working hours, Monday to Friday are working days at any time.
+func (tm *TodoManager) CheckWorkingTime(t time.Time) error {
+    cal, err := loadWorkCalendar(tm.db)
+    if err != nil {
+        return err
+    }
+    return cal.checkWorkingTime(t)
+}
+
+// addWorkingDays returns the n-th working day after t, at the same time of day.
+func (c *workCalendar) addWorkingDays(t time.Time, n int) (time.Time, error) {
+    day := t.Local()
+    for i := 0; i < maxWorkdaySearch; i++ {
+        day = day.AddDate(0, 0, 1)
+        if c.isWorkingDay(day) {
+            if n--; n <= 0 {
+                return day, nil
+            }
+        }
+    }
+    return time.Time{}, fmt.Errorf("%w: no working day found after %s; check the working hours", ErrInvalidInput, t.Local().Format("2006-01-02"))
+}
+
+// shift moves t to the next or previous working day, as the policy says, when it is not one.
+func (c *workCalendar) shift(t time.Time, policy string) (time.Time, error) {
+    step := 1
+    switch policy {
+    case ShiftNone:
+        return t, nil
+    case ShiftPrevious:
+        step = -1
+    }
+    day := t.Local()
+    for i := 0; i < maxWorkdaySearch; i++ {
+        if c.isWorkingDay(day) {
+            return day, nil
+        }
+        day = day.AddDate(0, 0, step)
+    }
+    return time.Time{}, fmt.Errorf("%w: no working day found near %s; check the working hours", ErrInvalidInput, t.Local().Format("2006-01-02"))
+}
+
+// normalizeShift returns the stored form of a shift policy: "none" is stored as no policy.
+func normalizeShift(policy string) string {
+    policy = strings.ToLower(strings.TrimSpace(policy))
+    if policy == "none" {
+        return ShiftNone
+    }
+    return policy
+}
+
+func (p *problems) checkShift(policy string) {
+    if policy != "" && !contains(validShifts, strings.ToLower(strings.TrimSpace(policy))) {
+        p.add("unknown recurrence shift '%s' (expected %s)", policy, strings.Join(validShifts, ", "))
+    }
+}
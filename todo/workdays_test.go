@@ -0,0 +1,153 @@
+package todo
+
+import (
+    "errors"
+    "testing"
+    "time"
+)
+
+// testCalendar has Monday 9 March 2026 as a holiday, and with hours, works Monday to Thursday.
+func testCalendar(t *testing.T, withHours bool) *workCalendar {
+    t.Helper()
+    cal := &workCalendar{hours: map[time.Weekday]WorkingHours{}, holidays: map[string]Holiday{"2026-03-09": {Name: "spring"}}}
+    if withHours {
+        for day := time.Monday; day <= time.Thursday; day++ {
+            cal.hours[day] = WorkingHours{DayOfWeek: int(day), StartHour: 9, EndHour: 17}
+        }
+        cal.hours[time.Friday] = WorkingHours{DayOfWeek: int(time.Friday), StartHour: 9, EndHour: 9} // no time to work
+    }
+    return cal
+}
+
+func TestIsWorkingDay(t *testing.T) {
+    tests := []struct {
+        day       string
+        withHours bool
+        want      bool
+    }{
+        {"2026-03-06 10:00", false, true},  // Friday
+        {"2026-03-07 10:00", false, false}, // Saturday
+        {"2026-03-09 10:00", false, false}, // holiday
+        {"2026-03-10 10:00", false, true},
+        {"2026-03-05 10:00", true, true}, // Thursday
+        {"2026-03-06 10:00", true, false},
+        {"2026-03-07 10:00", true, false},
+        {"2026-03-09 10:00", true, false},
+    }
+    for _, tt := range tests {
+        if got := testCalendar(t, tt.withHours).isWorkingDay(localTime(t, tt.day)); got != tt.want {
+            t.Errorf("isWorkingDay(%s) with hours %v = %v, want %v", tt.day, tt.withHours, got, tt.want)
+        }
+    }
+}
+
+func TestAddWorkingDays(t *testing.T) {
+    tests := []struct {
+        from      string
+        n         int
+        withHours bool
+        want      string
+    }{
+        {"2026-03-05 17:00", 1, false, "2026-03-06 17:00"},
+        {"2026-03-05 17:00", 2, false, "2026-03-10 17:00"}, // over the weekend and the holiday
+        {"2026-03-05 17:00", 3, false, "2026-03-11 17:00"},
+        {"2026-03-05 17:00", 1, true, "2026-03-10 17:00"}, // Friday has no working time
+        {"2026-03-07 17:00", 1, true, "2026-03-10 17:00"},
+    }
+    for _, tt := range tests {
+        got, err := testCalendar(t, tt.withHours).addWorkingDays(localTime(t, tt.from), tt.n)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !got.Equal(localTime(t, tt.want)) {
+            t.Errorf("addWorkingDays(%s, %d) with hours %v = %s, want %s", tt.from, tt.n, tt.withHours, got.Format("2006-01-02 15:04"), tt.want)
+        }
+    }
+}
+
+func TestShift(t *testing.T) {
+    tests := []struct {
+        day    string
+        policy string
+        want   string
+    }{
+        {"2026-03-07 17:00", ShiftNone, "2026-03-07 17:00"},
+        {"2026-03-07 17:00", ShiftNext, "2026-03-10 17:00"}, // past Sunday and the holiday
+        {"2026-03-07 17:00", ShiftPrevious, "2026-03-06 17:00"},
+        {"2026-03-09 17:00", ShiftPrevious, "2026-03-06 17:00"},
+        {"2026-03-10 17:00", ShiftNext, "2026-03-10 17:00"},
+    }
+    for _, tt := range tests {
+        got, err := testCalendar(t, false).shift(localTime(t, tt.day), tt.policy)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if !got.Equal(localTime(t, tt.want)) {
+            t.Errorf("shift(%s, %q) = %s, want %s", tt.day, tt.policy, got.Format("2006-01-02 15:04"), tt.want)
+        }
+    }
+}
+
+func TestNoWorkingDay(t *testing.T) {
+    cal := &workCalendar{hours: map[time.Weekday]WorkingHours{time.Monday: {StartHour: 9, EndHour: 9}}}
+    if _, err := cal.addWorkingDays(localTime(t, "2026-03-02 09:00"), 1); !errors.Is(err, ErrInvalidInput) {
+        t.Errorf("addWorkingDays: got %v, want ErrInvalidInput", err)
+    }
+    if _, err := cal.shift(localTime(t, "2026-03-02 09:00"), ShiftNext); !errors.Is(err, ErrInvalidInput) {
+        t.Errorf("shift: got %v, want ErrInvalidInput", err)
+    }
+}
+
+func TestCompleteOnWorkingDays(t *testing.T) {
+    tests := []struct {
+        name  string
+        input TaskInput
+        due   string // of the next instance
+    }{
+        {
+            name:  "every working day",
+            input: TaskInput{Title: "check backups", Recurrence: "workdaily", DueDate: strPtr("2026-03-06 17:00:00")},
+            due:   "2026-03-10 17:00",
+        },
+        {
+            name:  "every 2 working days",
+            input: TaskInput{Title: "check backups", Recurrence: "workdaily", RecurrenceInterval: 2, DueDate: strPtr("2026-03-05 17:00:00")},
+            due:   "2026-03-10 17:00",
+        },
+        {
+            name:  "weekly, shifted off the holiday",
+            input: TaskInput{Title: "team report", Recurrence: "weekly", RecurrenceShift: ShiftNext, DueDate: strPtr("2026-03-02 17:00:00")},
+            due:   "2026-03-10 17:00",
+        },
+        {
+            name:  "weekly, shifted back",
+            input: TaskInput{Title: "team report", Recurrence: "weekly", RecurrenceShift: ShiftPrevious, DueDate: strPtr("2026-03-02 17:00:00")},
+            due:   "2026-03-06 17:00",
+        },
+        {
+            name:  "weekly, not shifted",
+            input: TaskInput{Title: "team report", Recurrence: "weekly", DueDate: strPtr("2026-03-02 17:00:00")},
+            due:   "2026-03-09 17:00",
+        },
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tm := newTestManager(t)
+            if _, err := tm.AddHoliday("2026-03-09", "spring"); err != nil {
+                t.Fatal(err)
+            }
+            task, err := tm.AddTask(tt.input)
+            if err != nil {
+                t.Fatal(err)
+            }
+            update(t, tm, task.ID, TaskPatch{Status: strPtr("completed")})
+            next, err := tm.GetTask(task.ID + 1)
+            if err != nil {
+                t.Fatalf("no next instance: %v", err)
+            }
+            if !next.DueDate.Time.Equal(localTime(t, tt.due)) {
+                t.Errorf("next instance due %s, want %s", next.DueDate.Time.Local().Format("2006-01-02 15:04"), tt.due)
+            }
+        })
+    }
+}
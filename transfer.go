@@ -0,0 +1,138 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+
+    "github.com/igorp74/ToDo/todo"
+)
+
+// transferFormat returns the export/import format, defaulting to the file extension and then to csv.
+// A file named todo.txt or done.txt is read as todotxt.
+func transferFormat(format, file string) string {
+    if format == "tw" {
+        return "taskwarrior"
+    } else if format != "" {
+        return strings.ToLower(format)
+    }
+    if base := strings.ToLower(filepath.Base(file)); base == "todo.txt" || base == "done.txt" {
+        return "todotxt"
+    }
+    switch ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(file)), "."); ext {
+    case "":
+    case "ical", "ifb", "icalendar":
+        return "ics"
+    case "json":
+        return "taskwarrior"
+    default:
+        return ext
+    }
+    return "csv"
+}
+
+// exportTasks writes every task to file (standard output when empty) in the given format.
+func exportTasks(tm *todo.TodoManager, format, file string) error {
+    if format == "taskwarrior" {
+        // Taskwarrior identifies tasks by UUID, so repeated exports must carry the same ones
+        if _, err := tm.AssignTaskUUIDs(); err != nil {
+            return err
+        }
+    }
+    tasks, err := tm.ExportTasks()
+    if err != nil {
+        return err
+    }
+
+    if file == "" {
+        return writeTasks(tm, os.Stdout, format, tasks)
+    }
+    f, err := os.Create(file)
+    if err != nil {
+        return err
+    }
+    if err := writeTasks(tm, f, format, tasks); err != nil {
+        f.Close()
+        return err
+    }
+    // A write the file system deferred can still fail on close
+    return f.Close()
+}
+
+// writeTasks writes tasks to w in the given format.
+func writeTasks(tm *todo.TodoManager, w io.Writer, format string, tasks []todo.Task) error {
+    switch format {
+    case "csv":
+        return todo.WriteTasksCSV(w, tasks, ',')
+    case "tsv":
+        return todo.WriteTasksCSV(w, tasks, '\t')
+    case "todotxt":
+        return todo.WriteTasksTodoTxt(w, tasks)
+    case "ics":
+        holidays, err := tm.GetHolidays()
+        if err != nil {
+            return err
+        }
+        return todo.WriteICalendar(w, tasks, holidays)
+    case "taskwarrior":
+        return todo.WriteTasksTaskwarrior(w, tasks)
+    default:
+        return fmt.Errorf("unknown export format '%s' (expected csv, tsv, todotxt, ics or taskwarrior)", format)
+    }
+}
+
+// importTasks reads tasks from file in the given format and imports them as new tasks.
+func importTasks(tm *todo.TodoManager, format, file string, dryRun bool) (*todo.ImportResult, error) {
+    f, err := os.Open(file)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    var tasks []todo.Task
+    var fields todo.TaskFields
+    switch format {
+    case "csv":
+        tasks, fields, err = todo.ReadTasksCSV(f, ',')
+    case "tsv":
+        tasks, fields, err = todo.ReadTasksCSV(f, '\t')
+    case "todotxt":
+        fields = todo.TodoTxtFields
+        tasks, err = todo.ReadTasksTodoTxt(f)
+    case "ics":
+        return importICalendar(tm, f, dryRun)
+    case "taskwarrior":
+        return importTaskwarrior(tm, f, dryRun)
+    default:
+        return nil, fmt.Errorf("unknown import format '%s' (expected csv, tsv, todotxt, ics or taskwarrior)", format)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return tm.ImportTasks(tasks, fields, dryRun)
+}
+
+// importICalendar imports the tasks and holidays of an iCalendar file.
+func importICalendar(tm *todo.TodoManager, r io.Reader, dryRun bool) (*todo.ImportResult, error) {
+    cal, err := todo.ReadICalendar(r)
+    if err != nil {
+        return nil, err
+    }
+    return tm.ImportICalendar(cal, dryRun)
+}
+
+// importTaskwarrior imports the output of 'task export', updating tasks imported before.
+func importTaskwarrior(tm *todo.TodoManager, r io.Reader, dryRun bool) (*todo.ImportResult, error) {
+    tasks, warnings, err := todo.ReadTasksTaskwarrior(r)
+    if err != nil {
+        return nil, err
+    }
+    result, err := tm.ImportTasks(tasks, todo.TaskwarriorFields, dryRun)
+    if err != nil {
+        return nil, err
+    }
+    result.Warnings = append(warnings, result.Warnings...)
+    return result, nil
+}